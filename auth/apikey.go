@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	MethodAPIKey = "api-key"
+	MethodHMAC   = "hmac"
+
+	HeaderAPIKey    = "X-API-Key"
+	HeaderClientID  = "X-Client-ID"
+	HeaderTimestamp = "X-Timestamp"
+	HeaderSignature = "X-Signature"
+
+	hashPrefix = "sha256:"
+)
+
+var (
+	ErrNoCredentials      = errors.New("no credentials supplied")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrClientDisabled     = errors.New("client is disabled")
+)
+
+// Client is an API caller. API keys are stored only as hashes (see HashKey);
+// HMACSecret is the shared secret for signed requests.
+type Client struct {
+	ID         string   `yaml:"id"`
+	KeyHashes  []string `yaml:"keyHashes"`
+	HMACSecret string   `yaml:"hmacSecret"`
+	Roles      []string `yaml:"roles"`
+	Disabled   bool     `yaml:"disabled"`
+}
+
+// Store looks up clients by API key hash or by ID.
+type Store interface {
+	ClientByKeyHash(hash string) (Client, bool)
+	ClientByID(id string) (Client, bool)
+}
+
+// MemoryStore is a Store held in memory, usually loaded from a key file.
+type MemoryStore struct {
+	byHash map[string]Client
+	byID   map[string]Client
+}
+
+func NewMemoryStore(clients []Client) (*MemoryStore, error) {
+	s := &MemoryStore{byHash: map[string]Client{}, byID: map[string]Client{}}
+	for _, c := range clients {
+		if c.ID == "" {
+			return nil, fmt.Errorf("client without id")
+		}
+		if _, dup := s.byID[c.ID]; dup {
+			return nil, fmt.Errorf("duplicate client %q", c.ID)
+		}
+		s.byID[c.ID] = c
+		for _, h := range c.KeyHashes {
+			if !strings.HasPrefix(h, hashPrefix) {
+				return nil, fmt.Errorf("client %q: key hash must start with %q", c.ID, hashPrefix)
+			}
+			s.byHash[strings.ToLower(h)] = c
+		}
+	}
+	return s, nil
+}
+
+// LoadKeyFile reads a YAML file with a top-level "clients" list.
+func LoadKeyFile(path string) (*MemoryStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading key file: %w", err)
+	}
+	var file struct {
+		Clients []Client `yaml:"clients"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing key file %s: %w", path, err)
+	}
+	return NewMemoryStore(file.Clients)
+}
+
+func (s *MemoryStore) ClientByKeyHash(hash string) (Client, bool) {
+	c, ok := s.byHash[hash]
+	return c, ok
+}
+
+func (s *MemoryStore) ClientByID(id string) (Client, bool) {
+	c, ok := s.byID[id]
+	return c, ok
+}
+
+// HashKey returns the at-rest form of an API key, "sha256:<hex>".
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hashPrefix + hex.EncodeToString(sum[:])
+}
+
+// Sign computes the X-Signature value for a request: a hex HMAC-SHA256 over
+// the method, request URI, X-Timestamp value and SHA-256 of the body,
+// separated by newlines.
+func Sign(secret, method, requestURI, timestamp string, body []byte) string {
+	bodySum := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s", method, requestURI, timestamp, hex.EncodeToString(bodySum[:]))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Authenticator checks API keys (X-API-Key or "Authorization: Bearer") and
+// HMAC-signed requests against a Store.
+type Authenticator struct {
+	store        Store
+	maxClockSkew time.Duration
+	now          func() time.Time
+}
+
+func NewAuthenticator(store Store, maxClockSkew time.Duration) *Authenticator {
+	return &Authenticator{store: store, maxClockSkew: maxClockSkew, now: time.Now}
+}
+
+// Authenticate returns the identity of the caller of r. Signed requests have
+// their body read and replaced so handlers can still consume it.
+func (a *Authenticator) Authenticate(r *http.Request) (Identity, error) {
+	if r.Header.Get(HeaderSignature) != "" {
+		return a.authenticateHMAC(r)
+	}
+
+	key := r.Header.Get(HeaderAPIKey)
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		key = bearer
+	}
+	if key == "" {
+		return Identity{}, ErrNoCredentials
+	}
+	client, ok := a.store.ClientByKeyHash(HashKey(key))
+	if !ok {
+		return Identity{}, ErrInvalidCredentials
+	}
+	if client.Disabled {
+		return Identity{}, ErrClientDisabled
+	}
+	return Identity{ID: client.ID, Method: MethodAPIKey, Roles: client.Roles}, nil
+}
+
+func (a *Authenticator) authenticateHMAC(r *http.Request) (Identity, error) {
+	client, ok := a.store.ClientByID(r.Header.Get(HeaderClientID))
+	if !ok || client.HMACSecret == "" {
+		return Identity{}, ErrInvalidCredentials
+	}
+
+	timestamp := r.Header.Get(HeaderTimestamp)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return Identity{}, ErrInvalidCredentials
+	}
+	if skew := a.now().Sub(time.Unix(seconds, 0)); skew > a.maxClockSkew || skew < -a.maxClockSkew {
+		return Identity{}, ErrInvalidCredentials
+	}
+
+	var body []byte
+	if r.Body != nil {
+		if body, err = io.ReadAll(r.Body); err != nil {
+			return Identity{}, fmt.Errorf("%w: reading body: %w", ErrInvalidCredentials, err)
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	want := Sign(client.HMACSecret, r.Method, r.URL.RequestURI(), timestamp, body)
+	if !hmac.Equal([]byte(want), []byte(strings.ToLower(r.Header.Get(HeaderSignature)))) {
+		return Identity{}, ErrInvalidCredentials
+	}
+	if client.Disabled {
+		return Identity{}, ErrClientDisabled
+	}
+	return Identity{ID: client.ID, Method: MethodHMAC, Roles: client.Roles}, nil
+}
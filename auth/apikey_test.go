@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testAuthenticator(t *testing.T) *Authenticator {
+	t.Helper()
+	store, err := NewMemoryStore([]Client{
+		{ID: "partner-a", KeyHashes: []string{HashKey("key-a")}},
+		{ID: "partner-b", HMACSecret: "secret-b"},
+		{ID: "partner-c", KeyHashes: []string{HashKey("key-c")}, Disabled: true},
+	})
+	if err != nil {
+		t.Fatalf("NewMemoryStore() error = %v", err)
+	}
+	a := NewAuthenticator(store, 5*time.Minute)
+	a.now = func() time.Time { return time.Unix(1700000000, 0) }
+	return a
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	a := testAuthenticator(t)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		wantID  string
+		wantErr error
+	}{
+		{name: "X-API-Key header", headers: map[string]string{HeaderAPIKey: "key-a"}, wantID: "partner-a"},
+		{name: "bearer token", headers: map[string]string{"Authorization": "Bearer key-a"}, wantID: "partner-a"},
+		{name: "no credentials", wantErr: ErrNoCredentials},
+		{name: "unknown key", headers: map[string]string{HeaderAPIKey: "nope"}, wantErr: ErrInvalidCredentials},
+		{name: "disabled client", headers: map[string]string{HeaderAPIKey: "key-c"}, wantErr: ErrClientDisabled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/receipts/abc/points", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			id, err := a.Authenticate(req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Authenticate() error = %v, want %v", err, tt.wantErr)
+			}
+			if id.ID != tt.wantID {
+				t.Errorf("Authenticate() id = %v, want %v", id.ID, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestAuthenticateHMAC(t *testing.T) {
+	a := testAuthenticator(t)
+	body := `{"retailer":"Target"}`
+	now := strconv.FormatInt(a.now().Unix(), 10)
+	stale := strconv.FormatInt(a.now().Add(-time.Hour).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		clientID  string
+		timestamp string
+		signature string
+		wantErr   error
+	}{
+		{
+			name:      "valid signature",
+			clientID:  "partner-b",
+			timestamp: now,
+			signature: Sign("secret-b", "POST", "/receipts/process", now, []byte(body)),
+		},
+		{
+			name:      "tampered body",
+			clientID:  "partner-b",
+			timestamp: now,
+			signature: Sign("secret-b", "POST", "/receipts/process", now, []byte(`{}`)),
+			wantErr:   ErrInvalidCredentials,
+		},
+		{
+			name:      "stale timestamp",
+			clientID:  "partner-b",
+			timestamp: stale,
+			signature: Sign("secret-b", "POST", "/receipts/process", stale, []byte(body)),
+			wantErr:   ErrInvalidCredentials,
+		},
+		{
+			name:      "client without secret",
+			clientID:  "partner-a",
+			timestamp: now,
+			signature: Sign("", "POST", "/receipts/process", now, []byte(body)),
+			wantErr:   ErrInvalidCredentials,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/receipts/process", strings.NewReader(body))
+			req.Header.Set(HeaderClientID, tt.clientID)
+			req.Header.Set(HeaderTimestamp, tt.timestamp)
+			req.Header.Set(HeaderSignature, tt.signature)
+
+			id, err := a.Authenticate(req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Authenticate() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if id.ID != tt.clientID || id.Method != MethodHMAC {
+				t.Errorf("Authenticate() = %+v, want %s via hmac", id, tt.clientID)
+			}
+			if got, _ := io.ReadAll(req.Body); string(got) != body {
+				t.Errorf("request body after Authenticate() = %q, want %q", got, body)
+			}
+		})
+	}
+}
+
+func TestLoadKeyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	os.WriteFile(path, []byte("clients:\n  - id: partner-a\n    keyHashes: [\""+HashKey("key-a")+"\"]\n"), 0600)
+
+	store, err := LoadKeyFile(path)
+	if err != nil {
+		t.Fatalf("LoadKeyFile() error = %v", err)
+	}
+	if c, ok := store.ClientByKeyHash(HashKey("key-a")); !ok || c.ID != "partner-a" {
+		t.Errorf("ClientByKeyHash() = %v, %v, want partner-a", c, ok)
+	}
+
+	os.WriteFile(path, []byte("clients:\n  - id: partner-a\n    keyHashes: [\"plaintext\"]\n"), 0600)
+	if _, err := LoadKeyFile(path); err == nil {
+		t.Errorf("LoadKeyFile() with unhashed key error = nil, want error")
+	}
+}
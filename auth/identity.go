@@ -0,0 +1,29 @@
+// Package auth identifies the caller of a request.
+package auth
+
+import "context"
+
+// Authentication methods recorded on an Identity.
+const (
+	MethodClientCert = "client-cert"
+)
+
+// Identity is the authenticated caller of a request.
+type Identity struct {
+	ID     string   // stable caller name used for auditing and limits
+	Method string   // how the caller was authenticated
+	Roles  []string // roles granted by the credential, if it carries any
+}
+
+type contextKey struct{}
+
+// WithIdentity returns a copy of ctx carrying id.
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the identity attached to ctx, if any.
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(contextKey{}).(Identity)
+	return id, ok
+}
@@ -0,0 +1,185 @@
+// Package authz decides which callers may use which routes. Callers hold
+// roles, roles grant permissions, and a policy table maps each route and
+// method to the permission it requires.
+package authz
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/suryamp/receipt-processor/auth"
+)
+
+type Permission string
+
+const (
+	ReceiptsWrite Permission = "receipts:write"
+	ReceiptsRead  Permission = "receipts:read"
+	AdminRead     Permission = "admin:read"
+	AdminWrite    Permission = "admin:write"
+	RewardsRedeem Permission = "rewards:redeem"
+
+	// Public marks routes that need no identity at all.
+	Public Permission = "public"
+)
+
+type Role string
+
+const (
+	RoleSubmitter Role = "submitter"
+	RoleReader    Role = "reader"
+	RoleSupport   Role = "support"
+	RoleRedeemer  Role = "redeemer"
+	RoleAdmin     Role = "admin"
+)
+
+// RolePermissions lists what each role may do.
+var RolePermissions = map[Role][]Permission{
+	RoleSubmitter: {ReceiptsWrite},
+	RoleReader:    {ReceiptsRead},
+	RoleSupport:   {ReceiptsRead, AdminRead},
+	RoleRedeemer:  {ReceiptsRead, RewardsRedeem},
+	RoleAdmin:     {ReceiptsWrite, ReceiptsRead, AdminRead, AdminWrite, RewardsRedeem},
+}
+
+// Rule requires Permission for Method requests to the mux path template Path.
+type Rule struct {
+	Method     string
+	Path       string
+	Permission Permission
+}
+
+// Routes is the policy table. Routes missing from it are denied.
+var Routes = []Rule{
+	{http.MethodGet, "/health", Public},
+	{http.MethodGet, "/metrics", Public},
+	{http.MethodPost, "/receipts/process", ReceiptsWrite},
+	{http.MethodGet, "/receipts/{id}/points", ReceiptsRead},
+	{http.MethodPost, "/points/simulate", ReceiptsWrite},
+	{http.MethodGet, "/users/{id}/balance", ReceiptsRead},
+	{http.MethodGet, "/users/{id}/receipts", ReceiptsRead},
+	{http.MethodGet, "/users/{id}/ledger", ReceiptsRead},
+	{http.MethodGet, "/users/{id}/expiring", ReceiptsRead},
+	{http.MethodGet, "/users/{id}/tier", ReceiptsRead},
+	{http.MethodGet, "/tiers", ReceiptsRead},
+	{http.MethodGet, "/leaderboards", ReceiptsRead},
+	{http.MethodGet, "/leaderboards/{name}", ReceiptsRead},
+	{http.MethodGet, "/stats", ReceiptsRead},
+	{http.MethodGet, "/stats/{groupBy}", ReceiptsRead},
+	{http.MethodGet, "/items", ReceiptsRead},
+	{http.MethodGet, "/items/{name:.+}/prices", ReceiptsRead},
+	{http.MethodGet, "/items/{name:.+}", ReceiptsRead},
+	{http.MethodGet, "/rewards", ReceiptsRead},
+	{http.MethodPost, "/users/{id}/redemptions", RewardsRedeem},
+	{http.MethodGet, "/users/{id}/redemptions", ReceiptsRead},
+	{http.MethodPost, "/users/{id}/redemptions/{redemptionId}/cancellation", RewardsRedeem},
+	{http.MethodGet, "/admin/config", AdminRead},
+	{http.MethodGet, "/admin/promotions", AdminRead},
+	{http.MethodPost, "/admin/promotions", AdminWrite},
+	{http.MethodGet, "/admin/promotions/{id}", AdminRead},
+	{http.MethodPut, "/admin/promotions/{id}", AdminWrite},
+	{http.MethodDelete, "/admin/promotions/{id}", AdminWrite},
+	{http.MethodGet, "/admin/rules/versions", AdminRead},
+	{http.MethodGet, "/admin/rules/versions/{version}", AdminRead},
+	{http.MethodPost, "/admin/rescore", AdminWrite},
+	{http.MethodGet, "/admin/rescore/{id}", AdminRead},
+	{http.MethodGet, "/admin/shadow", AdminRead},
+	{http.MethodGet, "/admin/shadow/receipts/{id}", AdminRead},
+	{http.MethodPost, "/admin/users/{id}/adjustments", AdminWrite},
+	{http.MethodGet, "/admin/ledger/entries", AdminRead},
+	{http.MethodGet, "/admin/ledger/entries/{id}", AdminRead},
+	{http.MethodPost, "/admin/ledger/entries/{id}/reversal", AdminWrite},
+	{http.MethodGet, "/admin/ledger/reconcile", AdminRead},
+	{http.MethodGet, "/admin/rewards", AdminRead},
+	{http.MethodPost, "/admin/rewards", AdminWrite},
+	{http.MethodGet, "/admin/rewards/{id}", AdminRead},
+	{http.MethodPut, "/admin/rewards/{id}", AdminWrite},
+	{http.MethodDelete, "/admin/rewards/{id}", AdminWrite},
+	{http.MethodPost, "/admin/expiry/sweep", AdminWrite},
+	{http.MethodGet, "/admin/tiers/changes", AdminRead},
+	{http.MethodPost, "/admin/tiers/recalculate", AdminWrite},
+	{http.MethodGet, "/exports/receipts", AdminRead},
+	{http.MethodPost, "/imports", AdminWrite},
+}
+
+// Policy evaluates the policy table for callers whose roles come from their
+// identity or, failing that, from a static identity-to-roles mapping.
+type Policy struct {
+	routes      map[string]Permission
+	clientRoles map[string][]Role
+}
+
+// NewPolicy builds a policy from rules. clientRoles assigns roles to
+// identities that carry none of their own, such as client certificates.
+func NewPolicy(rules []Rule, clientRoles map[string][]string) (*Policy, error) {
+	p := &Policy{routes: map[string]Permission{}, clientRoles: map[string][]Role{}}
+	for _, r := range rules {
+		p.routes[r.Method+" "+r.Path] = r.Permission
+	}
+	for id, roles := range clientRoles {
+		for _, role := range roles {
+			if err := ValidateRole(role); err != nil {
+				return nil, fmt.Errorf("client %q: %w", id, err)
+			}
+			p.clientRoles[id] = append(p.clientRoles[id], Role(role))
+		}
+	}
+	return p, nil
+}
+
+// Required returns the permission needed for method on path and whether the
+// route is in the table.
+func (p *Policy) Required(method, path string) (Permission, bool) {
+	perm, ok := p.routes[method+" "+path]
+	return perm, ok
+}
+
+// Roles returns the roles held by id.
+func (p *Policy) Roles(id auth.Identity) []Role {
+	if len(id.Roles) > 0 {
+		roles := make([]Role, len(id.Roles))
+		for i, r := range id.Roles {
+			roles[i] = Role(r)
+		}
+		return roles
+	}
+	return p.clientRoles[id.ID]
+}
+
+// Allowed reports whether id holds perm through any of its roles.
+func (p *Policy) Allowed(id auth.Identity, perm Permission) bool {
+	for _, role := range p.Roles(id) {
+		for _, granted := range RolePermissions[role] {
+			if granted == perm {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ValidateRole reports whether role is one of the known roles.
+func ValidateRole(role string) error {
+	if _, ok := RolePermissions[Role(role)]; !ok {
+		return fmt.Errorf("unknown role %q", role)
+	}
+	return nil
+}
+
+type checkKey struct{}
+
+// WithCheck returns ctx carrying check, which reports whether the caller of
+// the request holds a permission.
+func WithCheck(ctx context.Context, check func(Permission) bool) context.Context {
+	return context.WithValue(ctx, checkKey{}, check)
+}
+
+// Can reports whether the caller of the request ctx belongs to holds perm,
+// for handlers that need more than their route's permission for some
+// requests. Without a check in ctx, as when authorization is off, every
+// caller may do everything.
+func Can(ctx context.Context, perm Permission) bool {
+	check, ok := ctx.Value(checkKey{}).(func(Permission) bool)
+	return !ok || check(perm)
+}
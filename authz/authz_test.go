@@ -0,0 +1,48 @@
+package authz
+
+import (
+	"testing"
+
+	"github.com/suryamp/receipt-processor/auth"
+)
+
+func TestPolicyAllowed(t *testing.T) {
+	policy, err := NewPolicy(Routes, map[string][]string{"partner-cert": {"reader"}})
+	if err != nil {
+		t.Fatalf("NewPolicy() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		id   auth.Identity
+		perm Permission
+		want bool
+	}{
+		{"submitter writes", auth.Identity{ID: "a", Roles: []string{"submitter"}}, ReceiptsWrite, true},
+		{"submitter cannot read", auth.Identity{ID: "a", Roles: []string{"submitter"}}, ReceiptsRead, false},
+		{"support reads admin", auth.Identity{ID: "a", Roles: []string{"support"}}, AdminRead, true},
+		{"support cannot write admin", auth.Identity{ID: "a", Roles: []string{"support"}}, AdminWrite, false},
+		{"admin writes admin", auth.Identity{ID: "a", Roles: []string{"admin"}}, AdminWrite, true},
+		{"submitter cannot redeem", auth.Identity{ID: "a", Roles: []string{"submitter"}}, RewardsRedeem, false},
+		{"redeemer redeems", auth.Identity{ID: "a", Roles: []string{"redeemer"}}, RewardsRedeem, true},
+		{"admin redeems", auth.Identity{ID: "a", Roles: []string{"admin"}}, RewardsRedeem, true},
+		{"roles from client mapping", auth.Identity{ID: "partner-cert"}, ReceiptsRead, true},
+		{"credential roles win over mapping", auth.Identity{ID: "partner-cert", Roles: []string{"submitter"}}, ReceiptsRead, false},
+		{"no roles", auth.Identity{ID: "nobody"}, ReceiptsRead, false},
+		{"unknown role grants nothing", auth.Identity{ID: "a", Roles: []string{"root"}}, AdminWrite, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.Allowed(tt.id, tt.perm); got != tt.want {
+				t.Errorf("Allowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPolicyRejectsUnknownRole(t *testing.T) {
+	if _, err := NewPolicy(Routes, map[string][]string{"partner": {"superuser"}}); err == nil {
+		t.Errorf("NewPolicy() error = nil, want error")
+	}
+}
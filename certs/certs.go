@@ -0,0 +1,107 @@
+// Package certs builds the server TLS configuration and keeps the serving
+// certificate fresh when its files are rotated on disk.
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/suryamp/receipt-processor/logger"
+)
+
+// DefaultCheckInterval is how often the certificate files are checked for rotation.
+const DefaultCheckInterval = 30 * time.Second
+
+// KeyPair serves a certificate loaded from certFile and keyFile, reloading
+// it when either file's modification time changes. If a rotated pair fails
+// to load the previous certificate keeps being served.
+type KeyPair struct {
+	certFile, keyFile string
+	checkInterval     time.Duration
+
+	mu          sync.Mutex
+	cert        *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+	lastCheck   time.Time
+}
+
+func NewKeyPair(certFile, keyFile string, checkInterval time.Duration) (*KeyPair, error) {
+	kp := &KeyPair{certFile: certFile, keyFile: keyFile, checkInterval: checkInterval}
+	if err := kp.load(); err != nil {
+		return nil, err
+	}
+	return kp, nil
+}
+
+func (kp *KeyPair) load() error {
+	certModTime, keyModTime := modTime(kp.certFile), modTime(kp.keyFile)
+	cert, err := tls.LoadX509KeyPair(kp.certFile, kp.keyFile)
+	if err != nil {
+		return fmt.Errorf("loading certificate: %w", err)
+	}
+	kp.cert = &cert
+	kp.certModTime, kp.keyModTime = certModTime, keyModTime
+	return nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (kp *KeyPair) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+
+	if now := time.Now(); now.Sub(kp.lastCheck) >= kp.checkInterval {
+		kp.lastCheck = now
+		if !modTime(kp.certFile).Equal(kp.certModTime) || !modTime(kp.keyFile).Equal(kp.keyModTime) {
+			if err := kp.load(); err != nil {
+				logger.ErrorLogger.Printf("Certificate rotation failed, serving previous certificate: %v", err)
+			} else {
+				logger.InfoLogger.Printf("Reloaded rotated certificate from %s", kp.certFile)
+			}
+		}
+	}
+	return kp.cert, nil
+}
+
+// ServerConfig returns a TLS configuration serving kp. When clientCAFile is
+// set, client certificates are verified against it; requireClientCert makes
+// presenting one mandatory (mutual TLS).
+func ServerConfig(kp *KeyPair, clientCAFile string, requireClientCert bool) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: kp.GetCertificate,
+	}
+	if clientCAFile == "" {
+		if requireClientCert {
+			return nil, fmt.Errorf("requiring client certificates needs a client CA bundle")
+		}
+		return cfg, nil
+	}
+
+	pem, err := os.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading client CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in client CA bundle %s", clientCAFile)
+	}
+	cfg.ClientCAs = pool
+	cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	if requireClientCert {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
+
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
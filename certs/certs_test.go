@@ -0,0 +1,187 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/suryamp/receipt-processor/auth"
+	"github.com/suryamp/receipt-processor/logger"
+	"github.com/suryamp/receipt-processor/middleware"
+)
+
+func init() {
+	if err := logger.Init(); err != nil {
+		panic(err)
+	}
+}
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+// issue creates a certificate for cn signed by parent, or self-signed when parent is nil.
+func issue(t *testing.T, cn string, parent *testCert, isCA bool) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn, Organization: []string{"Acme"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:              []string{"localhost"},
+	}
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+func (c *testCert) write(t *testing.T, dir, name string) (certFile, keyFile string) {
+	t.Helper()
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	certFile, keyFile = filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0644)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	return certFile, keyFile
+}
+
+func (c *testCert) tlsCert() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+func TestKeyPairRotation(t *testing.T) {
+	dir := t.TempDir()
+	first := issue(t, "first", nil, false)
+	certFile, keyFile := first.write(t, dir, "server")
+
+	kp, err := NewKeyPair(certFile, keyFile, 0)
+	if err != nil {
+		t.Fatalf("NewKeyPair() error = %v", err)
+	}
+	if got, _ := kp.GetCertificate(nil); got.Leaf.Subject.CommonName != "first" {
+		t.Fatalf("GetCertificate() CN = %v, want first", got.Leaf.Subject.CommonName)
+	}
+
+	t.Run("rotated files are picked up", func(t *testing.T) {
+		second := issue(t, "second", nil, false)
+		second.write(t, dir, "server")
+		future := time.Now().Add(time.Minute)
+		os.Chtimes(certFile, future, future)
+		os.Chtimes(keyFile, future, future)
+
+		if got, _ := kp.GetCertificate(nil); got.Leaf.Subject.CommonName != "second" {
+			t.Errorf("GetCertificate() CN = %v, want second", got.Leaf.Subject.CommonName)
+		}
+	})
+
+	t.Run("broken rotation keeps previous certificate", func(t *testing.T) {
+		os.WriteFile(certFile, []byte("garbage"), 0644)
+		future := time.Now().Add(2 * time.Minute)
+		os.Chtimes(certFile, future, future)
+
+		got, err := kp.GetCertificate(nil)
+		if err != nil || got.Leaf.Subject.CommonName != "second" {
+			t.Errorf("GetCertificate() = %v, %v, want previous certificate", got.Leaf.Subject.CommonName, err)
+		}
+	})
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := issue(t, "test-ca", nil, true)
+	caFile, _ := ca.write(t, dir, "ca")
+	serverCert := issue(t, "localhost", ca, false)
+	certFile, keyFile := serverCert.write(t, dir, "server")
+
+	kp, err := NewKeyPair(certFile, keyFile, DefaultCheckInterval)
+	if err != nil {
+		t.Fatalf("NewKeyPair() error = %v", err)
+	}
+	tlsCfg, err := ServerConfig(kp, caFile, true)
+	if err != nil {
+		t.Fatalf("ServerConfig() error = %v", err)
+	}
+
+	identities := map[string]string{"CN=partner-a,O=Acme": "partner-a-mapped"}
+	srv := httptest.NewUnstartedServer(middleware.ClientCertMiddleware(identities)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id, _ := auth.FromContext(r.Context())
+			w.Write([]byte(id.ID))
+		})))
+	srv.TLS = tlsCfg
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	tests := []struct {
+		name       string
+		clientCert *testCert
+		wantErr    bool
+		wantID     string
+	}{
+		{name: "mapped subject", clientCert: issue(t, "partner-a", ca, false), wantID: "partner-a-mapped"},
+		{name: "unmapped subject uses common name", clientCert: issue(t, "partner-b", ca, false), wantID: "partner-b"},
+		{name: "untrusted client certificate", clientCert: issue(t, "intruder", nil, false), wantErr: true},
+		{name: "no client certificate", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientTLS := &tls.Config{RootCAs: roots, ServerName: "localhost"}
+			if tt.clientCert != nil {
+				clientTLS.Certificates = []tls.Certificate{tt.clientCert.tlsCert()}
+			}
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientTLS}}
+
+			resp, err := client.Get(srv.URL)
+			if tt.wantErr {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatalf("Get() error = nil, want handshake failure")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			defer resp.Body.Close()
+			body := make([]byte, 64)
+			n, _ := resp.Body.Read(body)
+			if got := string(body[:n]); got != tt.wantID {
+				t.Errorf("identity = %q, want %q", got, tt.wantID)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+// Command receipt-export converts an NDJSON receipt export, as streamed by
+// GET /exports/receipts, into Parquet files partitioned by purchase month.
+//
+//	curl -s 'https://localhost:8080/exports/receipts?from=2024-01-01' | receipt-export -out ./warehouse
+//
+// Receipts are read and written one at a time, and files are kept open for
+// at most -open-months months at once, so the export can be larger than
+// memory.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/suryamp/receipt-processor/export"
+	"github.com/suryamp/receipt-processor/internal/parquet"
+	"github.com/suryamp/receipt-processor/processor"
+)
+
+// maxLine bounds one receipt's line of NDJSON.
+const maxLine = 16 << 20
+
+func main() {
+	in := flag.String("in", "-", "NDJSON export to read, or - for standard input")
+	out := flag.String("out", "", "directory to write the receipts and items tables under")
+	rowGroupSize := flag.Int("row-group-size", parquet.DefaultRowGroupSize, "rows per Parquet row group")
+	openMonths := flag.Int("open-months", export.DefaultOpenMonths, "purchase months to keep files open for at once")
+	flag.Parse()
+	if *out == "" || *rowGroupSize < 1 || *openMonths < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(*in, *out, *rowGroupSize, *openMonths); err != nil {
+		fmt.Fprintf(os.Stderr, "receipt-export: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(in, out string, rowGroupSize, openMonths int) error {
+	var r io.Reader = os.Stdin
+	if in != "-" {
+		f, err := os.Open(in)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	w := export.NewParquetWriter(out).WithRowGroupSize(rowGroupSize).WithOpenMonths(openMonths)
+	if err := convert(r, w); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d receipts to %s for %s\n", w.Receipts(), out, strings.Join(w.Months(), ", "))
+	return nil
+}
+
+// convert writes every receipt read from r to w.
+func convert(r io.Reader, w *export.ParquetWriter) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxLine)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var receipt processor.ExportedReceipt
+		if err := json.Unmarshal(scanner.Bytes(), &receipt); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := w.Write(receipt); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	return scanner.Err()
+}
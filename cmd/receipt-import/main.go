@@ -0,0 +1,138 @@
+// Command receipt-import uploads a CSV or NDJSON file of receipts to a
+// running server's POST /imports and prints the report.
+//
+//	receipt-import -in receipts.csv -mapping mapping.yaml -dry-run
+//	receipt-import -in receipts.csv -mapping mapping.yaml
+//
+// The file is streamed, not loaded. If an import fails part way, running
+// the same command again resumes it: receipts already imported from the
+// same source are skipped. The server remembers what it imported only until
+// it restarts.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/suryamp/receipt-processor/auth"
+	"github.com/suryamp/receipt-processor/importer"
+	"gopkg.in/yaml.v3"
+)
+
+func main() {
+	in := flag.String("in", "-", "CSV or NDJSON file to import, or - for standard input")
+	server := flag.String("server", "http://localhost:8080", "base URL of the receipt processor")
+	format := flag.String("format", "", "csv or ndjson; by default from the file's extension")
+	source := flag.String("source", "", "name the receipts' keys are remembered under; by default the file's name")
+	mapping := flag.String("mapping", "", "YAML file of CSV columns by receipt field, over the server's mapping")
+	dryRun := flag.Bool("dry-run", false, "validate and report without importing")
+	maxErrors := flag.Int("max-errors", 0, "stop after this many invalid receipts; 0 means never")
+	apiKey := flag.String("api-key", os.Getenv("RECEIPT_API_KEY"), "API key of a client with admin:write")
+	flag.Parse()
+
+	if *source == "" {
+		if *in == "-" {
+			fmt.Fprintln(os.Stderr, "receipt-import: set -source when reading standard input")
+			os.Exit(2)
+		}
+		*source = filepath.Base(*in)
+	}
+	if *format == "" {
+		*format = string(importer.NDJSON)
+		if strings.EqualFold(filepath.Ext(*in), ".csv") {
+			*format = string(importer.CSV)
+		}
+	}
+
+	params := url.Values{
+		"format":    {*format},
+		"source":    {*source},
+		"dryRun":    {strconv.FormatBool(*dryRun)},
+		"maxErrors": {strconv.Itoa(*maxErrors)},
+	}
+	if *mapping != "" {
+		columns, err := readMapping(*mapping)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "receipt-import: %v\n", err)
+			os.Exit(2)
+		}
+		for field, column := range columns {
+			params.Set("column."+field, column)
+		}
+	}
+
+	report, err := upload(*in, strings.TrimSuffix(*server, "/")+"/imports?"+params.Encode(), *apiKey)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "receipt-import: %v\n", err)
+		os.Exit(1)
+	}
+	out := json.NewEncoder(os.Stdout)
+	out.SetIndent("", "  ")
+	out.Encode(report)
+
+	fmt.Fprintf(os.Stderr, "%s: %d records, %d imported, %d skipped, %d invalid, %.0f receipts/s\n",
+		report.Status, report.Records, report.Imported, report.Skipped, report.Invalid, report.ReceiptsPerSecond)
+	if report.Status == importer.StatusFailed {
+		fmt.Fprintf(os.Stderr, "Import failed after line %d: %s\nRun the same command again to resume.\n", report.LastLine, report.Error)
+	}
+	if report.Status == importer.StatusFailed || report.Invalid > 0 {
+		os.Exit(1)
+	}
+}
+
+// readMapping reads a YAML file of column names by mapping field.
+func readMapping(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var columns map[string]string
+	if err := yaml.Unmarshal(data, &columns); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return columns, nil
+}
+
+// upload streams the file at path to target and decodes the report.
+func upload(path, target, apiKey string) (importer.Report, error) {
+	var body io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return importer.Report{}, err
+		}
+		defer f.Close()
+		body = f
+	}
+
+	req, err := http.NewRequest(http.MethodPost, target, body)
+	if err != nil {
+		return importer.Report{}, err
+	}
+	if apiKey != "" {
+		req.Header.Set(auth.HeaderAPIKey, apiKey)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return importer.Report{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusUnprocessableEntity {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return importer.Report{}, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	var report importer.Report
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return importer.Report{}, fmt.Errorf("reading the report: %w", err)
+	}
+	return report, nil
+}
@@ -0,0 +1,677 @@
+// Package config builds the service configuration by layering, in order of
+// increasing precedence: built-in defaults, a YAML file, RECEIPT_* environment
+// variables and command-line flags.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode"
+
+	"github.com/suryamp/receipt-processor/authz"
+	"github.com/suryamp/receipt-processor/expiry"
+	"github.com/suryamp/receipt-processor/importer"
+	"github.com/suryamp/receipt-processor/loadshed"
+	"github.com/suryamp/receipt-processor/logger"
+	"github.com/suryamp/receipt-processor/processor"
+	"github.com/suryamp/receipt-processor/ratelimit"
+	"github.com/suryamp/receipt-processor/tiers"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	EnvPrefix     = "RECEIPT_"
+	EnvConfigFile = EnvPrefix + "CONFIG"
+)
+
+// Source identifies the layer a configuration value came from.
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+	SourceEnv     Source = "env"
+	SourceFlag    Source = "flag"
+)
+
+type Config struct {
+	Server       ServerConfig       `yaml:"server"`
+	Logging      LoggingConfig      `yaml:"logging"`
+	Storage      StorageConfig      `yaml:"storage"`
+	Rules        processor.Rules    `yaml:"rules"`
+	Shadow       ShadowConfig       `yaml:"shadow"`
+	Limits       LimitsConfig       `yaml:"limits"`
+	Reload       ReloadConfig       `yaml:"reload"`
+	Auth         AuthConfig         `yaml:"auth"`
+	Authz        AuthzConfig        `yaml:"authz"`
+	RateLimit    RateLimitConfig    `yaml:"rateLimit"`
+	LoadShed     LoadShedConfig     `yaml:"loadShed"`
+	Expiry       ExpiryConfig       `yaml:"expiry"`
+	Tiers        TiersConfig        `yaml:"tiers"`
+	Leaderboards LeaderboardsConfig `yaml:"leaderboards"`
+	Imports      ImportsConfig      `yaml:"imports"`
+}
+
+type ServerConfig struct {
+	Addr            string        `yaml:"addr"`
+	ReadTimeout     time.Duration `yaml:"readTimeout"`
+	WriteTimeout    time.Duration `yaml:"writeTimeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+	TLS             TLSConfig     `yaml:"tls"`
+}
+
+// TLSConfig enables HTTPS when CertFile and KeyFile are set. ClientCAFile
+// turns on client certificate verification, and RequireClientCert makes it
+// mandatory. ClientIdentities maps verified certificate subjects to caller
+// identities; unlisted subjects use their common name.
+type TLSConfig struct {
+	CertFile          string            `yaml:"certFile"`
+	KeyFile           string            `yaml:"keyFile"`
+	ClientCAFile      string            `yaml:"clientCAFile"`
+	RequireClientCert bool              `yaml:"requireClientCert"`
+	ClientIdentities  map[string]string `yaml:"clientIdentities"`
+}
+
+// Enabled reports whether the server should serve HTTPS.
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+type LoggingConfig struct {
+	Dir   string `yaml:"dir"`
+	File  string `yaml:"file"`
+	Level string `yaml:"level"`
+}
+
+type StorageConfig struct {
+	Type string `yaml:"type"`
+}
+
+// ShadowConfig scores every processed receipt under candidate Rules as well
+// as the active rules, to compare payouts before rolling them out. Fields
+// left out take their defaults, not the values of the active rules.
+type ShadowConfig struct {
+	Enabled bool            `yaml:"enabled"`
+	Rules   processor.Rules `yaml:"rules"`
+}
+
+type LimitsConfig struct {
+	MaxHeaderBytes  int   `yaml:"maxHeaderBytes"`
+	MaxBodyBytes    int64 `yaml:"maxBodyBytes"`
+	MaxItems        int   `yaml:"maxItems"`
+	MaxStringLength int   `yaml:"maxStringLength"`
+}
+
+// ReloadConfig controls how often the config file is checked for changes.
+// A zero WatchInterval disables file watching; SIGHUP still triggers a reload.
+type ReloadConfig struct {
+	WatchInterval time.Duration `yaml:"watchInterval"`
+}
+
+// AuthConfig turns on caller authentication. Clients and their hashed API
+// keys and HMAC secrets are read from KeysFile; callers verified by a client
+// certificate are accepted without a key.
+type AuthConfig struct {
+	Enabled      bool          `yaml:"enabled"`
+	KeysFile     string        `yaml:"keysFile"`
+	MaxClockSkew time.Duration `yaml:"maxClockSkew"`
+}
+
+// AuthzConfig turns on role-based authorization. In DryRun mode denials are
+// only logged, so the policy can be checked against real traffic first.
+// ClientRoles grants roles to identities whose credential carries none,
+// such as client certificates.
+type AuthzConfig struct {
+	Enabled     bool                `yaml:"enabled"`
+	DryRun      bool                `yaml:"dryRun"`
+	ClientRoles map[string][]string `yaml:"clientRoles"`
+}
+
+// RateLimitConfig throttles each caller (client identity, or IP without
+// one) with a token bucket per route, using Default unless Routes overrides
+// it, and caps QuotaRoutes at DailyQuota requests per UTC day, or the
+// caller's entry in ClientQuotas. A zero quota means unlimited.
+type RateLimitConfig struct {
+	Enabled      bool                   `yaml:"enabled"`
+	Default      ratelimit.Limit        `yaml:"default"`
+	Routes       []ratelimit.RouteLimit `yaml:"routes"`
+	DailyQuota   int64                  `yaml:"dailyQuota"`
+	ClientQuotas map[string]int64       `yaml:"clientQuotas"`
+	QuotaRoutes  []string               `yaml:"quotaRoutes"`
+}
+
+// LoadShedConfig bounds requests in flight with an adaptive limit between
+// MinLimit and MaxLimit. The limit grows while requests finish within
+// LatencyTarget and is multiplied by Backoff when one is slower or fails.
+// Writes may use WriteFraction of the limit; rejected callers are asked to
+// come back after RetryAfter.
+type LoadShedConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	InitialLimit  int           `yaml:"initialLimit"`
+	MinLimit      int           `yaml:"minLimit"`
+	MaxLimit      int           `yaml:"maxLimit"`
+	LatencyTarget time.Duration `yaml:"latencyTarget"`
+	Backoff       float64       `yaml:"backoff"`
+	WriteFraction float64       `yaml:"writeFraction"`
+	RetryAfter    time.Duration `yaml:"retryAfter"`
+}
+
+// ExpiryConfig retires unspent points. Policy is "fixed" (each receipt's
+// points expire Months after its purchase date) or "inactivity" (a user's
+// whole balance expires Months after they last earned or redeemed points).
+type ExpiryConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	Policy        string        `yaml:"policy"`
+	Months        int           `yaml:"months"`
+	SweepInterval time.Duration `yaml:"sweepInterval"`
+	WarningDays   int           `yaml:"warningDays"` // default window for expiring-soon queries
+}
+
+// TiersConfig ranks users by their points from receipts over the trailing
+// twelve months. Levels run from lowest to highest; RecalculateAt is the
+// local time of the nightly recalculation.
+type TiersConfig struct {
+	Enabled       bool         `yaml:"enabled"`
+	Levels        tiers.Ladder `yaml:"levels"`
+	RecalculateAt string       `yaml:"recalculateAt"`
+}
+
+// LeaderboardsConfig keeps leaderboards of users and retailers. Weekly and
+// monthly boards start over at midnight in Timezone, an IANA name.
+type LeaderboardsConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Timezone string `yaml:"timezone"`
+}
+
+// ImportsConfig shapes bulk imports. Mapping names the CSV columns receipt
+// fields are read from; MaxBodyBytes replaces limits.maxBodyBytes for
+// uploads, where zero means no limit.
+type ImportsConfig struct {
+	MaxBodyBytes int64            `yaml:"maxBodyBytes"`
+	Mapping      importer.Mapping `yaml:"mapping"`
+}
+
+// Loaded is the effective configuration together with where each value came from.
+type Loaded struct {
+	Config      Config
+	File        string
+	Sources     map[string]Source
+	PrintConfig bool
+}
+
+// Default returns the configuration used when nothing else is specified.
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Addr:            ":8080",
+			ReadTimeout:     10 * time.Second,
+			WriteTimeout:    10 * time.Second,
+			ShutdownTimeout: 10 * time.Second,
+		},
+		Logging: LoggingConfig{
+			Dir:   logger.DefaultDir,
+			File:  logger.DefaultFile,
+			Level: logger.DefaultLevel,
+		},
+		Storage: StorageConfig{
+			Type: "memory",
+		},
+		Rules: processor.DefaultRules(),
+		Shadow: ShadowConfig{
+			Rules: processor.DefaultRules(),
+		},
+		Limits: LimitsConfig{
+			MaxHeaderBytes:  1 << 20,
+			MaxBodyBytes:    1 << 20,
+			MaxItems:        500,
+			MaxStringLength: 256,
+		},
+		Reload: ReloadConfig{
+			WatchInterval: 5 * time.Second,
+		},
+		Auth: AuthConfig{
+			MaxClockSkew: 5 * time.Minute,
+		},
+		RateLimit: RateLimitConfig{
+			Default:     ratelimit.Limit{Rate: 10, Burst: 20},
+			QuotaRoutes: []string{"POST /receipts/process"},
+		},
+		LoadShed: LoadShedConfig{
+			InitialLimit:  loadshed.DefaultConfig.InitialLimit,
+			MinLimit:      loadshed.DefaultConfig.MinLimit,
+			MaxLimit:      loadshed.DefaultConfig.MaxLimit,
+			LatencyTarget: loadshed.DefaultConfig.LatencyTarget,
+			Backoff:       loadshed.DefaultConfig.Backoff,
+			WriteFraction: loadshed.DefaultConfig.WriteFraction,
+			RetryAfter:    loadshed.DefaultConfig.RetryAfter,
+		},
+		Expiry: ExpiryConfig{
+			Policy:        string(expiry.ModeFixed),
+			Months:        12,
+			SweepInterval: time.Hour,
+			WarningDays:   30,
+		},
+		Tiers: TiersConfig{
+			Levels:        tiers.DefaultLadder(),
+			RecalculateAt: "03:00",
+		},
+		Leaderboards: LeaderboardsConfig{
+			Timezone: "UTC",
+		},
+		Imports: ImportsConfig{
+			MaxBodyBytes: 1 << 30,
+			Mapping:      importer.DefaultMapping(),
+		},
+	}
+}
+
+// Validate reports the first invalid setting in c.
+func (c Config) Validate() error {
+	if c.Server.Addr == "" {
+		return fmt.Errorf("server.addr must not be empty")
+	}
+	if c.Server.ReadTimeout <= 0 || c.Server.WriteTimeout <= 0 || c.Server.ShutdownTimeout <= 0 {
+		return fmt.Errorf("server timeouts must be positive")
+	}
+	if tls := c.Server.TLS; tls.Enabled() {
+		if tls.CertFile == "" || tls.KeyFile == "" {
+			return fmt.Errorf("server.tls.certFile and server.tls.keyFile must be set together")
+		}
+		if tls.RequireClientCert && tls.ClientCAFile == "" {
+			return fmt.Errorf("server.tls.requireClientCert needs server.tls.clientCAFile")
+		}
+	} else if c.Server.TLS.ClientCAFile != "" || c.Server.TLS.RequireClientCert {
+		return fmt.Errorf("client certificates need server.tls.certFile and server.tls.keyFile")
+	}
+	if c.Logging.Dir == "" || c.Logging.File == "" {
+		return fmt.Errorf("logging.dir and logging.file must not be empty")
+	}
+	if err := logger.ValidateLevel(c.Logging.Level); err != nil {
+		return fmt.Errorf("logging.level: %w", err)
+	}
+	if c.Storage.Type != "memory" {
+		return fmt.Errorf("storage.type %q is not supported", c.Storage.Type)
+	}
+	if err := c.Rules.Validate(); err != nil {
+		return fmt.Errorf("rules: %w", err)
+	}
+	if c.Shadow.Enabled {
+		if err := c.Shadow.Rules.Validate(); err != nil {
+			return fmt.Errorf("shadow.rules: %w", err)
+		}
+	}
+	if c.Limits.MaxHeaderBytes < 0 || c.Limits.MaxBodyBytes < 0 || c.Limits.MaxItems < 0 || c.Limits.MaxStringLength < 0 {
+		return fmt.Errorf("limits must not be negative")
+	}
+	if c.Reload.WatchInterval < 0 {
+		return fmt.Errorf("reload.watchInterval must not be negative")
+	}
+	if c.Auth.Enabled && c.Auth.KeysFile == "" && c.Server.TLS.ClientCAFile == "" {
+		return fmt.Errorf("auth.enabled needs auth.keysFile or server.tls.clientCAFile")
+	}
+	if c.Auth.MaxClockSkew <= 0 {
+		return fmt.Errorf("auth.maxClockSkew must be positive")
+	}
+	if c.Authz.Enabled && !c.Auth.Enabled {
+		return fmt.Errorf("authz.enabled needs auth.enabled")
+	}
+	if err := c.RateLimit.validate(); err != nil {
+		return err
+	}
+	if err := c.LoadShed.validate(); err != nil {
+		return err
+	}
+	if err := c.Expiry.validate(); err != nil {
+		return err
+	}
+	if err := c.Tiers.validate(); err != nil {
+		return err
+	}
+	if _, err := time.LoadLocation(c.Leaderboards.Timezone); err != nil {
+		return fmt.Errorf("leaderboards.timezone: %w", err)
+	}
+	if c.Imports.MaxBodyBytes < 0 {
+		return fmt.Errorf("imports.maxBodyBytes must not be negative")
+	}
+	if err := c.Imports.Mapping.Validate(); err != nil {
+		return fmt.Errorf("imports.%w", err)
+	}
+	for id, roles := range c.Authz.ClientRoles {
+		for _, role := range roles {
+			if err := authz.ValidateRole(role); err != nil {
+				return fmt.Errorf("authz.clientRoles[%s]: %w", id, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (c RateLimitConfig) validate() error {
+	limits := []ratelimit.Limit{c.Default}
+	for _, r := range c.Routes {
+		if r.Method == "" || r.Path == "" {
+			return fmt.Errorf("rateLimit.routes entries need method and path")
+		}
+		limits = append(limits, r.Limit)
+	}
+	for _, l := range limits {
+		if l.Rate <= 0 || l.Burst < 1 {
+			return fmt.Errorf("rateLimit rates must be positive and bursts at least 1")
+		}
+	}
+	if c.DailyQuota < 0 {
+		return fmt.Errorf("rateLimit.dailyQuota must not be negative")
+	}
+	for _, r := range c.QuotaRoutes {
+		if method, path, ok := strings.Cut(r, " "); !ok || method == "" || !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("rateLimit.quotaRoutes entry %q must look like \"POST /receipts/process\"", r)
+		}
+	}
+	return nil
+}
+
+func (c LoadShedConfig) validate() error {
+	if c.MinLimit < 1 || c.MinLimit > c.InitialLimit || c.InitialLimit > c.MaxLimit {
+		return fmt.Errorf("loadShed limits must satisfy 1 <= minLimit <= initialLimit <= maxLimit")
+	}
+	if c.LatencyTarget <= 0 || c.RetryAfter <= 0 {
+		return fmt.Errorf("loadShed.latencyTarget and loadShed.retryAfter must be positive")
+	}
+	if c.Backoff <= 0 || c.Backoff >= 1 {
+		return fmt.Errorf("loadShed.backoff must be between 0 and 1")
+	}
+	if c.WriteFraction <= 0 || c.WriteFraction > 1 {
+		return fmt.Errorf("loadShed.writeFraction must be in (0, 1]")
+	}
+	return nil
+}
+
+func (c ExpiryConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if err := c.ExpiryPolicy().Validate(); err != nil {
+		return fmt.Errorf("expiry: %w", err)
+	}
+	if c.SweepInterval <= 0 {
+		return fmt.Errorf("expiry.sweepInterval must be positive")
+	}
+	if c.WarningDays < 1 {
+		return fmt.Errorf("expiry.warningDays must be at least 1")
+	}
+	return nil
+}
+
+func (c TiersConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if err := c.Levels.Validate(); err != nil {
+		return fmt.Errorf("tiers.levels: %w", err)
+	}
+	if _, err := tiers.ParseTimeOfDay(c.RecalculateAt); err != nil {
+		return fmt.Errorf("tiers.recalculateAt: %w", err)
+	}
+	return nil
+}
+
+// ExpiryPolicy returns the configured expiry policy.
+func (c ExpiryConfig) ExpiryPolicy() expiry.Policy {
+	return expiry.Policy{Mode: expiry.Mode(c.Policy), Months: c.Months}
+}
+
+// Location returns where leaderboard weeks and months begin.
+func (c LeaderboardsConfig) Location() *time.Location {
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Load parses args and layers defaults, the config file, environment and
+// flags into a validated configuration. The config file is taken from
+// --config or RECEIPT_CONFIG.
+func Load(args []string) (*Loaded, error) {
+	cfg := Default()
+	fields := leaves(&cfg)
+
+	fs := flag.NewFlagSet("receipt-processor", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv(EnvConfigFile), "path to a YAML config file")
+	printConfig := fs.Bool("print-config", false, "print the effective configuration and exit")
+	flagValues := map[string]string{}
+	for _, f := range fields {
+		if !f.scalar() {
+			continue
+		}
+		key := f.key
+		fs.Func(key, "overrides "+key+" (env "+EnvName(key)+")", func(v string) error {
+			flagValues[key] = v
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	loaded := &Loaded{
+		File:        *configFile,
+		Sources:     map[string]Source{},
+		PrintConfig: *printConfig,
+	}
+	for _, f := range fields {
+		loaded.Sources[f.key] = SourceDefault
+	}
+
+	if loaded.File != "" {
+		present, err := loadFile(loaded.File, &cfg)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range fields {
+			if present(f.key) {
+				loaded.Sources[f.key] = SourceFile
+			}
+		}
+	}
+
+	for _, f := range fields {
+		if !f.scalar() {
+			continue
+		}
+		if v, ok := os.LookupEnv(EnvName(f.key)); ok {
+			if err := f.set(v); err != nil {
+				return nil, fmt.Errorf("%s: %w", EnvName(f.key), err)
+			}
+			loaded.Sources[f.key] = SourceEnv
+		}
+		if v, ok := flagValues[f.key]; ok {
+			if err := f.set(v); err != nil {
+				return nil, fmt.Errorf("--%s: %w", f.key, err)
+			}
+			loaded.Sources[f.key] = SourceFlag
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	loaded.Config = cfg
+	return loaded, nil
+}
+
+// Print writes every setting with its effective value and source.
+func (l *Loaded) Print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if l.File != "" {
+		fmt.Fprintf(tw, "# config file: %s\n", l.File)
+	}
+	cfg := l.Config
+	for _, f := range leaves(&cfg) {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", f.key, f.String(), l.Sources[f.key])
+	}
+	return tw.Flush()
+}
+
+// EnvName maps a dotted config key such as "server.readTimeout" to its
+// environment variable, RECEIPT_SERVER_READ_TIMEOUT.
+func EnvName(key string) string {
+	var b strings.Builder
+	b.WriteString(EnvPrefix)
+	for i, part := range strings.Split(key, ".") {
+		if i > 0 {
+			b.WriteByte('_')
+		}
+		for j, r := range part {
+			if j > 0 && unicode.IsUpper(r) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToUpper(r))
+		}
+	}
+	return b.String()
+}
+
+// loadFile decodes path over cfg and returns a predicate reporting which
+// keys the file set.
+func loadFile(path string, cfg *Config) (func(key string) bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	return func(key string) bool {
+		var node any = raw
+		for _, part := range strings.Split(key, ".") {
+			m, ok := node.(map[string]any)
+			if !ok {
+				return false
+			}
+			if node, ok = m[part]; !ok {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// field is a single settable leaf of Config addressed by its dotted yaml key.
+type field struct {
+	key   string
+	value reflect.Value
+}
+
+func leaves(cfg *Config) []field {
+	var out []field
+	var walk func(prefix string, v reflect.Value)
+	walk = func(prefix string, v reflect.Value) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+			if name == "" || name == "-" {
+				continue
+			}
+			key := prefix + name
+			fv := v.Field(i)
+			if fv.Kind() == reflect.Struct && fv.Type() != durationType {
+				walk(key+".", fv)
+				continue
+			}
+			out = append(out, field{key: key, value: fv})
+		}
+	}
+	walk("", reflect.ValueOf(cfg).Elem())
+	return out
+}
+
+// scalar reports whether the field can be set from a single string.
+func (f field) scalar() bool {
+	switch f.value.Kind() {
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int64, reflect.Float64:
+		return true
+	case reflect.Slice:
+		return f.value.Type().Elem().Kind() == reflect.String
+	}
+	return false
+}
+
+func (f field) set(s string) error {
+	v := f.value
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Slice:
+		var parts []string
+		for _, p := range strings.Split(s, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				parts = append(parts, p)
+			}
+		}
+		v.Set(reflect.ValueOf(parts))
+	default:
+		return fmt.Errorf("cannot be set outside the config file")
+	}
+	return nil
+}
+
+func (f field) String() string {
+	if f.scalar() || f.value.Type() == durationType {
+		if f.value.Kind() == reflect.Slice {
+			return strings.Join(f.value.Interface().([]string), ",")
+		}
+		return fmt.Sprint(f.value.Interface())
+	}
+	b, err := json.Marshal(f.value.Interface())
+	if err != nil {
+		return fmt.Sprint(f.value.Interface())
+	}
+	return string(b)
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestDefaultIsValid(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Errorf("Default().Validate() error = %v", err)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, `
+server:
+  addr: ":9000"
+  readTimeout: 5s
+rules:
+  happyHourStart: 15
+  happyHourEnd: 17
+`)
+	t.Setenv("RECEIPT_SERVER_READ_TIMEOUT", "7s")
+	t.Setenv("RECEIPT_RULES_HAPPY_HOUR_END", "18")
+
+	loaded, err := Load([]string{"--config", path, "--rules.happyHourEnd", "19"})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	cfg := loaded.Config
+
+	tests := []struct {
+		key        string
+		got, want  any
+		wantSource Source
+	}{
+		{"server.addr", cfg.Server.Addr, ":9000", SourceFile},
+		{"server.readTimeout", cfg.Server.ReadTimeout, 7 * time.Second, SourceEnv},
+		{"server.writeTimeout", cfg.Server.WriteTimeout, 10 * time.Second, SourceDefault},
+		{"rules.happyHourStart", cfg.Rules.HappyHourStart, 15, SourceFile},
+		{"rules.happyHourEnd", cfg.Rules.HappyHourEnd, 19, SourceFlag},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("%s = %v, want %v", tt.key, tt.got, tt.want)
+			}
+			if got := loaded.Sources[tt.key]; got != tt.wantSource {
+				t.Errorf("%s source = %v, want %v", tt.key, got, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		args []string
+		env  map[string]string
+	}{
+		{
+			name: "unknown file key",
+			file: "server:\n  port: 8080\n",
+		},
+		{
+			name: "invalid log level",
+			args: []string{"--logging.level", "verbose"},
+		},
+		{
+			name: "unparsable env duration",
+			env:  map[string]string{"RECEIPT_SERVER_WRITE_TIMEOUT": "ten"},
+		},
+		{
+			name: "happy hour ends before it starts",
+			file: "rules:\n  happyHourStart: 16\n  happyHourEnd: 14\n",
+		},
+		{
+			name: "unsupported storage",
+			args: []string{"--storage.type", "postgres"},
+		},
+		{
+			name: "load shed minimum above initial limit",
+			args: []string{"--loadShed.minLimit", "50"},
+		},
+		{
+			name: "invalid shadow rules",
+			file: "shadow:\n  enabled: true\n  rules:\n    itemDescriptionPointsModulus: 0\n",
+		},
+		{
+			name: "unknown expiry policy",
+			args: []string{"--expiry.enabled", "true", "--expiry.policy", "never"},
+		},
+		{
+			name: "expiry without months",
+			env:  map[string]string{"RECEIPT_EXPIRY_ENABLED": "true", "RECEIPT_EXPIRY_MONTHS": "0"},
+		},
+		{
+			name: "tiers out of order",
+			file: "tiers:\n  enabled: true\n  levels:\n    - {name: Gold, minPoints: 5000, multiplier: 1.25}\n    - {name: Silver, minPoints: 1000, multiplier: 1.1}\n",
+		},
+		{
+			name: "unknown leaderboard timezone",
+			env:  map[string]string{"RECEIPT_LEADERBOARDS_TIMEZONE": "Mars/Olympus_Mons"},
+		},
+		{
+			name: "invalid tier recalculation time",
+			args: []string{"--tiers.enabled", "true", "--tiers.recalculateAt", "3am"},
+		},
+		{
+			name: "import mapping without a key column",
+			args: []string{"--imports.mapping.key", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"--config", writeFile(t, tt.file)}, args...)
+			}
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			if _, err := Load(args); err == nil {
+				t.Errorf("Load() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	tests := map[string]string{
+		"server.addr":                        "RECEIPT_SERVER_ADDR",
+		"server.shutdownTimeout":             "RECEIPT_SERVER_SHUTDOWN_TIMEOUT",
+		"rules.itemDescriptionPointsModulus": "RECEIPT_RULES_ITEM_DESCRIPTION_POINTS_MODULUS",
+	}
+	for key, want := range tests {
+		if got := EnvName(key); got != want {
+			t.Errorf("EnvName(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestPrint(t *testing.T) {
+	t.Setenv("RECEIPT_LOGGING_LEVEL", "error")
+	loaded, err := Load(nil)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := loaded.Print(&buf); err != nil {
+		t.Fatalf("Print() error = %v", err)
+	}
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, "logging.level") {
+			if fields := strings.Fields(line); len(fields) != 3 || fields[1] != "error" || fields[2] != "env" {
+				t.Errorf("Print() line = %q, want value error from env", line)
+			}
+			return
+		}
+	}
+	t.Errorf("Print() output has no logging.level line:\n%s", buf.String())
+}
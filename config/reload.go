@@ -0,0 +1,190 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/suryamp/receipt-processor/logger"
+	"github.com/suryamp/receipt-processor/metrics"
+)
+
+// ApplyFunc installs a freshly loaded configuration. It must either apply
+// all of it or return an error and leave the running service untouched.
+type ApplyFunc func(*Loaded) error
+
+// Status describes the active configuration and the outcome of the last reload.
+type Status struct {
+	ConfigVersion   string    `json:"configVersion"`
+	RulesVersion    string    `json:"rulesVersion"`
+	File            string    `json:"file,omitempty"`
+	LoadedAt        time.Time `json:"loadedAt"`
+	Reloads         int       `json:"reloads"`
+	LastReloadAt    time.Time `json:"lastReloadAt,omitempty"`
+	LastReloadOK    bool      `json:"lastReloadOk"`
+	LastReloadError string    `json:"lastReloadError,omitempty"`
+}
+
+// Reloader re-reads the configuration on SIGHUP or when the config file
+// changes, and hands validated results to an ApplyFunc. A failed reload
+// keeps the previous configuration in service.
+type Reloader struct {
+	args  []string
+	apply ApplyFunc
+
+	mu      sync.Mutex
+	current *Loaded
+	status  Status
+	modTime time.Time
+}
+
+func NewReloader(args []string, initial *Loaded, apply ApplyFunc) *Reloader {
+	r := &Reloader{
+		args:    args,
+		apply:   apply,
+		current: initial,
+		status: Status{
+			ConfigVersion: Version(initial.Config),
+			RulesVersion:  initial.Config.Rules.Version(),
+			File:          initial.File,
+			LoadedAt:      time.Now(),
+			LastReloadOK:  true,
+		},
+		modTime: fileModTime(initial.File),
+	}
+	r.publish()
+	return r
+}
+
+// Current returns the configuration currently in service.
+func (r *Reloader) Current() *Loaded {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// Status returns a snapshot of the reload status.
+func (r *Reloader) Status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+// Reload loads and validates the configuration and, if that succeeds,
+// applies it. On error the previous configuration stays active.
+func (r *Reloader) Reload(reason string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.status.Reloads++
+	r.status.LastReloadAt = time.Now()
+	r.modTime = fileModTime(r.current.File)
+
+	next, err := Load(r.args)
+	if err == nil {
+		next.Config = reloadable(r.current.Config, next.Config)
+		err = r.apply(next)
+	}
+	if err != nil {
+		r.status.LastReloadOK = false
+		r.status.LastReloadError = err.Error()
+		metrics.ConfigReloadsTotal.WithLabelValues("failure").Inc()
+		metrics.ConfigLastReloadSuccess.Set(0)
+		logger.ErrorLogger.Printf("Configuration reload (%s) failed, keeping version %s: %v",
+			reason, r.status.ConfigVersion, err)
+		return err
+	}
+
+	r.current = next
+	r.status.ConfigVersion = Version(next.Config)
+	r.status.RulesVersion = next.Config.Rules.Version()
+	r.status.File = next.File
+	r.status.LoadedAt = r.status.LastReloadAt
+	r.status.LastReloadOK = true
+	r.status.LastReloadError = ""
+	metrics.ConfigReloadsTotal.WithLabelValues("success").Inc()
+	r.publish()
+	logger.InfoLogger.Printf("Configuration reloaded (%s): config version %s, rules version %s",
+		reason, r.status.ConfigVersion, r.status.RulesVersion)
+	return nil
+}
+
+// Run reloads on every signal received from hup and, when the watch
+// interval is positive, whenever the config file's modification time
+// changes. It returns when ctx is done.
+func (r *Reloader) Run(ctx context.Context, hup <-chan os.Signal) {
+	var tick <-chan time.Time
+	if interval := r.Current().Config.Reload.WatchInterval; interval > 0 && r.Current().File != "" {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.Reload("SIGHUP")
+		case <-tick:
+			if r.fileChanged() {
+				r.Reload("file change")
+			}
+		}
+	}
+}
+
+func (r *Reloader) fileChanged() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !fileModTime(r.current.File).Equal(r.modTime)
+}
+
+// publish exports the active versions; the caller must hold r.mu or own r.
+func (r *Reloader) publish() {
+	metrics.ConfigInfo.Reset()
+	metrics.ConfigInfo.WithLabelValues(r.status.ConfigVersion, r.status.RulesVersion).Set(1)
+	metrics.ConfigLastReloadSuccess.Set(1)
+}
+
+// Version returns a short content hash identifying v.
+func Version(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "unknown"
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:6])
+}
+
+func fileModTime(path string) time.Time {
+	if path == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// reloadable returns the running configuration with the settings that
+// apply without a restart (rules, shadow rules, tier levels and log level)
+// taken from next. It logs when next changes anything else, since those
+// settings stay as they are until the service restarts.
+func reloadable(running, next Config) Config {
+	applied := running
+	applied.Rules = next.Rules
+	applied.Shadow = next.Shadow
+	applied.Tiers.Levels = next.Tiers.Levels
+	applied.Logging.Level = next.Logging.Level
+	if !reflect.DeepEqual(applied, next) {
+		logger.InfoLogger.Printf("Settings other than rules, shadow rules, tier levels and log level changed; they take effect on restart")
+	}
+	return applied
+}
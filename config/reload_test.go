@@ -0,0 +1,102 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/suryamp/receipt-processor/logger"
+)
+
+func init() {
+	if err := logger.Init(); err != nil {
+		panic(err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := writeFile(t, "rules:\n  happyHourPoints: 10\n")
+	args := []string{"--config", path}
+	initial, err := Load(args)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	var applied []int64
+	reloader := NewReloader(args, initial, func(next *Loaded) error {
+		applied = append(applied, next.Config.Rules.HappyHourPoints)
+		return nil
+	})
+	before := reloader.Status()
+
+	t.Run("valid change is applied", func(t *testing.T) {
+		os.WriteFile(path, []byte("rules:\n  happyHourPoints: 20\n"), 0644)
+		if err := reloader.Reload("test"); err != nil {
+			t.Fatalf("Reload() error = %v", err)
+		}
+		status := reloader.Status()
+		if status.RulesVersion == before.RulesVersion {
+			t.Errorf("Reload() rules version unchanged: %v", status.RulesVersion)
+		}
+		if !status.LastReloadOK {
+			t.Errorf("Reload() LastReloadOK = false, want true")
+		}
+		if got := reloader.Current().Config.Rules.HappyHourPoints; got != 20 {
+			t.Errorf("Current() happyHourPoints = %v, want 20", got)
+		}
+		if fmt.Sprint(applied) != "[20]" {
+			t.Errorf("apply called with %v, want [20]", applied)
+		}
+	})
+
+	t.Run("invalid change keeps previous version", func(t *testing.T) {
+		active := reloader.Status()
+		os.WriteFile(path, []byte("rules:\n  happyHourStart: 30\n"), 0644)
+		if err := reloader.Reload("test"); err == nil {
+			t.Fatalf("Reload() error = nil, want error")
+		}
+		status := reloader.Status()
+		if status.ConfigVersion != active.ConfigVersion {
+			t.Errorf("Reload() config version = %v, want %v", status.ConfigVersion, active.ConfigVersion)
+		}
+		if status.LastReloadOK || status.LastReloadError == "" {
+			t.Errorf("Reload() status = %+v, want recorded failure", status)
+		}
+		if got := reloader.Current().Config.Rules.HappyHourPoints; got != 20 {
+			t.Errorf("Current() happyHourPoints = %v, want 20", got)
+		}
+		if len(applied) != 1 {
+			t.Errorf("apply called %d times, want 1", len(applied))
+		}
+	})
+
+	t.Run("settings that need a restart are kept", func(t *testing.T) {
+		os.WriteFile(path, []byte("rules:\n  happyHourPoints: 20\nshadow:\n  enabled: true\nserver:\n  addr: \":9999\"\n"), 0644)
+		if err := reloader.Reload("test"); err != nil {
+			t.Fatalf("Reload() error = %v", err)
+		}
+		current := reloader.Current().Config
+		if current.Server.Addr != initial.Config.Server.Addr {
+			t.Errorf("Current() addr = %v, want the running %v", current.Server.Addr, initial.Config.Server.Addr)
+		}
+		if !current.Shadow.Enabled {
+			t.Errorf("Current() shadow disabled, want the reloaded shadow rules")
+		}
+		if status := reloader.Status(); status.ConfigVersion != Version(current) {
+			t.Errorf("Reload() config version = %v, want the version of the running config", status.ConfigVersion)
+		}
+	})
+
+	t.Run("apply error keeps previous version", func(t *testing.T) {
+		failing := NewReloader(args, reloader.Current(), func(*Loaded) error {
+			return fmt.Errorf("rejected")
+		})
+		os.WriteFile(path, []byte("rules:\n  happyHourPoints: 30\n"), 0644)
+		if err := failing.Reload("test"); err == nil {
+			t.Fatalf("Reload() error = nil, want error")
+		}
+		if got := failing.Current().Config.Rules.HappyHourPoints; got != 20 {
+			t.Errorf("Current() happyHourPoints = %v, want 20", got)
+		}
+	})
+}
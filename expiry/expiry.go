@@ -0,0 +1,285 @@
+// Package expiry retires points that go unspent.
+//
+// Under the fixed policy every credit expires Months after it was earned:
+// a receipt's points after its purchase date, anything else after it was
+// posted. Spending uses up the points that expire soonest first, and a
+// cancelled redemption puts its points back with the expiry they had. Under
+// the inactivity policy a user's whole balance expires once Months pass
+// without them earning or redeeming points.
+//
+// Expired points are moved to ledger.AccountExpired by a sweep, which runs
+// on a schedule; nothing expires between sweeps.
+package expiry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/suryamp/receipt-processor/ledger"
+	"github.com/suryamp/receipt-processor/logger"
+	"github.com/suryamp/receipt-processor/metrics"
+	"github.com/suryamp/receipt-processor/users"
+)
+
+const dateLayout = "2006-01-02"
+
+// Mode is how points expire.
+type Mode string
+
+const (
+	ModeFixed      Mode = "fixed"      // each credit Months after it was earned
+	ModeInactivity Mode = "inactivity" // everything Months after the last activity
+)
+
+// Policy says when points expire.
+type Policy struct {
+	Mode   Mode
+	Months int
+}
+
+// Validate reports whether p is a usable policy.
+func (p Policy) Validate() error {
+	if p.Mode != ModeFixed && p.Mode != ModeInactivity {
+		return fmt.Errorf("policy must be %q or %q, not %q", ModeFixed, ModeInactivity, p.Mode)
+	}
+	if p.Months < 1 {
+		return fmt.Errorf("months must be at least 1")
+	}
+	return nil
+}
+
+// Lot is points that expire together.
+type Lot struct {
+	Points    int64     `json:"points"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// lots replays the history of an account into the lots it still holds,
+// soonest to expire first. purchaseDates maps receipt IDs to purchase dates.
+func (p Policy) lots(account string, history []ledger.Entry, purchaseDates map[string]string) []Lot {
+	if p.Mode == ModeInactivity {
+		return p.inactivityLots(account, history)
+	}
+
+	var lots []Lot
+	var deficit int64           // spent beyond what the lots held, repaid by later credits
+	spent := map[string][]Lot{} // the lots each redemption used, by entry ID
+	for _, e := range history {
+		amount := amountFor(account, e)
+		switch {
+		case amount > 0:
+			// A refund restores the points it returns to the lots they came from
+			for _, lot := range spent[e.Reverses] {
+				restored := min(amount, lot.Points)
+				if restored == 0 {
+					break
+				}
+				lots = append(lots, Lot{Points: restored, ExpiresAt: lot.ExpiresAt})
+				amount -= restored
+			}
+			delete(spent, e.Reverses)
+			if amount == 0 {
+				continue
+			}
+			repaid := min(deficit, amount)
+			deficit -= repaid
+			if amount -= repaid; amount > 0 {
+				lots = append(lots, Lot{Points: amount, ExpiresAt: p.earned(e, purchaseDates).AddDate(0, p.Months, 0)})
+			}
+		case amount < 0:
+			sortLots(lots)
+			need := -amount
+			for len(lots) > 0 && need > 0 {
+				used := min(need, lots[0].Points)
+				if e.Reason == ledger.ReasonRedemption {
+					spent[e.ID] = append(spent[e.ID], Lot{Points: used, ExpiresAt: lots[0].ExpiresAt})
+				}
+				lots[0].Points -= used
+				need -= used
+				if lots[0].Points == 0 {
+					lots = lots[1:]
+				}
+			}
+			deficit += need
+		}
+	}
+	sortLots(lots)
+	return lots
+}
+
+// inactivityLots puts the whole balance in one lot expiring Months after the
+// account last earned or redeemed points.
+func (p Policy) inactivityLots(account string, history []ledger.Entry) []Lot {
+	var balance int64
+	var active time.Time
+	for _, e := range history {
+		balance += amountFor(account, e)
+		if e.Reason == ledger.ReasonReceipt || e.Reason == ledger.ReasonRedemption || active.IsZero() {
+			active = e.Time
+		}
+	}
+	if balance <= 0 {
+		return nil
+	}
+	return []Lot{{Points: balance, ExpiresAt: active.AddDate(0, p.Months, 0)}}
+}
+
+// earned returns when the points of a credit were earned: the purchase date
+// of its receipt, if it has one, or else when it was posted.
+func (p Policy) earned(e ledger.Entry, purchaseDates map[string]string) time.Time {
+	if date, ok := purchaseDates[e.ReceiptID]; ok && e.ReceiptID != "" {
+		if d, err := time.Parse(dateLayout, date); err == nil {
+			return d
+		}
+	}
+	return e.Time
+}
+
+func amountFor(account string, e ledger.Entry) int64 {
+	var amount int64
+	for _, posting := range e.Postings {
+		if posting.Account == account {
+			amount += posting.Amount
+		}
+	}
+	return amount
+}
+
+func sortLots(lots []Lot) {
+	sort.SliceStable(lots, func(i, j int) bool { return lots[i].ExpiresAt.Before(lots[j].ExpiresAt) })
+}
+
+// Forecast is what a user has expiring by a given time. Lots already past
+// their expiry are included until a sweep retires them.
+type Forecast struct {
+	UserID   string    `json:"userId"`
+	Balance  int64     `json:"balance"`
+	Until    time.Time `json:"until"`
+	Expiring int64     `json:"expiring"`
+	Lots     []Lot     `json:"lots"`
+}
+
+// Sweep is the outcome of one expiry sweep.
+type Sweep struct {
+	At      time.Time `json:"at"`
+	Users   int       `json:"users"`   // users checked
+	Entries int       `json:"entries"` // expiry entries posted
+	Expired int64     `json:"expired"` // points retired
+	Skipped int       `json:"skipped"` // users whose points moved mid-sweep, retried next time
+}
+
+// Sweeper applies a policy to every user's points.
+type Sweeper struct {
+	policy Policy
+	users  *users.Store
+	ledger *ledger.Ledger
+	now    func() time.Time
+	mu     sync.Mutex // one sweep at a time
+}
+
+// NewSweeper returns a sweeper applying p to the users in accounts.
+func NewSweeper(p Policy, accounts *users.Store) *Sweeper {
+	return &Sweeper{policy: p, users: accounts, ledger: accounts.Ledger(), now: time.Now}
+}
+
+// WithClock sets the clock that decides what has expired.
+func (s *Sweeper) WithClock(now func() time.Time) *Sweeper {
+	s.now = now
+	return s
+}
+
+// Policy returns the policy the sweeper applies.
+func (s *Sweeper) Policy() Policy {
+	return s.policy
+}
+
+// state returns the lots a user holds and the sequence number of the last
+// entry they were computed from.
+func (s *Sweeper) state(userID string) ([]Lot, int64, error) {
+	dates, err := s.users.PurchaseDates(userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	account := ledger.UserAccount(userID)
+	history := s.ledger.History(account)
+	var seq int64
+	if len(history) > 0 {
+		seq = history[len(history)-1].Seq
+	}
+	return s.policy.lots(account, history, dates), seq, nil
+}
+
+// Expiring returns the points a user has expiring within the given window.
+func (s *Sweeper) Expiring(userID string, within time.Duration) (Forecast, error) {
+	lots, _, err := s.state(userID)
+	if err != nil {
+		return Forecast{}, err
+	}
+	f := Forecast{UserID: userID, Until: s.now().Add(within), Lots: []Lot{}}
+	for _, lot := range lots {
+		f.Balance += lot.Points
+		if !lot.ExpiresAt.After(f.Until) {
+			f.Expiring += lot.Points
+			f.Lots = append(f.Lots, lot)
+		}
+	}
+	return f, nil
+}
+
+// Sweep posts an expiry entry for every user with points past their expiry.
+func (s *Sweeper) Sweep() Sweep {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	sweep := Sweep{At: now}
+	for _, id := range s.users.IDs() {
+		sweep.Users++
+		lots, seq, err := s.state(id)
+		if err != nil {
+			continue
+		}
+		var due int64
+		for _, lot := range lots {
+			if !lot.ExpiresAt.After(now) {
+				due += lot.Points
+			}
+		}
+		if due == 0 {
+			continue
+		}
+
+		entry := ledger.Transfer(ledger.ReasonExpiry, ledger.UserAccount(id), ledger.AccountExpired, due)
+		entry.Memo = fmt.Sprintf("%s expiry after %d months", s.policy.Mode, s.policy.Months)
+		// Post only if nothing was earned or spent since the lots were worked out
+		if _, err := s.ledger.PostIfLatest(entry, ledger.UserAccount(id), seq); err != nil {
+			if !errors.Is(err, ledger.ErrStale) && !errors.Is(err, ledger.ErrInsufficientPoints) {
+				logger.ErrorLogger.Printf("Expiring %d points for user %s: %v", due, id, err)
+			}
+			sweep.Skipped++
+			continue
+		}
+		sweep.Entries++
+		sweep.Expired += due
+		metrics.PointsExpiredTotal.Add(float64(due))
+	}
+	logger.InfoLogger.Printf("Expiry sweep: %d points expired for %d of %d users (%d skipped)", sweep.Expired, sweep.Entries, sweep.Users, sweep.Skipped)
+	return sweep
+}
+
+// Run sweeps every interval until ctx is cancelled.
+func (s *Sweeper) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Sweep()
+		}
+	}
+}
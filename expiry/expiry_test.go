@@ -0,0 +1,217 @@
+package expiry
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/suryamp/receipt-processor/ledger"
+	"github.com/suryamp/receipt-processor/logger"
+	"github.com/suryamp/receipt-processor/users"
+)
+
+func init() {
+	if err := logger.Init(); err != nil {
+		panic(err)
+	}
+}
+
+// clock is a settable time source shared by the ledger and the sweeper.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func (c *clock) set(date string) {
+	c.t, _ = time.Parse(dateLayout, date)
+}
+
+func newTestSweeper(p Policy, c *clock) (*Sweeper, *users.Store, *ledger.Ledger) {
+	l := ledger.New().WithClock(c.now)
+	accounts := users.NewStore(l)
+	return NewSweeper(p, accounts).WithClock(c.now), accounts, l
+}
+
+func TestPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		wantErr bool
+	}{
+		{"fixed", Policy{Mode: ModeFixed, Months: 12}, false},
+		{"inactivity", Policy{Mode: ModeInactivity, Months: 6}, false},
+		{"unknown mode", Policy{Mode: "never", Months: 12}, true},
+		{"no months", Policy{Mode: ModeFixed}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFixedExpiry(t *testing.T) {
+	c := &clock{}
+	c.set("2024-03-10")
+	s, accounts, l := newTestSweeper(Policy{Mode: ModeFixed, Months: 12}, c)
+	alice := ledger.UserAccount("alice")
+
+	accounts.AddReceipt("alice", users.Receipt{ID: "r1", PurchaseDate: "2024-01-05", Points: 100})
+	accounts.AddReceipt("alice", users.Receipt{ID: "r2", PurchaseDate: "2024-03-01", Points: 50})
+	c.set("2024-04-01")
+	// Spending uses the points that expire first, from r1
+	l.PostCovered(ledger.Transfer(ledger.ReasonRedemption, alice, ledger.AccountRedeemed, 30))
+
+	c.set("2024-12-10")
+	f, err := s.Expiring("alice", 30*24*time.Hour)
+	if err != nil {
+		t.Fatalf("Expiring() error = %v", err)
+	}
+	if f.Balance != 120 || f.Expiring != 70 || len(f.Lots) != 1 || f.Lots[0].ExpiresAt.Format(dateLayout) != "2025-01-05" {
+		t.Errorf("Expiring() = %+v, want 70 of 120 points expiring on 2025-01-05", f)
+	}
+	if f, _ := s.Expiring("alice", 120*24*time.Hour); f.Expiring != 120 || len(f.Lots) != 2 {
+		t.Errorf("Expiring() over 120 days = %+v, want all 120 points in 2 lots", f)
+	}
+
+	steps := []struct {
+		date        string
+		wantExpired int64
+		wantBalance int64
+	}{
+		{"2025-01-04", 0, 120},
+		{"2025-01-05", 70, 50}, // r1's points expire on the anniversary of its purchase
+		{"2025-01-06", 0, 50},  // and only once
+		{"2025-03-01", 50, 0},
+	}
+	for _, step := range steps {
+		c.set(step.date)
+		sweep := s.Sweep()
+		if sweep.Expired != step.wantExpired || sweep.Users != 1 {
+			t.Errorf("Sweep() on %s = %+v, want %d expired", step.date, sweep, step.wantExpired)
+		}
+		if b := l.Balance(alice); b != step.wantBalance {
+			t.Errorf("balance after sweeping on %s = %d, want %d", step.date, b, step.wantBalance)
+		}
+	}
+
+	if got := l.Balance(ledger.AccountExpired); got != 120 {
+		t.Errorf("expired account balance = %d, want 120", got)
+	}
+	entries, _ := l.Entries(alice, 0, 1)
+	if entries[0].Reason != ledger.ReasonExpiry {
+		t.Errorf("last entry reason = %s, want %s", entries[0].Reason, ledger.ReasonExpiry)
+	}
+	if r := l.Reconcile(); !r.OK() {
+		t.Errorf("Reconcile() = %+v, want no mismatches", r)
+	}
+}
+
+func TestInactivityExpiry(t *testing.T) {
+	c := &clock{}
+	c.set("2024-01-15")
+	s, accounts, l := newTestSweeper(Policy{Mode: ModeInactivity, Months: 6}, c)
+
+	accounts.AddReceipt("alice", users.Receipt{ID: "r1", PurchaseDate: "2024-01-15", Points: 40})
+	c.set("2024-04-15")
+	accounts.AddReceipt("alice", users.Receipt{ID: "r2", PurchaseDate: "2024-04-15", Points: 60})
+	c.set("2024-05-01")
+	// Adjustments are not activity
+	accounts.Adjust("alice", 5, "goodwill")
+
+	c.set("2024-10-01")
+	f, _ := s.Expiring("alice", 30*24*time.Hour)
+	if f.Expiring != 105 || f.Lots[0].ExpiresAt.Format(dateLayout) != "2024-10-15" {
+		t.Errorf("Expiring() = %+v, want all 105 points expiring on 2024-10-15", f)
+	}
+	if sweep := s.Sweep(); sweep.Expired != 0 {
+		t.Errorf("Sweep() before the deadline expired %d points", sweep.Expired)
+	}
+
+	c.set("2024-10-15")
+	if sweep := s.Sweep(); sweep.Expired != 105 || sweep.Entries != 1 {
+		t.Errorf("Sweep() at the deadline = %+v, want 105 expired", sweep)
+	}
+	if b := l.Balance(ledger.UserAccount("alice")); b != 0 {
+		t.Errorf("balance = %d, want 0", b)
+	}
+	if f, _ := s.Expiring("alice", 30*24*time.Hour); f.Expiring != 0 || f.Balance != 0 {
+		t.Errorf("Expiring() after the sweep = %+v, want nothing", f)
+	}
+}
+
+func TestFixedLotsDeficit(t *testing.T) {
+	p := Policy{Mode: ModeFixed, Months: 1}
+	day := func(date string) time.Time {
+		d, _ := time.Parse(dateLayout, date)
+		return d
+	}
+	account := ledger.UserAccount("alice")
+	credit := func(date string, amount int64) ledger.Entry {
+		e := ledger.Transfer(ledger.ReasonAdjustment, ledger.AccountAdjustments, account, amount)
+		e.Time = day(date)
+		return e
+	}
+
+	// A debit larger than the balance is repaid from the next credit
+	history := []ledger.Entry{credit("2024-01-01", 10), credit("2024-01-02", -25), credit("2024-01-03", 40)}
+	lots := p.lots(account, history, nil)
+	if len(lots) != 1 || lots[0].Points != 25 || !lots[0].ExpiresAt.Equal(day("2024-02-03")) {
+		t.Errorf("lots() = %+v, want 25 points expiring on 2024-02-03", lots)
+	}
+}
+
+func TestFixedLotsRefund(t *testing.T) {
+	c := &clock{}
+	c.set("2024-03-10")
+	s, accounts, l := newTestSweeper(Policy{Mode: ModeFixed, Months: 12}, c)
+	alice := ledger.UserAccount("alice")
+
+	accounts.AddReceipt("alice", users.Receipt{ID: "r1", PurchaseDate: "2024-01-05", Points: 100})
+	accounts.AddReceipt("alice", users.Receipt{ID: "r2", PurchaseDate: "2024-03-01", Points: 50})
+	c.set("2024-04-01")
+	// The redemption uses all of r1 and 20 of r2; cancelling it in June
+	// returns those points to their lots rather than making a new one
+	redemption, _ := l.PostCovered(ledger.Transfer(ledger.ReasonRedemption, alice, ledger.AccountRedeemed, 120))
+	c.set("2024-06-01")
+	if _, err := l.Refund(redemption.ID, "redemption cancelled"); err != nil {
+		t.Fatalf("Refund() error = %v", err)
+	}
+
+	lots, _, _ := s.state("alice")
+	want := []Lot{
+		{Points: 100, ExpiresAt: time.Date(2025, 1, 5, 0, 0, 0, 0, time.UTC)},
+		{Points: 50, ExpiresAt: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	if !reflect.DeepEqual(mergeLots(lots), want) {
+		t.Errorf("lots after the refund = %+v, want %+v", lots, want)
+	}
+
+	c.set("2025-01-05")
+	if sweep := s.Sweep(); sweep.Expired != 100 {
+		t.Errorf("Sweep() = %+v, want r1's 100 points expired on its own date", sweep)
+	}
+}
+
+// mergeLots adds up lots that expire at the same time.
+func mergeLots(lots []Lot) []Lot {
+	var merged []Lot
+	for _, lot := range lots {
+		if n := len(merged); n > 0 && merged[n-1].ExpiresAt.Equal(lot.ExpiresAt) {
+			merged[n-1].Points += lot.Points
+			continue
+		}
+		merged = append(merged, lot)
+	}
+	return merged
+}
+
+func TestExpiringUnknownUser(t *testing.T) {
+	s, _, _ := newTestSweeper(Policy{Mode: ModeFixed, Months: 12}, &clock{})
+	if _, err := s.Expiring("bob", time.Hour); !errors.Is(err, users.ErrNotFound) {
+		t.Errorf("Expiring() unknown user error = %v, want users.ErrNotFound", err)
+	}
+}
@@ -0,0 +1,128 @@
+// Package export writes stored receipts out for analysis: as CSV or NDJSON
+// streams, and as Parquet files partitioned by purchase month.
+//
+// Every writer takes one receipt at a time, so an export of any size needs
+// no more memory than its buffers.
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/suryamp/receipt-processor/processor"
+)
+
+// Format is an export's encoding.
+type Format string
+
+const (
+	CSV    Format = "csv"    // one row per item, with its receipt's columns repeated
+	NDJSON Format = "ndjson" // one receipt per line, items nested
+)
+
+// ParseFormat returns the format named s, NDJSON if s is empty.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case "":
+		return NDJSON, nil
+	case CSV, NDJSON:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown export format %q: use csv or ndjson", s)
+}
+
+// ContentType is the media type of the format.
+func (f Format) ContentType() string {
+	if f == CSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+// Encoder writes receipts in a format.
+type Encoder interface {
+	Encode(r processor.ExportedReceipt) error
+	// Flush writes out anything buffered.
+	Flush() error
+}
+
+// NewEncoder returns an encoder of f to w. A CSV encoder starts with its
+// header, so even an empty export has one.
+func NewEncoder(w io.Writer, f Format) Encoder {
+	if f == CSV {
+		e := &csvEncoder{w: csv.NewWriter(w)}
+		e.w.Write(CSVHeader) // an error sticks and is returned by Flush
+		return e
+	}
+	buf := bufio.NewWriter(w)
+	return &jsonEncoder{buf: buf, enc: json.NewEncoder(buf)}
+}
+
+type jsonEncoder struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+func (e *jsonEncoder) Encode(r processor.ExportedReceipt) error {
+	return e.enc.Encode(r)
+}
+
+func (e *jsonEncoder) Flush() error {
+	return e.buf.Flush()
+}
+
+// CSVHeader is the header row of a CSV export. promotion_ids lists the
+// applied campaigns separated by semicolons, and promotion_bonus is what they
+// added together.
+var CSVHeader = []string{
+	"receipt_id", "user_id", "retailer", "canonical_retailer", "purchase_date", "purchase_time", "timezone", "utc_offset", "total",
+	"submitted_at", "rules_version", "points", "base_points", "tier", "tier_bonus", "promotion_ids", "promotion_bonus",
+	"item_index", "item_description", "item_price",
+}
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func (e *csvEncoder) Encode(r processor.ExportedReceipt) error {
+	ids, bonus := promotions(r)
+	receipt := []string{
+		r.ID, r.UserID, r.Retailer, r.CanonicalRetailer, r.PurchaseDate, r.PurchaseTime, r.Timezone, r.UTCOffset, r.Total,
+		r.SubmittedAt.UTC().Format(time.RFC3339), r.RulesVersion,
+		strconv.FormatInt(r.Points, 10), strconv.FormatInt(r.BasePoints, 10), r.Tier, strconv.FormatInt(r.TierBonus, 10),
+		ids, strconv.FormatInt(bonus, 10),
+	}
+	if len(r.Items) == 0 {
+		return e.w.Write(append(receipt, "", "", ""))
+	}
+	for i, item := range r.Items {
+		row := append(receipt[:len(receipt):len(receipt)], strconv.Itoa(i), item.ShortDescription, item.Price)
+		if err := e.w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// promotions returns the IDs of the campaigns applied to r, separated by
+// semicolons, and the points they added.
+func promotions(r processor.ExportedReceipt) (string, int64) {
+	ids := make([]string, len(r.Promotions))
+	var bonus int64
+	for i, p := range r.Promotions {
+		ids[i] = p.ID
+		bonus += p.Bonus
+	}
+	return strings.Join(ids, ";"), bonus
+}
+
+func (e *csvEncoder) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
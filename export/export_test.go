@@ -0,0 +1,242 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/suryamp/receipt-processor/models"
+	"github.com/suryamp/receipt-processor/processor"
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+var submitted = time.Date(2024, 2, 1, 12, 0, 0, 0, time.UTC)
+
+func receipt(id, date string, items ...models.Item) processor.ExportedReceipt {
+	return processor.ExportedReceipt{
+		ID:                id,
+		UserID:            "u1",
+		Retailer:          "Target #12",
+		CanonicalRetailer: "Target",
+		PurchaseDate:      date,
+		PurchaseTime:      "13:01",
+		Timezone:          "America/Chicago",
+		UTCOffset:         "-06:00",
+		Total:             "10.50",
+		Items:             items,
+		SubmittedAt:       submitted,
+		RulesVersion:      "v1",
+		Points:            30,
+		BasePoints:        25,
+		Tier:              "gold",
+		TierBonus:         5,
+		Promotions:        []models.AppliedPromotion{{ID: "p1", Name: "Bread week", Bonus: 3}, {ID: "p2", Name: "Target", Bonus: 2}},
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Format
+		wantErr bool
+	}{
+		{"", NDJSON, false},
+		{"csv", CSV, false},
+		{"ndjson", NDJSON, false},
+		{"xml", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseFormat(tt.in)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("ParseFormat(%q) = %q, %v, want %q, error %t", tt.in, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCSVEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf, CSV)
+	items := []models.Item{{ShortDescription: "Milk, 1 GAL", Price: "4.00"}, {ShortDescription: "Bread", Price: "6.50"}}
+	if err := enc.Encode(receipt("r1", "2024-01-31", items...)); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(receipt("r2", "2024-02-01")); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Join(CSVHeader, ",") + "\n" +
+		`r1,u1,Target #12,Target,2024-01-31,13:01,America/Chicago,-06:00,10.50,2024-02-01T12:00:00Z,v1,30,25,gold,5,p1;p2,5,0,"Milk, 1 GAL",4.00` + "\n" +
+		`r1,u1,Target #12,Target,2024-01-31,13:01,America/Chicago,-06:00,10.50,2024-02-01T12:00:00Z,v1,30,25,gold,5,p1;p2,5,1,Bread,6.50` + "\n" +
+		`r2,u1,Target #12,Target,2024-02-01,13:01,America/Chicago,-06:00,10.50,2024-02-01T12:00:00Z,v1,30,25,gold,5,p1;p2,5,,,` + "\n"
+	if buf.String() != want {
+		t.Errorf("csv =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestEmptyCSVHasHeader(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf, CSV).Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Join(CSVHeader, ",") + "\n"; buf.String() != want {
+		t.Errorf("csv = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestNDJSONEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf, NDJSON)
+	for _, id := range []string{"r1", "r2"} {
+		if err := enc.Encode(receipt(id, "2024-01-31", models.Item{ShortDescription: "Bread", Price: "6.50"})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2:\n%s", len(lines), buf.String())
+	}
+	var got processor.ExportedReceipt
+	if err := json.Unmarshal([]byte(lines[1]), &got); err != nil {
+		t.Fatal(err)
+	}
+	if want := receipt("r2", "2024-01-31", models.Item{ShortDescription: "Bread", Price: "6.50"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("line 2 = %+v, want %+v", got, want)
+	}
+}
+
+func TestParquetWriter(t *testing.T) {
+	dir := t.TempDir()
+	w := NewParquetWriter(dir).WithRowGroupSize(1)
+	bread := models.Item{ShortDescription: "Bread", Price: "6.50"}
+	for _, r := range []processor.ExportedReceipt{
+		receipt("r1", "2024-01-31", bread, bread),
+		receipt("r2", "2024-02-01", bread),
+		receipt("r3", "2024-01-01", bread),
+	} {
+		if err := w.Write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := w.Months(), []string{"2024-01", "2024-02"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Months() = %v, want %v", got, want)
+	}
+	if w.Receipts() != 3 {
+		t.Errorf("Receipts() = %d, want 3", w.Receipts())
+	}
+	for _, table := range []string{"receipts", "items"} {
+		for _, month := range w.Months() {
+			path := filepath.Join(dir, table, "purchase_month="+month, "part-0.parquet")
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) {
+				t.Errorf("%s is not a Parquet file", path)
+			}
+		}
+	}
+	if err := w.Write(receipt("r4", "2024-01-01")); err == nil {
+		t.Error("Write after Close succeeded")
+	}
+}
+
+func TestParquetWriterClosesMonths(t *testing.T) {
+	dir := t.TempDir()
+	w := NewParquetWriter(dir).WithOpenMonths(1)
+	bread := models.Item{ShortDescription: "Bread", Price: "6.50"}
+	// January is closed when February starts, so its last receipt goes to a second part
+	for _, r := range []processor.ExportedReceipt{
+		receipt("r1", "2024-01-31", bread),
+		receipt("r2", "2024-02-01", bread),
+		receipt("r3", "2024-01-01", bread, bread),
+	} {
+		if err := w.Write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(w.open) != 1 {
+		t.Errorf("%d months open, want 1", len(w.open))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		table, month, part string
+		rows               int64
+	}{
+		{"receipts", "2024-01", "part-0", 1},
+		{"receipts", "2024-01", "part-1", 1},
+		{"receipts", "2024-02", "part-0", 1},
+		{"items", "2024-01", "part-0", 1},
+		{"items", "2024-01", "part-1", 2},
+		{"items", "2024-02", "part-0", 1},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.table, "purchase_month="+tt.month, tt.part+".parquet")
+		if rows := countRows(t, path); rows != tt.rows {
+			t.Errorf("%s has %d rows, want %d", path, rows, tt.rows)
+		}
+	}
+	if w.Parts("2024-01") != 2 || w.Parts("2024-02") != 1 {
+		t.Errorf("Parts() = %d and %d, want 2 and 1", w.Parts("2024-01"), w.Parts("2024-02"))
+	}
+}
+
+// countRows reads the number of rows in a Parquet file.
+func countRows(t *testing.T, path string) int64 {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := buffer.NewBufferFile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, err := reader.NewParquetColumnReader(file, 1)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	defer pr.ReadStop()
+	return pr.GetNumRows()
+}
+
+func TestParquetWriterRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		r    processor.ExportedReceipt
+	}{
+		{"bad date", receipt("r1", "01/31/2024")},
+		{"bad total", func() processor.ExportedReceipt { r := receipt("r1", "2024-01-31"); r.Total = "ten"; return r }()},
+		{"bad price", receipt("r1", "2024-01-31", models.Item{ShortDescription: "Bread", Price: "free"})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewParquetWriter(t.TempDir())
+			defer w.Close()
+			if err := w.Write(tt.r); err == nil {
+				t.Error("Write succeeded")
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/suryamp/receipt-processor/internal/parquet"
+	"github.com/suryamp/receipt-processor/items"
+	"github.com/suryamp/receipt-processor/processor"
+)
+
+// ReceiptColumns are the columns of the receipts table: one row per receipt.
+var ReceiptColumns = []parquet.Column{
+	{Name: "receipt_id", Kind: parquet.String},
+	{Name: "user_id", Kind: parquet.String},
+	{Name: "retailer", Kind: parquet.String},
+	{Name: "canonical_retailer", Kind: parquet.String},
+	{Name: "purchase_date", Kind: parquet.Date},
+	{Name: "purchase_time", Kind: parquet.String},
+	{Name: "timezone", Kind: parquet.String},
+	{Name: "utc_offset", Kind: parquet.String},
+	{Name: "total", Kind: parquet.Cents},
+	{Name: "item_count", Kind: parquet.Int64},
+	{Name: "submitted_at", Kind: parquet.Timestamp},
+	{Name: "rules_version", Kind: parquet.String},
+	{Name: "points", Kind: parquet.Int64},
+	{Name: "base_points", Kind: parquet.Int64},
+	{Name: "tier", Kind: parquet.String},
+	{Name: "tier_bonus", Kind: parquet.Int64},
+	{Name: "promotion_ids", Kind: parquet.String},
+	{Name: "promotion_bonus", Kind: parquet.Int64},
+}
+
+// ItemColumns are the columns of the items table: one row per item, joined
+// to its receipt by receipt_id.
+var ItemColumns = []parquet.Column{
+	{Name: "receipt_id", Kind: parquet.String},
+	{Name: "purchase_date", Kind: parquet.Date},
+	{Name: "canonical_retailer", Kind: parquet.String},
+	{Name: "item_index", Kind: parquet.Int64},
+	{Name: "short_description", Kind: parquet.String},
+	{Name: "normalized_description", Kind: parquet.String},
+	{Name: "price", Kind: parquet.Cents},
+}
+
+// DefaultOpenMonths is how many purchase months a ParquetWriter keeps files
+// open for at once.
+const DefaultOpenMonths = 12
+
+// partition is the open files of one purchase month.
+type partition struct {
+	files    []*os.File
+	receipts *parquet.Writer
+	items    *parquet.Writer
+	used     int64 // when it was last written to, in receipts written
+}
+
+// close finishes the partition's files, returning any errors.
+func (part *partition) close() error {
+	errs := []error{part.receipts.Close(), part.items.Close()}
+	for _, f := range part.files {
+		errs = append(errs, f.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// ParquetWriter writes receipts to Parquet files under a directory, in a
+// receipts table and an items table, each partitioned by purchase month the
+// way Hive, Spark and DuckDB expect:
+//
+//	receipts/purchase_month=2024-01/part-0.parquet
+//	items/purchase_month=2024-01/part-0.parquet
+//
+// Files stay open, holding up to a row group of rows each, for at most
+// DefaultOpenMonths months at a time. Writing to another month closes the
+// files of the one written to least recently; if that month comes up again
+// its later receipts go to part-1, part-2 and so on. Existing files of the
+// same names are replaced, so write to an empty directory.
+type ParquetWriter struct {
+	dir          string
+	rowGroupSize int
+	openMonths   int
+	open         map[string]*partition // by purchase month
+	parts        map[string]int        // files started, by purchase month
+	receipts     int64
+	closed       bool
+}
+
+// NewParquetWriter returns a writer of partitioned files under dir.
+func NewParquetWriter(dir string) *ParquetWriter {
+	return &ParquetWriter{
+		dir:          dir,
+		rowGroupSize: parquet.DefaultRowGroupSize,
+		openMonths:   DefaultOpenMonths,
+		open:         map[string]*partition{},
+		parts:        map[string]int{},
+	}
+}
+
+// WithRowGroupSize sets the number of rows per row group in every file.
+func (w *ParquetWriter) WithRowGroupSize(n int) *ParquetWriter {
+	w.rowGroupSize = n
+	return w
+}
+
+// WithOpenMonths sets how many months' files are kept open at once.
+func (w *ParquetWriter) WithOpenMonths(n int) *ParquetWriter {
+	w.openMonths = n
+	return w
+}
+
+// Write adds a receipt and its items to its purchase month's files.
+func (w *ParquetWriter) Write(r processor.ExportedReceipt) error {
+	if w.closed {
+		return errors.New("export: write after close")
+	}
+	date, err := time.Parse(time.DateOnly, r.PurchaseDate)
+	if err != nil {
+		return fmt.Errorf("receipt %s: purchase date %q is not YYYY-MM-DD", r.ID, r.PurchaseDate)
+	}
+	total, err := cents(r.Total)
+	if err != nil {
+		return fmt.Errorf("receipt %s: total: %w", r.ID, err)
+	}
+	prices := make([]int64, len(r.Items))
+	for i, item := range r.Items {
+		if prices[i], err = cents(item.Price); err != nil {
+			return fmt.Errorf("receipt %s: item %d: price: %w", r.ID, i, err)
+		}
+	}
+	part, err := w.partition(date.Format("2006-01"))
+	if err != nil {
+		return err
+	}
+
+	ids, bonus := promotions(r)
+	err = part.receipts.Write(r.ID, r.UserID, r.Retailer, r.CanonicalRetailer, date, r.PurchaseTime, r.Timezone, r.UTCOffset, total,
+		int64(len(r.Items)), r.SubmittedAt, r.RulesVersion, r.Points, r.BasePoints, r.Tier, r.TierBonus, ids, bonus)
+	if err != nil {
+		return fmt.Errorf("receipt %s: %w", r.ID, err)
+	}
+	for i, item := range r.Items {
+		err = part.items.Write(r.ID, date, r.CanonicalRetailer, int64(i), item.ShortDescription, items.Normalize(item.ShortDescription), prices[i])
+		if err != nil {
+			return fmt.Errorf("receipt %s: item %d: %w", r.ID, i, err)
+		}
+	}
+	w.receipts++
+	part.used = w.receipts
+	return nil
+}
+
+// partition returns the open files of month, starting the month's next
+// part if none are open.
+func (w *ParquetWriter) partition(month string) (*partition, error) {
+	if part, ok := w.open[month]; ok {
+		return part, nil
+	}
+	if len(w.open) >= w.openMonths {
+		if err := w.closeLeastRecent(); err != nil {
+			return nil, err
+		}
+	}
+	part := &partition{}
+	fail := func(err error) (*partition, error) {
+		for _, f := range part.files {
+			f.Close()
+		}
+		return nil, err
+	}
+	name := fmt.Sprintf("part-%d.parquet", w.parts[month])
+	for _, table := range []struct {
+		name    string
+		columns []parquet.Column
+		writer  **parquet.Writer
+	}{
+		{"receipts", ReceiptColumns, &part.receipts},
+		{"items", ItemColumns, &part.items},
+	} {
+		dir := filepath.Join(w.dir, table.name, "purchase_month="+month)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fail(err)
+		}
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			return fail(err)
+		}
+		part.files = append(part.files, f)
+		pw, err := parquet.NewWriter(f, table.columns)
+		if err != nil {
+			return fail(err)
+		}
+		*table.writer = pw.WithRowGroupSize(w.rowGroupSize)
+	}
+	w.open[month] = part
+	w.parts[month]++
+	return part, nil
+}
+
+// closeLeastRecent finishes the files of the open month written to least
+// recently.
+func (w *ParquetWriter) closeLeastRecent() error {
+	var oldest string
+	for month, part := range w.open {
+		if oldest == "" || part.used < w.open[oldest].used {
+			oldest = month
+		}
+	}
+	part := w.open[oldest]
+	delete(w.open, oldest)
+	if err := part.close(); err != nil {
+		return fmt.Errorf("purchase month %s: %w", oldest, err)
+	}
+	return nil
+}
+
+// Close finishes every open file, returning any errors.
+func (w *ParquetWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	var errs []error
+	for _, part := range w.open {
+		errs = append(errs, part.close())
+	}
+	return errors.Join(errs...)
+}
+
+// Months returns the purchase months written, in order.
+func (w *ParquetWriter) Months() []string {
+	months := make([]string, 0, len(w.parts))
+	for month := range w.parts {
+		months = append(months, month)
+	}
+	sort.Strings(months)
+	return months
+}
+
+// Parts returns how many parts of each table month was written to.
+func (w *ParquetWriter) Parts(month string) int {
+	return w.parts[month]
+}
+
+// Receipts returns how many receipts have been written.
+func (w *ParquetWriter) Receipts() int64 {
+	return w.receipts
+}
+
+// cents parses an amount in dollars, as on a receipt.
+func cents(amount string) (int64, error) {
+	f, err := strconv.ParseFloat(amount, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not an amount", amount)
+	}
+	return int64(math.Round(f * 100)), nil
+}
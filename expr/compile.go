@@ -0,0 +1,462 @@
+package expr
+
+import (
+	"errors"
+	"fmt"
+)
+
+// evalFn evaluates a compiled node.
+type evalFn func(s *state) (any, error)
+
+type binding struct {
+	typ  *Type
+	slot int
+}
+
+// compiler type checks nodes and turns them into closures.
+type compiler struct {
+	scope map[string]binding
+	slots int
+}
+
+func errorAt(n node, format string, args ...any) error {
+	return fmt.Errorf("%d: %s", n.position()+1, fmt.Sprintf(format, args...))
+}
+
+// errDivision is returned when an integer is divided by zero.
+var errDivision = errors.New("integer division by zero")
+
+func (c *compiler) compile(n node) (*Type, evalFn, error) {
+	switch n := n.(type) {
+	case *literalNode:
+		return c.literal(n)
+	case *identNode:
+		b, ok := c.scope[n.name]
+		if !ok {
+			return nil, nil, errorAt(n, "undeclared variable %s", n.name)
+		}
+		return b.typ, func(s *state) (any, error) {
+			return s.vars[b.slot], s.step()
+		}, nil
+	case *unaryNode:
+		return c.unary(n)
+	case *binaryNode:
+		return c.binary(n)
+	case *condNode:
+		return c.cond(n)
+	case *selectNode:
+		return c.selectField(n)
+	case *indexNode:
+		return c.index(n)
+	case *listNode:
+		return c.list(n)
+	case *callNode:
+		return c.call(n)
+	}
+	return nil, nil, errorAt(n, "unsupported expression")
+}
+
+func (c *compiler) literal(n *literalNode) (*Type, evalFn, error) {
+	var typ *Type
+	switch n.val.(type) {
+	case int64:
+		typ = Int
+	case float64:
+		typ = Double
+	case string:
+		typ = String
+	case bool:
+		typ = Bool
+	}
+	val := n.val
+	return typ, func(s *state) (any, error) { return val, s.step() }, nil
+}
+
+func (c *compiler) unary(n *unaryNode) (*Type, evalFn, error) {
+	typ, x, err := c.compile(n.x)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch {
+	case n.op == "!" && typ.Kind == KindBool:
+		return Bool, func(s *state) (any, error) {
+			v, err := eval(s, x)
+			return !v.(bool), err
+		}, nil
+	case n.op == "-" && typ.Kind == KindInt:
+		return Int, func(s *state) (any, error) {
+			v, err := eval(s, x)
+			return -v.(int64), err
+		}, nil
+	case n.op == "-" && typ.Kind == KindDouble:
+		return Double, func(s *state) (any, error) {
+			v, err := eval(s, x)
+			return -v.(float64), err
+		}, nil
+	}
+	return nil, nil, errorAt(n, "operator %s does not apply to %s", n.op, typ)
+}
+
+func (c *compiler) binary(n *binaryNode) (*Type, evalFn, error) {
+	xt, x, err := c.compile(n.x)
+	if err != nil {
+		return nil, nil, err
+	}
+	yt, y, err := c.compile(n.y)
+	if err != nil {
+		return nil, nil, err
+	}
+	mismatch := errorAt(n, "operator %s does not apply to %s and %s", n.op, xt, yt)
+
+	switch n.op {
+	case "&&", "||":
+		if xt.Kind != KindBool || yt.Kind != KindBool {
+			return nil, nil, mismatch
+		}
+		and := n.op == "&&"
+		return Bool, func(s *state) (any, error) {
+			v, err := eval(s, x)
+			if err != nil || v.(bool) != and {
+				return v, err
+			}
+			return eval(s, y)
+		}, nil
+
+	case "+", "-", "*", "/", "%":
+		if n.op == "+" && xt.Kind == KindString && yt.Kind == KindString {
+			return String, func(s *state) (any, error) {
+				a, b, err := eval2(s, x, y)
+				if err != nil {
+					return nil, err
+				}
+				joined := a.(string) + b.(string)
+				return joined, s.alloc(int64(len(joined)))
+			}, nil
+		}
+		if n.op == "+" && xt.Kind == KindList && xt.equal(yt) {
+			typ := xt
+			if typ.Elem == nil {
+				typ = yt
+			}
+			return typ, func(s *state) (any, error) {
+				a, b, err := eval2(s, x, y)
+				if err != nil {
+					return nil, err
+				}
+				la, lb := a.([]any), b.([]any)
+				if err := s.alloc(int64(len(la)+len(lb)) * listElemBytes); err != nil {
+					return nil, err
+				}
+				return append(append(make([]any, 0, len(la)+len(lb)), la...), lb...), nil
+			}, nil
+		}
+		if !xt.numeric() || !yt.numeric() {
+			return nil, nil, mismatch
+		}
+		return arithmetic(n, xt, yt, x, y)
+
+	case "==", "!=":
+		if !(xt.numeric() && yt.numeric()) && !(xt.equal(yt) && (xt.Kind == KindString || xt.Kind == KindBool)) {
+			return nil, nil, mismatch
+		}
+		eq := n.op == "=="
+		return Bool, func(s *state) (any, error) {
+			a, b, err := eval2(s, x, y)
+			if err != nil {
+				return nil, err
+			}
+			return equalValues(a, b) == eq, nil
+		}, nil
+
+	case "<", "<=", ">", ">=":
+		if !(xt.numeric() && yt.numeric()) && !(xt.Kind == KindString && yt.Kind == KindString) {
+			return nil, nil, mismatch
+		}
+		op := n.op
+		return Bool, func(s *state) (any, error) {
+			a, b, err := eval2(s, x, y)
+			if err != nil {
+				return nil, err
+			}
+			cmp := compareValues(a, b)
+			switch op {
+			case "<":
+				return cmp < 0, nil
+			case "<=":
+				return cmp <= 0, nil
+			case ">":
+				return cmp > 0, nil
+			}
+			return cmp >= 0, nil
+		}, nil
+
+	case "in":
+		if yt.Kind != KindList || (yt.Elem != nil && !(yt.Elem.numeric() && xt.numeric()) && !yt.Elem.equal(xt)) {
+			return nil, nil, mismatch
+		}
+		if xt.Kind == KindList || xt.Kind == KindObject {
+			return nil, nil, mismatch
+		}
+		return Bool, func(s *state) (any, error) {
+			a, b, err := eval2(s, x, y)
+			if err != nil {
+				return nil, err
+			}
+			for _, elem := range b.([]any) {
+				if err := s.step(); err != nil {
+					return nil, err
+				}
+				if equalValues(a, elem) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}, nil
+	}
+	return nil, nil, errorAt(n, "unknown operator %s", n.op)
+}
+
+func arithmetic(n *binaryNode, xt, yt *Type, x, y evalFn) (*Type, evalFn, error) {
+	op := n.op
+	if xt.Kind == KindInt && yt.Kind == KindInt {
+		return Int, func(s *state) (any, error) {
+			a, b, err := eval2(s, x, y)
+			if err != nil {
+				return nil, err
+			}
+			i, j := a.(int64), b.(int64)
+			switch op {
+			case "+":
+				return i + j, nil
+			case "-":
+				return i - j, nil
+			case "*":
+				return i * j, nil
+			}
+			if j == 0 {
+				return nil, errDivision
+			}
+			if op == "/" {
+				return i / j, nil
+			}
+			return i % j, nil
+		}, nil
+	}
+	if op == "%" {
+		return nil, nil, errorAt(n, "operator %% needs int operands, not %s and %s", xt, yt)
+	}
+	return Double, func(s *state) (any, error) {
+		a, b, err := eval2(s, x, y)
+		if err != nil {
+			return nil, err
+		}
+		f, g := toDouble(a), toDouble(b)
+		switch op {
+		case "+":
+			return f + g, nil
+		case "-":
+			return f - g, nil
+		case "*":
+			return f * g, nil
+		}
+		return f / g, nil
+	}, nil
+}
+
+func (c *compiler) cond(n *condNode) (*Type, evalFn, error) {
+	ct, cond, err := c.compile(n.c)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ct.Kind != KindBool {
+		return nil, nil, errorAt(n, "condition has type %s, want bool", ct)
+	}
+	tt, t, err := c.compile(n.t)
+	if err != nil {
+		return nil, nil, err
+	}
+	ft, f, err := c.compile(n.f)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	typ := tt
+	switch {
+	case tt.numeric() && ft.numeric() && tt.Kind != ft.Kind:
+		typ = Double
+		t, f = promote(t, tt), promote(f, ft)
+	case !tt.equal(ft):
+		return nil, nil, errorAt(n, "branches have types %s and %s", tt, ft)
+	case tt.Kind == KindList && tt.Elem == nil:
+		typ = ft
+	}
+	return typ, func(s *state) (any, error) {
+		v, err := eval(s, cond)
+		if err != nil {
+			return nil, err
+		}
+		if v.(bool) {
+			return eval(s, t)
+		}
+		return eval(s, f)
+	}, nil
+}
+
+func (c *compiler) selectField(n *selectNode) (*Type, evalFn, error) {
+	xt, x, err := c.compile(n.x)
+	if err != nil {
+		return nil, nil, err
+	}
+	if xt.Kind != KindObject {
+		return nil, nil, errorAt(n, "%s has no fields", xt)
+	}
+	ft, ok := xt.Fields[n.field]
+	if !ok {
+		return nil, nil, errorAt(n, "%s has no field %s (fields: %s)", xt, n.field, xt.fieldNames())
+	}
+	field := n.field
+	return ft, func(s *state) (any, error) {
+		v, err := eval(s, x)
+		if err != nil {
+			return nil, err
+		}
+		return v.(map[string]any)[field], nil
+	}, nil
+}
+
+func (c *compiler) index(n *indexNode) (*Type, evalFn, error) {
+	xt, x, err := c.compile(n.x)
+	if err != nil {
+		return nil, nil, err
+	}
+	it, i, err := c.compile(n.i)
+	if err != nil {
+		return nil, nil, err
+	}
+	if xt.Kind != KindList || xt.Elem == nil || it.Kind != KindInt {
+		return nil, nil, errorAt(n, "cannot index %s with %s", xt, it)
+	}
+	return xt.Elem, func(s *state) (any, error) {
+		list, idx, err := eval2(s, x, i)
+		if err != nil {
+			return nil, err
+		}
+		l, k := list.([]any), idx.(int64)
+		if k < 0 || k >= int64(len(l)) {
+			return nil, fmt.Errorf("index %d out of range for list of %d", k, len(l))
+		}
+		return l[k], nil
+	}, nil
+}
+
+func (c *compiler) list(n *listNode) (*Type, evalFn, error) {
+	var elem *Type
+	elems := make([]evalFn, len(n.elems))
+	types := make([]*Type, len(n.elems))
+	for i, e := range n.elems {
+		t, fn, err := c.compile(e)
+		if err != nil {
+			return nil, nil, err
+		}
+		elems[i], types[i] = fn, t
+		switch {
+		case elem == nil:
+			elem = t
+		case elem.numeric() && t.numeric():
+			if t.Kind == KindDouble {
+				elem = Double
+			}
+		case !elem.equal(t):
+			return nil, nil, errorAt(e, "list mixes %s and %s", elem, t)
+		}
+	}
+	if elem != nil && elem.Kind == KindDouble {
+		for i := range elems {
+			elems[i] = promote(elems[i], types[i])
+		}
+	}
+	return ListOf(elem), func(s *state) (any, error) {
+		if err := s.alloc(int64(len(elems)) * listElemBytes); err != nil {
+			return nil, err
+		}
+		out := make([]any, len(elems))
+		for i, e := range elems {
+			v, err := eval(s, e)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = v
+		}
+		return out, nil
+	}, nil
+}
+
+// eval charges a step and evaluates fn.
+func eval(s *state, fn evalFn) (any, error) {
+	if err := s.step(); err != nil {
+		return nil, err
+	}
+	return fn(s)
+}
+
+func eval2(s *state, x, y evalFn) (any, any, error) {
+	a, err := eval(s, x)
+	if err != nil {
+		return nil, nil, err
+	}
+	b, err := eval(s, y)
+	return a, b, err
+}
+
+// promote converts the int results of fn to double.
+func promote(fn evalFn, typ *Type) evalFn {
+	if typ.Kind != KindInt {
+		return fn
+	}
+	return func(s *state) (any, error) {
+		v, err := fn(s)
+		if err != nil {
+			return nil, err
+		}
+		return float64(v.(int64)), nil
+	}
+}
+
+func toDouble(v any) float64 {
+	if i, ok := v.(int64); ok {
+		return float64(i)
+	}
+	return v.(float64)
+}
+
+func equalValues(a, b any) bool {
+	switch a.(type) {
+	case int64, float64:
+		if _, ok := b.(string); ok {
+			return false
+		}
+		return toDouble(a) == toDouble(b)
+	}
+	return a == b
+}
+
+func compareValues(a, b any) int {
+	if sa, ok := a.(string); ok {
+		sb := b.(string)
+		switch {
+		case sa < sb:
+			return -1
+		case sa > sb:
+			return 1
+		}
+		return 0
+	}
+	fa, fb := toDouble(a), toDouble(b)
+	switch {
+	case fa < fb:
+		return -1
+	case fa > fb:
+		return 1
+	}
+	return 0
+}
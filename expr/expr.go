@@ -0,0 +1,134 @@
+// Package expr is a small, sandboxed expression language for scoring rules,
+// modelled on CEL. Expressions are type checked against declared variables
+// when compiled, have no access to anything but those variables, and are
+// evaluated under step, memory and time limits.
+//
+//	receipt.weekday in [0, 6] ? 5 * size(receipt.items.filter(i, i.price > 10.0)) : 0
+//
+// The language has int, double, string, bool, lists and read-only objects;
+// arithmetic, comparison and logical operators with ints promoted to
+// doubles where they meet; the ternary operator; list literals, indexing and
+// "in"; the list macros filter, map, exists and all; and the functions
+// listed in functions.go.
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// MaxSourceLength bounds the length of an expression.
+const MaxSourceLength = 4096
+
+var (
+	ErrStepLimit   = errors.New("expression exceeded its step limit")
+	ErrMemoryLimit = errors.New("expression exceeded its memory limit")
+	ErrTimeout     = errors.New("expression exceeded its time limit")
+)
+
+// Limits bounds one evaluation. A zero field is not enforced.
+type Limits struct {
+	MaxSteps       int64         `yaml:"maxSteps" json:"maxSteps"`
+	MaxMemoryBytes int64         `yaml:"maxMemoryBytes" json:"maxMemoryBytes"`
+	Timeout        time.Duration `yaml:"timeout" json:"timeout"`
+}
+
+var DefaultLimits = Limits{
+	MaxSteps:       10000,
+	MaxMemoryBytes: 64 << 10,
+	Timeout:        5 * time.Millisecond,
+}
+
+// Program is a compiled, type checked expression. It is safe for
+// concurrent use.
+type Program struct {
+	Type  *Type
+	eval  evalFn
+	vars  []string // variable names by slot
+	slots int
+}
+
+// Compile parses and type checks src against the declared variables and
+// requires its result to have type want, if want is not nil.
+func Compile(src string, vars map[string]*Type, want *Type) (*Program, error) {
+	if len(src) > MaxSourceLength {
+		return nil, fmt.Errorf("expression longer than %d characters", MaxSourceLength)
+	}
+	n, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &compiler{scope: map[string]binding{}}
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c.scope[name] = binding{typ: vars[name], slot: c.slots}
+		c.slots++
+	}
+
+	typ, eval, err := c.compile(n)
+	if err != nil {
+		return nil, err
+	}
+	if want != nil && !typ.equal(want) {
+		return nil, fmt.Errorf("expression has type %s, want %s", typ, want)
+	}
+	return &Program{Type: typ, eval: eval, vars: names, slots: c.slots}, nil
+}
+
+// Eval runs the program with the given variable values under limits.
+// Values must match the declared types.
+func (p *Program) Eval(vars map[string]any, limits Limits) (any, error) {
+	s := &state{vars: make([]any, p.slots), limits: limits}
+	for i, name := range p.vars {
+		v, ok := vars[name]
+		if !ok {
+			return nil, fmt.Errorf("variable %s has no value", name)
+		}
+		s.vars[i] = v
+	}
+	if limits.Timeout > 0 {
+		s.deadline = time.Now().Add(limits.Timeout)
+	}
+	return p.eval(s)
+}
+
+// state is the mutable part of one evaluation.
+type state struct {
+	vars     []any
+	limits   Limits
+	steps    int64
+	memory   int64
+	deadline time.Time
+}
+
+// step charges one unit of work, checking the clock every so often since
+// reading it costs more than most steps.
+func (s *state) step() error {
+	s.steps++
+	if s.limits.MaxSteps > 0 && s.steps > s.limits.MaxSteps {
+		return ErrStepLimit
+	}
+	if s.steps%256 == 0 && !s.deadline.IsZero() && time.Now().After(s.deadline) {
+		return ErrTimeout
+	}
+	return nil
+}
+
+// alloc charges bytes of memory allocated by the expression.
+func (s *state) alloc(bytes int64) error {
+	s.memory += bytes
+	if s.limits.MaxMemoryBytes > 0 && s.memory > s.limits.MaxMemoryBytes {
+		return ErrMemoryLimit
+	}
+	return nil
+}
+
+// listElemBytes is the memory charged per list element.
+const listElemBytes = 16
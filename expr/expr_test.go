@@ -0,0 +1,155 @@
+package expr
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var itemType = Object("item", map[string]*Type{"description": String, "price": Double})
+
+var testVars = map[string]*Type{
+	"weekday": Int,
+	"name":    String,
+	"items":   ListOf(itemType),
+}
+
+func testValues() map[string]any {
+	item := func(desc string, price float64) any {
+		return map[string]any{"description": desc, "price": price}
+	}
+	return map[string]any{
+		"weekday": int64(6),
+		"name":    "Target",
+		"items":   []any{item("Gatorade", 12.5), item("Chips", 3.25), item("Gatorade Zero", 10.0)},
+	}
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		src  string
+		want any
+	}{
+		{"1 + 2 * 3", int64(7)},
+		{"(1 + 2) * 3", int64(9)},
+		{"7 / 2", int64(3)},
+		{"7 % 2", int64(1)},
+		{"7 / 2.0", 3.5},
+		{"-weekday + 1", int64(-5)},
+		{"1 < 2 && !(2 < 1)", true},
+		{"false || weekday == 6", true},
+		{"weekday in [0, 6]", true},
+		{"weekday in [1.5, 2.0]", false},
+		{"weekday == 6.0", true},
+		{"name + '!'", "Target!"},
+		{"name.lower().startsWith(\"tar\")", true},
+		{"name.contains('rg') ? 1 : 2.5", 1.0},
+		{"size(name) + name.size()", int64(12)},
+		{"size(items.filter(i, i.price > 10))", int64(1)},
+		{"size(items.filter(i, i.price >= 10))", int64(2)},
+		{"items.map(i, i.description.size())", []any{int64(8), int64(5), int64(13)}},
+		{"items.exists(i, i.description.contains('Zero'))", true},
+		{"items.all(i, i.price > 5)", false},
+		{"sum(items.map(i, i.price))", 25.75},
+		{"sum([1, 2, 3])", int64(6)},
+		{"ceil(items[1].price)", int64(4)},
+		{"floor(3.9) + round(2.5) + int(-2.7)", int64(4)},
+		{"min(3, 2.5) + max(1, 2)", 4.5},
+		{"abs(-3)", int64(3)},
+		{"[] + [1]", []any{int64(1)}},
+		{"weekday in [0, 6] ? 5 * size(items.filter(i, i.price > 10.0)) : 0", int64(5)},
+		{"items.filter(i, i.price > 10).map(i, items.exists(j, j.price > i.price))", []any{false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			p, err := Compile(tt.src, testVars, nil)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			got, err := p.Eval(testValues(), DefaultLimits)
+			if err != nil {
+				t.Fatalf("Eval() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Eval() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		src     string
+		want    *Type
+		wantErr string
+	}{
+		{"1 +", nil, "unexpected end of expression"},
+		{"weekday @ 2", nil, "unexpected character"},
+		{"'open", nil, "unterminated string"},
+		{"total > 5", nil, "undeclared variable total"},
+		{"items[0].prize", nil, "item has no field prize"},
+		{"name + 1", nil, "operator + does not apply to string and int"},
+		{"weekday && true", nil, "operator && does not apply"},
+		{"1.5 % 2", nil, "needs int operands"},
+		{"weekday ? 1 : 2", nil, "condition has type int"},
+		{"true ? 'a' : 1", nil, "branches have types"},
+		{"[1, 'a']", nil, "list mixes"},
+		{"items.filter(i, i.price)", nil, "needs a bool expression"},
+		{"items.filter(1, true)", nil, "must be a variable name"},
+		{"name.reverse()", nil, "unknown method reverse"},
+		{"exec('rm')", nil, "unknown function exec"},
+		{"size(weekday)", nil, "size does not accept (int)"},
+		{"items.filter(i, true) == items", nil, "does not apply"},
+		{"weekday > 1", Int, "expression has type bool, want int"},
+		{strings.Repeat("(", 100) + "1" + strings.Repeat(")", 100), nil, "nested more than"},
+		{strings.Repeat("1+", MaxSourceLength), nil, "longer than"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			_, err := Compile(tt.src, testVars, tt.want)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Compile() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEvalLimits(t *testing.T) {
+	big := "items.map(a, items.map(b, items.map(c, items.map(d, name + name))))"
+
+	tests := []struct {
+		name    string
+		src     string
+		limits  Limits
+		wantErr error
+	}{
+		{"step limit", big, Limits{MaxSteps: 100}, ErrStepLimit},
+		{"memory limit", big, Limits{MaxMemoryBytes: 256}, ErrMemoryLimit},
+		{"time limit", big, Limits{Timeout: time.Nanosecond}, ErrTimeout},
+		{"division by zero", "weekday / (weekday - 6)", DefaultLimits, errDivision},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Compile(tt.src, testVars, nil)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			if _, err := p.Eval(testValues(), tt.limits); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Eval() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	p, _ := Compile(big, testVars, nil)
+	if _, err := p.Eval(testValues(), Limits{}); err != nil {
+		t.Errorf("Eval() without limits error = %v", err)
+	}
+	if _, err := p.Eval(map[string]any{}, Limits{}); err == nil {
+		t.Error("Eval() without variables succeeded")
+	}
+}
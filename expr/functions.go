@@ -0,0 +1,316 @@
+package expr
+
+import (
+	"math"
+	"strings"
+)
+
+// Functions:
+//
+//	size(string|list) int           also as a method: s.size()
+//	int(number) int                 truncates toward zero
+//	double(number) double
+//	ceil(number), floor(number), round(number) int
+//	abs(number), min(a, b), max(a, b)
+//	sum(list<int>) int, sum(list<double>) double
+//
+// String methods: contains, startsWith, endsWith (string) bool; lower(),
+// upper() string.
+//
+// List macros, where x names each element in turn:
+//
+//	list.filter(x, bool) list   list.map(x, expr) list
+//	list.exists(x, bool) bool   list.all(x, bool) bool
+
+func (c *compiler) call(n *callNode) (*Type, evalFn, error) {
+	if n.recv != nil {
+		switch n.fn {
+		case "filter", "map", "exists", "all":
+			return c.macro(n)
+		}
+		// Methods are functions taking the receiver first.
+		n = &callNode{pos: n.pos, fn: n.fn, args: append([]node{n.recv}, n.args...)}
+		if _, ok := stringMethods[n.fn]; !ok && n.fn != "size" {
+			return nil, nil, errorAt(n, "unknown method %s", n.fn)
+		}
+	}
+
+	types := make([]*Type, len(n.args))
+	args := make([]evalFn, len(n.args))
+	for i, a := range n.args {
+		t, fn, err := c.compile(a)
+		if err != nil {
+			return nil, nil, err
+		}
+		types[i], args[i] = t, fn
+	}
+	wrongArgs := errorAt(n, "%s does not accept (%s)", n.fn, typeList(types))
+
+	one := func(check func(*Type) bool) bool {
+		return len(types) == 1 && check(types[0])
+	}
+	numeric := func(t *Type) bool { return t.numeric() }
+
+	switch n.fn {
+	case "size":
+		if !one(func(t *Type) bool { return t.Kind == KindString || t.Kind == KindList }) {
+			return nil, nil, wrongArgs
+		}
+		return Int, unaryFn(args[0], func(v any) (any, error) {
+			if s, ok := v.(string); ok {
+				return int64(len(s)), nil
+			}
+			return int64(len(v.([]any))), nil
+		}), nil
+
+	case "int":
+		if !one(numeric) {
+			return nil, nil, wrongArgs
+		}
+		return Int, unaryFn(args[0], func(v any) (any, error) {
+			return toInt(toDouble(v)), nil
+		}), nil
+
+	case "double":
+		if !one(numeric) {
+			return nil, nil, wrongArgs
+		}
+		return Double, unaryFn(args[0], func(v any) (any, error) {
+			return toDouble(v), nil
+		}), nil
+
+	case "ceil", "floor", "round":
+		if !one(numeric) {
+			return nil, nil, wrongArgs
+		}
+		round := map[string]func(float64) float64{"ceil": math.Ceil, "floor": math.Floor, "round": math.Round}[n.fn]
+		return Int, unaryFn(args[0], func(v any) (any, error) {
+			return toInt(round(toDouble(v))), nil
+		}), nil
+
+	case "abs":
+		if !one(numeric) {
+			return nil, nil, wrongArgs
+		}
+		return types[0], unaryFn(args[0], func(v any) (any, error) {
+			if i, ok := v.(int64); ok {
+				if i < 0 {
+					return -i, nil
+				}
+				return i, nil
+			}
+			return math.Abs(v.(float64)), nil
+		}), nil
+
+	case "min", "max":
+		if len(types) != 2 || !types[0].numeric() || !types[1].numeric() {
+			return nil, nil, wrongArgs
+		}
+		typ := Int
+		if types[0].Kind == KindDouble || types[1].Kind == KindDouble {
+			typ = Double
+			args[0], args[1] = promote(args[0], types[0]), promote(args[1], types[1])
+		}
+		wantLess := n.fn == "min"
+		return typ, func(s *state) (any, error) {
+			a, b, err := eval2(s, args[0], args[1])
+			if err != nil {
+				return nil, err
+			}
+			if (compareValues(a, b) < 0) == wantLess {
+				return a, nil
+			}
+			return b, nil
+		}, nil
+
+	case "sum":
+		if !one(func(t *Type) bool { return t.Kind == KindList && t.Elem != nil && t.Elem.numeric() }) {
+			return nil, nil, wrongArgs
+		}
+		elem := types[0].Elem
+		return elem, func(s *state) (any, error) {
+			v, err := eval(s, args[0])
+			if err != nil {
+				return nil, err
+			}
+			var i int64
+			var f float64
+			for _, e := range v.([]any) {
+				if err := s.step(); err != nil {
+					return nil, err
+				}
+				if elem.Kind == KindInt {
+					i += e.(int64)
+				} else {
+					f += e.(float64)
+				}
+			}
+			if elem.Kind == KindInt {
+				return i, nil
+			}
+			return f, nil
+		}, nil
+	}
+
+	if method, ok := stringMethods[n.fn]; ok {
+		if len(types) != method.args+1 {
+			return nil, nil, wrongArgs
+		}
+		for _, t := range types {
+			if t.Kind != KindString {
+				return nil, nil, wrongArgs
+			}
+		}
+		return method.typ, func(s *state) (any, error) {
+			vals := make([]string, len(args))
+			for i, a := range args {
+				v, err := eval(s, a)
+				if err != nil {
+					return nil, err
+				}
+				vals[i] = v.(string)
+			}
+			result := method.fn(vals)
+			if str, ok := result.(string); ok {
+				return str, s.alloc(int64(len(str)))
+			}
+			return result, nil
+		}, nil
+	}
+	return nil, nil, errorAt(n, "unknown function %s", n.fn)
+}
+
+type stringMethod struct {
+	args int
+	typ  *Type
+	fn   func(args []string) any
+}
+
+var stringMethods = map[string]stringMethod{
+	"contains":   {1, Bool, func(a []string) any { return strings.Contains(a[0], a[1]) }},
+	"startsWith": {1, Bool, func(a []string) any { return strings.HasPrefix(a[0], a[1]) }},
+	"endsWith":   {1, Bool, func(a []string) any { return strings.HasSuffix(a[0], a[1]) }},
+	"lower":      {0, String, func(a []string) any { return strings.ToLower(a[0]) }},
+	"upper":      {0, String, func(a []string) any { return strings.ToUpper(a[0]) }},
+}
+
+// macro compiles list.filter(x, body) and its siblings, binding x to each
+// element while body runs.
+func (c *compiler) macro(n *callNode) (*Type, evalFn, error) {
+	lt, list, err := c.compile(n.recv)
+	if err != nil {
+		return nil, nil, err
+	}
+	if lt.Kind != KindList {
+		return nil, nil, errorAt(n, "%s applies to lists, not %s", n.fn, lt)
+	}
+	if len(n.args) != 2 {
+		return nil, nil, errorAt(n, "%s takes a variable name and an expression", n.fn)
+	}
+	name, ok := n.args[0].(*identNode)
+	if !ok {
+		return nil, nil, errorAt(n.args[0], "first argument of %s must be a variable name", n.fn)
+	}
+	elem := lt.Elem
+	if elem == nil {
+		elem = Int // iterating an empty list literal never binds the variable
+	}
+
+	slot := c.slots
+	c.slots++
+	outer, shadowed := c.scope[name.name]
+	c.scope[name.name] = binding{typ: elem, slot: slot}
+	bt, body, err := c.compile(n.args[1])
+	if shadowed {
+		c.scope[name.name] = outer
+	} else {
+		delete(c.scope, name.name)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if n.fn != "map" && bt.Kind != KindBool {
+		return nil, nil, errorAt(n.args[1], "%s needs a bool expression, not %s", n.fn, bt)
+	}
+
+	fn := n.fn
+	typ := Bool
+	switch fn {
+	case "filter":
+		typ = lt
+	case "map":
+		typ = ListOf(bt)
+	}
+	return typ, func(s *state) (any, error) {
+		v, err := eval(s, list)
+		if err != nil {
+			return nil, err
+		}
+		in := v.([]any)
+		var out []any
+		if fn == "filter" || fn == "map" {
+			if err := s.alloc(int64(len(in)) * listElemBytes); err != nil {
+				return nil, err
+			}
+			out = make([]any, 0, len(in))
+		}
+		for _, e := range in {
+			s.vars[slot] = e
+			r, err := eval(s, body)
+			if err != nil {
+				return nil, err
+			}
+			switch fn {
+			case "filter":
+				if r.(bool) {
+					out = append(out, e)
+				}
+			case "map":
+				out = append(out, r)
+			case "exists":
+				if r.(bool) {
+					return true, nil
+				}
+			case "all":
+				if !r.(bool) {
+					return false, nil
+				}
+			}
+		}
+		if fn == "exists" || fn == "all" {
+			return fn == "all", nil
+		}
+		return out, nil
+	}, nil
+}
+
+func unaryFn(arg evalFn, f func(any) (any, error)) evalFn {
+	return func(s *state) (any, error) {
+		v, err := eval(s, arg)
+		if err != nil {
+			return nil, err
+		}
+		return f(v)
+	}
+}
+
+// toInt converts f to an int, saturating at the int64 range.
+func toInt(f float64) int64 {
+	switch {
+	case math.IsNaN(f):
+		return 0
+	case f >= math.MaxInt64:
+		return math.MaxInt64
+	case f <= math.MinInt64:
+		return math.MinInt64
+	}
+	return int64(f)
+}
+
+func typeList(types []*Type) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = t.String()
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,311 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// maxDepth bounds expression nesting so hostile input cannot exhaust the
+// stack while parsing, checking or evaluating.
+const maxDepth = 64
+
+type node interface {
+	position() int
+}
+
+type (
+	literalNode struct {
+		pos int
+		val any
+	}
+	identNode struct {
+		pos  int
+		name string
+	}
+	unaryNode struct {
+		pos int
+		op  string
+		x   node
+	}
+	binaryNode struct {
+		pos  int
+		op   string
+		x, y node
+	}
+	condNode struct {
+		pos     int
+		c, t, f node
+	}
+	selectNode struct {
+		pos   int
+		x     node
+		field string
+	}
+	indexNode struct {
+		pos  int
+		x, i node
+	}
+	listNode struct {
+		pos   int
+		elems []node
+	}
+	// callNode is a function call, or a method call when recv is set.
+	callNode struct {
+		pos  int
+		recv node
+		fn   string
+		args []node
+	}
+)
+
+func (n *literalNode) position() int { return n.pos }
+func (n *identNode) position() int   { return n.pos }
+func (n *unaryNode) position() int   { return n.pos }
+func (n *binaryNode) position() int  { return n.pos }
+func (n *condNode) position() int    { return n.pos }
+func (n *selectNode) position() int  { return n.pos }
+func (n *indexNode) position() int   { return n.pos }
+func (n *listNode) position() int    { return n.pos }
+func (n *callNode) position() int    { return n.pos }
+
+type parser struct {
+	tokens []token
+	next   int
+	depth  int
+}
+
+func parse(src string) (node, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	n, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t, "unexpected %s", describe(t))
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token { return p.tokens[p.next] }
+
+func (p *parser) advance() token {
+	t := p.tokens[p.next]
+	if t.kind != tokEOF {
+		p.next++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the operator op.
+func (p *parser) accept(op string) bool {
+	if t := p.peek(); t.kind == tokOp && t.text == op {
+		p.next++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if !p.accept(op) {
+		t := p.peek()
+		return p.errorf(t, "expected %q, found %s", op, describe(t))
+	}
+	return nil
+}
+
+func (p *parser) errorf(t token, format string, args ...any) error {
+	return fmt.Errorf("%d: %s", t.pos+1, fmt.Sprintf(format, args...))
+}
+
+func describe(t token) string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+func (p *parser) expr() (node, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxDepth {
+		return nil, p.errorf(p.peek(), "expression nested more than %d deep", maxDepth)
+	}
+
+	c, err := p.binary(0)
+	if err != nil {
+		return nil, err
+	}
+	pos := p.peek().pos
+	if !p.accept("?") {
+		return c, nil
+	}
+	t, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	f, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	return &condNode{pos: pos, c: c, t: t, f: f}, nil
+}
+
+// precedence of binary operators; higher binds tighter.
+var precedence = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"==": 3, "!=": 3, "<": 3, "<=": 3, ">": 3, ">=": 3, "in": 3,
+	"+": 4, "-": 4,
+	"*": 5, "/": 5, "%": 5,
+}
+
+func (p *parser) binary(min int) (node, error) {
+	x, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		prec, ok := precedence[t.text]
+		if !ok || t.kind == tokString || prec <= min {
+			return x, nil
+		}
+		p.advance()
+		y, err := p.binary(prec)
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryNode{pos: t.pos, op: t.text, x: x, y: y}
+	}
+}
+
+func (p *parser) unary() (node, error) {
+	t := p.peek()
+	if t.kind == tokOp && (t.text == "!" || t.text == "-") {
+		p.advance()
+		p.depth++
+		defer func() { p.depth-- }()
+		if p.depth > maxDepth {
+			return nil, p.errorf(t, "expression nested more than %d deep", maxDepth)
+		}
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{pos: t.pos, op: t.text, x: x}, nil
+	}
+	return p.postfix()
+}
+
+func (p *parser) postfix() (node, error) {
+	x, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		switch {
+		case p.accept("."):
+			name := p.advance()
+			if name.kind != tokIdent {
+				return nil, p.errorf(name, "expected field or method name, found %s", describe(name))
+			}
+			if p.accept("(") {
+				args, err := p.args(")")
+				if err != nil {
+					return nil, err
+				}
+				x = &callNode{pos: name.pos, recv: x, fn: name.text, args: args}
+			} else {
+				x = &selectNode{pos: name.pos, x: x, field: name.text}
+			}
+		case p.accept("["):
+			i, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			x = &indexNode{pos: t.pos, x: x, i: i}
+		default:
+			return x, nil
+		}
+	}
+}
+
+func (p *parser) primary() (node, error) {
+	t := p.advance()
+	switch t.kind {
+	case tokInt:
+		v, err := strconv.ParseInt(t.text, 10, 64)
+		if err != nil {
+			return nil, p.errorf(t, "integer %s out of range", t.text)
+		}
+		return &literalNode{pos: t.pos, val: v}, nil
+	case tokDouble:
+		v, _ := strconv.ParseFloat(t.text, 64)
+		return &literalNode{pos: t.pos, val: v}, nil
+	case tokString:
+		return &literalNode{pos: t.pos, val: t.text}, nil
+	case tokIdent:
+		switch t.text {
+		case "true", "false":
+			return &literalNode{pos: t.pos, val: t.text == "true"}, nil
+		case "in":
+			return nil, p.errorf(t, "unexpected %s", describe(t))
+		}
+		if p.accept("(") {
+			args, err := p.args(")")
+			if err != nil {
+				return nil, err
+			}
+			return &callNode{pos: t.pos, fn: t.text, args: args}, nil
+		}
+		return &identNode{pos: t.pos, name: t.text}, nil
+	case tokOp:
+		switch t.text {
+		case "(":
+			x, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expect(")")
+		case "[":
+			elems, err := p.args("]")
+			if err != nil {
+				return nil, err
+			}
+			return &listNode{pos: t.pos, elems: elems}, nil
+		}
+	}
+	return nil, p.errorf(t, "unexpected %s", describe(t))
+}
+
+// args parses a comma separated list of expressions up to the closing operator.
+func (p *parser) args(closing string) ([]node, error) {
+	var args []node
+	if p.accept(closing) {
+		return args, nil
+	}
+	for {
+		a, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, a)
+		if p.accept(closing) {
+			return args, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package expr
+
+import (
+	"sort"
+	"strings"
+)
+
+type Kind int
+
+const (
+	KindInt Kind = iota + 1
+	KindDouble
+	KindString
+	KindBool
+	KindList
+	KindObject
+)
+
+// Type is the static type of an expression. Values of each kind are
+// int64, float64, string, bool, []any and map[string]any respectively.
+type Type struct {
+	Kind   Kind
+	Elem   *Type            // element type of a list
+	Name   string           // name of an object type
+	Fields map[string]*Type // fields of an object type
+}
+
+var (
+	Int    = &Type{Kind: KindInt}
+	Double = &Type{Kind: KindDouble}
+	String = &Type{Kind: KindString}
+	Bool   = &Type{Kind: KindBool}
+)
+
+func ListOf(elem *Type) *Type {
+	return &Type{Kind: KindList, Elem: elem}
+}
+
+// Object declares a named record type with the given fields.
+func Object(name string, fields map[string]*Type) *Type {
+	return &Type{Kind: KindObject, Name: name, Fields: fields}
+}
+
+func (t *Type) String() string {
+	switch t.Kind {
+	case KindInt:
+		return "int"
+	case KindDouble:
+		return "double"
+	case KindString:
+		return "string"
+	case KindBool:
+		return "bool"
+	case KindList:
+		if t.Elem == nil {
+			return "list"
+		}
+		return "list<" + t.Elem.String() + ">"
+	}
+	return t.Name
+}
+
+func (t *Type) numeric() bool {
+	return t.Kind == KindInt || t.Kind == KindDouble
+}
+
+// equal reports whether the types are the same. An empty list literal has
+// no element type and is equal to any list.
+func (t *Type) equal(o *Type) bool {
+	if t.Kind != o.Kind {
+		return false
+	}
+	switch t.Kind {
+	case KindList:
+		return t.Elem == nil || o.Elem == nil || t.Elem.equal(o.Elem)
+	case KindObject:
+		return t.Name == o.Name
+	}
+	return true
+}
+
+// fieldNames lists an object's fields for error messages.
+func (t *Type) fieldNames() string {
+	names := make([]string, 0, len(t.Fields))
+	for name := range t.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
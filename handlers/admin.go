@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/suryamp/receipt-processor/config"
+)
+
+type AdminHandler struct {
+	reloader *config.Reloader
+}
+
+func NewAdminHandler(reloader *config.Reloader) *AdminHandler {
+	return &AdminHandler{reloader: reloader}
+}
+
+// ConfigStatusHandler reports the active config and rules versions and the
+// outcome of the last reload.
+func (h *AdminHandler) ConfigStatusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.reloader.Status())
+}
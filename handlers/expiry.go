@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/suryamp/receipt-processor/expiry"
+	"github.com/suryamp/receipt-processor/problem"
+)
+
+// maxWarningDays bounds the window of expiring-soon queries.
+const maxWarningDays = 3660
+
+// ExpiryHandler serves expiring-soon queries and on-demand expiry sweeps.
+type ExpiryHandler struct {
+	sweeper     *expiry.Sweeper // nil when points do not expire
+	warningDays int
+}
+
+func NewExpiryHandler(sweeper *expiry.Sweeper, warningDays int) *ExpiryHandler {
+	return &ExpiryHandler{sweeper: sweeper, warningDays: warningDays}
+}
+
+// ExpiringHandler returns the points a user has expiring within ?days=,
+// which defaults to the configured warning window.
+func (h *ExpiryHandler) ExpiringHandler(w http.ResponseWriter, r *http.Request) {
+	if h.sweeper == nil {
+		problem.Write(w, problem.Details{Status: http.StatusNotFound, Detail: "points do not expire"})
+		return
+	}
+	days := h.warningDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		var err error
+		if days, err = strconv.Atoi(v); err != nil || days < 1 || days > maxWarningDays {
+			problem.Write(w, problem.Details{Status: http.StatusBadRequest, Detail: "days must be between 1 and " + strconv.Itoa(maxWarningDays)})
+			return
+		}
+	}
+
+	forecast, err := h.sweeper.Expiring(mux.Vars(r)["id"], time.Duration(days)*24*time.Hour)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, forecast)
+}
+
+// SweepHandler runs an expiry sweep now rather than waiting for the next one.
+func (h *ExpiryHandler) SweepHandler(w http.ResponseWriter, r *http.Request) {
+	if h.sweeper == nil {
+		problem.Write(w, problem.Details{Status: http.StatusNotFound, Detail: "points do not expire"})
+		return
+	}
+	writeJSON(w, http.StatusOK, h.sweeper.Sweep())
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/suryamp/receipt-processor/expiry"
+	"github.com/suryamp/receipt-processor/ledger"
+	"github.com/suryamp/receipt-processor/users"
+)
+
+func TestExpiryHandler(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	accounts := users.NewStore(ledger.New().WithClock(clock))
+	accounts.AddReceipt("alice", users.Receipt{ID: "r1", PurchaseDate: "2024-01-20", Points: 10})
+	accounts.AddReceipt("alice", users.Receipt{ID: "r2", PurchaseDate: "2024-06-01", Points: 20})
+	sweeper := expiry.NewSweeper(expiry.Policy{Mode: expiry.ModeFixed, Months: 12}, accounts).WithClock(clock)
+
+	router := mux.NewRouter()
+	h := NewExpiryHandler(sweeper, 30)
+	router.HandleFunc("/users/{id}/expiring", h.ExpiringHandler).Methods("GET")
+	router.HandleFunc("/admin/expiry/sweep", h.SweepHandler).Methods("POST")
+	disabled := NewExpiryHandler(nil, 30)
+	router.HandleFunc("/disabled/users/{id}/expiring", disabled.ExpiringHandler).Methods("GET")
+	router.HandleFunc("/disabled/sweep", disabled.SweepHandler).Methods("POST")
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		wantStatus   int
+		wantExpiring int64
+	}{
+		{"default window", "GET", "/users/alice/expiring", http.StatusOK, 10},
+		{"longer window", "GET", "/users/alice/expiring?days=200", http.StatusOK, 30},
+		{"invalid days", "GET", "/users/alice/expiring?days=0", http.StatusBadRequest, 0},
+		{"unknown user", "GET", "/users/bob/expiring", http.StatusNotFound, 0},
+		{"sweep", "POST", "/admin/expiry/sweep", http.StatusOK, 0},
+		{"expiry disabled", "GET", "/disabled/users/alice/expiring", http.StatusNotFound, 0},
+		{"sweep disabled", "POST", "/disabled/sweep", http.StatusNotFound, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %v %s, want %v", w.Code, w.Body, tt.wantStatus)
+			}
+			if tt.wantExpiring == 0 {
+				return
+			}
+			var f expiry.Forecast
+			json.NewDecoder(w.Body).Decode(&f)
+			if f.Expiring != tt.wantExpiring {
+				t.Errorf("expiring = %d, want %d", f.Expiring, tt.wantExpiring)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/suryamp/receipt-processor/export"
+	"github.com/suryamp/receipt-processor/logger"
+	"github.com/suryamp/receipt-processor/problem"
+	"github.com/suryamp/receipt-processor/processor"
+)
+
+const (
+	// exportFlushEvery is how many receipts are written between flushes.
+	exportFlushEvery = 500
+	// exportWriteWindow is how long the client has to take each batch; the
+	// server's write timeout would otherwise cut off large exports.
+	exportWriteWindow = 30 * time.Second
+)
+
+// Exporter is a receipt store that can be exported.
+type Exporter interface {
+	Export(f processor.ExportFilter, fn func(processor.ExportedReceipt) error) error
+}
+
+// ExportHandler streams stored receipts out for analysis.
+type ExportHandler struct {
+	exporter Exporter
+}
+
+func NewExportHandler(e Exporter) *ExportHandler {
+	return &ExportHandler{exporter: e}
+}
+
+// ReceiptsHandler streams the receipts purchased between ?from= and ?to=,
+// optionally only one ?retailer='s or ?userId='s, with their items and
+// points, as ?format=csv or ndjson (the default).
+func (h *ExportHandler) ReceiptsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	format, err := export.ParseFormat(q.Get("format"))
+	if err != nil {
+		problem.Write(w, problem.Details{Status: http.StatusBadRequest, Detail: err.Error()})
+		return
+	}
+	filter := processor.ExportFilter{From: q.Get("from"), To: q.Get("to"), Retailer: q.Get("retailer"), UserID: q.Get("userId")}
+	for _, d := range []string{filter.From, filter.To} {
+		if _, err := time.Parse(time.DateOnly, d); d != "" && err != nil {
+			problem.Write(w, problem.Details{Status: http.StatusBadRequest, Detail: fmt.Sprintf("%q is not a YYYY-MM-DD date", d)})
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="receipts.%s"`, format))
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Now().Add(exportWriteWindow))
+	enc := export.NewEncoder(w, format)
+	var n int
+	err = h.exporter.Export(filter, func(receipt processor.ExportedReceipt) error {
+		if err := r.Context().Err(); err != nil {
+			return err
+		}
+		if err := enc.Encode(receipt); err != nil {
+			return err
+		}
+		if n++; n%exportFlushEvery == 0 {
+			if err := enc.Flush(); err != nil {
+				return err
+			}
+			rc.Flush()
+			rc.SetWriteDeadline(time.Now().Add(exportWriteWindow))
+		}
+		return nil
+	})
+	if err == nil {
+		err = enc.Flush()
+	}
+	if err != nil {
+		// The status is already sent, so the client sees a truncated export
+		logger.ErrorLogger.Printf("Receipt export stopped after %d receipts: %v", n, err)
+		return
+	}
+	logger.InfoLogger.Printf("Exported %d receipts as %s", n, format)
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/suryamp/receipt-processor/models"
+	"github.com/suryamp/receipt-processor/processor"
+)
+
+func TestExportHandler(t *testing.T) {
+	p := processor.NewInMemoryProcessor(processor.DefaultRules())
+	for _, date := range []string{"2024-01-02", "2024-02-10"} {
+		p.ProcessReceipt(context.Background(), models.Receipt{
+			Retailer:     "Target",
+			PurchaseDate: date,
+			PurchaseTime: "13:01",
+			Items:        []models.Item{{ShortDescription: "Pepsi", Price: "1.25"}},
+			Total:        "1.25",
+		})
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/exports/receipts", NewExportHandler(p).ReceiptsHandler).Methods("GET")
+
+	tests := []struct {
+		name        string
+		path        string
+		wantStatus  int
+		wantType    string
+		wantLines   int
+		wantContain string
+	}{
+		{"ndjson by default", "/exports/receipts", http.StatusOK, "application/x-ndjson", 2, `"points":31`},
+		{"csv", "/exports/receipts?format=csv", http.StatusOK, "text/csv; charset=utf-8", 3, "receipt_id,user_id"},
+		{"filtered", "/exports/receipts?from=2024-02-01&retailer=Target", http.StatusOK, "application/x-ndjson", 1, `"purchaseDate":"2024-02-10"`},
+		{"none match", "/exports/receipts?format=csv&userId=nobody", http.StatusOK, "text/csv; charset=utf-8", 1, "item_price"},
+		{"unknown format", "/exports/receipts?format=xml", http.StatusBadRequest, "application/problem+json", 0, "unknown export format"},
+		{"invalid date", "/exports/receipts?to=soon", http.StatusBadRequest, "application/problem+json", 0, "YYYY-MM-DD"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %v %s, want %v", w.Code, w.Body, tt.wantStatus)
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantType)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantContain) {
+				t.Errorf("body = %s, want it to contain %s", w.Body, tt.wantContain)
+			}
+			if tt.wantLines > 0 {
+				if lines := strings.Count(w.Body.String(), "\n"); lines != tt.wantLines {
+					t.Errorf("body has %d lines, want %d:\n%s", lines, tt.wantLines, w.Body)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/suryamp/receipt-processor/importer"
+	"github.com/suryamp/receipt-processor/problem"
+)
+
+// importReadWindow is how long the client has to send each part of an
+// upload; the server's read timeout would otherwise cut off large imports.
+const importReadWindow = 30 * time.Second
+
+// ImportsHandler imports receipts in bulk.
+type ImportsHandler struct {
+	importer *importer.Importer
+	mapping  importer.Mapping
+}
+
+func NewImportsHandler(im *importer.Importer, mapping importer.Mapping) *ImportsHandler {
+	return &ImportsHandler{importer: im, mapping: mapping}
+}
+
+// ImportHandler imports the receipts in the request body, CSV or NDJSON as
+// ?format= or the Content-Type says, and returns the report. It takes
+// ?source=, ?dryRun=, ?maxErrors= and column.<field>= overrides of the
+// configured CSV mapping. A failed import is a 422 whose report says how
+// far it got; sending the same file with the same source resumes it.
+func (h *ImportsHandler) ImportHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	format, err := importFormat(r)
+	if err != nil {
+		problem.Write(w, problem.Details{Status: http.StatusUnsupportedMediaType, Detail: err.Error()})
+		return
+	}
+	opts := importer.Options{Source: q.Get("source")}
+	if v := q.Get("dryRun"); v != "" {
+		if opts.DryRun, err = strconv.ParseBool(v); err != nil {
+			problem.Write(w, problem.Details{Status: http.StatusBadRequest, Detail: "dryRun must be true or false"})
+			return
+		}
+	}
+	if v := q.Get("maxErrors"); v != "" {
+		if opts.MaxErrors, err = strconv.Atoi(v); err != nil || opts.MaxErrors < 0 {
+			problem.Write(w, problem.Details{Status: http.StatusBadRequest, Detail: "maxErrors must be a non-negative integer"})
+			return
+		}
+	}
+	columns := map[string]string{}
+	for k := range q {
+		if field, ok := strings.CutPrefix(k, "column."); ok {
+			columns[field] = q.Get(k)
+		}
+	}
+	mapping, err := h.mapping.With(columns)
+	if err != nil {
+		problem.Write(w, problem.Details{Status: http.StatusBadRequest, Detail: err.Error()})
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	reader, err := importer.NewReader(&deadlineReader{r: r.Body, rc: rc}, format, mapping)
+	if err != nil {
+		problem.Write(w, problem.Details{Status: http.StatusBadRequest, Detail: err.Error()})
+		return
+	}
+	report := h.importer.Run(r.Context(), reader, opts)
+
+	rc.SetWriteDeadline(time.Now().Add(importReadWindow))
+	status := http.StatusOK
+	if report.Status == importer.StatusFailed {
+		status = http.StatusUnprocessableEntity
+	}
+	writeJSON(w, status, report)
+}
+
+// importFormat returns the format ?format= names, or else the one the
+// Content-Type is.
+func importFormat(r *http.Request) (importer.Format, error) {
+	if f := r.URL.Query().Get("format"); f != "" {
+		return importer.ParseFormat(f)
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		return importer.CSV, nil
+	case "application/x-ndjson", "application/jsonl":
+		return importer.NDJSON, nil
+	}
+	return "", errors.New("send text/csv or application/x-ndjson, or set ?format=csv or ndjson")
+}
+
+// deadlineReader extends the connection's read deadline before each read,
+// so an upload may take as long as it keeps arriving.
+type deadlineReader struct {
+	r  io.Reader
+	rc *http.ResponseController
+}
+
+func (d *deadlineReader) Read(p []byte) (int, error) {
+	d.rc.SetReadDeadline(time.Now().Add(importReadWindow))
+	return d.r.Read(p)
+}
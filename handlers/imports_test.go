@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/suryamp/receipt-processor/importer"
+	"github.com/suryamp/receipt-processor/processor"
+)
+
+func TestImportsHandler(t *testing.T) {
+	p := processor.NewInMemoryProcessor(processor.DefaultRules())
+	router := mux.NewRouter()
+	router.HandleFunc("/imports", NewImportsHandler(importer.New(p), importer.DefaultMapping()).ImportHandler).Methods("POST")
+
+	csv := "Order,retailer,purchase_date,purchase_time,total,item_description,item_price\n" +
+		"A1,Target,2024-01-02,13:01,7.50,Pepsi,1.25\n" +
+		"A1,Target,2024-01-02,13:01,7.50,Doritos,6.25\n" +
+		"B2,Target!?,2024-01-03,08:00,2.00,Gum,2.00\n"
+	ndjson := `{"id":"n1","retailer":"Target","purchaseDate":"2024-01-02","purchaseTime":"13:01","total":"1.25","items":[{"shortDescription":"Pepsi","price":"1.25"}]}` + "\n"
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		wantStatus  int
+		want        importer.Report // compared by status and counts
+	}{
+		{"csv dry run", "/imports?dryRun=true&column.key=Order", "text/csv", csv, http.StatusOK,
+			importer.Report{Status: importer.StatusDone, Records: 2, Imported: 1, Invalid: 1}},
+		{"csv", "/imports?source=orders&column.key=Order", "text/csv", csv, http.StatusOK,
+			importer.Report{Status: importer.StatusDone, Records: 2, Imported: 1, Invalid: 1}},
+		{"csv again", "/imports?source=orders&column.key=Order", "text/csv", csv, http.StatusOK,
+			importer.Report{Status: importer.StatusDone, Records: 2, Skipped: 1, Invalid: 1}},
+		{"ndjson by format", "/imports?format=ndjson", "", ndjson, http.StatusOK,
+			importer.Report{Status: importer.StatusDone, Records: 1, Imported: 1}},
+		{"too many errors", "/imports?format=csv&column.key=Order&maxErrors=1&source=other", "", csv, http.StatusUnprocessableEntity,
+			importer.Report{Status: importer.StatusFailed, Records: 2, Imported: 1, Invalid: 1}},
+		{"missing column", "/imports", "text/csv", csv, http.StatusBadRequest, importer.Report{}},
+		{"unknown mapping field", "/imports?column.store=Store", "text/csv", csv, http.StatusBadRequest, importer.Report{}},
+		{"unknown format", "/imports", "application/json", ndjson, http.StatusUnsupportedMediaType, importer.Report{}},
+		{"bad dry run", "/imports?dryRun=maybe", "text/csv", csv, http.StatusBadRequest, importer.Report{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %v %s, want %v", w.Code, w.Body, tt.wantStatus)
+			}
+			if tt.want.Status == "" {
+				return
+			}
+			var got importer.Report
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != tt.want.Status || got.Records != tt.want.Records || got.Imported != tt.want.Imported ||
+				got.Skipped != tt.want.Skipped || got.Invalid != tt.want.Invalid {
+				t.Errorf("report = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/suryamp/receipt-processor/items"
+	"github.com/suryamp/receipt-processor/problem"
+	"github.com/suryamp/receipt-processor/stats"
+)
+
+// ItemsHandler serves the catalog of items bought across receipts.
+type ItemsHandler struct {
+	catalog *items.Catalog
+}
+
+func NewItemsHandler(catalog *items.Catalog) *ItemsHandler {
+	return &ItemsHandler{catalog: catalog}
+}
+
+// itemPage is one page of the catalog.
+type itemPage struct {
+	Total  int             `json:"total"`
+	Offset int             `json:"offset"`
+	Limit  int             `json:"limit"`
+	Items  []items.Summary `json:"items"`
+}
+
+// ListHandler lists catalog entries, most bought first by default.
+func (h *ItemsHandler) ListHandler(w http.ResponseWriter, r *http.Request) {
+	offset, limit, err := pageParams(r)
+	if err != nil {
+		problem.Write(w, problem.Details{Status: http.StatusBadRequest, Detail: err.Error()})
+		return
+	}
+	list, total, err := h.catalog.List(items.ListQuery{
+		Search:   r.URL.Query().Get("q"),
+		Retailer: r.URL.Query().Get("retailer"),
+		Sort:     r.URL.Query().Get("sort"),
+		Offset:   offset,
+		Limit:    limit,
+	})
+	if err != nil {
+		problem.Write(w, problem.Details{Status: http.StatusBadRequest, Detail: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, itemPage{Total: total, Offset: offset, Limit: limit, Items: list})
+}
+
+// GetHandler returns an item with its prices overall and by retailer.
+func (h *ItemsHandler) GetHandler(w http.ResponseWriter, r *http.Request) {
+	item, err := h.catalog.Get(mux.Vars(r)["name"])
+	if err != nil {
+		writeItemError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, item)
+}
+
+// PricesHandler returns an item's prices by ?groupBy= day, week or month
+// (the default).
+func (h *ItemsHandler) PricesHandler(w http.ResponseWriter, r *http.Request) {
+	groupBy := stats.Grouping(r.URL.Query().Get("groupBy"))
+	if groupBy == stats.ByNone {
+		groupBy = stats.ByMonth
+	}
+	q := r.URL.Query()
+	trend, err := h.catalog.Trend(mux.Vars(r)["name"], groupBy, q.Get("from"), q.Get("to"), q.Get("retailer"))
+	if err != nil {
+		writeItemError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, trend)
+}
+
+func writeItemError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	if errors.Is(err, items.ErrNotFound) {
+		status = http.StatusNotFound
+	}
+	problem.Write(w, problem.Details{Status: status, Detail: err.Error()})
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/suryamp/receipt-processor/items"
+	"github.com/suryamp/receipt-processor/models"
+	"github.com/suryamp/receipt-processor/processor"
+)
+
+func TestItemsHandler(t *testing.T) {
+	catalog := items.NewCatalog()
+	catalog.Add("2024-01-02", "Target", []models.Item{{ShortDescription: "1/2 Gal Milk", Price: "2.99"}})
+	catalog.Add("2024-02-02", "Target", []models.Item{{ShortDescription: "Pepsi 12PK", Price: "6.99"}})
+
+	router := mux.NewRouter()
+	h := NewItemsHandler(catalog)
+	router.HandleFunc("/items", h.ListHandler).Methods("GET")
+	router.HandleFunc("/items/{name:.+}/prices", h.PricesHandler).Methods("GET")
+	router.HandleFunc("/items/{name:.+}", h.GetHandler).Methods("GET")
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"list", "/items?sort=name", http.StatusOK, `"items":[{"name":"1/2 GALLON MILK"`},
+		{"search", "/items?q=pepsi", http.StatusOK, `"total":1`},
+		{"invalid sort", "/items?sort=price", http.StatusBadRequest, ""},
+		{"item", "/items/pepsi%2012%20pack", http.StatusOK, `"byRetailer":{"Target":{"purchases":1`},
+		{"name with a slash", "/items/1%2F2%20gallon%20milk", http.StatusOK, `"median":"2.99"`},
+		{"prices", "/items/1%2F2%20gallon%20milk/prices", http.StatusOK, `"groupBy":"month","points":[{"period":"2024-01"`},
+		{"prices by day", "/items/pepsi%2012pk/prices?groupBy=day", http.StatusOK, `"period":"2024-02-02"`},
+		{"invalid grouping", "/items/pepsi%2012pk/prices?groupBy=year", http.StatusBadRequest, ""},
+		{"unknown item", "/items/coke", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %v %s, want %v", w.Code, w.Body, tt.wantStatus)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", w.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+// TestItemsFromProcessedReceipts checks that descriptions items.Normalize
+// understands get past validation and into the catalog.
+func TestItemsFromProcessedReceipts(t *testing.T) {
+	catalog := items.NewCatalog()
+	router := mux.NewRouter()
+	router.HandleFunc("/receipts/process", NewHandler(processor.NewInMemoryProcessor(processor.DefaultRules()).WithItems(catalog)).ProcessReceiptHandler).Methods("POST")
+	router.HandleFunc("/items/{name:.+}", NewItemsHandler(catalog).GetHandler).Methods("GET")
+
+	receipt := `{"retailer":"Target","purchaseDate":"2024-01-02","purchaseTime":"13:01","total":"12.47","items":[` +
+		`{"shortDescription":"1/2 Gal Milk","price":"2.99"},` +
+		`{"shortDescription":"Bagel w/ cream cheese","price":"3.49"},` +
+		`{"shortDescription":"Org. Bnls Chkn Breast 1.5LB","price":"5.99"}]}`
+	req := httptest.NewRequest("POST", "/receipts/process", strings.NewReader(receipt))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("process status = %v %s, want 200", w.Code, w.Body)
+	}
+
+	for _, path := range []string{
+		"/items/1%2F2%20gallon%20milk",
+		"/items/bagel%20with%20cream%20cheese",
+		"/items/organic%20boneless%20chicken%20breast%201.5%20pound",
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("GET %s status = %v %s, want 200", path, w.Code, w.Body)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/suryamp/receipt-processor/leaderboard"
+	"github.com/suryamp/receipt-processor/problem"
+)
+
+// LeaderboardsHandler serves the user and retailer leaderboards.
+type LeaderboardsHandler struct {
+	boards *leaderboard.Boards // nil when leaderboards are off
+}
+
+func NewLeaderboardsHandler(boards *leaderboard.Boards) *LeaderboardsHandler {
+	return &LeaderboardsHandler{boards: boards}
+}
+
+// enabled writes a 404 and returns false when leaderboards are off.
+func (h *LeaderboardsHandler) enabled(w http.ResponseWriter) bool {
+	if h.boards == nil {
+		problem.Write(w, problem.Details{Status: http.StatusNotFound, Detail: "leaderboards are not enabled"})
+		return false
+	}
+	return true
+}
+
+// ListHandler describes every leaderboard.
+func (h *LeaderboardsHandler) ListHandler(w http.ResponseWriter, r *http.Request) {
+	if h.enabled(w) {
+		writeJSON(w, http.StatusOK, h.boards.List())
+	}
+}
+
+// BoardHandler returns a page of a leaderboard, best first. The board's
+// subject, ?userId= or ?retailer=, asks for that entry's own standing too.
+func (h *LeaderboardsHandler) BoardHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.enabled(w) {
+		return
+	}
+	name := mux.Vars(r)["name"]
+	subject, err := h.boards.Subject(name)
+	if errors.Is(err, leaderboard.ErrNotFound) {
+		problem.Write(w, problem.Details{Status: http.StatusNotFound, Detail: err.Error()})
+		return
+	}
+	offset, limit, err := pageParams(r)
+	if err != nil {
+		problem.Write(w, problem.Details{Status: http.StatusBadRequest, Detail: err.Error()})
+		return
+	}
+
+	page, err := h.boards.Page(name, offset, limit, r.URL.Query().Get(subject))
+	if err != nil {
+		problem.Write(w, problem.Details{Status: http.StatusNotFound, Detail: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
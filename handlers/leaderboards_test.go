@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/suryamp/receipt-processor/leaderboard"
+	"github.com/suryamp/receipt-processor/ledger"
+)
+
+func TestLeaderboardsHandler(t *testing.T) {
+	points := ledger.New()
+	boards := leaderboard.New(time.UTC).Follow(points)
+	for _, r := range []struct {
+		userID, retailer string
+		points           int64
+	}{{"alice", "Target", 120}, {"bob", "Walgreens", 80}} {
+		points.Post(ledger.Transfer(ledger.ReasonAdjustment, ledger.AccountAdjustments, ledger.UserAccount(r.userID), r.points))
+		boards.Record(r.retailer, r.points)
+	}
+
+	router := mux.NewRouter()
+	h := NewLeaderboardsHandler(boards)
+	router.HandleFunc("/leaderboards", h.ListHandler).Methods("GET")
+	router.HandleFunc("/leaderboards/{name}", h.BoardHandler).Methods("GET")
+	disabled := NewLeaderboardsHandler(nil)
+	router.HandleFunc("/disabled/leaderboards/{name}", disabled.BoardHandler).Methods("GET")
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"list", "/leaderboards", http.StatusOK, `"name":"users-week"`},
+		{"board", "/leaderboards/users-all-time", http.StatusOK, `"standings":[{"rank":1,"id":"alice","score":120}`},
+		{"own rank", "/leaderboards/users-month?userId=bob&limit=1", http.StatusOK, `"self":{"rank":2,"id":"bob","score":80}`},
+		{"retailer rank", "/leaderboards/retailers-points?retailer=Walgreens", http.StatusOK, `"self":{"rank":2,"id":"Walgreens"`},
+		{"unknown board", "/leaderboards/users-year", http.StatusNotFound, ""},
+		{"invalid page", "/leaderboards/users-week?offset=-1", http.StatusBadRequest, ""},
+		{"leaderboards disabled", "/disabled/leaderboards/users-week", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %v %s, want %v", w.Code, w.Body, tt.wantStatus)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", w.Body, tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/suryamp/receipt-processor/ledger"
+	"github.com/suryamp/receipt-processor/problem"
+)
+
+// maxReversalBytes bounds reversal request bodies.
+const maxReversalBytes = 4 << 10
+
+// LedgerHandler serves the admin API for the points ledger.
+type LedgerHandler struct {
+	ledger *ledger.Ledger
+}
+
+func NewLedgerHandler(l *ledger.Ledger) *LedgerHandler {
+	return &LedgerHandler{ledger: l}
+}
+
+// ledgerPage is one page of the whole ledger, newest first.
+type ledgerPage struct {
+	Total   int            `json:"total"`
+	Offset  int            `json:"offset"`
+	Limit   int            `json:"limit"`
+	Entries []ledger.Entry `json:"entries"`
+}
+
+// reversalRequest says why an entry is being reversed.
+type reversalRequest struct {
+	Memo string `json:"memo"`
+}
+
+// EntriesHandler lists ledger entries, optionally for one account.
+func (h *LedgerHandler) EntriesHandler(w http.ResponseWriter, r *http.Request) {
+	offset, limit, err := pageParams(r)
+	if err != nil {
+		problem.Write(w, problem.Details{Status: http.StatusBadRequest, Detail: err.Error()})
+		return
+	}
+	entries, total := h.ledger.Entries(r.URL.Query().Get("account"), offset, limit)
+	writeJSON(w, http.StatusOK, ledgerPage{Total: total, Offset: offset, Limit: limit, Entries: entries})
+}
+
+// EntryHandler returns one ledger entry.
+func (h *LedgerHandler) EntryHandler(w http.ResponseWriter, r *http.Request) {
+	entry, err := h.ledger.Get(mux.Vars(r)["id"])
+	if err != nil {
+		problem.Write(w, problem.Details{Status: http.StatusNotFound, Detail: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, entry)
+}
+
+// ReversalHandler posts an entry undoing another.
+func (h *LedgerHandler) ReversalHandler(w http.ResponseWriter, r *http.Request) {
+	var req reversalRequest
+	if !isJSON(r) {
+		problem.Write(w, problem.Details{Status: http.StatusUnsupportedMediaType, Detail: "Content-Type must be application/json"})
+		return
+	}
+	if err := decodeJSON(w, r, maxReversalBytes, &req); err != nil {
+		problem.Write(w, problem.Details{Status: http.StatusBadRequest, Detail: "invalid reversal: " + err.Error()})
+		return
+	}
+
+	entry, err := h.ledger.Reverse(mux.Vars(r)["id"], req.Memo)
+	switch {
+	case errors.Is(err, ledger.ErrNotFound):
+		problem.Write(w, problem.Details{Status: http.StatusNotFound, Detail: err.Error()})
+		return
+	case err != nil:
+		problem.Write(w, problem.Details{Status: http.StatusConflict, Detail: err.Error()})
+		return
+	}
+	w.Header().Set("Location", "/admin/ledger/entries/"+entry.ID)
+	writeJSON(w, http.StatusCreated, entry)
+}
+
+// ReconcileHandler rebuilds every balance from the entries and reports any
+// that differ from the balances kept as entries were posted.
+func (h *LedgerHandler) ReconcileHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.ledger.Reconcile())
+}
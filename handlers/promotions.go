@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/suryamp/receipt-processor/logger"
+	"github.com/suryamp/receipt-processor/problem"
+	"github.com/suryamp/receipt-processor/promotions"
+)
+
+// maxCampaignBytes bounds admin request bodies; campaigns are small.
+const maxCampaignBytes = 64 << 10
+
+// PromotionsHandler serves the admin API for promotional campaigns.
+type PromotionsHandler struct {
+	store *promotions.Store
+}
+
+func NewPromotionsHandler(store *promotions.Store) *PromotionsHandler {
+	return &PromotionsHandler{store: store}
+}
+
+func (h *PromotionsHandler) ListHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.store.List())
+}
+
+func (h *PromotionsHandler) GetHandler(w http.ResponseWriter, r *http.Request) {
+	c, err := h.store.Get(mux.Vars(r)["id"])
+	if err != nil {
+		writeCampaignError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+func (h *PromotionsHandler) CreateHandler(w http.ResponseWriter, r *http.Request) {
+	c, ok := decodeCampaign(w, r)
+	if !ok {
+		return
+	}
+	c, err := h.store.Create(c)
+	if err != nil {
+		writeCampaignError(w, err)
+		return
+	}
+	logger.InfoLogger.Printf("Created promotion %s (%s)", c.ID, c.Name)
+	w.Header().Set("Location", "/admin/promotions/"+c.ID)
+	writeJSON(w, http.StatusCreated, c)
+}
+
+func (h *PromotionsHandler) UpdateHandler(w http.ResponseWriter, r *http.Request) {
+	c, ok := decodeCampaign(w, r)
+	if !ok {
+		return
+	}
+	c, err := h.store.Update(mux.Vars(r)["id"], c)
+	if err != nil {
+		writeCampaignError(w, err)
+		return
+	}
+	logger.InfoLogger.Printf("Updated promotion %s (%s)", c.ID, c.Name)
+	writeJSON(w, http.StatusOK, c)
+}
+
+func (h *PromotionsHandler) DeleteHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if err := h.store.Delete(id); err != nil {
+		writeCampaignError(w, err)
+		return
+	}
+	logger.InfoLogger.Printf("Deleted promotion %s", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func decodeCampaign(w http.ResponseWriter, r *http.Request) (promotions.Campaign, bool) {
+	var c promotions.Campaign
+	if !isJSON(r) {
+		problem.Write(w, problem.Details{Status: http.StatusUnsupportedMediaType, Detail: "Content-Type must be application/json"})
+		return c, false
+	}
+	if err := decodeJSON(w, r, maxCampaignBytes, &c); err != nil {
+		problem.Write(w, problem.Details{Status: http.StatusBadRequest, Detail: "invalid campaign: " + err.Error()})
+		return c, false
+	}
+	return c, true
+}
+
+func writeCampaignError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	if errors.Is(err, promotions.ErrNotFound) {
+		status = http.StatusNotFound
+	}
+	problem.Write(w, problem.Details{Status: status, Detail: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
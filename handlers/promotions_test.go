@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/suryamp/receipt-processor/promotions"
+)
+
+func TestPromotionsHandler(t *testing.T) {
+	h := NewPromotionsHandler(promotions.NewStore())
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/promotions", h.ListHandler).Methods("GET")
+	router.HandleFunc("/admin/promotions", h.CreateHandler).Methods("POST")
+	router.HandleFunc("/admin/promotions/{id}", h.GetHandler).Methods("GET")
+	router.HandleFunc("/admin/promotions/{id}", h.UpdateHandler).Methods("PUT")
+	router.HandleFunc("/admin/promotions/{id}", h.DeleteHandler).Methods("DELETE")
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	campaign := `{"name":"Black Friday","startDate":"2024-11-25","endDate":"2024-12-01","retailers":["Target"],"multiplier":2}`
+	w := send("POST", "/admin/promotions", campaign)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create = %v %s, want 201", w.Code, w.Body)
+	}
+	var created promotions.Campaign
+	json.NewDecoder(w.Body).Decode(&created)
+	if created.ID == "" || w.Header().Get("Location") != "/admin/promotions/"+created.ID {
+		t.Fatalf("create returned %+v with Location %q", created, w.Header().Get("Location"))
+	}
+
+	steps := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"get", "GET", "/admin/promotions/" + created.ID, "", http.StatusOK},
+		{"list", "GET", "/admin/promotions", "", http.StatusOK},
+		{"update", "PUT", "/admin/promotions/" + created.ID, strings.Replace(campaign, `"multiplier":2`, `"bonus":50`, 1), http.StatusOK},
+		{"invalid campaign", "POST", "/admin/promotions", `{"name":"x","startDate":"2024-01-02","endDate":"2024-01-01","bonus":5}`, http.StatusBadRequest},
+		{"unknown field", "POST", "/admin/promotions", `{"name":"x","points":5}`, http.StatusBadRequest},
+		{"update missing", "PUT", "/admin/promotions/missing", campaign, http.StatusNotFound},
+		{"delete", "DELETE", "/admin/promotions/" + created.ID, "", http.StatusNoContent},
+		{"get deleted", "GET", "/admin/promotions/" + created.ID, "", http.StatusNotFound},
+		{"delete missing", "DELETE", "/admin/promotions/" + created.ID, "", http.StatusNotFound},
+	}
+
+	for _, s := range steps {
+		if w := send(s.method, s.path, s.body); w.Code != s.wantStatus {
+			t.Errorf("%s: status = %v %s, want %v", s.name, w.Code, w.Body, s.wantStatus)
+		}
+	}
+}
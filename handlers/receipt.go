@@ -1,30 +1,81 @@
 package handlers
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/suryamp/receipt-processor/authz"
 	"github.com/suryamp/receipt-processor/logger"
 	"github.com/suryamp/receipt-processor/models"
+	"github.com/suryamp/receipt-processor/problem"
 	"github.com/suryamp/receipt-processor/processor"
 	"github.com/suryamp/receipt-processor/validator"
 )
 
+// Limits bounds what ProcessReceiptHandler accepts. A zero field is not enforced.
+type Limits struct {
+	MaxBodyBytes    int64
+	MaxItems        int
+	MaxStringLength int
+}
+
+var DefaultLimits = Limits{
+	MaxBodyBytes:    1 << 20,
+	MaxItems:        500,
+	MaxStringLength: 256,
+}
+
 type Handler struct {
 	processor processor.ReceiptProcessor
+	limits    Limits
 }
 
 func NewHandler(p processor.ReceiptProcessor) *Handler {
-	return &Handler{processor: p}
+	return &Handler{processor: p, limits: DefaultLimits}
+}
+
+// WithLimits replaces the default request limits.
+func (h *Handler) WithLimits(l Limits) *Handler {
+	h.limits = l
+	return h
 }
 
 func (h *Handler) ProcessReceiptHandler(w http.ResponseWriter, r *http.Request) {
 	var receipt models.Receipt
 
-	if err := json.NewDecoder(r.Body).Decode(&receipt); err != nil {
+	if !isJSON(r) {
+		problem.Write(w, problem.Details{
+			Status: http.StatusUnsupportedMediaType,
+			Detail: "Content-Type must be application/json",
+		})
+		logger.ErrorLogger.Printf("Rejected receipt with Content-Type %q", r.Header.Get("Content-Type"))
+		return
+	}
+
+	if err := decodeJSON(w, r, h.limits.MaxBodyBytes, &receipt); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeTooLarge(w, fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+			return
+		}
 		http.Error(w, "The receipt is invalid.", http.StatusBadRequest)
-		logger.ErrorLogger.Printf("The receipt is invalid. HTTP JSON decoding failed.")
+		logger.ErrorLogger.Printf("The receipt is invalid. HTTP JSON decoding failed: %v", err)
+		return
+	}
+
+	if err := validator.ValidateLimits(receipt, h.limits.MaxItems, h.limits.MaxStringLength); err != nil {
+		if errors.Is(err, validator.ErrTooManyItems) {
+			writeTooLarge(w, err.Error())
+			return
+		}
+		http.Error(w, "The receipt is invalid.", http.StatusBadRequest)
+		logger.ErrorLogger.Printf("The receipt is invalid. %v", err)
 		return
 	}
 
@@ -34,7 +85,7 @@ func (h *Handler) ProcessReceiptHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	id, err := h.processor.ProcessReceipt(receipt)
+	id, err := h.processor.ProcessReceipt(r.Context(), receipt)
 	if err != nil {
 		http.Error(w, "The receipt is invalid.", http.StatusBadRequest)
 		logger.ErrorLogger.Printf("The receipt is invalid. Receipt processing failed.")
@@ -49,7 +100,13 @@ func (h *Handler) GetPointsHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	points, err := h.processor.GetPoints(id)
+	// Receipts are scored under the rules they were submitted under unless
+	// the caller asks for another version
+	score, err := h.processor.GetScoreAt(id, r.URL.Query().Get("rulesVersion"))
+	if errors.Is(err, processor.ErrUnknownVersion) {
+		problem.Write(w, problem.Details{Status: http.StatusBadRequest, Detail: err.Error()})
+		return
+	}
 	if err != nil {
 		http.Error(w, "No receipt found for that ID.", http.StatusNotFound)
 		logger.ErrorLogger.Printf("No receipt found for the ID: " + id)
@@ -57,5 +114,127 @@ func (h *Handler) GetPointsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(models.PointsResponse{Points: points})
+	json.NewEncoder(w).Encode(models.PointsResponse{
+		Points:       score.Points,
+		RulesVersion: score.RulesVersion,
+		Tier:         score.Tier,
+		TierBonus:    score.TierBonus,
+		Promotions:   score.Promotions,
+	})
+}
+
+// simulateRequest is a receipt to score and, optionally, candidate rules.
+// Candidate rules are laid over the current rules, so a proposal need only
+// name the fields it changes.
+type simulateRequest struct {
+	Receipt models.Receipt  `json:"receipt"`
+	Rules   json.RawMessage `json:"rules,omitempty"`
+}
+
+// SimulateHandler validates and scores a receipt without storing it.
+func (h *Handler) SimulateHandler(w http.ResponseWriter, r *http.Request) {
+	var req simulateRequest
+
+	if !isJSON(r) {
+		problem.Write(w, problem.Details{Status: http.StatusUnsupportedMediaType, Detail: "Content-Type must be application/json"})
+		return
+	}
+	if err := decodeJSON(w, r, h.limits.MaxBodyBytes, &req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeTooLarge(w, fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+			return
+		}
+		problem.Write(w, problem.Details{Status: http.StatusBadRequest, Detail: "invalid request: " + err.Error()})
+		return
+	}
+
+	if err := validator.ValidateLimits(req.Receipt, h.limits.MaxItems, h.limits.MaxStringLength); err != nil {
+		if errors.Is(err, validator.ErrTooManyItems) {
+			writeTooLarge(w, err.Error())
+			return
+		}
+		problem.Write(w, problem.Details{Status: http.StatusBadRequest, Detail: "invalid receipt: " + err.Error()})
+		return
+	}
+	if err := validator.ValidateReceipt(req.Receipt); err != nil {
+		problem.Write(w, problem.Details{Status: http.StatusBadRequest, Detail: "invalid receipt: " + err.Error()})
+		return
+	}
+
+	var candidate *processor.Rules
+	if len(req.Rules) > 0 {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(req.Rules, &fields); err != nil {
+			problem.Write(w, problem.Details{Status: http.StatusBadRequest, Detail: "invalid rules: " + err.Error()})
+			return
+		}
+		// Expressions run on the server, so only the server sets their limits
+		// and only admins, who may write the live ones, may send them
+		if _, ok := fields["expressionLimits"]; ok {
+			problem.Write(w, problem.Details{Status: http.StatusBadRequest, Detail: "invalid rules: expressionLimits cannot be overridden"})
+			return
+		}
+		if _, ok := fields["customRules"]; ok && !authz.Can(r.Context(), authz.AdminWrite) {
+			problem.Write(w, problem.Details{Status: http.StatusForbidden, Detail: "candidate customRules need permission " + string(authz.AdminWrite)})
+			return
+		}
+
+		rules := h.processor.CurrentRules()
+		dec := json.NewDecoder(bytes.NewReader(req.Rules))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&rules); err != nil {
+			problem.Write(w, problem.Details{Status: http.StatusBadRequest, Detail: "invalid rules: " + err.Error()})
+			return
+		}
+		candidate = &rules
+	}
+
+	score, err := h.processor.Simulate(req.Receipt, candidate)
+	if err != nil {
+		problem.Write(w, problem.Details{Status: http.StatusBadRequest, Detail: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, models.SimulateResponse{
+		Points:       score.Points,
+		BasePoints:   score.BasePoints,
+		RulesVersion: score.RulesVersion,
+		Breakdown:    score.Breakdown,
+		Tier:         score.Tier,
+		TierBonus:    score.TierBonus,
+		Promotions:   score.Promotions,
+	})
+}
+
+// isJSON reports whether the request declares a JSON body.
+func isJSON(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+// decodeJSON decodes exactly one JSON value from the body into v, rejecting
+// unknown fields, trailing data and bodies larger than maxBytes.
+func decodeJSON(w http.ResponseWriter, r *http.Request, maxBytes int64, v any) error {
+	body := r.Body
+	if maxBytes > 0 {
+		body = http.MaxBytesReader(w, r.Body, maxBytes)
+	}
+	dec := json.NewDecoder(body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return err
+		}
+		return fmt.Errorf("unexpected data after JSON value")
+	}
+	return nil
+}
+
+func writeTooLarge(w http.ResponseWriter, detail string) {
+	problem.Write(w, problem.Details{Status: http.StatusRequestEntityTooLarge, Detail: detail})
+	logger.ErrorLogger.Printf("Rejected receipt: %s", detail)
 }
@@ -2,15 +2,20 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gorilla/mux"
+	"github.com/suryamp/receipt-processor/authz"
 	"github.com/suryamp/receipt-processor/logger"
 	"github.com/suryamp/receipt-processor/models"
+	"github.com/suryamp/receipt-processor/problem"
+	"github.com/suryamp/receipt-processor/processor"
 )
 
 func init() {
@@ -25,7 +30,7 @@ type MockProcessor struct {
 	points      int64
 }
 
-func (m *MockProcessor) ProcessReceipt(receipt models.Receipt) (string, error) {
+func (m *MockProcessor) ProcessReceipt(ctx context.Context, receipt models.Receipt) (string, error) {
 	if m.shouldError {
 		return "", fmt.Errorf("mock error")
 	}
@@ -39,6 +44,31 @@ func (m *MockProcessor) GetPoints(id string) (int64, error) {
 	return m.points, nil
 }
 
+func (m *MockProcessor) GetScore(id string) (processor.Score, error) {
+	points, err := m.GetPoints(id)
+	return processor.Score{Points: points, BasePoints: points}, err
+}
+
+func (m *MockProcessor) CurrentRules() processor.Rules {
+	return processor.DefaultRules()
+}
+
+func (m *MockProcessor) Simulate(receipt models.Receipt, candidate *processor.Rules) (processor.Score, error) {
+	if candidate != nil {
+		if err := candidate.Validate(); err != nil {
+			return processor.Score{}, err
+		}
+	}
+	return processor.Score{Points: m.points, BasePoints: m.points}, nil
+}
+
+func (m *MockProcessor) GetScoreAt(id, rulesVersion string) (processor.Score, error) {
+	if rulesVersion == "missing" {
+		return processor.Score{}, processor.ErrUnknownVersion
+	}
+	return m.GetScore(id)
+}
+
 func TestProcessReceiptHandler(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -105,6 +135,7 @@ func TestProcessReceiptHandler(t *testing.T) {
 			}
 
 			req := httptest.NewRequest("POST", "/receipts/process", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
 			w := httptest.NewRecorder()
 
 			// Call handler
@@ -133,10 +164,105 @@ func TestProcessReceiptHandler(t *testing.T) {
 	}
 }
 
+func TestProcessReceiptHandlerRetailers(t *testing.T) {
+	rules := processor.DefaultRules()
+	rules.Retailers = []processor.Retailer{{
+		Name:      "Walgreens",
+		Overrides: processor.RuleOverrides{RetailerNameMultiplier: func(v int64) *int64 { return &v }(2)},
+	}}
+	p := processor.NewInMemoryProcessor(rules)
+	handler := NewHandler(p)
+
+	tests := []struct {
+		retailer   string
+		wantStatus int
+		wantPoints int64
+	}{
+		// 75 (round dollar and quarter) plus the name points, doubled for Walgreens
+		{"WALGREENS #123", http.StatusOK, 75 + 2*12},
+		{"Walgreens No. 17", http.StatusOK, 75 + 2*13},
+		{"Walgreen's", http.StatusOK, 75 + 9},
+		{"Target@$", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.retailer, func(t *testing.T) {
+			body, _ := json.Marshal(models.Receipt{
+				Retailer:     tt.retailer,
+				PurchaseDate: "2024-01-02",
+				PurchaseTime: "10:15",
+				Items:        []models.Item{{ShortDescription: "ab", Price: "5.00"}},
+				Total:        "5.00",
+			})
+			req := httptest.NewRequest("POST", "/receipts/process", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			handler.ProcessReceiptHandler(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("ProcessReceiptHandler() status = %v %s, want %v", w.Code, w.Body, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var got models.ProcessResponse
+			json.NewDecoder(w.Body).Decode(&got)
+			if points, _ := p.GetPoints(got.ID); points != tt.wantPoints {
+				t.Errorf("points = %d, want %d", points, tt.wantPoints)
+			}
+		})
+	}
+}
+
+func TestProcessReceiptHandlerLimits(t *testing.T) {
+	valid := `{"retailer":"Target","purchaseDate":"2024-01-01","purchaseTime":"13:01","total":"1.25","items":[{"shortDescription":"Mountain Dew","price":"1.25"}]}`
+	twoItems := `{"retailer":"Target","purchaseDate":"2024-01-01","purchaseTime":"13:01","total":"2.50","items":[{"shortDescription":"Dew","price":"1.25"},{"shortDescription":"Dew","price":"1.25"}]}`
+	limits := Limits{MaxBodyBytes: 256, MaxItems: 1, MaxStringLength: 20}
+
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		wantStatus  int
+		wantProblem bool
+	}{
+		{name: "valid receipt", body: valid, contentType: "application/json", wantStatus: http.StatusOK},
+		{name: "content type with charset", body: valid, contentType: "application/json; charset=utf-8", wantStatus: http.StatusOK},
+		{name: "missing content type", body: valid, wantStatus: http.StatusUnsupportedMediaType, wantProblem: true},
+		{name: "wrong content type", body: valid, contentType: "text/plain", wantStatus: http.StatusUnsupportedMediaType, wantProblem: true},
+		{name: "body too large", body: valid + strings.Repeat(" ", 256), contentType: "application/json", wantStatus: http.StatusRequestEntityTooLarge, wantProblem: true},
+		{name: "too many items", body: twoItems, contentType: "application/json", wantStatus: http.StatusRequestEntityTooLarge, wantProblem: true},
+		{name: "unknown field", body: strings.Replace(valid, `"total"`, `"points":100,"total"`, 1), contentType: "application/json", wantStatus: http.StatusBadRequest},
+		{name: "trailing data", body: valid + `{}`, contentType: "application/json", wantStatus: http.StatusBadRequest},
+		{name: "string too long", body: strings.Replace(valid, "Mountain Dew", strings.Repeat("a", 21), 1), contentType: "application/json", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHandler(&MockProcessor{}).WithLimits(limits)
+			req := httptest.NewRequest("POST", "/receipts/process", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			handler.ProcessReceiptHandler(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("ProcessReceiptHandler() status = %v, want %v (body %s)", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantProblem {
+				var details problem.Details
+				if err := json.NewDecoder(w.Body).Decode(&details); err != nil || details.Status != tt.wantStatus || details.Detail == "" {
+					t.Errorf("ProcessReceiptHandler() problem = %+v, %v, want status %v with detail", details, err, tt.wantStatus)
+				}
+			}
+		})
+	}
+}
+
 func TestGetPointsHandler(t *testing.T) {
 	tests := []struct {
 		name         string
 		id           string
+		query        string
 		points       int64
 		shouldError  bool
 		wantStatus   int
@@ -157,6 +283,19 @@ func TestGetPointsHandler(t *testing.T) {
 			wantStatus:   http.StatusNotFound,
 			wantResponse: "No receipt found for that ID.\n",
 		},
+		{
+			name:       "other rules version",
+			id:         "test-id",
+			query:      "?rulesVersion=abc",
+			points:     100,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "unknown rules version",
+			id:         "test-id",
+			query:      "?rulesVersion=missing",
+			wantStatus: http.StatusBadRequest,
+		},
 	}
 
 	for _, tt := range tests {
@@ -169,7 +308,7 @@ func TestGetPointsHandler(t *testing.T) {
 			handler := NewHandler(mockProc)
 
 			// Create request with mux vars
-			req := httptest.NewRequest("GET", "/receipts/{id}/points", nil)
+			req := httptest.NewRequest("GET", "/receipts/{id}/points"+tt.query, nil)
 			w := httptest.NewRecorder()
 
 			// Add URL parameters to request
@@ -195,7 +334,7 @@ func TestGetPointsHandler(t *testing.T) {
 				if got.Points != tt.points {
 					t.Errorf("GetPointsHandler() response = %v, want %v", got.Points, tt.points)
 				}
-			} else {
+			} else if tt.wantResponse != "" {
 				if got := w.Body.String(); got != tt.wantResponse {
 					t.Errorf("GetPointsHandler() response = %v, want %v", got, tt.wantResponse)
 				}
@@ -203,3 +342,70 @@ func TestGetPointsHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestSimulateHandler(t *testing.T) {
+	receipt := `{"retailer":"Target","purchaseDate":"2024-01-02","purchaseTime":"13:01","items":[{"shortDescription":"Pepsi","price":"1.25"}],"total":"1.25"}`
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int
+		wantPoints  int64
+	}{
+		// 6 (retailer) + 25 (multiple of 0.25)
+		{"current rules", "application/json", `{"receipt":` + receipt + `}`, http.StatusOK, 31},
+		{"candidate rules", "application/json", `{"receipt":` + receipt + `,"rules":{"quarterDollarPoints":100}}`, http.StatusOK, 106},
+		{"invalid candidate rules", "application/json", `{"receipt":` + receipt + `,"rules":{"happyHourEnd":0}}`, http.StatusBadRequest, 0},
+		{"unknown rules field", "application/json", `{"receipt":` + receipt + `,"rules":{"bonus":1}}`, http.StatusBadRequest, 0},
+		{"invalid receipt", "application/json", `{"receipt":{"retailer":"Target"}}`, http.StatusBadRequest, 0},
+		{"bare receipt", "application/json", receipt, http.StatusBadRequest, 0},
+		{"not json", "text/plain", `{"receipt":` + receipt + `}`, http.StatusUnsupportedMediaType, 0},
+		{"expression limits", "application/json", `{"receipt":` + receipt + `,"rules":{"expressionLimits":{"maxSteps":1000000000}}}`, http.StatusBadRequest, 0},
+		{"custom rules without admin", "application/json", `{"receipt":` + receipt + `,"rules":{"customRules":[{"name":"bonus","expression":"3"}]}}`, http.StatusForbidden, 0},
+	}
+
+	p := processor.NewInMemoryProcessor(processor.DefaultRules())
+	handler := NewHandler(p)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/points/simulate", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			req = req.WithContext(authz.WithCheck(req.Context(), func(perm authz.Permission) bool { return perm != authz.AdminWrite }))
+			w := httptest.NewRecorder()
+			handler.SimulateHandler(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("SimulateHandler() status = %v %s, want %v", w.Code, w.Body, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if got := w.Header().Get("Content-Type"); got != problem.ContentType {
+					t.Errorf("SimulateHandler() Content-Type = %q, want %q", got, problem.ContentType)
+				}
+				return
+			}
+			var got models.SimulateResponse
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if got.Points != tt.wantPoints || len(got.Breakdown) != 7 {
+				t.Errorf("SimulateHandler() = %+v, want %d points from 7 rules", got, tt.wantPoints)
+			}
+		})
+	}
+
+	// Admins may try custom rules
+	body := `{"receipt":` + receipt + `,"rules":{"customRules":[{"name":"bonus","expression":"3"}]}}`
+	req := httptest.NewRequest("POST", "/points/simulate", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.SimulateHandler(w, req)
+	var got models.SimulateResponse
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil || w.Code != http.StatusOK || got.Points != 34 {
+		t.Errorf("SimulateHandler() with custom rules as admin = %v %+v, want 34 points", w.Code, got)
+	}
+
+	if versions := p.RulesVersions(); len(versions) != 1 || versions[0].Receipts != 0 {
+		t.Errorf("simulating changed the processor: %+v", versions)
+	}
+}
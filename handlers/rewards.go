@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/suryamp/receipt-processor/ledger"
+	"github.com/suryamp/receipt-processor/logger"
+	"github.com/suryamp/receipt-processor/problem"
+	"github.com/suryamp/receipt-processor/rewards"
+	"github.com/suryamp/receipt-processor/users"
+)
+
+// maxRewardBytes bounds reward and redemption request bodies.
+const maxRewardBytes = 16 << 10
+
+// RewardsHandler serves the reward catalog, its admin API, and users'
+// redemptions.
+type RewardsHandler struct {
+	store *rewards.Store
+	users *users.Store
+}
+
+func NewRewardsHandler(store *rewards.Store, accounts *users.Store) *RewardsHandler {
+	return &RewardsHandler{store: store, users: accounts}
+}
+
+// redeemRequest names the reward to spend points on.
+type redeemRequest struct {
+	RewardID string `json:"rewardId"`
+}
+
+// redemptionPage is one page of a user's redemptions, newest first.
+type redemptionPage struct {
+	UserID      string               `json:"userId"`
+	Total       int                  `json:"total"`
+	Offset      int                  `json:"offset"`
+	Limit       int                  `json:"limit"`
+	Redemptions []rewards.Redemption `json:"redemptions"`
+}
+
+// CatalogHandler lists the rewards that can be redeemed now.
+func (h *RewardsHandler) CatalogHandler(w http.ResponseWriter, r *http.Request) {
+	available := h.store.Available()
+	if available == nil {
+		available = []rewards.Reward{}
+	}
+	writeJSON(w, http.StatusOK, available)
+}
+
+func (h *RewardsHandler) ListHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.store.List())
+}
+
+func (h *RewardsHandler) GetHandler(w http.ResponseWriter, r *http.Request) {
+	reward, err := h.store.Get(mux.Vars(r)["id"])
+	if err != nil {
+		writeRewardError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, reward)
+}
+
+func (h *RewardsHandler) CreateHandler(w http.ResponseWriter, r *http.Request) {
+	reward, ok := decodeReward(w, r)
+	if !ok {
+		return
+	}
+	reward, err := h.store.Create(reward)
+	if err != nil {
+		writeRewardError(w, err)
+		return
+	}
+	logger.InfoLogger.Printf("Created reward %s (%s)", reward.ID, reward.Name)
+	w.Header().Set("Location", "/admin/rewards/"+reward.ID)
+	writeJSON(w, http.StatusCreated, reward)
+}
+
+func (h *RewardsHandler) UpdateHandler(w http.ResponseWriter, r *http.Request) {
+	reward, ok := decodeReward(w, r)
+	if !ok {
+		return
+	}
+	reward, err := h.store.Update(mux.Vars(r)["id"], reward)
+	if err != nil {
+		writeRewardError(w, err)
+		return
+	}
+	logger.InfoLogger.Printf("Updated reward %s (%s)", reward.ID, reward.Name)
+	writeJSON(w, http.StatusOK, reward)
+}
+
+func (h *RewardsHandler) DeleteHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if err := h.store.Delete(id); err != nil {
+		writeRewardError(w, err)
+		return
+	}
+	logger.InfoLogger.Printf("Deleted reward %s", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RedeemHandler spends a user's points on a reward.
+func (h *RewardsHandler) RedeemHandler(w http.ResponseWriter, r *http.Request) {
+	var req redeemRequest
+	if !isJSON(r) {
+		problem.Write(w, problem.Details{Status: http.StatusUnsupportedMediaType, Detail: "Content-Type must be application/json"})
+		return
+	}
+	if err := decodeJSON(w, r, maxRewardBytes, &req); err != nil || req.RewardID == "" {
+		detail := "rewardId is required"
+		if err != nil {
+			detail = "invalid redemption: " + err.Error()
+		}
+		problem.Write(w, problem.Details{Status: http.StatusBadRequest, Detail: detail})
+		return
+	}
+
+	userID := mux.Vars(r)["id"]
+	if _, err := h.users.Get(userID); err != nil {
+		writeRewardError(w, err)
+		return
+	}
+	redemption, err := h.store.Redeem(userID, req.RewardID)
+	if err != nil {
+		writeRewardError(w, err)
+		return
+	}
+	logger.InfoLogger.Printf("User %s redeemed %d points for reward %s (redemption %s)", userID, redemption.Cost, redemption.RewardID, redemption.ID)
+	w.Header().Set("Location", "/users/"+userID+"/redemptions/"+redemption.ID)
+	writeJSON(w, http.StatusCreated, redemption)
+}
+
+// RedemptionsHandler lists a user's redemptions, newest first.
+func (h *RewardsHandler) RedemptionsHandler(w http.ResponseWriter, r *http.Request) {
+	offset, limit, err := pageParams(r)
+	if err != nil {
+		problem.Write(w, problem.Details{Status: http.StatusBadRequest, Detail: err.Error()})
+		return
+	}
+	userID := mux.Vars(r)["id"]
+	if _, err := h.users.Get(userID); err != nil {
+		writeRewardError(w, err)
+		return
+	}
+	page, total := h.store.Redemptions(userID, offset, limit)
+	writeJSON(w, http.StatusOK, redemptionPage{UserID: userID, Total: total, Offset: offset, Limit: limit, Redemptions: page})
+}
+
+// CancelHandler refunds a redemption.
+func (h *RewardsHandler) CancelHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	redemption, err := h.store.Cancel(vars["id"], vars["redemptionId"])
+	if err != nil {
+		writeRewardError(w, err)
+		return
+	}
+	logger.InfoLogger.Printf("User %s cancelled redemption %s, refunding %d points", redemption.UserID, redemption.ID, redemption.Cost)
+	writeJSON(w, http.StatusOK, redemption)
+}
+
+func decodeReward(w http.ResponseWriter, r *http.Request) (rewards.Reward, bool) {
+	var reward rewards.Reward
+	if !isJSON(r) {
+		problem.Write(w, problem.Details{Status: http.StatusUnsupportedMediaType, Detail: "Content-Type must be application/json"})
+		return reward, false
+	}
+	if err := decodeJSON(w, r, maxRewardBytes, &reward); err != nil {
+		problem.Write(w, problem.Details{Status: http.StatusBadRequest, Detail: "invalid reward: " + err.Error()})
+		return reward, false
+	}
+	return reward, true
+}
+
+func writeRewardError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, rewards.ErrNotFound), errors.Is(err, rewards.ErrRedemptionNotFound), errors.Is(err, users.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ledger.ErrInsufficientPoints), errors.Is(err, rewards.ErrOutOfStock),
+		errors.Is(err, rewards.ErrUnavailable), errors.Is(err, rewards.ErrCancelled), errors.Is(err, ledger.ErrAlreadyReversed):
+		status = http.StatusConflict
+	}
+	problem.Write(w, problem.Details{Status: status, Detail: err.Error()})
+}
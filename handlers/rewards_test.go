@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/suryamp/receipt-processor/ledger"
+	"github.com/suryamp/receipt-processor/rewards"
+	"github.com/suryamp/receipt-processor/users"
+)
+
+func TestRewardsHandler(t *testing.T) {
+	l := ledger.New()
+	accounts := users.NewStore(l)
+	accounts.AddReceipt("alice", users.Receipt{ID: "r1", Points: 150})
+	h := NewRewardsHandler(rewards.NewStore(l), accounts)
+	router := mux.NewRouter()
+	router.HandleFunc("/rewards", h.CatalogHandler).Methods("GET")
+	router.HandleFunc("/users/{id}/redemptions", h.RedeemHandler).Methods("POST")
+	router.HandleFunc("/users/{id}/redemptions", h.RedemptionsHandler).Methods("GET")
+	router.HandleFunc("/users/{id}/redemptions/{redemptionId}/cancellation", h.CancelHandler).Methods("POST")
+	router.HandleFunc("/admin/rewards", h.ListHandler).Methods("GET")
+	router.HandleFunc("/admin/rewards", h.CreateHandler).Methods("POST")
+	router.HandleFunc("/admin/rewards/{id}", h.GetHandler).Methods("GET")
+	router.HandleFunc("/admin/rewards/{id}", h.UpdateHandler).Methods("PUT")
+	router.HandleFunc("/admin/rewards/{id}", h.DeleteHandler).Methods("DELETE")
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("POST", "/admin/rewards", `{"name":"Mug","cost":100,"inventory":2}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create reward = %v %s, want 201", w.Code, w.Body)
+	}
+	var mug rewards.Reward
+	json.NewDecoder(w.Body).Decode(&mug)
+
+	w = send("POST", "/users/alice/redemptions", `{"rewardId":"`+mug.ID+`"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("redeem = %v %s, want 201", w.Code, w.Body)
+	}
+	var redemption rewards.Redemption
+	json.NewDecoder(w.Body).Decode(&redemption)
+	cancellation := "/users/alice/redemptions/" + redemption.ID + "/cancellation"
+
+	steps := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"create invalid reward", "POST", "/admin/rewards", `{"name":"Free","cost":0}`, http.StatusBadRequest},
+		{"create unknown field", "POST", "/admin/rewards", `{"name":"Mug","price":5}`, http.StatusBadRequest},
+		{"get missing reward", "GET", "/admin/rewards/missing", "", http.StatusNotFound},
+		{"update missing reward", "PUT", "/admin/rewards/missing", `{"name":"Mug","cost":100}`, http.StatusNotFound},
+		{"list rewards", "GET", "/admin/rewards", "", http.StatusOK},
+		{"catalog", "GET", "/rewards", "", http.StatusOK},
+		{"overdraft", "POST", "/users/alice/redemptions", `{"rewardId":"` + mug.ID + `"}`, http.StatusConflict},
+		{"unknown user", "POST", "/users/bob/redemptions", `{"rewardId":"` + mug.ID + `"}`, http.StatusNotFound},
+		{"unknown reward", "POST", "/users/alice/redemptions", `{"rewardId":"missing"}`, http.StatusNotFound},
+		{"no reward", "POST", "/users/alice/redemptions", `{}`, http.StatusBadRequest},
+		{"list redemptions", "GET", "/users/alice/redemptions", "", http.StatusOK},
+		{"cancel another user's", "POST", "/users/bob/redemptions/" + redemption.ID + "/cancellation", "", http.StatusNotFound},
+		{"cancel", "POST", cancellation, "", http.StatusOK},
+		{"cancel twice", "POST", cancellation, "", http.StatusConflict},
+		{"delete reward", "DELETE", "/admin/rewards/" + mug.ID, "", http.StatusNoContent},
+		{"delete missing reward", "DELETE", "/admin/rewards/" + mug.ID, "", http.StatusNotFound},
+	}
+	for _, s := range steps {
+		if w := send(s.method, s.path, s.body); w.Code != s.wantStatus {
+			t.Errorf("%s: status = %v %s, want %v", s.name, w.Code, w.Body, s.wantStatus)
+		}
+	}
+
+	if u, _ := accounts.Get("alice"); u.Balance != 150 {
+		t.Errorf("balance after cancelling = %d, want 150", u.Balance)
+	}
+	var page redemptionPage
+	json.NewDecoder(send("GET", "/users/alice/redemptions", "").Body).Decode(&page)
+	if page.Total != 1 || page.Redemptions[0].Status != rewards.StatusCancelled {
+		t.Errorf("redemptions = %+v, want one cancelled", page)
+	}
+}
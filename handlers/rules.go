@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/suryamp/receipt-processor/problem"
+	"github.com/suryamp/receipt-processor/processor"
+)
+
+// maxRescoreBytes bounds rescore request bodies.
+const maxRescoreBytes = 4 << 10
+
+// RulesHandler serves the admin API for rules versions, bulk rescoring and
+// shadow evaluation.
+type RulesHandler struct {
+	processor *processor.InMemoryProcessor
+}
+
+func NewRulesHandler(p *processor.InMemoryProcessor) *RulesHandler {
+	return &RulesHandler{processor: p}
+}
+
+// VersionsHandler lists every rules version receipts can be scored under.
+func (h *RulesHandler) VersionsHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.processor.RulesVersions())
+}
+
+// VersionHandler returns the rules of one version.
+func (h *RulesHandler) VersionHandler(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.processor.Rules(mux.Vars(r)["version"])
+	if err != nil {
+		problem.Write(w, problem.Details{Status: http.StatusNotFound, Detail: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, rules)
+}
+
+// StartRescoreHandler starts a bulk rescore and points to its report.
+func (h *RulesHandler) StartRescoreHandler(w http.ResponseWriter, r *http.Request) {
+	var req processor.RescoreRequest
+	if !isJSON(r) {
+		problem.Write(w, problem.Details{Status: http.StatusUnsupportedMediaType, Detail: "Content-Type must be application/json"})
+		return
+	}
+	if err := decodeJSON(w, r, maxRescoreBytes, &req); err != nil {
+		problem.Write(w, problem.Details{Status: http.StatusBadRequest, Detail: "invalid rescore request: " + err.Error()})
+		return
+	}
+
+	job, err := h.processor.StartRescore(req)
+	switch {
+	case errors.Is(err, processor.ErrUnknownVersion):
+		problem.Write(w, problem.Details{Status: http.StatusBadRequest, Detail: err.Error()})
+		return
+	case errors.Is(err, processor.ErrRescoreRunning):
+		problem.Write(w, problem.Details{Status: http.StatusConflict, Detail: err.Error()})
+		return
+	case err != nil:
+		problem.Write(w, problem.Details{Status: http.StatusInternalServerError, Detail: err.Error()})
+		return
+	}
+	w.Header().Set("Location", "/admin/rescore/"+job.ID)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// RescoreHandler reports a rescore's progress and, once done, its diff.
+func (h *RulesHandler) RescoreHandler(w http.ResponseWriter, r *http.Request) {
+	job, err := h.processor.Rescore(mux.Vars(r)["id"])
+	if err != nil {
+		problem.Write(w, problem.Details{Status: http.StatusNotFound, Detail: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// ShadowReportHandler compares payouts under the candidate and active rules.
+func (h *RulesHandler) ShadowReportHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.processor.ShadowReport())
+}
+
+// ShadowDeltaHandler returns one receipt's shadow evaluation.
+func (h *RulesHandler) ShadowDeltaHandler(w http.ResponseWriter, r *http.Request) {
+	delta, err := h.processor.ShadowDelta(mux.Vars(r)["id"])
+	if err != nil {
+		problem.Write(w, problem.Details{Status: http.StatusNotFound, Detail: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, delta)
+}
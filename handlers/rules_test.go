@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/suryamp/receipt-processor/processor"
+)
+
+func TestRulesHandler(t *testing.T) {
+	p := processor.NewInMemoryProcessor(processor.DefaultRules())
+	version := processor.DefaultRules().Version()
+	h := NewRulesHandler(p)
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/rules/versions", h.VersionsHandler).Methods("GET")
+	router.HandleFunc("/admin/rules/versions/{version}", h.VersionHandler).Methods("GET")
+	router.HandleFunc("/admin/rescore", h.StartRescoreHandler).Methods("POST")
+	router.HandleFunc("/admin/rescore/{id}", h.RescoreHandler).Methods("GET")
+	router.HandleFunc("/admin/shadow", h.ShadowReportHandler).Methods("GET")
+	router.HandleFunc("/admin/shadow/receipts/{id}", h.ShadowDeltaHandler).Methods("GET")
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	steps := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"list versions", "GET", "/admin/rules/versions", "", http.StatusOK},
+		{"get version", "GET", "/admin/rules/versions/" + version, "", http.StatusOK},
+		{"get missing version", "GET", "/admin/rules/versions/missing", "", http.StatusNotFound},
+		{"rescore missing version", "POST", "/admin/rescore", `{"rulesVersion":"missing"}`, http.StatusBadRequest},
+		{"rescore unknown field", "POST", "/admin/rescore", `{"version":"x"}`, http.StatusBadRequest},
+		{"get missing job", "GET", "/admin/rescore/missing", "", http.StatusNotFound},
+		{"shadow report", "GET", "/admin/shadow", "", http.StatusOK},
+		{"shadow delta without candidate", "GET", "/admin/shadow/receipts/missing", "", http.StatusNotFound},
+	}
+	for _, s := range steps {
+		if w := send(s.method, s.path, s.body); w.Code != s.wantStatus {
+			t.Errorf("%s: status = %v %s, want %v", s.name, w.Code, w.Body, s.wantStatus)
+		}
+	}
+
+	w := send("POST", "/admin/rescore", `{"dryRun":true}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("start rescore = %v %s, want 202", w.Code, w.Body)
+	}
+	var job processor.RescoreJob
+	json.NewDecoder(w.Body).Decode(&job)
+	location := w.Header().Get("Location")
+	if job.ID == "" || location != "/admin/rescore/"+job.ID || job.RulesVersion != version {
+		t.Fatalf("start rescore returned %+v with Location %q", job, location)
+	}
+	for deadline := time.Now().Add(5 * time.Second); job.Status != processor.RescoreDone && time.Now().Before(deadline); {
+		w := send("GET", location, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("get rescore = %v %s, want 200", w.Code, w.Body)
+		}
+		json.NewDecoder(w.Body).Decode(&job)
+	}
+	if job.Status != processor.RescoreDone {
+		t.Errorf("rescore status = %s, want %s", job.Status, processor.RescoreDone)
+	}
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/suryamp/receipt-processor/problem"
+	"github.com/suryamp/receipt-processor/stats"
+)
+
+// groupings maps the path of each grouped stats endpoint to its grouping.
+var groupings = map[string]stats.Grouping{
+	"retailers": stats.ByRetailer,
+	"days":      stats.ByDay,
+	"weeks":     stats.ByWeek,
+	"months":    stats.ByMonth,
+}
+
+// StatsHandler serves summaries of the stored receipts.
+type StatsHandler struct {
+	rollup *stats.Rollup
+}
+
+func NewStatsHandler(rollup *stats.Rollup) *StatsHandler {
+	return &StatsHandler{rollup: rollup}
+}
+
+// SummaryHandler summarizes the receipts purchased between ?from= and ?to=,
+// optionally from one ?retailer=, and groups them as the path asks.
+func (h *StatsHandler) SummaryHandler(w http.ResponseWriter, r *http.Request) {
+	q := stats.Query{
+		From:     r.URL.Query().Get("from"),
+		To:       r.URL.Query().Get("to"),
+		Retailer: r.URL.Query().Get("retailer"),
+	}
+	if by, ok := mux.Vars(r)["groupBy"]; ok {
+		if q.GroupBy, ok = groupings[by]; !ok {
+			problem.Write(w, problem.Details{Status: http.StatusNotFound, Detail: "stats can be grouped by retailers, days, weeks or months"})
+			return
+		}
+	}
+
+	report, err := h.rollup.Query(q)
+	if err != nil {
+		problem.Write(w, problem.Details{Status: http.StatusBadRequest, Detail: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
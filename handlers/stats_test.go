@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/suryamp/receipt-processor/stats"
+)
+
+func TestStatsHandler(t *testing.T) {
+	rollup := stats.NewRollup()
+	rollup.Add("2024-01-01", "Target", "10.00", 2, 100)
+	rollup.Add("2024-02-01", "Walgreens", "5.00", 1, 20)
+
+	router := mux.NewRouter()
+	h := NewStatsHandler(rollup)
+	router.HandleFunc("/stats", h.SummaryHandler).Methods("GET")
+	router.HandleFunc("/stats/{groupBy}", h.SummaryHandler).Methods("GET")
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"total", "/stats", http.StatusOK, `"total":{"receipts":2,"points":120`},
+		{"range", "/stats?from=2024-01-15", http.StatusOK, `"total":{"receipts":1,"points":20`},
+		{"by retailer", "/stats/retailers?retailer=Target", http.StatusOK, `"groups":[{"key":"Target","receipts":1`},
+		{"by month", "/stats/months", http.StatusOK, `{"key":"2024-02","receipts":1`},
+		{"unknown grouping", "/stats/years", http.StatusNotFound, ""},
+		{"invalid date", "/stats/days?from=yesterday", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %v %s, want %v", w.Code, w.Body, tt.wantStatus)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", w.Body, tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/suryamp/receipt-processor/problem"
+	"github.com/suryamp/receipt-processor/tiers"
+)
+
+// TiersHandler serves the tier ladder, users' progress along it, and tier
+// changes.
+type TiersHandler struct {
+	tracker *tiers.Tracker // nil when tiers are off
+}
+
+func NewTiersHandler(tracker *tiers.Tracker) *TiersHandler {
+	return &TiersHandler{tracker: tracker}
+}
+
+// changePage is one page of tier changes, newest first.
+type changePage struct {
+	Total   int            `json:"total"`
+	Offset  int            `json:"offset"`
+	Limit   int            `json:"limit"`
+	Changes []tiers.Change `json:"changes"`
+}
+
+// recalculation reports how many users a recalculation moved.
+type recalculation struct {
+	Changed int `json:"changed"`
+}
+
+// enabled writes a 404 and returns false when tiers are off.
+func (h *TiersHandler) enabled(w http.ResponseWriter) bool {
+	if h.tracker == nil {
+		problem.Write(w, problem.Details{Status: http.StatusNotFound, Detail: "tiers are not enabled"})
+		return false
+	}
+	return true
+}
+
+// LadderHandler lists the tiers from lowest to highest.
+func (h *TiersHandler) LadderHandler(w http.ResponseWriter, r *http.Request) {
+	if h.enabled(w) {
+		writeJSON(w, http.StatusOK, h.tracker.Ladder())
+	}
+}
+
+// ProgressHandler returns a user's tier and the points they need for the next.
+func (h *TiersHandler) ProgressHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.enabled(w) {
+		return
+	}
+	progress, err := h.tracker.Progress(mux.Vars(r)["id"])
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, progress)
+}
+
+// ChangesHandler lists tier changes, newest first.
+func (h *TiersHandler) ChangesHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.enabled(w) {
+		return
+	}
+	offset, limit, err := pageParams(r)
+	if err != nil {
+		problem.Write(w, problem.Details{Status: http.StatusBadRequest, Detail: err.Error()})
+		return
+	}
+	changes, total := h.tracker.Changes(offset, limit)
+	writeJSON(w, http.StatusOK, changePage{Total: total, Offset: offset, Limit: limit, Changes: changes})
+}
+
+// RecalculateHandler re-evaluates every user now rather than waiting for
+// the nightly run.
+func (h *TiersHandler) RecalculateHandler(w http.ResponseWriter, r *http.Request) {
+	if h.enabled(w) {
+		writeJSON(w, http.StatusOK, recalculation{Changed: h.tracker.Recalculate()})
+	}
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/suryamp/receipt-processor/ledger"
+	"github.com/suryamp/receipt-processor/tiers"
+	"github.com/suryamp/receipt-processor/users"
+)
+
+func TestTiersHandler(t *testing.T) {
+	accounts := users.NewStore(ledger.New())
+	accounts.AddReceipt("alice", users.Receipt{ID: "r1", Points: 1200})
+	tracker := tiers.NewTracker(tiers.DefaultLadder(), accounts)
+
+	router := mux.NewRouter()
+	h := NewTiersHandler(tracker)
+	router.HandleFunc("/tiers", h.LadderHandler).Methods("GET")
+	router.HandleFunc("/users/{id}/tier", h.ProgressHandler).Methods("GET")
+	router.HandleFunc("/admin/tiers/changes", h.ChangesHandler).Methods("GET")
+	router.HandleFunc("/admin/tiers/recalculate", h.RecalculateHandler).Methods("POST")
+	disabled := NewTiersHandler(nil)
+	router.HandleFunc("/disabled/users/{id}/tier", disabled.ProgressHandler).Methods("GET")
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"ladder", "GET", "/tiers", http.StatusOK, `"name":"Platinum"`},
+		{"progress", "GET", "/users/alice/tier", http.StatusOK, `"pointsToNext":3800`},
+		{"unknown user", "GET", "/users/bob/tier", http.StatusNotFound, ""},
+		{"recalculate", "POST", "/admin/tiers/recalculate", http.StatusOK, `"changed":1`},
+		{"changes", "GET", "/admin/tiers/changes", http.StatusOK, `"to":"Silver"`},
+		{"invalid page", "GET", "/admin/tiers/changes?limit=0", http.StatusBadRequest, ""},
+		{"tiers disabled", "GET", "/disabled/users/alice/tier", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %v %s, want %v", w.Code, w.Body, tt.wantStatus)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", w.Body, tt.wantBody)
+			}
+		})
+	}
+}
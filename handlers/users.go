@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/suryamp/receipt-processor/ledger"
+	"github.com/suryamp/receipt-processor/problem"
+	"github.com/suryamp/receipt-processor/users"
+)
+
+// Page sizes for listing a user's receipts and ledger entries.
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// maxAdjustmentBytes bounds adjustment request bodies.
+const maxAdjustmentBytes = 4 << 10
+
+// UsersHandler serves users' balances, receipt histories and ledger entries.
+type UsersHandler struct {
+	store *users.Store
+}
+
+func NewUsersHandler(store *users.Store) *UsersHandler {
+	return &UsersHandler{store: store}
+}
+
+// receiptPage is one page of a user's receipts, newest first.
+type receiptPage struct {
+	UserID   string          `json:"userId"`
+	Total    int             `json:"total"`
+	Offset   int             `json:"offset"`
+	Limit    int             `json:"limit"`
+	Receipts []users.Receipt `json:"receipts"`
+}
+
+// entryPage is one page of a user's ledger entries, newest first.
+type entryPage struct {
+	UserID  string         `json:"userId"`
+	Total   int            `json:"total"`
+	Offset  int            `json:"offset"`
+	Limit   int            `json:"limit"`
+	Entries []ledger.Entry `json:"entries"`
+}
+
+// adjustmentRequest is a manual correction to a user's points.
+type adjustmentRequest struct {
+	Points int64  `json:"points"`
+	Memo   string `json:"memo"`
+}
+
+func (h *UsersHandler) BalanceHandler(w http.ResponseWriter, r *http.Request) {
+	u, err := h.store.Get(mux.Vars(r)["id"])
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, u)
+}
+
+func (h *UsersHandler) ReceiptsHandler(w http.ResponseWriter, r *http.Request) {
+	offset, limit, err := pageParams(r)
+	if err != nil {
+		problem.Write(w, problem.Details{Status: http.StatusBadRequest, Detail: err.Error()})
+		return
+	}
+	id := mux.Vars(r)["id"]
+	receipts, total, err := h.store.Receipts(id, offset, limit)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, receiptPage{UserID: id, Total: total, Offset: offset, Limit: limit, Receipts: receipts})
+}
+
+func (h *UsersHandler) LedgerHandler(w http.ResponseWriter, r *http.Request) {
+	offset, limit, err := pageParams(r)
+	if err != nil {
+		problem.Write(w, problem.Details{Status: http.StatusBadRequest, Detail: err.Error()})
+		return
+	}
+	id := mux.Vars(r)["id"]
+	entries, total, err := h.store.Entries(id, offset, limit)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, entryPage{UserID: id, Total: total, Offset: offset, Limit: limit, Entries: entries})
+}
+
+// AdjustHandler posts a manual correction to a user's points.
+func (h *UsersHandler) AdjustHandler(w http.ResponseWriter, r *http.Request) {
+	var req adjustmentRequest
+	if !isJSON(r) {
+		problem.Write(w, problem.Details{Status: http.StatusUnsupportedMediaType, Detail: "Content-Type must be application/json"})
+		return
+	}
+	if err := decodeJSON(w, r, maxAdjustmentBytes, &req); err != nil {
+		problem.Write(w, problem.Details{Status: http.StatusBadRequest, Detail: "invalid adjustment: " + err.Error()})
+		return
+	}
+	if req.Points == 0 || strings.TrimSpace(req.Memo) == "" {
+		problem.Write(w, problem.Details{Status: http.StatusBadRequest, Detail: "an adjustment needs non-zero points and a memo"})
+		return
+	}
+
+	entry, err := h.store.Adjust(mux.Vars(r)["id"], req.Points, req.Memo)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/admin/ledger/entries/"+entry.ID)
+	writeJSON(w, http.StatusCreated, entry)
+}
+
+// pageParams reads the offset and limit query parameters.
+func pageParams(r *http.Request) (offset, limit int, err error) {
+	limit = defaultPageSize
+	q := r.URL.Query()
+	if v := q.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			return 0, 0, errors.New("offset must be a non-negative integer")
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxPageSize {
+			return 0, 0, errors.New("limit must be between 1 and " + strconv.Itoa(maxPageSize))
+		}
+	}
+	return offset, limit, nil
+}
+
+func writeUserError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, users.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ledger.ErrInsufficientPoints):
+		status = http.StatusConflict
+	}
+	problem.Write(w, problem.Details{Status: status, Detail: err.Error()})
+}
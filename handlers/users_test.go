@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/suryamp/receipt-processor/ledger"
+	"github.com/suryamp/receipt-processor/users"
+)
+
+func TestUsersHandler(t *testing.T) {
+	store := users.NewStore(ledger.New())
+	store.AddReceipt("alice", users.Receipt{ID: "r1", Points: 10})
+	store.AddReceipt("alice", users.Receipt{ID: "r2", Points: 20})
+	h := NewUsersHandler(store)
+	router := mux.NewRouter()
+	router.HandleFunc("/users/{id}/balance", h.BalanceHandler).Methods("GET")
+	router.HandleFunc("/users/{id}/receipts", h.ReceiptsHandler).Methods("GET")
+	router.HandleFunc("/users/{id}/ledger", h.LedgerHandler).Methods("GET")
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantIDs    []string
+	}{
+		{"balance", "/users/alice/balance", http.StatusOK, nil},
+		{"unknown user balance", "/users/bob/balance", http.StatusNotFound, nil},
+		{"receipts", "/users/alice/receipts", http.StatusOK, []string{"r2", "r1"}},
+		{"receipts page", "/users/alice/receipts?offset=1&limit=1", http.StatusOK, []string{"r1"}},
+		{"invalid limit", "/users/alice/receipts?limit=0", http.StatusBadRequest, nil},
+		{"invalid offset", "/users/alice/receipts?offset=-1", http.StatusBadRequest, nil},
+		{"unknown user receipts", "/users/bob/receipts", http.StatusNotFound, nil},
+		{"invalid ledger limit", "/users/alice/ledger?limit=501", http.StatusBadRequest, nil},
+		{"unknown user ledger", "/users/bob/ledger", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %v %s, want %v", w.Code, w.Body, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if tt.wantIDs == nil {
+				var u users.User
+				json.NewDecoder(w.Body).Decode(&u)
+				if u.ID != "alice" || u.Balance != 30 {
+					t.Errorf("balance = %+v, want alice with 30", u)
+				}
+				return
+			}
+			var page receiptPage
+			json.NewDecoder(w.Body).Decode(&page)
+			if page.Total != 2 || len(page.Receipts) != len(tt.wantIDs) {
+				t.Fatalf("receipts = %+v, want %v of 2", page, tt.wantIDs)
+			}
+			for i, r := range page.Receipts {
+				if r.ID != tt.wantIDs[i] {
+					t.Errorf("receipts[%d] = %s, want %s", i, r.ID, tt.wantIDs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestUsersLedger(t *testing.T) {
+	l := ledger.New()
+	store := users.NewStore(l)
+	store.AddReceipt("alice", users.Receipt{ID: "r1", Points: 10})
+	h := NewUsersHandler(store)
+	lh := NewLedgerHandler(l)
+	router := mux.NewRouter()
+	router.HandleFunc("/users/{id}/ledger", h.LedgerHandler).Methods("GET")
+	router.HandleFunc("/admin/users/{id}/adjustments", h.AdjustHandler).Methods("POST")
+	router.HandleFunc("/admin/ledger/entries", lh.EntriesHandler).Methods("GET")
+	router.HandleFunc("/admin/ledger/entries/{id}", lh.EntryHandler).Methods("GET")
+	router.HandleFunc("/admin/ledger/entries/{id}/reversal", lh.ReversalHandler).Methods("POST")
+	router.HandleFunc("/admin/ledger/reconcile", lh.ReconcileHandler).Methods("GET")
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	steps := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"adjust unknown user", "POST", "/admin/users/bob/adjustments", `{"points":5,"memo":"goodwill"}`, http.StatusNotFound},
+		{"adjust without memo", "POST", "/admin/users/alice/adjustments", `{"points":5}`, http.StatusBadRequest},
+		{"adjust zero points", "POST", "/admin/users/alice/adjustments", `{"points":0,"memo":"nothing"}`, http.StatusBadRequest},
+		{"adjust past the balance", "POST", "/admin/users/alice/adjustments", `{"points":-1000000,"memo":"overdraft"}`, http.StatusConflict},
+		{"adjust unknown field", "POST", "/admin/users/alice/adjustments", `{"amount":5,"memo":"goodwill"}`, http.StatusBadRequest},
+		{"reverse missing entry", "POST", "/admin/ledger/entries/missing/reversal", `{}`, http.StatusNotFound},
+		{"get missing entry", "GET", "/admin/ledger/entries/missing", "", http.StatusNotFound},
+		{"list entries", "GET", "/admin/ledger/entries?account=user:alice", "", http.StatusOK},
+	}
+	for _, s := range steps {
+		if w := send(s.method, s.path, s.body); w.Code != s.wantStatus {
+			t.Errorf("%s: status = %v %s, want %v", s.name, w.Code, w.Body, s.wantStatus)
+		}
+	}
+
+	w := send("POST", "/admin/users/alice/adjustments", `{"points":-4,"memo":"duplicate receipt"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("adjust = %v %s, want 201", w.Code, w.Body)
+	}
+	var adjustment ledger.Entry
+	json.NewDecoder(w.Body).Decode(&adjustment)
+	if adjustment.Reason != ledger.ReasonAdjustment || w.Header().Get("Location") != "/admin/ledger/entries/"+adjustment.ID {
+		t.Errorf("adjust returned %+v with Location %q", adjustment, w.Header().Get("Location"))
+	}
+	if u, _ := store.Get("alice"); u.Balance != 6 {
+		t.Errorf("balance after adjustment = %d, want 6", u.Balance)
+	}
+
+	reversal := "/admin/ledger/entries/" + adjustment.ID + "/reversal"
+	if w := send("POST", reversal, `{"memo":"wrong user"}`); w.Code != http.StatusCreated {
+		t.Fatalf("reverse = %v %s, want 201", w.Code, w.Body)
+	}
+	if w := send("POST", reversal, `{}`); w.Code != http.StatusConflict {
+		t.Errorf("reverse twice = %v %s, want 409", w.Code, w.Body)
+	}
+	if u, _ := store.Get("alice"); u.Balance != 10 {
+		t.Errorf("balance after reversal = %d, want 10", u.Balance)
+	}
+
+	var page entryPage
+	w = send("GET", "/users/alice/ledger?limit=2", "")
+	json.NewDecoder(w.Body).Decode(&page)
+	if page.Total != 3 || len(page.Entries) != 2 || page.Entries[0].Reason != ledger.ReasonReversal {
+		t.Errorf("ledger = %+v, want 2 of 3 entries starting with the reversal", page)
+	}
+
+	var r ledger.Reconciliation
+	json.NewDecoder(send("GET", "/admin/ledger/reconcile", "").Body).Decode(&r)
+	if !r.OK() || r.Entries != 3 {
+		t.Errorf("reconcile = %+v, want 3 entries and no mismatches", r)
+	}
+}
@@ -0,0 +1,222 @@
+// Package importer loads receipts in bulk from CSV and NDJSON files.
+//
+// Files are read a receipt at a time. Every receipt carries a key, the
+// value of a CSV key column or an NDJSON receipt's "id", and an import
+// skips receipts whose key was already imported under the same source. So
+// an import that fails part way is resumed by running it again.
+//
+// The keys are held in memory, as the processor holds the receipts, and are
+// lost with them when the server restarts.
+package importer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/suryamp/receipt-processor/logger"
+	"github.com/suryamp/receipt-processor/metrics"
+	"github.com/suryamp/receipt-processor/models"
+	"github.com/suryamp/receipt-processor/validator"
+)
+
+// maxReportedErrors bounds the errors an import report lists; the counts
+// always cover every record.
+const maxReportedErrors = 1000
+
+// Import states.
+const (
+	StatusDone   = "done"
+	StatusFailed = "failed"
+)
+
+// Processor stores receipts; processor.ReceiptProcessor is one.
+type Processor interface {
+	ProcessReceipt(ctx context.Context, receipt models.Receipt) (string, error)
+}
+
+// Options control one import. Keys are only compared within a Source. A
+// DryRun validates every record and reports what would be imported without
+// storing anything. An import stops as failed after MaxErrors invalid
+// records, unless it is zero.
+type Options struct {
+	Source    string
+	DryRun    bool
+	MaxErrors int
+}
+
+// RowError is a record that was not imported.
+type RowError struct {
+	Line  int    `json:"line"`
+	Key   string `json:"key,omitempty"`
+	Error string `json:"error"`
+}
+
+// Report is the outcome of an import. Imported counts the receipts stored,
+// or that would be in a dry run; Skipped those already imported. LastLine
+// is where the last record handled started.
+type Report struct {
+	Source            string     `json:"source"`
+	DryRun            bool       `json:"dryRun"`
+	Status            string     `json:"status"`
+	Error             string     `json:"error,omitempty"` // why it failed
+	Records           int        `json:"records"`
+	Imported          int        `json:"imported"`
+	Skipped           int        `json:"skipped"`
+	Invalid           int        `json:"invalid"`
+	Errors            []RowError `json:"errors"`
+	Truncated         bool       `json:"truncated"`
+	LastLine          int        `json:"lastLine"`
+	Seconds           float64    `json:"seconds"`
+	ReceiptsPerSecond float64    `json:"receiptsPerSecond"`
+}
+
+func (r *Report) reject(record Record, err error) {
+	r.Invalid++
+	if len(r.Errors) < maxReportedErrors {
+		r.Errors = append(r.Errors, RowError{Line: record.Line, Key: record.Key, Error: err.Error()})
+	} else {
+		r.Truncated = true
+	}
+}
+
+// Importer imports receipts into a processor and remembers the key of
+// every receipt imported. It is safe for concurrent use.
+type Importer struct {
+	processor       Processor
+	maxItems        int
+	maxStringLength int
+
+	mu       sync.Mutex
+	imported map[string]string // receipt IDs by source and key
+	claimed  map[string]bool   // keys being imported now
+}
+
+func New(p Processor) *Importer {
+	return &Importer{processor: p, imported: map[string]string{}, claimed: map[string]bool{}}
+}
+
+// WithLimits rejects receipts over the limits the API enforces. A zero
+// limit is not enforced.
+func (im *Importer) WithLimits(maxItems, maxStringLength int) *Importer {
+	im.maxItems, im.maxStringLength = maxItems, maxStringLength
+	return im
+}
+
+// claim reserves key for an import, unless it was imported or is being
+// imported already.
+func (im *Importer) claim(key string) bool {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	if _, ok := im.imported[key]; ok || im.claimed[key] {
+		return false
+	}
+	im.claimed[key] = true
+	return true
+}
+
+// settle releases a claimed key, recording it as imported as id unless id
+// is empty.
+func (im *Importer) settle(key, id string) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	delete(im.claimed, key)
+	if id != "" {
+		im.imported[key] = id
+	}
+}
+
+func (im *Importer) validate(receipt models.Receipt) error {
+	if err := validator.ValidateLimits(receipt, im.maxItems, im.maxStringLength); err != nil {
+		return err
+	}
+	return validator.ValidateReceipt(receipt)
+}
+
+// Run imports every record r reads, until the end of the file, a read
+// error, ctx is cancelled or there are too many invalid records.
+func (im *Importer) Run(ctx context.Context, r Reader, opts Options) Report {
+	report := Report{Source: opts.Source, DryRun: opts.DryRun, Status: StatusDone, Errors: []RowError{}}
+	dryRun := strconv.FormatBool(opts.DryRun)
+	started := time.Now()
+	seen := map[string]bool{} // keys read by a dry run, which claims none
+	logger.InfoLogger.Printf("Import from source %q started, dry run %t", opts.Source, opts.DryRun)
+
+	fail := func(err error) {
+		report.Status = StatusFailed
+		report.Error = err.Error()
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			fail(err)
+			break
+		}
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fail(err)
+			break
+		}
+		report.Records++
+		report.LastLine = record.Line
+
+		result := im.handle(ctx, record, opts, seen, &report)
+		metrics.ImportReceiptsTotal.WithLabelValues(result, dryRun).Inc()
+		if opts.MaxErrors > 0 && report.Invalid >= opts.MaxErrors {
+			fail(fmt.Errorf("stopped after %d invalid records", report.Invalid))
+			break
+		}
+	}
+
+	report.Seconds = time.Since(started).Seconds()
+	if report.Seconds > 0 {
+		report.ReceiptsPerSecond = float64(report.Imported) / report.Seconds
+	}
+	logger.InfoLogger.Printf("Import from source %q %s: %d records, %d imported, %d skipped, %d invalid, %.0f receipts/s",
+		opts.Source, report.Status, report.Records, report.Imported, report.Skipped, report.Invalid, report.ReceiptsPerSecond)
+	return report
+}
+
+// handle imports one record and returns the result it counts as.
+func (im *Importer) handle(ctx context.Context, record Record, opts Options, seen map[string]bool, report *Report) string {
+	if record.Err != nil {
+		report.reject(record, record.Err)
+		return "invalid"
+	}
+	if err := im.validate(record.Receipt); err != nil {
+		report.reject(record, err)
+		return "invalid"
+	}
+
+	key := opts.Source + "\x00" + record.Key
+	if opts.DryRun {
+		im.mu.Lock()
+		_, imported := im.imported[key]
+		im.mu.Unlock()
+		if imported || seen[key] {
+			report.Skipped++
+			return "skipped"
+		}
+		seen[key] = true
+		report.Imported++
+		return "imported"
+	}
+
+	if !im.claim(key) {
+		report.Skipped++
+		return "skipped"
+	}
+	id, err := im.processor.ProcessReceipt(ctx, record.Receipt)
+	im.settle(key, id)
+	if err != nil {
+		report.reject(record, err)
+		return "invalid"
+	}
+	report.Imported++
+	return "imported"
+}
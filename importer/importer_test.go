@@ -0,0 +1,239 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/suryamp/receipt-processor/export"
+	"github.com/suryamp/receipt-processor/logger"
+	"github.com/suryamp/receipt-processor/models"
+	"github.com/suryamp/receipt-processor/processor"
+	"github.com/suryamp/receipt-processor/promotions"
+)
+
+func init() {
+	if err := logger.Init(); err != nil {
+		panic(err)
+	}
+}
+
+// counter is a processor that counts the receipts it stores.
+type counter struct {
+	stored int
+	fail   string // a retailer whose receipts it refuses
+}
+
+func (c *counter) ProcessReceipt(_ context.Context, r models.Receipt) (string, error) {
+	if r.Retailer == c.fail {
+		return "", errors.New("refused")
+	}
+	c.stored++
+	return fmt.Sprintf("id-%d", c.stored), nil
+}
+
+func ndjson(ids ...string) string {
+	var b strings.Builder
+	for _, id := range ids {
+		retailer := "Target"
+		if id == "bad" {
+			retailer = "Target!?"
+		}
+		fmt.Fprintf(&b, `{"id":%q,"retailer":%q,"purchaseDate":"2024-01-02","purchaseTime":"13:01","total":"1.25","items":[{"shortDescription":"Pepsi","price":"1.25"}]}`+"\n", id, retailer)
+	}
+	return b.String()
+}
+
+func run(t *testing.T, im *Importer, file io.Reader, opts Options) Report {
+	t.Helper()
+	r, err := NewReader(file, NDJSON, Mapping{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return im.Run(context.Background(), r, opts)
+}
+
+func TestRun(t *testing.T) {
+	p := processor.NewInMemoryProcessor(processor.DefaultRules())
+	im := New(p).WithLimits(500, 256)
+
+	report := run(t, im, strings.NewReader(ndjson("r1", "bad", "r2", "r1")), Options{Source: "a"})
+	if report.Status != StatusDone || report.Records != 4 || report.Imported != 2 || report.Skipped != 1 || report.Invalid != 1 {
+		t.Errorf("report = %+v, want 2 of 4 imported, 1 skipped and 1 invalid", report)
+	}
+	if len(report.Errors) != 1 || report.Errors[0].Line != 2 || report.Errors[0].Key != "bad" {
+		t.Errorf("errors = %+v, want the receipt on line 2", report.Errors)
+	}
+
+	// The same keys under another source are other receipts
+	if report := run(t, im, strings.NewReader(ndjson("r1")), Options{Source: "b"}); report.Imported != 1 {
+		t.Errorf("import from another source = %+v, want 1 imported", report)
+	}
+}
+
+func TestRunDryRun(t *testing.T) {
+	p := &counter{}
+	im := New(p)
+	run(t, im, strings.NewReader(ndjson("r1")), Options{})
+
+	report := run(t, im, strings.NewReader(ndjson("r1", "r2", "r2", "bad")), Options{DryRun: true})
+	if !report.DryRun || report.Imported != 1 || report.Skipped != 2 || report.Invalid != 1 {
+		t.Errorf("report = %+v, want 1 would be imported, 2 skipped and 1 invalid", report)
+	}
+	if p.stored != 1 {
+		t.Errorf("stored %d receipts, want only the 1 from before the dry run", p.stored)
+	}
+}
+
+// failingReader returns err after r is read.
+type failingReader struct {
+	r   io.Reader
+	err error
+}
+
+func (f *failingReader) Read(b []byte) (int, error) {
+	n, err := f.r.Read(b)
+	if err == io.EOF {
+		return n, f.err
+	}
+	return n, err
+}
+
+func TestRunResumes(t *testing.T) {
+	p := &counter{}
+	im := New(p)
+	file := ndjson("r1", "r2", "r3", "r4")
+	lines := strings.SplitAfter(file, "\n")
+
+	// The upload breaks off after two receipts
+	cut := &failingReader{r: strings.NewReader(lines[0] + lines[1]), err: io.ErrUnexpectedEOF}
+	report := run(t, im, cut, Options{Source: "s"})
+	if report.Status != StatusFailed || report.Imported != 2 || report.LastLine != 2 {
+		t.Errorf("report = %+v, want a failure after 2 receipts", report)
+	}
+
+	report = run(t, im, strings.NewReader(file), Options{Source: "s"})
+	if report.Status != StatusDone || report.Imported != 2 || report.Skipped != 2 || p.stored != 4 {
+		t.Errorf("resumed report = %+v with %d stored, want the last 2 imported and 4 stored", report, p.stored)
+	}
+}
+
+func TestRunStops(t *testing.T) {
+	tests := []struct {
+		name   string
+		ctx    func() context.Context
+		opts   Options
+		failOn string
+		want   Report
+	}{
+		{
+			name: "too many errors",
+			ctx:  context.Background,
+			opts: Options{MaxErrors: 2},
+			want: Report{Status: StatusFailed, Records: 3, Imported: 1, Invalid: 2},
+		},
+		{
+			name:   "processing errors count",
+			ctx:    context.Background,
+			opts:   Options{MaxErrors: 1},
+			failOn: "Target",
+			want:   Report{Status: StatusFailed, Records: 1, Invalid: 1},
+		},
+		{
+			name: "cancelled",
+			ctx: func() context.Context {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx
+			},
+			want: Report{Status: StatusFailed},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			im := New(&counter{fail: tt.failOn})
+			r, _ := NewReader(strings.NewReader(ndjson("r1", "bad", "bad", "r2")), NDJSON, Mapping{})
+			got := im.Run(tt.ctx(), r, tt.opts)
+			if got.Status != tt.want.Status || got.Records != tt.want.Records || got.Imported != tt.want.Imported || got.Invalid != tt.want.Invalid || got.Error == "" {
+				t.Errorf("report = %+v, want %+v with an error", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	campaigns := promotions.NewStore()
+	campaigns.Create(promotions.Campaign{Name: "Gatorade March", StartDate: "2024-03-01", EndDate: "2024-03-31", Keywords: []string{"gatorade"}, Bonus: 100})
+	source := processor.NewInMemoryProcessor(processor.DefaultRules()).WithPromotions(campaigns)
+	for _, r := range []models.Receipt{
+		{
+			UserID:       "alice",
+			Retailer:     "Target",
+			PurchaseDate: "2024-03-02",
+			PurchaseTime: "13:01",
+			Timezone:     "America/New_York",
+			UTCOffset:    "-05:00",
+			Items:        []models.Item{{ShortDescription: "Gatorade", Price: "2.25"}, {ShortDescription: "Milk, 1 GAL", Price: "4.00"}},
+			Total:        "6.25",
+		},
+		{
+			Retailer:     "Walgreens #12",
+			PurchaseDate: "2024-01-02",
+			PurchaseTime: "08:13",
+			Items:        []models.Item{{ShortDescription: "Pepsi", Price: "1.25"}},
+			Total:        "1.25",
+		},
+	} {
+		if _, err := source.ProcessReceipt(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := exported(t, source)
+
+	for _, format := range []export.Format{export.NDJSON, export.CSV} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := export.NewEncoder(&buf, format)
+			source.Export(processor.ExportFilter{}, enc.Encode)
+			if err := enc.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			dest := processor.NewInMemoryProcessor(processor.DefaultRules()).WithPromotions(campaigns)
+			r, err := NewReader(&buf, Format(format), DefaultMapping())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report := New(dest).Run(context.Background(), r, Options{Source: "export"}); report.Imported != 2 {
+				t.Fatalf("report = %+v, want 2 imported", report)
+			}
+			if got := exported(t, dest); !reflect.DeepEqual(got, want) {
+				t.Errorf("re-exported %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+// exported returns every receipt p exports by purchase date, without the
+// fields an import assigns afresh.
+func exported(t *testing.T, p *processor.InMemoryProcessor) []processor.ExportedReceipt {
+	t.Helper()
+	var receipts []processor.ExportedReceipt
+	err := p.Export(processor.ExportFilter{}, func(r processor.ExportedReceipt) error {
+		r.ID, r.SubmittedAt = "", time.Time{}
+		receipts = append(receipts, r)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(receipts, func(i, j int) bool { return receipts[i].PurchaseDate < receipts[j].PurchaseDate })
+	return receipts
+}
@@ -0,0 +1,286 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/suryamp/receipt-processor/models"
+)
+
+// Format is an import file's encoding.
+type Format string
+
+const (
+	CSV    Format = "csv"    // one row per item, grouped into receipts by a key column
+	NDJSON Format = "ndjson" // one receipt per line
+)
+
+// ParseFormat returns the format named s.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case CSV, NDJSON:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown import format %q: use csv or ndjson", s)
+}
+
+// maxLine bounds one line of an NDJSON file.
+const maxLine = 16 << 20
+
+// Mapping names the CSV columns each receipt field is read from. UserID,
+// Timezone and UTCOffset are optional: they may be empty or name a column
+// the file does not have.
+type Mapping struct {
+	Key             string `yaml:"key"`
+	UserID          string `yaml:"userId"`
+	Retailer        string `yaml:"retailer"`
+	PurchaseDate    string `yaml:"purchaseDate"`
+	PurchaseTime    string `yaml:"purchaseTime"`
+	Timezone        string `yaml:"timezone"`
+	UTCOffset       string `yaml:"utcOffset"`
+	Total           string `yaml:"total"`
+	ItemDescription string `yaml:"itemDescription"`
+	ItemPrice       string `yaml:"itemPrice"`
+}
+
+// DefaultMapping reads the columns of a CSV receipt export.
+func DefaultMapping() Mapping {
+	return Mapping{
+		Key:             "receipt_id",
+		UserID:          "user_id",
+		Retailer:        "retailer",
+		PurchaseDate:    "purchase_date",
+		PurchaseTime:    "purchase_time",
+		Timezone:        "timezone",
+		UTCOffset:       "utc_offset",
+		Total:           "total",
+		ItemDescription: "item_description",
+		ItemPrice:       "item_price",
+	}
+}
+
+// fields returns the mapping's fields by their YAML names, and whether
+// each is required.
+func (m *Mapping) fields() map[string]struct {
+	column   *string
+	required bool
+} {
+	type field = struct {
+		column   *string
+		required bool
+	}
+	return map[string]field{
+		"key":             {&m.Key, true},
+		"userId":          {&m.UserID, false},
+		"retailer":        {&m.Retailer, true},
+		"purchaseDate":    {&m.PurchaseDate, true},
+		"purchaseTime":    {&m.PurchaseTime, true},
+		"timezone":        {&m.Timezone, false},
+		"utcOffset":       {&m.UTCOffset, false},
+		"total":           {&m.Total, true},
+		"itemDescription": {&m.ItemDescription, true},
+		"itemPrice":       {&m.ItemPrice, true},
+	}
+}
+
+// Validate reports the first required field that names no column.
+func (m Mapping) Validate() error {
+	for name, f := range m.fields() {
+		if f.required && *f.column == "" {
+			return fmt.Errorf("mapping.%s must name a column", name)
+		}
+	}
+	return nil
+}
+
+// With returns m with the columns of some fields, named as in YAML,
+// replaced.
+func (m Mapping) With(columns map[string]string) (Mapping, error) {
+	fields := m.fields()
+	for name, column := range columns {
+		f, ok := fields[name]
+		if !ok {
+			return m, fmt.Errorf("unknown mapping field %q", name)
+		}
+		*f.column = column
+	}
+	return m, m.Validate()
+}
+
+// Record is one receipt read from a file. Line is where it starts. If Err
+// is set the receipt could not be read and Receipt is incomplete.
+type Record struct {
+	Key     string
+	Line    int
+	Receipt models.Receipt
+	Err     error
+}
+
+// Reader reads receipts from a file one at a time.
+type Reader interface {
+	// Read returns the next record, or io.EOF after the last. Any other
+	// error means the rest of the file cannot be read.
+	Read() (Record, error)
+}
+
+// NewReader returns a reader of f from r. A CSV file's first row must be a
+// header with every column m requires.
+func NewReader(r io.Reader, f Format, m Mapping) (Reader, error) {
+	if f == NDJSON {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64<<10), maxLine)
+		return &ndjsonReader{scanner: scanner}, nil
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading the CSV header: %w", err)
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		index[strings.TrimSpace(name)] = i
+	}
+	columns := map[string]int{}
+	for name, f := range m.fields() {
+		i, ok := index[*f.column]
+		if !ok && f.required {
+			return nil, fmt.Errorf("the CSV header has no %q column for %s", *f.column, name)
+		}
+		if ok {
+			columns[name] = i
+		}
+	}
+	return &csvReader{r: cr, columns: columns, seen: map[string]int{}}, nil
+}
+
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (n *ndjsonReader) Read() (Record, error) {
+	for n.scanner.Scan() {
+		n.line++
+		line := n.scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var v struct {
+			ID string `json:"id"`
+			models.Receipt
+		}
+		if err := json.Unmarshal(line, &v); err != nil {
+			return Record{Line: n.line, Err: err}, nil
+		}
+		key := v.ID
+		if key == "" {
+			// Key the receipt by its content, so a file without IDs can
+			// still be imported again without duplicates
+			b, _ := json.Marshal(v.Receipt)
+			sum := sha256.Sum256(b)
+			key = "sha256:" + hex.EncodeToString(sum[:])
+		}
+		return Record{Key: key, Line: n.line, Receipt: v.Receipt}, nil
+	}
+	if err := n.scanner.Err(); err != nil {
+		return Record{}, fmt.Errorf("line %d: %w", n.line+1, err)
+	}
+	return Record{}, io.EOF
+}
+
+// csvReader groups consecutive rows with the same key into a receipt. The
+// receipt's fields come from its first row.
+type csvReader struct {
+	r       *csv.Reader
+	columns map[string]int // by mapping field
+	seen    map[string]int // the line each key started on
+	next    *csvRow        // read ahead, not yet part of a record
+}
+
+type csvRow struct {
+	fields []string
+	line   int
+	err    error
+}
+
+func (c *csvReader) row() (*csvRow, error) {
+	if row := c.next; row != nil {
+		c.next = nil
+		return row, nil
+	}
+	fields, err := c.r.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return &csvRow{line: parseErr.StartLine, err: err}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	line, _ := c.r.FieldPos(0)
+	return &csvRow{fields: fields, line: line}, nil
+}
+
+func (c *csvReader) field(row *csvRow, name string) string {
+	i, ok := c.columns[name]
+	if !ok || i >= len(row.fields) {
+		return ""
+	}
+	return strings.TrimSpace(row.fields[i])
+}
+
+func (c *csvReader) Read() (Record, error) {
+	var record *Record
+	for {
+		row, err := c.row()
+		if err == io.EOF && record != nil {
+			return *record, nil
+		}
+		if err != nil {
+			return Record{}, err
+		}
+		if row.err != nil || record != nil && c.field(row, "key") != record.Key {
+			if record != nil {
+				c.next = row
+				return *record, nil
+			}
+			return Record{Line: row.line, Err: row.err}, nil
+		}
+
+		if record == nil {
+			key := c.field(row, "key")
+			if key == "" {
+				return Record{Line: row.line, Err: errors.New("the row has no receipt key")}, nil
+			}
+			if line, ok := c.seen[key]; ok {
+				return Record{Key: key, Line: row.line, Err: fmt.Errorf("receipt %q started on line %d; a receipt's rows must be together", key, line)}, nil
+			}
+			c.seen[key] = row.line
+			record = &Record{Key: key, Line: row.line, Receipt: models.Receipt{
+				UserID:       c.field(row, "userId"),
+				Retailer:     c.field(row, "retailer"),
+				PurchaseDate: c.field(row, "purchaseDate"),
+				PurchaseTime: c.field(row, "purchaseTime"),
+				Timezone:     c.field(row, "timezone"),
+				UTCOffset:    c.field(row, "utcOffset"),
+				Total:        c.field(row, "total"),
+				Items:        []models.Item{},
+			}}
+		}
+		item := models.Item{ShortDescription: c.field(row, "itemDescription"), Price: c.field(row, "itemPrice")}
+		if item != (models.Item{}) {
+			record.Receipt.Items = append(record.Receipt.Items, item)
+		}
+	}
+}
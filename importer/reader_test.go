@@ -0,0 +1,151 @@
+package importer
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/suryamp/receipt-processor/models"
+)
+
+// readAll returns every record in a file, or the error that stopped it.
+func readAll(t *testing.T, r Reader) []Record {
+	t.Helper()
+	var records []Record
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return records
+		}
+		if err != nil {
+			t.Fatalf("Read() error = %v", err)
+		}
+		records = append(records, record)
+	}
+}
+
+func TestMapping(t *testing.T) {
+	tests := []struct {
+		name    string
+		columns map[string]string
+		wantErr bool
+	}{
+		{"no overrides", nil, false},
+		{"rename", map[string]string{"retailer": "Store", "userId": ""}, false},
+		{"unknown field", map[string]string{"store": "Store"}, true},
+		{"required field cleared", map[string]string{"key": ""}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := DefaultMapping().With(tt.columns)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("With() error = %v, want error %t", err, tt.wantErr)
+			}
+			if err == nil && tt.columns["retailer"] != "" && m.Retailer != tt.columns["retailer"] {
+				t.Errorf("Retailer column = %q, want %q", m.Retailer, tt.columns["retailer"])
+			}
+		})
+	}
+}
+
+func TestCSVReader(t *testing.T) {
+	file := `Order,Store,Date,Time,Total,Item,Price,Extra
+A1,Target,2024-01-02,13:01,7.50,Pepsi,1.25,x
+A1,Target,2024-01-02,13:01,7.50,Doritos,6.25,x
+B2,Walgreens,2024-01-03,08:00,2.00,Gum,2.00,x
+,Walgreens,2024-01-03,08:00,2.00,Gum,2.00,x
+C3,"Bad "quote,2024-01-03,08:00,2.00,Gum,2.00,x
+A1,Target,2024-01-02,13:01,7.50,Chips,1.00,x
+D4,Target,2024-01-04,09:00,1.00,Milk,1.00,x
+`
+	m, _ := DefaultMapping().With(map[string]string{
+		"key": "Order", "retailer": "Store", "purchaseDate": "Date", "purchaseTime": "Time",
+		"total": "Total", "itemDescription": "Item", "itemPrice": "Price",
+	})
+	r, err := NewReader(strings.NewReader(file), CSV, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := readAll(t, r)
+
+	type summary struct {
+		Key   string
+		Line  int
+		Items int
+		Err   bool
+	}
+	var got []summary
+	for _, rec := range records {
+		got = append(got, summary{rec.Key, rec.Line, len(rec.Receipt.Items), rec.Err != nil})
+	}
+	want := []summary{
+		{"A1", 2, 2, false},
+		{"B2", 4, 1, false},
+		{"", 5, 0, true},   // no key
+		{"", 6, 0, true},   // bad quoting
+		{"A1", 7, 0, true}, // A1 again, apart from its other rows
+		{"D4", 8, 1, false},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("records = %+v, want %+v", got, want)
+	}
+
+	wantReceipt := models.Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2024-01-02",
+		PurchaseTime: "13:01",
+		Total:        "7.50",
+		Items:        []models.Item{{ShortDescription: "Pepsi", Price: "1.25"}, {ShortDescription: "Doritos", Price: "6.25"}},
+	}
+	if !reflect.DeepEqual(records[0].Receipt, wantReceipt) {
+		t.Errorf("receipt A1 = %+v, want %+v", records[0].Receipt, wantReceipt)
+	}
+}
+
+func TestCSVReaderHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		wantErr bool
+	}{
+		{"export header", "receipt_id,user_id,retailer,canonical_retailer,purchase_date,purchase_time,total,item_index,item_description,item_price\n", false},
+		{"optional columns missing", "receipt_id,retailer,purchase_date,purchase_time,total,item_description,item_price\n", false},
+		{"required column missing", "receipt_id,retailer,purchase_date,purchase_time,item_description,item_price\n", true},
+		{"empty file", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewReader(strings.NewReader(tt.file), CSV, DefaultMapping())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewReader() error = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNDJSONReader(t *testing.T) {
+	file := `{"id":"r1","retailer":"Target","purchaseDate":"2024-01-02","purchaseTime":"13:01","total":"1.25","items":[{"shortDescription":"Pepsi","price":"1.25"}],"points":31}
+
+{"retailer":"Target","purchaseDate":"2024-01-02"}
+{"retailer":
+`
+	r, err := NewReader(strings.NewReader(file), NDJSON, Mapping{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := readAll(t, r)
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want 3: %+v", len(records), records)
+	}
+	if rec := records[0]; rec.Key != "r1" || rec.Line != 1 || rec.Receipt.Retailer != "Target" || len(rec.Receipt.Items) != 1 {
+		t.Errorf("record 1 = %+v, want r1 from Target with 1 item", rec)
+	}
+	if rec := records[1]; !strings.HasPrefix(rec.Key, "sha256:") || rec.Line != 3 {
+		t.Errorf("record 2 = %+v, want a content key on line 3", rec)
+	}
+	if rec := records[2]; rec.Err == nil || rec.Line != 4 {
+		t.Errorf("record 3 = %+v, want a decoding error on line 4", rec)
+	}
+}
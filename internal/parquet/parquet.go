@@ -0,0 +1,164 @@
+// Package parquet writes flat Parquet files with parquet-go: required
+// columns of strings, integers, decimals, dates and timestamps. Pages are
+// Snappy compressed, and every column chunk records its min and max so
+// engines can skip the row groups a query rules out.
+//
+// Rows are buffered into row groups that are written out as they fill, so
+// a file of any size takes only a row group's worth of memory to write.
+package parquet
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	parquetgo "github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// DefaultRowGroupSize is the number of rows buffered before a row group is
+// written.
+const DefaultRowGroupSize = 10000
+
+// Kind is the type of a column.
+type Kind int
+
+const (
+	String    Kind = iota // UTF-8 string
+	Int64                 // 64-bit integer
+	Cents                 // decimal(18, 2) stored as an int64 number of cents
+	Date                  // date stored as days since 1970-01-01
+	Timestamp             // UTC instant stored as milliseconds since the epoch
+)
+
+// Column is a column of a file's schema.
+type Column struct {
+	Name string
+	Kind Kind
+}
+
+// schema returns the column as parquet-go metadata.
+func (c Column) schema() string {
+	tag := "name=" + c.Name + ", repetitiontype=REQUIRED, "
+	switch c.Kind {
+	case String:
+		return tag + "type=BYTE_ARRAY, convertedtype=UTF8"
+	case Cents:
+		return tag + "type=INT64, convertedtype=DECIMAL, scale=2, precision=18"
+	case Date:
+		return tag + "type=INT32, convertedtype=DATE"
+	case Timestamp:
+		return tag + "type=INT64, convertedtype=TIMESTAMP_MILLIS"
+	}
+	return tag + "type=INT64"
+}
+
+// value converts v to what parquet-go stores for the column.
+func (c Column) value(v any) (any, error) {
+	switch c.Kind {
+	case String:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case Int64, Cents:
+		if n, ok := v.(int64); ok {
+			return n, nil
+		}
+	case Date:
+		if t, ok := v.(time.Time); ok {
+			y, m, d := t.Date()
+			return int32(time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / 86400), nil
+		}
+	case Timestamp:
+		if t, ok := v.(time.Time); ok {
+			return t.UnixMilli(), nil
+		}
+	}
+	return nil, fmt.Errorf("parquet: column %s cannot hold %T", c.Name, v)
+}
+
+// Writer writes rows to a Parquet file.
+type Writer struct {
+	out          *bufio.Writer
+	pw           *writer.CSVWriter
+	columns      []Column
+	rowGroupSize int
+	rows         int // in the current row group
+	total        int64
+	closed       bool
+}
+
+// NewWriter returns a writer of rows with the given columns to w. Close
+// must be called to finish the file.
+func NewWriter(w io.Writer, columns []Column) (*Writer, error) {
+	if len(columns) == 0 {
+		return nil, errors.New("parquet: no columns")
+	}
+	schema := make([]string, len(columns))
+	for i, c := range columns {
+		schema[i] = c.schema()
+	}
+	out := bufio.NewWriter(w)
+	pw, err := writer.NewCSVWriterFromWriter(schema, out, 1)
+	if err != nil {
+		return nil, fmt.Errorf("parquet: %w", err)
+	}
+	pw.CompressionType = parquetgo.CompressionCodec_SNAPPY
+	return &Writer{out: out, pw: pw, columns: columns, rowGroupSize: DefaultRowGroupSize}, nil
+}
+
+// WithRowGroupSize sets the number of rows per row group.
+func (w *Writer) WithRowGroupSize(n int) *Writer {
+	w.rowGroupSize = max(n, 1)
+	return w
+}
+
+// Write adds a row. Values must match the columns: a string for String, an
+// int64 for Int64 and Cents, and a time.Time for Date and Timestamp.
+func (w *Writer) Write(row ...any) error {
+	if w.closed {
+		return errors.New("parquet: write after close")
+	}
+	if len(row) != len(w.columns) {
+		return fmt.Errorf("parquet: row has %d values for %d columns", len(row), len(w.columns))
+	}
+	values := make([]any, len(row))
+	for i, c := range w.columns {
+		v, err := c.value(row[i])
+		if err != nil {
+			return err
+		}
+		values[i] = v
+	}
+	if err := w.pw.Write(values); err != nil {
+		return fmt.Errorf("parquet: %w", err)
+	}
+	w.total++
+	if w.rows++; w.rows >= w.rowGroupSize {
+		w.rows = 0
+		if err := w.pw.Flush(true); err != nil {
+			return fmt.Errorf("parquet: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close writes any buffered rows and the footer. It does not close the
+// underlying writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if err := w.pw.WriteStop(); err != nil {
+		return fmt.Errorf("parquet: %w", err)
+	}
+	return w.out.Flush()
+}
+
+// Rows returns how many rows have been written.
+func (w *Writer) Rows() int64 {
+	return w.total
+}
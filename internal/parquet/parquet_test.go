@@ -0,0 +1,232 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xitongsys/parquet-go-source/buffer"
+	parquetgo "github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+// readFile returns the footer and the rows of a file the writer produced,
+// read back with parquet-go.
+func readFile(t *testing.T, data []byte) (*parquetgo.FileMetaData, [][]any) {
+	t.Helper()
+	file, err := buffer.NewBufferFile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, err := reader.NewParquetColumnReader(file, 1)
+	if err != nil {
+		t.Fatalf("parquet-go cannot open the file: %v", err)
+	}
+	defer pr.ReadStop()
+
+	n := pr.GetNumRows()
+	rows := make([][]any, n)
+	for c := range len(pr.Footer.Schema) - 1 {
+		values, _, _, err := pr.ReadColumnByIndex(int64(c), n)
+		if err != nil {
+			t.Fatalf("parquet-go cannot read column %d: %v", c, err)
+		}
+		for r, v := range values {
+			rows[r] = append(rows[r], v)
+		}
+	}
+	return pr.Footer, rows
+}
+
+// names returns the column names of a footer. parquet-go capitalizes them as
+// it reads the schema.
+func names(footer *parquetgo.FileMetaData) []string {
+	var list []string
+	for _, element := range footer.Schema[1:] {
+		list = append(list, strings.ToLower(element.Name))
+	}
+	return list
+}
+
+func TestWriter(t *testing.T) {
+	columns := []Column{
+		{"id", String},
+		{"count", Int64},
+		{"total", Cents},
+		{"date", Date},
+		{"at", Timestamp},
+	}
+	at := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WithRowGroupSize(2)
+	for i := range 3 {
+		if err := w.Write(fmt.Sprintf("r%d", i), int64(i), int64(1050+i), at.AddDate(0, 0, i), at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if w.Rows() != 3 {
+		t.Errorf("Rows() = %d, want 3", w.Rows())
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	footer, rows := readFile(t, buf.Bytes())
+	if want := []string{"id", "count", "total", "date", "at"}; !reflect.DeepEqual(names(footer), want) {
+		t.Errorf("columns = %v, want %v", names(footer), want)
+	}
+	if len(footer.RowGroups) != 2 {
+		t.Errorf("file has %d row groups, want 2", len(footer.RowGroups))
+	}
+	want := [][]any{
+		{"r0", int64(0), int64(1050), int32(19724), at.UnixMilli()},
+		{"r1", int64(1), int64(1051), int32(19725), at.UnixMilli()},
+		{"r2", int64(2), int64(1052), int32(19726), at.UnixMilli()},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %v, want %v", rows, want)
+	}
+}
+
+func TestWriterManyColumns(t *testing.T) {
+	var columns []Column
+	var row []any
+	for i := range 20 {
+		columns = append(columns, Column{fmt.Sprintf("c%d", i), Int64})
+		row = append(row, int64(i))
+	}
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(row...); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	footer, rows := readFile(t, buf.Bytes())
+	if len(names(footer)) != 20 || !reflect.DeepEqual(rows, [][]any{row}) {
+		t.Errorf("read %d columns and rows %v, want 20 columns and %v", len(names(footer)), rows, row)
+	}
+}
+
+func TestWriterErrors(t *testing.T) {
+	if _, err := NewWriter(&bytes.Buffer{}, nil); err == nil {
+		t.Error("NewWriter with no columns succeeded")
+	}
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, []Column{{"id", String}, {"n", Int64}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		row  []any
+	}{
+		{"too few values", []any{"a"}},
+		{"wrong type", []any{"a", 1}},
+		{"string for int", []any{"a", "1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := w.Write(tt.row...); err == nil {
+				t.Errorf("Write(%v) succeeded", tt.row)
+			}
+		})
+	}
+
+	// A rejected row leaves nothing behind
+	if err := w.Write("b", int64(2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, rows := readFile(t, buf.Bytes()); !reflect.DeepEqual(rows, [][]any{{"b", int64(2)}}) {
+		t.Errorf("rows = %v, want only the valid row", rows)
+	}
+	if err := w.Write("a", int64(1)); err == nil {
+		t.Error("Write after Close succeeded")
+	}
+}
+
+// TestWriterMetadata checks the types, compression and statistics a reader
+// relies on to interpret the columns and prune row groups.
+func TestWriterMetadata(t *testing.T) {
+	columns := []Column{
+		{"id", String},
+		{"count", Int64},
+		{"total", Cents},
+		{"date", Date},
+		{"at", Timestamp},
+	}
+	at := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf, columns)
+	w.WithRowGroupSize(2)
+	for i := range 3 {
+		if err := w.Write(fmt.Sprintf("r%d", i), int64(i), int64(1050+i), at.AddDate(0, 0, i), at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	footer, _ := readFile(t, buf.Bytes())
+
+	converted := map[string]parquetgo.ConvertedType{}
+	for _, element := range footer.Schema[1:] {
+		if element.ConvertedType == nil {
+			continue
+		}
+		converted[strings.ToLower(element.Name)] = *element.ConvertedType
+		if *element.ConvertedType == parquetgo.ConvertedType_DECIMAL && (element.GetPrecision() != 18 || element.GetScale() != 2) {
+			t.Errorf("column %s is decimal(%d, %d), want decimal(18, 2)", element.Name, element.GetPrecision(), element.GetScale())
+		}
+	}
+	wantConverted := map[string]parquetgo.ConvertedType{
+		"id":    parquetgo.ConvertedType_UTF8,
+		"total": parquetgo.ConvertedType_DECIMAL,
+		"date":  parquetgo.ConvertedType_DATE,
+		"at":    parquetgo.ConvertedType_TIMESTAMP_MILLIS,
+	}
+	if !reflect.DeepEqual(converted, wantConverted) {
+		t.Errorf("converted types = %v, want %v", converted, wantConverted)
+	}
+
+	// The count column of each row group spans only that group's rows
+	wantRange := [][2]int64{{0, 1}, {2, 2}}
+	for g, group := range footer.RowGroups {
+		for _, chunk := range group.Columns {
+			if chunk.MetaData.Codec != parquetgo.CompressionCodec_SNAPPY {
+				t.Errorf("row group %d column %v codec = %v, want SNAPPY", g, chunk.MetaData.PathInSchema, chunk.MetaData.Codec)
+			}
+			stats := chunk.MetaData.Statistics
+			if stats == nil || stats.MinValue == nil || stats.MaxValue == nil {
+				t.Errorf("row group %d column %v has no min and max", g, chunk.MetaData.PathInSchema)
+				continue
+			}
+			if strings.ToLower(chunk.MetaData.PathInSchema[0]) != "count" {
+				continue
+			}
+			lo := int64(binary.LittleEndian.Uint64(stats.MinValue))
+			hi := int64(binary.LittleEndian.Uint64(stats.MaxValue))
+			if g < len(wantRange) && [2]int64{lo, hi} != wantRange[g] {
+				t.Errorf("row group %d count range = [%d, %d], want %v", g, lo, hi, wantRange[g])
+			}
+		}
+	}
+}
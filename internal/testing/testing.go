@@ -1,8 +1,9 @@
 package testing
 
-import "flag"
+import "testing"
 
-// Testing returns true if the code is running under go test
+// Testing returns true if the code is running under go test. Unlike looking
+// up the test flags, it is right from package init functions too.
 func Testing() bool {
-	return flag.Lookup("test.v") != nil
+	return testing.Testing()
 }
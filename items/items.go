@@ -0,0 +1,398 @@
+// Package items keeps a catalog of the items bought across all receipts.
+//
+// Item descriptions are normalized, so "Doritos Nacho Cheese 12PK" and
+// "DORITOS NACHO CHEESE 12 PK" are the same item, and each item keeps the
+// prices it was bought at by retailer and by purchase date. Prices are kept
+// as running totals and a bounded sample, so recording a purchase takes the
+// same time however often the item has been bought. Medians are exact up to
+// maxSample purchases and estimated from a uniform sample beyond.
+package items
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/suryamp/receipt-processor/models"
+	"github.com/suryamp/receipt-processor/stats"
+)
+
+var (
+	// ErrNotFound is returned for an item that was never bought.
+	ErrNotFound = errors.New("item not found")
+	// ErrInvalidQuery is returned for a price query with a bad range or grouping.
+	ErrInvalidQuery = errors.New("invalid item query")
+)
+
+// maxDescriptions bounds the printed descriptions kept for each item.
+const maxDescriptions = 10
+
+// maxSample bounds the prices kept to estimate a median.
+const maxSample = 1000
+
+// abbreviations are expanded wherever they appear as a word, or after a
+// number as in "12PK".
+var abbreviations = map[string]string{
+	"BNLS": "BONELESS",
+	"BTL":  "BOTTLE",
+	"CHKN": "CHICKEN",
+	"CHOC": "CHOCOLATE",
+	"CT":   "COUNT",
+	"GAL":  "GALLON",
+	"LB":   "POUND",
+	"LBS":  "POUND",
+	"LG":   "LARGE",
+	"ORG":  "ORGANIC",
+	"OZ":   "OUNCE",
+	"PK":   "PACK",
+	"SM":   "SMALL",
+	"W/":   "WITH",
+	"WHL":  "WHOLE",
+}
+
+var (
+	// quantity matches a number run into a unit, as in "12PK" or "1.5-LB".
+	quantity = regexp.MustCompile(`^(\d+(?:\.\d+)?)-?([A-Z]+)$`)
+	// edges matches punctuation around a word.
+	edges = regexp.MustCompile(`^[.,;:!?"'()]+|[.,;:!?"'()]+$`)
+)
+
+// Normalize reduces a printed item description to the form items are
+// compared in: upper case, single spaces, no punctuation around words, and
+// common abbreviations spelled out.
+func Normalize(description string) string {
+	var words []string
+	for _, token := range strings.Fields(strings.ToUpper(description)) {
+		if token = edges.ReplaceAllString(token, ""); token == "" {
+			continue
+		}
+		if full, ok := abbreviations[token]; ok {
+			words = append(words, full)
+			continue
+		}
+		if m := quantity.FindStringSubmatch(token); m != nil {
+			if full, ok := abbreviations[m[2]]; ok {
+				words = append(words, m[1], full)
+				continue
+			}
+		}
+		words = append(words, token)
+	}
+	return strings.Join(words, " ")
+}
+
+// prices summarizes prices in cents: their count, sum and range, and a
+// uniform sample of at most maxSample of them, in the order they came.
+type prices struct {
+	n, sum   int64
+	min, max int64
+	sample   []int64
+}
+
+func (p *prices) add(cents int64) {
+	if p.n == 0 || cents < p.min {
+		p.min = cents
+	}
+	if p.n == 0 || cents > p.max {
+		p.max = cents
+	}
+	p.n++
+	p.sum += cents
+	// Reservoir sampling keeps each price seen with equal chance
+	if len(p.sample) < maxSample {
+		p.sample = append(p.sample, cents)
+	} else if i := rand.Int64N(p.n); i < maxSample {
+		p.sample[i] = cents
+	}
+}
+
+// merge adds the prices summarized by q. The samples are pooled, which is
+// exact while neither summary has outgrown its sample.
+func (p *prices) merge(q *prices) {
+	if q.n == 0 {
+		return
+	}
+	if p.n == 0 || q.min < p.min {
+		p.min = q.min
+	}
+	if p.n == 0 || q.max > p.max {
+		p.max = q.max
+	}
+	p.n += q.n
+	p.sum += q.sum
+	p.sample = append(p.sample, q.sample...)
+}
+
+// PriceStats summarizes the prices an item was bought at.
+type PriceStats struct {
+	Purchases int64  `json:"purchases"`
+	Min       string `json:"min"`
+	Max       string `json:"max"`
+	Median    string `json:"median"`
+	Average   string `json:"average"`
+}
+
+func (p *prices) stats() PriceStats {
+	if p.n == 0 {
+		return PriceStats{}
+	}
+	sorted := slices.Sorted(slices.Values(p.sample))
+	median := float64(sorted[len(sorted)/2])
+	if len(sorted)%2 == 0 {
+		median = float64(sorted[len(sorted)/2-1]+sorted[len(sorted)/2]) / 2
+	}
+	return PriceStats{
+		Purchases: p.n,
+		Min:       dollars(float64(p.min)),
+		Max:       dollars(float64(p.max)),
+		Median:    dollars(median),
+		Average:   dollars(float64(p.sum) / float64(p.n)),
+	}
+}
+
+func dollars(cents float64) string {
+	return fmt.Sprintf("%.2f", math.Round(cents)/100)
+}
+
+// item is one catalog entry and every price it was bought at.
+type item struct {
+	name         string
+	descriptions []string // as printed, first seen first
+	spend        int64    // cents
+	all          prices
+	byRetailer   map[string]*prices
+	byDate       map[string]map[string]*prices // by date, then retailer
+	firstBought  string
+	lastBought   string
+}
+
+// Summary is a catalog entry with its purchases and spend.
+type Summary struct {
+	Name        string `json:"name"`
+	Purchases   int64  `json:"purchases"`
+	Spend       string `json:"spend"`
+	FirstBought string `json:"firstBought"`
+	LastBought  string `json:"lastBought"`
+}
+
+// Item is a catalog entry in full, with its prices overall and at each
+// retailer.
+type Item struct {
+	Summary
+	Descriptions []string              `json:"descriptions"`
+	Prices       PriceStats            `json:"prices"`
+	ByRetailer   map[string]PriceStats `json:"byRetailer"`
+}
+
+func (it *item) summary() Summary {
+	return Summary{
+		Name:        it.name,
+		Purchases:   it.all.n,
+		Spend:       dollars(float64(it.spend)),
+		FirstBought: it.firstBought,
+		LastBought:  it.lastBought,
+	}
+}
+
+// Sort orders for listing the catalog.
+const (
+	SortPurchases = "purchases"
+	SortSpend     = "spend"
+	SortName      = "name"
+)
+
+// ListQuery selects and orders catalog entries. Search matches part of a
+// normalized name; Retailer keeps items bought there and counts only those
+// purchases.
+type ListQuery struct {
+	Search   string
+	Retailer string
+	Sort     string
+	Offset   int
+	Limit    int
+}
+
+// TrendPoint is the prices an item was bought at in one period.
+type TrendPoint struct {
+	Period string `json:"period"`
+	PriceStats
+}
+
+// Trend is an item's prices over time, oldest period first.
+type Trend struct {
+	Name     string         `json:"name"`
+	Retailer string         `json:"retailer,omitempty"`
+	GroupBy  stats.Grouping `json:"groupBy"`
+	Points   []TrendPoint   `json:"points"`
+}
+
+// Catalog keeps every item bought. It is safe for concurrent use.
+type Catalog struct {
+	mu    sync.RWMutex
+	items map[string]*item // by normalized name
+}
+
+func NewCatalog() *Catalog {
+	return &Catalog{items: map[string]*item{}}
+}
+
+// Add records the items on a receipt bought from retailer on date, a
+// "2006-01-02" purchase date. Items whose price does not parse are skipped.
+func (c *Catalog) Add(date, retailer string, bought []models.Item) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, b := range bought {
+		name := Normalize(b.ShortDescription)
+		price, err := strconv.ParseFloat(b.Price, 64)
+		if name == "" || err != nil {
+			continue
+		}
+		cents := int64(math.Round(price * 100))
+
+		it, ok := c.items[name]
+		if !ok {
+			it = &item{name: name, byRetailer: map[string]*prices{}, byDate: map[string]map[string]*prices{}, firstBought: date, lastBought: date}
+			c.items[name] = it
+		}
+		if description := strings.TrimSpace(b.ShortDescription); len(it.descriptions) < maxDescriptions && !slices.Contains(it.descriptions, description) {
+			it.descriptions = append(it.descriptions, description)
+		}
+		it.spend += cents
+		it.all.add(cents)
+		if it.byDate[date] == nil {
+			it.byDate[date] = map[string]*prices{}
+		}
+		for _, m := range []map[string]*prices{it.byRetailer, it.byDate[date]} {
+			if m[retailer] == nil {
+				m[retailer] = &prices{}
+			}
+			m[retailer].add(cents)
+		}
+		it.firstBought = min(it.firstBought, date)
+		it.lastBought = max(it.lastBought, date)
+	}
+}
+
+// List returns a page of catalog entries, most bought first unless q says
+// otherwise, and how many entries q selects in all.
+func (c *Catalog) List(q ListQuery) ([]Summary, int, error) {
+	switch q.Sort {
+	case "", SortPurchases, SortSpend, SortName:
+	default:
+		return nil, 0, fmt.Errorf("%w: cannot sort by %q", ErrInvalidQuery, q.Sort)
+	}
+	search := Normalize(q.Search)
+
+	type entry struct {
+		Summary
+		spend int64 // cents
+	}
+	c.mu.RLock()
+	var list []entry
+	for name, it := range c.items {
+		if search != "" && !strings.Contains(name, search) {
+			continue
+		}
+		e := entry{Summary: it.summary(), spend: it.spend}
+		if q.Retailer != "" {
+			at, ok := it.byRetailer[q.Retailer]
+			if !ok {
+				continue
+			}
+			e.Purchases, e.spend = at.n, at.sum
+			e.Spend = dollars(float64(e.spend))
+		}
+		list = append(list, e)
+	}
+	c.mu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		switch {
+		case q.Sort == SortSpend && a.spend != b.spend:
+			return a.spend > b.spend
+		case q.Sort != SortName && a.Purchases != b.Purchases:
+			return a.Purchases > b.Purchases
+		}
+		return a.Name < b.Name
+	})
+
+	page := []Summary{}
+	for i := q.Offset; i < len(list) && len(page) < q.Limit; i++ {
+		page = append(page, list[i].Summary)
+	}
+	return page, len(list), nil
+}
+
+// Get returns an item by its description, which is normalized first.
+func (c *Catalog) Get(description string) (Item, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	it, ok := c.items[Normalize(description)]
+	if !ok {
+		return Item{}, ErrNotFound
+	}
+	result := Item{
+		Summary:      it.summary(),
+		Descriptions: append([]string{}, it.descriptions...),
+		Prices:       it.all.stats(),
+		ByRetailer:   make(map[string]PriceStats, len(it.byRetailer)),
+	}
+	for retailer, p := range it.byRetailer {
+		result.ByRetailer[retailer] = p.stats()
+	}
+	return result, nil
+}
+
+// Trend returns an item's prices by day, week or month between from and to,
+// inclusive "2006-01-02" purchase dates that may be empty for no bound.
+// Only the prices at retailer count, unless it is empty.
+func (c *Catalog) Trend(description string, groupBy stats.Grouping, from, to, retailer string) (Trend, error) {
+	if groupBy != stats.ByDay && groupBy != stats.ByWeek && groupBy != stats.ByMonth {
+		return Trend{}, fmt.Errorf("%w: cannot group prices by %q", ErrInvalidQuery, groupBy)
+	}
+	for _, d := range []string{from, to} {
+		if _, err := time.Parse(time.DateOnly, d); d != "" && err != nil {
+			return Trend{}, fmt.Errorf("%w: %q is not a YYYY-MM-DD date", ErrInvalidQuery, d)
+		}
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	it, ok := c.items[Normalize(description)]
+	if !ok {
+		return Trend{}, ErrNotFound
+	}
+
+	periods := map[string]*prices{}
+	for date, byRetailer := range it.byDate {
+		if from != "" && date < from || to != "" && date > to {
+			continue
+		}
+		for at, p := range byRetailer {
+			if retailer != "" && at != retailer {
+				continue
+			}
+			key := groupBy.Period(date)
+			if periods[key] == nil {
+				periods[key] = &prices{}
+			}
+			periods[key].merge(p)
+		}
+	}
+
+	trend := Trend{Name: it.name, Retailer: retailer, GroupBy: groupBy, Points: make([]TrendPoint, 0, len(periods))}
+	for key, p := range periods {
+		trend.Points = append(trend.Points, TrendPoint{Period: key, PriceStats: p.stats()})
+	}
+	sort.Slice(trend.Points, func(i, j int) bool { return trend.Points[i].Period < trend.Points[j].Period })
+	return trend, nil
+}
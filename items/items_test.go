@@ -0,0 +1,179 @@
+package items
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/suryamp/receipt-processor/models"
+	"github.com/suryamp/receipt-processor/stats"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		description string
+		want        string
+	}{
+		{"Mountain Dew 12PK", "MOUNTAIN DEW 12 PACK"},
+		{"  mountain   dew 12-pk ", "MOUNTAIN DEW 12 PACK"},
+		{"MOUNTAIN DEW 12 PK", "MOUNTAIN DEW 12 PACK"},
+		{"Org. Bnls Chkn Breast 1.5LB", "ORGANIC BONELESS CHICKEN BREAST 1.5 POUND"},
+		{"Klarbrunn 12-PK 12 FL OZ", "KLARBRUNN 12 PACK 12 FL OUNCE"},
+		{"Bagel w/ cream cheese", "BAGEL WITH CREAM CHEESE"},
+		{"1/2 Gal Milk", "1/2 GALLON MILK"},
+		{`"Emils Cheese Pizza"`, "EMILS CHEESE PIZZA"},
+		{"PK", "PACK"},
+		{"PKG", "PKG"}, // only whole abbreviations
+		{" ", ""},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.description); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.description, got, tt.want)
+		}
+	}
+}
+
+func newTestCatalog() *Catalog {
+	c := NewCatalog()
+	c.Add("2024-01-02", "Target", []models.Item{
+		{ShortDescription: "Mountain Dew 12PK", Price: "6.49"},
+		{ShortDescription: "Doritos Nacho Cheese", Price: "3.35"},
+	})
+	c.Add("2024-01-20", "Walgreens", []models.Item{
+		{ShortDescription: "MOUNTAIN DEW 12 PK", Price: "5.99"},
+		{ShortDescription: "Gatorade", Price: "2.25"},
+		{ShortDescription: "Gatorade", Price: "2.25"},
+	})
+	c.Add("2024-02-05", "Target", []models.Item{
+		{ShortDescription: "mountain dew 12-pk", Price: "7.49"},
+		{ShortDescription: "Broken", Price: "free"},
+	})
+	return c
+}
+
+func TestGet(t *testing.T) {
+	c := newTestCatalog()
+	item, err := c.Get("Mountain Dew 12 pack")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if item.Purchases != 3 || item.Spend != "19.97" || item.FirstBought != "2024-01-02" || item.LastBought != "2024-02-05" {
+		t.Errorf("Get() = %+v, want 3 purchases for 19.97 from 2024-01-02 to 2024-02-05", item.Summary)
+	}
+	if len(item.Descriptions) != 3 {
+		t.Errorf("descriptions = %v, want the 3 spellings", item.Descriptions)
+	}
+	want := PriceStats{Purchases: 3, Min: "5.99", Max: "7.49", Median: "6.49", Average: "6.66"}
+	if item.Prices != want {
+		t.Errorf("prices = %+v, want %+v", item.Prices, want)
+	}
+	want = PriceStats{Purchases: 2, Min: "6.49", Max: "7.49", Median: "6.99", Average: "6.99"}
+	if got := item.ByRetailer["Target"]; got != want {
+		t.Errorf("Target prices = %+v, want %+v", got, want)
+	}
+	if _, err := c.Get("Broken"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of an item with no valid price error = %v, want ErrNotFound", err)
+	}
+}
+
+// TestManyPurchases checks that an item's prices stay bounded in memory, and
+// exact but for the median, however often it is bought.
+func TestManyPurchases(t *testing.T) {
+	c := NewCatalog()
+	for i := range 10 * maxSample {
+		c.Add("2024-01-02", "Target", []models.Item{{ShortDescription: "Gatorade", Price: fmt.Sprintf("%d.%02d", 1+i%300/100, i%100)}})
+	}
+	item, err := c.Get("Gatorade")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(c.items["GATORADE"].all.sample); got != maxSample {
+		t.Errorf("sample holds %d prices, want %d", got, maxSample)
+	}
+	want := PriceStats{Purchases: 10 * maxSample, Min: "1.00", Max: "3.99", Average: "2.49"}
+	median := item.Prices.Median
+	item.Prices.Median = ""
+	if item.Prices != want {
+		t.Errorf("prices = %+v, want %+v", item.Prices, want)
+	}
+	if median < "2.30" || median > "2.70" {
+		t.Errorf("median = %s, want about 2.49", median)
+	}
+}
+
+func TestList(t *testing.T) {
+	c := newTestCatalog()
+	tests := []struct {
+		name      string
+		query     ListQuery
+		want      string
+		wantTotal int
+	}{
+		{"most bought", ListQuery{Limit: 10}, "[MOUNTAIN DEW 12 PACK:3 GATORADE:2 DORITOS NACHO CHEESE:1]", 3},
+		{"by spend", ListQuery{Sort: SortSpend, Limit: 10}, "[MOUNTAIN DEW 12 PACK:3 GATORADE:2 DORITOS NACHO CHEESE:1]", 3},
+		{"by name", ListQuery{Sort: SortName, Limit: 2}, "[DORITOS NACHO CHEESE:1 GATORADE:2]", 3},
+		{"page", ListQuery{Offset: 1, Limit: 1}, "[GATORADE:2]", 3},
+		{"at a retailer", ListQuery{Retailer: "Target", Limit: 10}, "[MOUNTAIN DEW 12 PACK:2 DORITOS NACHO CHEESE:1]", 2},
+		{"search", ListQuery{Search: "dew 12pk", Limit: 10}, "[MOUNTAIN DEW 12 PACK:3]", 1},
+		{"past the end", ListQuery{Offset: 5, Limit: 10}, "[]", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, total, err := c.List(tt.query)
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			var got []string
+			for _, s := range list {
+				got = append(got, fmt.Sprintf("%s:%d", s.Name, s.Purchases))
+			}
+			if fmt.Sprint(got) != tt.want || total != tt.wantTotal {
+				t.Errorf("List() = %v of %d, want %s of %d", got, total, tt.want, tt.wantTotal)
+			}
+		})
+	}
+	if _, _, err := c.List(ListQuery{Sort: "price"}); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("List() with an unknown sort error = %v, want ErrInvalidQuery", err)
+	}
+}
+
+func TestTrend(t *testing.T) {
+	c := newTestCatalog()
+	tests := []struct {
+		name     string
+		groupBy  stats.Grouping
+		from, to string
+		retailer string
+		want     string
+	}{
+		{"by month", stats.ByMonth, "", "", "", "[2024-01:2:5.99-6.49 2024-02:1:7.49-7.49]"},
+		{"by week", stats.ByWeek, "", "", "", "[2024-01-01:1:6.49-6.49 2024-01-15:1:5.99-5.99 2024-02-05:1:7.49-7.49]"},
+		{"range", stats.ByDay, "2024-01-10", "2024-01-31", "", "[2024-01-20:1:5.99-5.99]"},
+		{"at a retailer", stats.ByMonth, "", "", "Target", "[2024-01:1:6.49-6.49 2024-02:1:7.49-7.49]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trend, err := c.Trend("Mountain Dew 12PK", tt.groupBy, tt.from, tt.to, tt.retailer)
+			if err != nil {
+				t.Fatalf("Trend() error = %v", err)
+			}
+			var got []string
+			for _, p := range trend.Points {
+				got = append(got, fmt.Sprintf("%s:%d:%s-%s", p.Period, p.Purchases, p.Min, p.Max))
+			}
+			if fmt.Sprint(got) != tt.want {
+				t.Errorf("Trend() = %v, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := c.Trend("Mountain Dew 12PK", stats.ByRetailer, "", "", ""); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("Trend() by retailer error = %v, want ErrInvalidQuery", err)
+	}
+	if _, err := c.Trend("Mountain Dew 12PK", stats.ByDay, "Jan 1", "", ""); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("Trend() with a bad date error = %v, want ErrInvalidQuery", err)
+	}
+	if _, err := c.Trend("Pepsi", stats.ByDay, "", "", ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Trend() of an unknown item error = %v, want ErrNotFound", err)
+	}
+}
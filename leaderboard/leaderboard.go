@@ -0,0 +1,318 @@
+// Package leaderboard ranks users by the points they earn and retailers by
+// the receipts and points submitted for them.
+//
+// User boards follow the ledger, so every movement of a user's points that
+// is not spending them or letting them lapse shows up: receipts, rescores,
+// adjustments and reversals. Retailer boards are fed by the processor as receipts are
+// submitted and rescored.
+//
+// Boards are kept sorted as scores change, so updating a score, reading a
+// page or finding an entry's rank never scans the whole board. Weekly and
+// monthly boards start over at the beginning of each week (Monday) and
+// month.
+package leaderboard
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/suryamp/receipt-processor/ledger"
+	"github.com/suryamp/receipt-processor/logger"
+)
+
+// ErrNotFound is returned for a board that does not exist.
+var ErrNotFound = errors.New("leaderboard not found")
+
+// Period is how long a board's scores accumulate before it starts over.
+type Period string
+
+const (
+	PeriodWeek    Period = "week"
+	PeriodMonth   Period = "month"
+	PeriodAllTime Period = "all-time"
+)
+
+// Board names.
+const (
+	UsersWeek         = "users-week"
+	UsersMonth        = "users-month"
+	UsersAllTime      = "users-all-time"
+	RetailersReceipts = "retailers-receipts"
+	RetailersPoints   = "retailers-points"
+)
+
+// What a board ranks, named after the receipt field it comes from.
+const (
+	subjectUserID   = "userId"
+	subjectRetailer = "retailer"
+)
+
+// Standing is an entry's place on a board. Entries with the same score
+// share a rank, and the next rank after a tie is skipped.
+type Standing struct {
+	Rank  int    `json:"rank"`
+	ID    string `json:"id"`
+	Score int64  `json:"score"`
+}
+
+// Info describes a board.
+type Info struct {
+	Name    string     `json:"name"`
+	Period  Period     `json:"period"`
+	Subject string     `json:"subject"` // what the entries are: "userId" or "retailer"
+	Start   *time.Time `json:"start,omitempty"`
+	End     *time.Time `json:"end,omitempty"`
+	Entries int        `json:"entries"`
+}
+
+// Page is a slice of a board, best first, and optionally one entry's own
+// standing.
+type Page struct {
+	Info
+	Offset    int        `json:"offset"`
+	Limit     int        `json:"limit"`
+	Standings []Standing `json:"standings"`
+	Self      *Standing  `json:"self,omitempty"` // nil when not asked for or not on the board
+}
+
+// board is the scores of one leaderboard, with their IDs ranked by score,
+// highest first, and then by ID.
+type board struct {
+	name    string
+	period  Period
+	subject string
+	start   time.Time // zero for all-time boards
+	end     time.Time
+	scores  map[string]int64
+	order   *ranking
+}
+
+func newBoard(name string, period Period, subject string) *board {
+	return &board{name: name, period: period, subject: subject, scores: map[string]int64{}, order: newRanking()}
+}
+
+// add moves id's score by delta, keeping the order sorted.
+func (b *board) add(id string, delta int64) {
+	if delta == 0 {
+		return
+	}
+	if old, ok := b.scores[id]; ok {
+		b.order.remove(id, old)
+	}
+	b.scores[id] += delta
+	b.order.insert(id, b.scores[id])
+}
+
+// rank returns the rank of a score: one more than the number of IDs that
+// scored higher.
+func (b *board) rank(score int64) int {
+	return 1 + b.order.above(score)
+}
+
+// rollover starts the board over if now is past the end of its window.
+func (b *board) rollover(now time.Time) {
+	if b.period == PeriodAllTime || now.Before(b.end) && !b.start.IsZero() {
+		return
+	}
+	if !b.start.IsZero() {
+		logger.InfoLogger.Printf("Leaderboard %s reset after the %s from %s with %d entries",
+			b.name, b.period, b.start.Format(time.DateOnly), b.order.len)
+	}
+	b.start, b.end = window(b.period, now)
+	b.scores = map[string]int64{}
+	b.order = newRanking()
+}
+
+func (b *board) info() Info {
+	i := Info{Name: b.name, Period: b.period, Subject: b.subject, Entries: b.order.len}
+	if b.period != PeriodAllTime {
+		start, end := b.start, b.end
+		i.Start, i.End = &start, &end
+	}
+	return i
+}
+
+// window returns the week or month containing t, in t's location.
+func window(p Period, t time.Time) (start, end time.Time) {
+	y, m, d := t.Date()
+	if p == PeriodMonth {
+		start = time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+		return start, start.AddDate(0, 1, 0)
+	}
+	days := (int(t.Weekday()) + 6) % 7 // since Monday
+	start = time.Date(y, m, d-days, 0, 0, 0, 0, t.Location())
+	return start, start.AddDate(0, 0, 7)
+}
+
+// Boards keeps every leaderboard. It is safe for concurrent use.
+type Boards struct {
+	mu     sync.Mutex
+	boards map[string]*board
+	loc    *time.Location // where weeks and months begin
+	now    func() time.Time
+}
+
+// New returns empty boards whose weeks and months begin at midnight in loc.
+func New(loc *time.Location) *Boards {
+	return &Boards{
+		boards: map[string]*board{
+			UsersWeek:         newBoard(UsersWeek, PeriodWeek, subjectUserID),
+			UsersMonth:        newBoard(UsersMonth, PeriodMonth, subjectUserID),
+			UsersAllTime:      newBoard(UsersAllTime, PeriodAllTime, subjectUserID),
+			RetailersReceipts: newBoard(RetailersReceipts, PeriodAllTime, subjectRetailer),
+			RetailersPoints:   newBoard(RetailersPoints, PeriodAllTime, subjectRetailer),
+		},
+		loc: loc,
+		now: time.Now,
+	}
+}
+
+// WithClock sets the clock that decides which week and month it is.
+func (b *Boards) WithClock(now func() time.Time) *Boards {
+	b.now = now
+	return b
+}
+
+// rollover starts over every board whose window has ended. The caller
+// holds mu.
+func (b *Boards) rollover() {
+	now := b.now().In(b.loc)
+	for _, board := range b.boards {
+		board.rollover(now)
+	}
+}
+
+// Record adds a processed receipt and its points to its retailer.
+func (b *Boards) Record(retailer string, points int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rollover()
+	b.boards[RetailersReceipts].add(retailer, 1)
+	b.boards[RetailersPoints].add(retailer, points)
+}
+
+// AddPoints moves a retailer's points by delta, as when its receipts are
+// rescored.
+func (b *Boards) AddPoints(retailer string, delta int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rollover()
+	b.boards[RetailersPoints].add(retailer, delta)
+}
+
+// Follow keeps the user boards in step with l from now on: every entry
+// moves the scores of the users it posts to. Redemptions and their refunds
+// are left out, since spending points is not earning fewer, and so is
+// expiry, since points lapsing later does not undo earning them.
+func (b *Boards) Follow(l *ledger.Ledger) *Boards {
+	l.OnPost(func(e ledger.Entry) {
+		if e.Reason == ledger.ReasonRedemption || e.Reason == ledger.ReasonExpiry {
+			return
+		}
+		if e.Reason == ledger.ReasonReversal {
+			if reversed, err := l.Get(e.Reverses); err == nil && reversed.Reason == ledger.ReasonRedemption {
+				return
+			}
+		}
+		b.post(e)
+	})
+	return b
+}
+
+// post adds the user postings of e to the user boards.
+func (b *Boards) post(e ledger.Entry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rollover()
+	for _, p := range e.Postings {
+		userID, ok := ledger.UserID(p.Account)
+		if !ok {
+			continue
+		}
+		for _, name := range []string{UsersWeek, UsersMonth, UsersAllTime} {
+			b.boards[name].add(userID, p.Amount)
+		}
+	}
+}
+
+// List describes every board, in name order.
+func (b *Boards) List() []Info {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rollover()
+	list := make([]Info, 0, len(b.boards))
+	for _, board := range b.boards {
+		list = append(list, board.info())
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Subject returns what a board's entries are: "userId" or "retailer".
+func (b *Boards) Subject(name string) (string, error) {
+	board, ok := b.boards[name]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return board.subject, nil
+}
+
+// Page returns up to limit standings on a board, best first, after skipping
+// offset of them, and self's standing if self is not empty.
+func (b *Boards) Page(name string, offset, limit int, self string) (Page, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	board, ok := b.boards[name]
+	if !ok {
+		return Page{}, ErrNotFound
+	}
+	b.rollover()
+
+	page := Page{Info: board.info(), Offset: offset, Limit: limit, Standings: []Standing{}}
+	board.order.each(offset, func(id string, score int64) bool {
+		if len(page.Standings) == limit {
+			return false
+		}
+		page.Standings = append(page.Standings, Standing{Rank: board.rank(score), ID: id, Score: score})
+		return true
+	})
+	if score, ok := board.scores[self]; ok && self != "" {
+		page.Self = &Standing{Rank: board.rank(score), ID: self, Score: score}
+	}
+	return page, nil
+}
+
+// next returns when the next windowed board ends.
+func (b *Boards) next() time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rollover()
+	var next time.Time
+	for _, board := range b.boards {
+		if board.period != PeriodAllTime && (next.IsZero() || board.end.Before(next)) {
+			next = board.end
+		}
+	}
+	return next
+}
+
+// Run starts weekly and monthly boards over as their windows end, until ctx
+// is cancelled. Boards also start over when next read or written after
+// their window ends, so Run only makes the reset happen on time.
+func (b *Boards) Run(ctx context.Context) {
+	for {
+		timer := time.NewTimer(time.Until(b.next()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			b.mu.Lock()
+			b.rollover()
+			b.mu.Unlock()
+		}
+	}
+}
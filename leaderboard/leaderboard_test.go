@@ -0,0 +1,258 @@
+package leaderboard
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/suryamp/receipt-processor/expiry"
+	"github.com/suryamp/receipt-processor/ledger"
+	"github.com/suryamp/receipt-processor/logger"
+	"github.com/suryamp/receipt-processor/users"
+)
+
+func init() {
+	if err := logger.Init(); err != nil {
+		panic(err)
+	}
+}
+
+// clock is a settable time source.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func (c *clock) set(date string) {
+	c.t, _ = time.Parse(time.DateOnly, date)
+}
+
+// earn posts a receipt's points to its user, if it has one, and records it
+// for its retailer, as the processor does.
+func earn(t *testing.T, l *ledger.Ledger, b *Boards, userID, retailer string, points int64) {
+	t.Helper()
+	if userID != "" {
+		credit := ledger.Transfer(ledger.ReasonReceipt, ledger.AccountIssued, ledger.UserAccount(userID), points)
+		credit.ReceiptID = "receipt"
+		if _, err := l.Post(credit); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b.Record(retailer, points)
+}
+
+func ids(standings []Standing) []string {
+	list := make([]string, len(standings))
+	for i, s := range standings {
+		list[i] = fmt.Sprintf("%d:%s:%d", s.Rank, s.ID, s.Score)
+	}
+	return list
+}
+
+func TestRecord(t *testing.T) {
+	c := &clock{}
+	c.set("2024-01-03") // a Wednesday
+	l := ledger.New()
+	b := New(time.UTC).WithClock(c.now).Follow(l)
+
+	earn(t, l, b, "alice", "Target", 100)
+	earn(t, l, b, "bob", "Target", 250)
+	earn(t, l, b, "carol", "Walgreens", 100)
+	earn(t, l, b, "alice", "Walgreens", 150)
+	earn(t, l, b, "", "M&M Corner Market", 500)
+
+	tests := []struct {
+		board string
+		want  string
+	}{
+		{UsersWeek, "[1:alice:250 1:bob:250 3:carol:100]"},
+		{UsersAllTime, "[1:alice:250 1:bob:250 3:carol:100]"},
+		{RetailersReceipts, "[1:Target:2 1:Walgreens:2 3:M&M Corner Market:1]"},
+		{RetailersPoints, "[1:M&M Corner Market:500 2:Target:350 3:Walgreens:250]"},
+	}
+	for _, tt := range tests {
+		page, err := b.Page(tt.board, 0, 10, "")
+		if err != nil {
+			t.Fatalf("Page(%s) error = %v", tt.board, err)
+		}
+		if got := fmt.Sprint(ids(page.Standings)); got != tt.want {
+			t.Errorf("Page(%s) = %s, want %s", tt.board, got, tt.want)
+		}
+	}
+
+	page, _ := b.Page(UsersMonth, 1, 1, "carol")
+	if got := fmt.Sprint(ids(page.Standings)); got != "[1:bob:250]" || page.Entries != 3 {
+		t.Errorf("Page(offset 1, limit 1) = %s of %d, want [1:bob:250] of 3", got, page.Entries)
+	}
+	if page.Self == nil || page.Self.Rank != 3 || page.Self.Score != 100 {
+		t.Errorf("Page() self = %+v, want carol third", page.Self)
+	}
+	if page, _ := b.Page(UsersMonth, 0, 1, "dave"); page.Self != nil {
+		t.Errorf("Page() self for a user not on the board = %+v, want none", page.Self)
+	}
+	if _, err := b.Page("users-year", 0, 10, ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Page() unknown board error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestWindows(t *testing.T) {
+	c := &clock{}
+	c.set("2024-01-31") // a Wednesday
+	l := ledger.New()
+	b := New(time.UTC).WithClock(c.now).Follow(l)
+	earn(t, l, b, "alice", "Target", 100)
+
+	// A new month, but the same week
+	c.set("2024-02-01")
+	earn(t, l, b, "bob", "Target", 40)
+	standings := func(board string) string {
+		page, _ := b.Page(board, 0, 10, "")
+		return fmt.Sprint(ids(page.Standings))
+	}
+	if got := standings(UsersWeek); got != "[1:alice:100 2:bob:40]" {
+		t.Errorf("week = %s, want alice and bob", got)
+	}
+	if got := standings(UsersMonth); got != "[1:bob:40]" {
+		t.Errorf("month = %s, want only bob", got)
+	}
+
+	// Monday starts a new week
+	c.set("2024-02-05")
+	if got := standings(UsersWeek); got != "[]" {
+		t.Errorf("week after Monday = %s, want it empty", got)
+	}
+	if got := standings(UsersAllTime); got != "[1:alice:100 2:bob:40]" {
+		t.Errorf("all time = %s, want alice and bob", got)
+	}
+	page, _ := b.Page(UsersWeek, 0, 10, "")
+	if page.Start.Format(time.DateOnly) != "2024-02-05" || page.End.Format(time.DateOnly) != "2024-02-12" {
+		t.Errorf("week window = %s to %s, want 2024-02-05 to 2024-02-12", page.Start, page.End)
+	}
+	if next := b.next(); next.Format(time.DateOnly) != "2024-02-12" {
+		t.Errorf("next() = %s, want the end of the week", next)
+	}
+}
+
+func TestWindowTimezone(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Skip("no tz database")
+	}
+	c := &clock{}
+	// Monday 03:00 UTC is still Sunday in Chicago
+	c.t = time.Date(2024, 2, 5, 3, 0, 0, 0, time.UTC)
+	b := New(chicago).WithClock(c.now)
+	page, _ := b.Page(UsersWeek, 0, 10, "")
+	if got := page.Start.Format(time.DateOnly); got != "2024-01-29" {
+		t.Errorf("week start = %s, want 2024-01-29", got)
+	}
+}
+
+// TestOrder checks the incrementally sorted board against sorting from
+// scratch.
+func TestOrder(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	board := newBoard("test", PeriodAllTime, subjectUserID)
+	for i := 0; i < 2000; i++ {
+		board.add(fmt.Sprintf("user%02d", rng.Intn(50)), int64(rng.Intn(20)-5))
+	}
+
+	want := make([]string, 0, len(board.scores))
+	for id := range board.scores {
+		want = append(want, id)
+	}
+	sort.Slice(want, func(i, j int) bool {
+		si, sj := board.scores[want[i]], board.scores[want[j]]
+		return si > sj || si == sj && want[i] < want[j]
+	})
+	var order []string
+	board.order.each(0, func(id string, _ int64) bool {
+		order = append(order, id)
+		return true
+	})
+	if fmt.Sprint(order) != fmt.Sprint(want) || board.order.len != len(want) {
+		t.Fatalf("order = %v of %d, want %v", order, board.order.len, want)
+	}
+	for i, id := range want {
+		higher := 0
+		for _, other := range want {
+			if board.scores[other] > board.scores[id] {
+				higher++
+			}
+		}
+		if got := board.rank(board.scores[id]); got != higher+1 {
+			t.Errorf("rank of %s at %d = %d, want %d", id, i, got, higher+1)
+		}
+		var at string
+		board.order.each(i, func(id string, _ int64) bool {
+			at = id
+			return false
+		})
+		if at != id {
+			t.Errorf("entry at %d = %s, want %s", i, at, id)
+		}
+	}
+}
+
+// TestFollow checks that the user boards move with every kind of ledger
+// entry except spending.
+func TestFollow(t *testing.T) {
+	l := ledger.New()
+	b := New(time.UTC).Follow(l)
+	score := func() int64 {
+		page, _ := b.Page(UsersAllTime, 0, 10, "alice")
+		if page.Self == nil {
+			return 0
+		}
+		return page.Self.Score
+	}
+
+	earn(t, l, b, "alice", "Target", 100)
+	adjustment, _ := l.Post(ledger.Transfer(ledger.ReasonAdjustment, ledger.AccountAdjustments, ledger.UserAccount("alice"), 20))
+	l.Reverse(adjustment.ID, "")
+	l.Post(ledger.Transfer(ledger.ReasonExpiry, ledger.UserAccount("alice"), ledger.AccountExpired, 30))
+	if got := score(); got != 100 {
+		t.Errorf("score after an adjustment, its reversal and expiry = %d, want 100", got)
+	}
+
+	redemption, _ := l.PostCovered(ledger.Transfer(ledger.ReasonRedemption, ledger.UserAccount("alice"), ledger.AccountRedeemed, 50))
+	l.Refund(redemption.ID, "")
+	if got := score(); got != 100 {
+		t.Errorf("score after a redemption and its refund = %d, want 100", got)
+	}
+	if got, want := score(), l.Balance(ledger.UserAccount("alice"))+30; got != want {
+		t.Errorf("score = %d, want the balance and the 30 expired, %d", got, want)
+	}
+}
+
+func TestFollowExpirySweep(t *testing.T) {
+	c := &clock{}
+	c.set("2024-01-05")
+	l := ledger.New().WithClock(c.now)
+	accounts := users.NewStore(l)
+	b := New(time.UTC).WithClock(c.now).Follow(l)
+	sweeper := expiry.NewSweeper(expiry.Policy{Mode: expiry.ModeFixed, Months: 1}, accounts).WithClock(c.now)
+	score := func(board string) int64 {
+		page, _ := b.Page(board, 0, 10, "alice")
+		if page.Self == nil {
+			return 0
+		}
+		return page.Self.Score
+	}
+
+	accounts.AddReceipt("alice", users.Receipt{ID: "r1", PurchaseDate: "2024-01-05", Points: 100})
+	c.set("2024-02-10")
+	if sweep := sweeper.Sweep(); sweep.Expired != 100 {
+		t.Fatalf("Sweep() = %+v, want 100 points expired", sweep)
+	}
+
+	// Points earned in January lapsing in February take nothing off either
+	if got := score(UsersAllTime); got != 100 {
+		t.Errorf("all-time score after the sweep = %d, want the 100 earned", got)
+	}
+	if got := score(UsersMonth); got != 0 {
+		t.Errorf("February score after the sweep = %d, want 0", got)
+	}
+}
@@ -0,0 +1,139 @@
+package leaderboard
+
+import "math/rand/v2"
+
+// maxLevel bounds the height of the skip list, enough for billions of entries.
+const maxLevel = 32
+
+// node is an entry in a ranking. span[i] is how many places next[i] is
+// ahead of it.
+type node struct {
+	id    string
+	score int64
+	next  []*node
+	span  []int
+}
+
+// before reports whether n sorts ahead of an entry with the given score
+// and ID: higher scores first, then IDs in order.
+func (n *node) before(score int64, id string) bool {
+	return n.score > score || n.score == score && n.id < id
+}
+
+// ranking is an indexable skip list of scores, so inserting, removing and
+// finding an entry's place all take logarithmic time however long the board.
+type ranking struct {
+	head  *node
+	level int
+	len   int
+}
+
+func newRanking() *ranking {
+	return &ranking{head: &node{next: make([]*node, maxLevel), span: make([]int, maxLevel)}, level: 1}
+}
+
+func randomLevel() int {
+	level := 1
+	for level < maxLevel && rand.IntN(4) == 0 {
+		level++
+	}
+	return level
+}
+
+// insert adds id with score. id must not already be in the ranking.
+func (r *ranking) insert(id string, score int64) {
+	var update [maxLevel]*node
+	var rank [maxLevel]int // the place of update[i]
+	x := r.head
+	for i := r.level - 1; i >= 0; i-- {
+		if i < r.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.next[i] != nil && x.next[i].before(score, id) {
+			rank[i] += x.span[i]
+			x = x.next[i]
+		}
+		update[i] = x
+	}
+
+	level := randomLevel()
+	for i := r.level; i < level; i++ {
+		update[i] = r.head
+		r.head.span[i] = r.len
+	}
+	r.level = max(r.level, level)
+
+	n := &node{id: id, score: score, next: make([]*node, level), span: make([]int, level)}
+	for i := 0; i < level; i++ {
+		n.next[i] = update[i].next[i]
+		update[i].next[i] = n
+		n.span[i] = update[i].span[i] - (rank[0] - rank[i])
+		update[i].span[i] = rank[0] - rank[i] + 1
+	}
+	for i := level; i < r.level; i++ {
+		update[i].span[i]++
+	}
+	r.len++
+}
+
+// remove takes out id, which must be in the ranking with score.
+func (r *ranking) remove(id string, score int64) {
+	var update [maxLevel]*node
+	x := r.head
+	for i := r.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && x.next[i].before(score, id) {
+			x = x.next[i]
+		}
+		update[i] = x
+	}
+	n := x.next[0]
+	if n == nil || n.id != id {
+		return
+	}
+	for i := 0; i < r.level; i++ {
+		if update[i].next[i] == n {
+			update[i].span[i] += n.span[i] - 1
+			update[i].next[i] = n.next[i]
+		} else {
+			update[i].span[i]--
+		}
+	}
+	for r.level > 1 && r.head.next[r.level-1] == nil {
+		r.level--
+	}
+	r.len--
+}
+
+// above returns how many entries scored more than score.
+func (r *ranking) above(score int64) int {
+	var count int
+	x := r.head
+	for i := r.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && x.next[i].score > score {
+			count += x.span[i]
+			x = x.next[i]
+		}
+	}
+	return count
+}
+
+// each calls fn with the entries from the offset'th, best first, until fn
+// returns false.
+func (r *ranking) each(offset int, fn func(id string, score int64) bool) {
+	if offset < 0 || offset >= r.len {
+		return
+	}
+	var place int
+	x := r.head
+	for i := r.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && place+x.span[i] <= offset {
+			place += x.span[i]
+			x = x.next[i]
+		}
+	}
+	for x = x.next[0]; x != nil; x = x.next[0] {
+		if !fn(x.id, x.score) {
+			return
+		}
+	}
+}
@@ -0,0 +1,473 @@
+// Package ledger records every movement of points as an append-only,
+// double-entry journal. Each entry moves points between accounts with
+// postings that sum to zero, so points are never created or destroyed
+// without a matching system account; balances are derived from the entries.
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrNotFound is returned for an unknown entry ID.
+	ErrNotFound = errors.New("ledger entry not found")
+	// ErrUnbalanced is returned for an entry whose postings do not sum to zero.
+	ErrUnbalanced = errors.New("postings do not balance")
+	// ErrAlreadyReversed is returned when reversing an entry a second time.
+	ErrAlreadyReversed = errors.New("entry is already reversed")
+	// ErrInsufficientPoints is returned when an entry would overdraw a user.
+	ErrInsufficientPoints = errors.New("insufficient points")
+	// ErrStale is returned by PostIfLatest when the account has moved on.
+	ErrStale = errors.New("account has new entries")
+	// ErrRedemption is returned by Reverse for a redemption, which is only
+	// undone by cancelling it so the reward gets its unit back.
+	ErrRedemption = errors.New("redemptions are refunded by cancelling them")
+)
+
+// Reason says why points moved.
+type Reason string
+
+const (
+	ReasonReceipt    Reason = "receipt"    // points earned by a receipt
+	ReasonRescore    Reason = "rescore"    // a receipt's points changed by new rules
+	ReasonAdjustment Reason = "adjustment" // a manual correction
+	ReasonReversal   Reason = "reversal"   // undoes an earlier entry
+	ReasonRedemption Reason = "redemption" // points spent on a reward
+	ReasonExpiry     Reason = "expiry"     // points that lapsed unused
+)
+
+var reasons = []Reason{ReasonReceipt, ReasonRescore, ReasonAdjustment, ReasonReversal, ReasonRedemption, ReasonExpiry}
+
+// System accounts are the other side of every user posting.
+const (
+	AccountIssued      = "system:issued"      // points earned by receipts
+	AccountAdjustments = "system:adjustments" // manual corrections
+	AccountRedeemed    = "system:redeemed"    // points spent on rewards
+	AccountExpired     = "system:expired"     // points that lapsed
+)
+
+const userPrefix = "user:"
+
+// UserAccount returns the account holding a user's points.
+func UserAccount(userID string) string {
+	return userPrefix + userID
+}
+
+// UserID returns the user whose points account holds, if it is a user account.
+func UserID(account string) (string, bool) {
+	return strings.CutPrefix(account, userPrefix)
+}
+
+// Posting changes one account's balance by Amount.
+type Posting struct {
+	Account string `json:"account"`
+	Amount  int64  `json:"amount"`
+}
+
+// Entry is one balanced movement of points. ReceiptID refers to the
+// receipt the points came from, Reverses to the entry a reversal undoes,
+// and Reference to anything else, such as a redemption.
+type Entry struct {
+	ID        string    `json:"id"`
+	Seq       int64     `json:"seq"`
+	Time      time.Time `json:"time"`
+	Reason    Reason    `json:"reason"`
+	ReceiptID string    `json:"receiptId,omitempty"`
+	Reverses  string    `json:"reverses,omitempty"`
+	Reference string    `json:"reference,omitempty"`
+	Memo      string    `json:"memo,omitempty"`
+	Postings  []Posting `json:"postings"`
+}
+
+// Transfer returns an entry moving amount points from one account to another.
+func Transfer(reason Reason, from, to string, amount int64) Entry {
+	return Entry{Reason: reason, Postings: []Posting{{Account: from, Amount: -amount}, {Account: to, Amount: amount}}}
+}
+
+// Validate reports whether e can be posted.
+func (e Entry) Validate() error {
+	if !validReason(e.Reason) {
+		return fmt.Errorf("unknown reason %q", e.Reason)
+	}
+	if e.Reason == ReasonReceipt && e.ReceiptID == "" {
+		return fmt.Errorf("%s entries need a receipt ID", e.Reason)
+	}
+	if len(e.Postings) < 2 {
+		return fmt.Errorf("an entry needs at least two postings")
+	}
+	var sum int64
+	for _, p := range e.Postings {
+		if p.Account == "" {
+			return fmt.Errorf("posting without an account")
+		}
+		if p.Amount == 0 {
+			return fmt.Errorf("posting to %s moves no points", p.Account)
+		}
+		sum += p.Amount
+	}
+	if sum != 0 {
+		return fmt.Errorf("%w: they sum to %d", ErrUnbalanced, sum)
+	}
+	return nil
+}
+
+func validReason(r Reason) bool {
+	for _, known := range reasons {
+		if r == known {
+			return true
+		}
+	}
+	return false
+}
+
+type receiptKey struct {
+	account, receiptID string
+}
+
+// Ledger is an in-memory journal. Entries are never changed or removed;
+// mistakes are corrected by reversing them.
+type Ledger struct {
+	mu       sync.RWMutex
+	entries  []Entry
+	byID     map[string]int
+	reversed map[string]string // entry ID to the ID of its reversal
+
+	// Derived views, kept up to date as entries are posted
+	balances  map[string]int64
+	receipts  map[receiptKey]int64
+	reverted  map[receiptKey]bool // receipt credits that were reversed
+	byAccount map[string][]int    // indexes of the entries posting to each account
+
+	now    func() time.Time
+	onPost []func(Entry)
+}
+
+func New() *Ledger {
+	return &Ledger{
+		byID:      map[string]int{},
+		reversed:  map[string]string{},
+		balances:  map[string]int64{},
+		receipts:  map[receiptKey]int64{},
+		reverted:  map[receiptKey]bool{},
+		byAccount: map[string][]int{},
+		now:       time.Now,
+	}
+}
+
+// WithClock sets the clock that timestamps entries.
+func (l *Ledger) WithClock(now func() time.Time) *Ledger {
+	l.now = now
+	return l
+}
+
+// OnPost calls fn with every entry posted from now on, after it is posted
+// and outside the ledger's lock, so fn may read the ledger. Entries posted
+// concurrently may reach fn in either order.
+func (l *Ledger) OnPost(fn func(Entry)) *Ledger {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.onPost = append(l.onPost, fn)
+	return l
+}
+
+// commit appends the entry build returns, with l.mu held so that build can
+// check it against the ledger, and then tells the OnPost functions.
+func (l *Ledger) commit(build func() (Entry, error)) (Entry, error) {
+	l.mu.Lock()
+	e, err := build()
+	if err == nil {
+		e = l.append(e)
+	}
+	hooks := l.onPost
+	l.mu.Unlock()
+	if err != nil {
+		return Entry{}, err
+	}
+	for _, fn := range hooks {
+		fn(e.clone())
+	}
+	return e, nil
+}
+
+// Post validates e, assigns its ID, sequence number and time, and appends it.
+func (l *Ledger) Post(e Entry) (Entry, error) {
+	if err := e.Validate(); err != nil {
+		return Entry{}, err
+	}
+	return l.commit(func() (Entry, error) { return e, nil })
+}
+
+// PostCovered is Post for entries that spend a user's points: it refuses,
+// atomically with posting, any entry that would leave a user account with a
+// negative balance.
+func (l *Ledger) PostCovered(e Entry) (Entry, error) {
+	if err := e.Validate(); err != nil {
+		return Entry{}, err
+	}
+	return l.commit(func() (Entry, error) { return e, l.covers(e) })
+}
+
+// PostIfLatest is PostCovered for entries computed from an account's
+// history: it posts e only if the last entry posting to account is still the
+// one with sequence number seq, or there is none and seq is zero.
+func (l *Ledger) PostIfLatest(e Entry, account string, seq int64) (Entry, error) {
+	if err := e.Validate(); err != nil {
+		return Entry{}, err
+	}
+	return l.commit(func() (Entry, error) {
+		var latest int64
+		if indexes := l.byAccount[account]; len(indexes) > 0 {
+			latest = l.entries[indexes[len(indexes)-1]].Seq
+		}
+		if latest != seq {
+			return Entry{}, fmt.Errorf("%w: %s is at %d, not %d", ErrStale, account, latest, seq)
+		}
+		return e, l.covers(e)
+	})
+}
+
+// covers reports whether the user accounts e debits can afford it; the
+// caller must hold l.mu.
+func (l *Ledger) covers(e Entry) error {
+	after := map[string]int64{}
+	for _, p := range e.Postings {
+		if strings.HasPrefix(p.Account, userPrefix) {
+			if _, ok := after[p.Account]; !ok {
+				after[p.Account] = l.balances[p.Account]
+			}
+			after[p.Account] += p.Amount
+		}
+	}
+	for account, balance := range after {
+		if balance < 0 {
+			return fmt.Errorf("%w: %s has %d", ErrInsufficientPoints, account, l.balances[account])
+		}
+	}
+	return nil
+}
+
+// append records a valid entry; the caller must hold l.mu.
+func (l *Ledger) append(e Entry) Entry {
+	e.ID = uuid.New().String()
+	e.Seq = int64(len(l.entries)) + 1
+	e.Time = l.now()
+	e.Postings = append([]Posting{}, e.Postings...)
+
+	i := len(l.entries)
+	l.byID[e.ID] = i
+	l.entries = append(l.entries, e)
+	for _, p := range e.Postings {
+		l.balances[p.Account] += p.Amount
+		if e.ReceiptID != "" {
+			l.receipts[receiptKey{p.Account, e.ReceiptID}] += p.Amount
+		}
+		if indexes := l.byAccount[p.Account]; len(indexes) == 0 || indexes[len(indexes)-1] != i {
+			l.byAccount[p.Account] = append(indexes, i)
+		}
+	}
+	if e.Reverses != "" {
+		l.reversed[e.Reverses] = e.ID
+		if original := l.entries[l.byID[e.Reverses]]; original.Reason == ReasonReceipt {
+			for _, p := range original.Postings {
+				l.reverted[receiptKey{p.Account, original.ReceiptID}] = true
+			}
+		}
+	}
+	return e.clone()
+}
+
+// clone copies e so that callers cannot change the recorded postings.
+func (e Entry) clone() Entry {
+	e.Postings = append([]Posting{}, e.Postings...)
+	return e
+}
+
+// Reverse posts an entry that undoes the entry with the given ID. An entry
+// can be reversed once, and reversals cannot themselves be reversed.
+// Redemptions are refused with ErrRedemption; see Refund.
+func (l *Ledger) Reverse(id, memo string) (Entry, error) {
+	return l.reverse(id, memo, false)
+}
+
+// Refund reverses the redemption entry with the given ID, for the rewards
+// store to call when a redemption is cancelled.
+func (l *Ledger) Refund(id, memo string) (Entry, error) {
+	return l.reverse(id, memo, true)
+}
+
+// reverse posts the reversal of entry id, which must be a redemption if and
+// only if redemption is set.
+func (l *Ledger) reverse(id, memo string, redemption bool) (Entry, error) {
+	return l.commit(func() (Entry, error) {
+		i, ok := l.byID[id]
+		if !ok {
+			return Entry{}, ErrNotFound
+		}
+		original := l.entries[i]
+		if original.Reason == ReasonReversal {
+			return Entry{}, fmt.Errorf("entry %s is a reversal and cannot be reversed", id)
+		}
+		if isRedemption := original.Reason == ReasonRedemption; isRedemption != redemption {
+			if isRedemption {
+				return Entry{}, fmt.Errorf("entry %s: %w", id, ErrRedemption)
+			}
+			return Entry{}, fmt.Errorf("entry %s is a %s, not a redemption", id, original.Reason)
+		}
+		if by, ok := l.reversed[id]; ok {
+			return Entry{}, fmt.Errorf("%w by %s", ErrAlreadyReversed, by)
+		}
+
+		reversal := Entry{
+			Reason:    ReasonReversal,
+			ReceiptID: original.ReceiptID,
+			Reverses:  id,
+			Reference: original.Reference,
+			Memo:      memo,
+		}
+		for _, p := range original.Postings {
+			reversal.Postings = append(reversal.Postings, Posting{Account: p.Account, Amount: -p.Amount})
+		}
+		return reversal, nil
+	})
+}
+
+// Get returns the entry with the given ID.
+func (l *Ledger) Get(id string) (Entry, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	i, ok := l.byID[id]
+	if !ok {
+		return Entry{}, ErrNotFound
+	}
+	return l.entries[i].clone(), nil
+}
+
+// Balance returns an account's balance.
+func (l *Ledger) Balance(account string) int64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.balances[account]
+}
+
+// ReceiptPoints returns the net points an account has received for a receipt.
+func (l *Ledger) ReceiptPoints(account, receiptID string) int64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.receipts[receiptKey{account, receiptID}]
+}
+
+// ReceiptReversed reports whether an account's credit for a receipt was
+// reversed.
+func (l *Ledger) ReceiptReversed(account, receiptID string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.reverted[receiptKey{account, receiptID}]
+}
+
+// Entries returns up to limit of the entries posting to account, or of all
+// entries if account is empty, newest first after skipping offset of them,
+// and how many such entries there are.
+func (l *Ledger) Entries(account string, offset, limit int) ([]Entry, int) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	entry := func(i int) Entry { return l.entries[i] }
+	total := len(l.entries)
+	if account != "" {
+		indexes := l.byAccount[account]
+		entry = func(i int) Entry { return l.entries[indexes[i]] }
+		total = len(indexes)
+	}
+	page := []Entry{}
+	for i := total - 1 - offset; i >= 0 && len(page) < limit; i-- {
+		page = append(page, entry(i).clone())
+	}
+	return page, total
+}
+
+// History returns the entries posting to account in posting order.
+func (l *Ledger) History(account string) []Entry {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	indexes := l.byAccount[account]
+	history := make([]Entry, len(indexes))
+	for i, index := range indexes {
+		history[i] = l.entries[index].clone()
+	}
+	return history
+}
+
+// All returns every entry in posting order.
+func (l *Ledger) All() []Entry {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	all := make([]Entry, len(l.entries))
+	for i, e := range l.entries {
+		all[i] = e.clone()
+	}
+	return all
+}
+
+// Rebuild derives account balances from entries alone.
+func Rebuild(entries []Entry) map[string]int64 {
+	balances := map[string]int64{}
+	for _, e := range entries {
+		for _, p := range e.Postings {
+			balances[p.Account] += p.Amount
+		}
+	}
+	return balances
+}
+
+// Mismatch is an account whose kept balance differs from the rebuilt one.
+type Mismatch struct {
+	Account string `json:"account"`
+	Kept    int64  `json:"kept"`
+	Rebuilt int64  `json:"rebuilt"`
+}
+
+// Reconciliation compares the kept balances with balances rebuilt from the
+// entries. Total is the sum of all balances, which is zero for a sound ledger.
+type Reconciliation struct {
+	Entries    int        `json:"entries"`
+	Accounts   int        `json:"accounts"`
+	Total      int64      `json:"total"`
+	Mismatches []Mismatch `json:"mismatches"`
+}
+
+// OK reports whether the ledger reconciles.
+func (r Reconciliation) OK() bool {
+	return len(r.Mismatches) == 0 && r.Total == 0
+}
+
+// Reconcile rebuilds every balance from the entries and compares it with
+// the balance kept as entries were posted.
+func (l *Ledger) Reconcile() Reconciliation {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	rebuilt := Rebuild(l.entries)
+	r := Reconciliation{Entries: len(l.entries), Mismatches: []Mismatch{}}
+
+	accounts := map[string]bool{}
+	for a := range rebuilt {
+		accounts[a] = true
+	}
+	for a := range l.balances {
+		accounts[a] = true
+	}
+	for a := range accounts {
+		r.Total += rebuilt[a]
+		if rebuilt[a] != l.balances[a] {
+			r.Mismatches = append(r.Mismatches, Mismatch{Account: a, Kept: l.balances[a], Rebuilt: rebuilt[a]})
+		}
+	}
+	r.Accounts = len(accounts)
+	sort.Slice(r.Mismatches, func(i, j int) bool { return r.Mismatches[i].Account < r.Mismatches[j].Account })
+	return r
+}
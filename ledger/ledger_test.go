@@ -0,0 +1,272 @@
+package ledger
+
+import (
+	"errors"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	receipt := Transfer(ReasonReceipt, AccountIssued, UserAccount("alice"), 10)
+	receipt.ReceiptID = "r1"
+
+	tests := []struct {
+		name    string
+		entry   Entry
+		wantErr bool
+	}{
+		{"receipt credit", receipt, false},
+		{"adjustment", Transfer(ReasonAdjustment, AccountAdjustments, UserAccount("alice"), -5), false},
+		{"three postings", Entry{Reason: ReasonRedemption, Postings: []Posting{{UserAccount("alice"), -10}, {AccountRedeemed, 8}, {AccountAdjustments, 2}}}, false},
+		{"unknown reason", Transfer("bonus", AccountIssued, UserAccount("alice"), 10), true},
+		{"receipt without receipt ID", Transfer(ReasonReceipt, AccountIssued, UserAccount("alice"), 10), true},
+		{"one posting", Entry{Reason: ReasonAdjustment, Postings: []Posting{{UserAccount("alice"), 10}}}, true},
+		{"zero amount", Transfer(ReasonAdjustment, AccountAdjustments, UserAccount("alice"), 0), true},
+		{"empty account", Transfer(ReasonAdjustment, "", UserAccount("alice"), 10), true},
+		{"unbalanced", Entry{Reason: ReasonAdjustment, Postings: []Posting{{AccountAdjustments, -10}, {UserAccount("alice"), 9}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.entry.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	unbalanced := Entry{Reason: ReasonAdjustment, Postings: []Posting{{AccountAdjustments, -10}, {UserAccount("alice"), 9}}}
+	if _, err := New().Post(unbalanced); !errors.Is(err, ErrUnbalanced) {
+		t.Errorf("Post() unbalanced error = %v, want ErrUnbalanced", err)
+	}
+}
+
+func TestReverse(t *testing.T) {
+	l := New()
+	alice := UserAccount("alice")
+	credit := Transfer(ReasonReceipt, AccountIssued, alice, 40)
+	credit.ReceiptID = "r1"
+	posted, err := l.Post(credit)
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	if posted.ID == "" || posted.Seq != 1 || posted.Time.IsZero() {
+		t.Errorf("Post() = %+v, want an ID, sequence 1 and a time", posted)
+	}
+
+	reversal, err := l.Reverse(posted.ID, "fraudulent receipt")
+	if err != nil {
+		t.Fatalf("Reverse() error = %v", err)
+	}
+	if reversal.Reason != ReasonReversal || reversal.Reverses != posted.ID || reversal.ReceiptID != "r1" {
+		t.Errorf("Reverse() = %+v, want a reversal of %s for receipt r1", reversal, posted.ID)
+	}
+	if b := l.Balance(alice); b != 0 {
+		t.Errorf("Balance() after reversal = %d, want 0", b)
+	}
+	if p := l.ReceiptPoints(alice, "r1"); p != 0 {
+		t.Errorf("ReceiptPoints() after reversal = %d, want 0", p)
+	}
+	if !l.ReceiptReversed(alice, "r1") || l.ReceiptReversed(alice, "r2") {
+		t.Error("ReceiptReversed() should report r1's credit only")
+	}
+
+	if _, err := l.Reverse(posted.ID, "again"); !errors.Is(err, ErrAlreadyReversed) {
+		t.Errorf("Reverse() twice error = %v, want ErrAlreadyReversed", err)
+	}
+	if _, err := l.Reverse(reversal.ID, "undo"); err == nil {
+		t.Error("Reverse() of a reversal succeeded, want an error")
+	}
+	if _, err := l.Reverse("missing", ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Reverse() unknown entry error = %v, want ErrNotFound", err)
+	}
+	if _, err := l.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() unknown entry error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRefund(t *testing.T) {
+	l := New()
+	alice := UserAccount("alice")
+	receipt := Transfer(ReasonReceipt, AccountIssued, alice, 40)
+	receipt.ReceiptID = "r1"
+	credit, _ := l.Post(receipt)
+	debit, err := l.PostCovered(Transfer(ReasonRedemption, alice, AccountRedeemed, 30))
+	if err != nil {
+		t.Fatalf("PostCovered() error = %v", err)
+	}
+
+	if _, err := l.Reverse(debit.ID, "refund"); !errors.Is(err, ErrRedemption) {
+		t.Errorf("Reverse() of a redemption error = %v, want ErrRedemption", err)
+	}
+	if _, err := l.Refund(credit.ID, "refund"); err == nil {
+		t.Error("Refund() of a receipt credit succeeded, want an error")
+	}
+	refund, err := l.Refund(debit.ID, "redemption cancelled")
+	if err != nil {
+		t.Fatalf("Refund() error = %v", err)
+	}
+	if refund.Reason != ReasonReversal || refund.Reverses != debit.ID || l.Balance(alice) != 40 {
+		t.Errorf("Refund() = %+v leaving %d, want a reversal of %s leaving 40", refund, l.Balance(alice), debit.ID)
+	}
+	if _, err := l.Refund(debit.ID, "again"); !errors.Is(err, ErrAlreadyReversed) {
+		t.Errorf("Refund() twice error = %v, want ErrAlreadyReversed", err)
+	}
+}
+
+func TestEntries(t *testing.T) {
+	l := New()
+	for i := 1; i <= 5; i++ {
+		e := Transfer(ReasonReceipt, AccountIssued, UserAccount("alice"), int64(i))
+		e.ReceiptID = "r" + strconv.Itoa(i)
+		l.Post(e)
+	}
+	l.Post(Transfer(ReasonAdjustment, AccountAdjustments, UserAccount("bob"), 3))
+
+	tests := []struct {
+		account       string
+		offset, limit int
+		wantTotal     int
+		wantSeqs      []int64
+	}{
+		{UserAccount("alice"), 0, 10, 5, []int64{5, 4, 3, 2, 1}},
+		{UserAccount("alice"), 1, 2, 5, []int64{4, 3}},
+		{UserAccount("alice"), 5, 2, 5, nil},
+		{UserAccount("bob"), 0, 10, 1, []int64{6}},
+		{"", 0, 2, 6, []int64{6, 5}},
+		{UserAccount("carol"), 0, 10, 0, nil},
+	}
+	for _, tt := range tests {
+		page, total := l.Entries(tt.account, tt.offset, tt.limit)
+		if total != tt.wantTotal || len(page) != len(tt.wantSeqs) {
+			t.Errorf("Entries(%q, %d, %d) = %d of %d, want %v of %d", tt.account, tt.offset, tt.limit, len(page), total, tt.wantSeqs, tt.wantTotal)
+			continue
+		}
+		for i, e := range page {
+			if e.Seq != tt.wantSeqs[i] {
+				t.Errorf("Entries(%q, %d, %d)[%d].Seq = %d, want %d", tt.account, tt.offset, tt.limit, i, e.Seq, tt.wantSeqs[i])
+			}
+		}
+	}
+
+	// Changing a returned entry does not change the ledger
+	page, _ := l.Entries(UserAccount("bob"), 0, 1)
+	page[0].Postings[1].Amount = 1000
+	if b := l.Balance(UserAccount("bob")); b != 3 {
+		t.Errorf("Balance() after changing a returned entry = %d, want 3", b)
+	}
+	if r := l.Reconcile(); !r.OK() {
+		t.Errorf("Reconcile() after changing a returned entry = %+v, want no mismatches", r)
+	}
+}
+
+// TestReconcile posts a random mix of every kind of entry and checks that
+// balances rebuilt from the entries alone match the ones kept as they were
+// posted.
+func TestReconcile(t *testing.T) {
+	l := New()
+	rng := rand.New(rand.NewSource(1))
+	users := []string{"alice", "bob", "carol"}
+	var posted []Entry
+	for i := 0; i < 500; i++ {
+		user := UserAccount(users[rng.Intn(len(users))])
+		amount := int64(rng.Intn(100) + 1)
+		var e Entry
+		switch rng.Intn(5) {
+		case 0, 1:
+			e = Transfer(ReasonReceipt, AccountIssued, user, amount)
+			e.ReceiptID = "r" + strconv.Itoa(i)
+		case 2:
+			e = Transfer(ReasonAdjustment, AccountAdjustments, user, amount-50)
+		case 3:
+			e = Transfer(ReasonRedemption, user, AccountRedeemed, amount)
+		case 4:
+			e = Transfer(ReasonExpiry, user, AccountExpired, amount)
+		}
+		if e.Postings[0].Amount == 0 {
+			continue
+		}
+		entry, err := l.Post(e)
+		if err != nil {
+			t.Fatalf("Post(%+v) error = %v", e, err)
+		}
+		posted = append(posted, entry)
+		if rng.Intn(10) == 0 {
+			target, reverse := posted[rng.Intn(len(posted))], l.Reverse
+			if target.Reason == ReasonRedemption {
+				reverse = l.Refund
+			}
+			if _, err := reverse(target.ID, "random"); err != nil && !errors.Is(err, ErrAlreadyReversed) {
+				t.Fatalf("Reverse() error = %v", err)
+			}
+		}
+	}
+
+	r := l.Reconcile()
+	if !r.OK() {
+		t.Fatalf("Reconcile() = %+v, want no mismatches and a zero total", r)
+	}
+	all := l.All()
+	if r.Entries != len(all) {
+		t.Errorf("Reconcile() checked %d entries, want %d", r.Entries, len(all))
+	}
+	for account, balance := range Rebuild(all) {
+		if kept := l.Balance(account); kept != balance {
+			t.Errorf("Balance(%s) = %d, rebuilt %d", account, kept, balance)
+		}
+	}
+
+	// A kept balance that drifts from the entries is reported
+	l.balances[UserAccount("alice")]++
+	r = l.Reconcile()
+	if r.OK() || len(r.Mismatches) != 1 || r.Mismatches[0].Account != UserAccount("alice") {
+		t.Errorf("Reconcile() after drift = %+v, want one mismatch for alice", r)
+	}
+}
+
+func TestPostCovered(t *testing.T) {
+	l := New()
+	alice := UserAccount("alice")
+	l.Post(Transfer(ReasonAdjustment, AccountAdjustments, alice, 50))
+
+	if _, err := l.PostCovered(Transfer(ReasonRedemption, alice, AccountRedeemed, 51)); !errors.Is(err, ErrInsufficientPoints) {
+		t.Errorf("PostCovered() overdraft error = %v, want ErrInsufficientPoints", err)
+	}
+	if _, err := l.PostCovered(Transfer(ReasonRedemption, alice, AccountRedeemed, 50)); err != nil {
+		t.Errorf("PostCovered() of the whole balance error = %v", err)
+	}
+	if b := l.Balance(alice); b != 0 {
+		t.Errorf("Balance() = %d, want 0", b)
+	}
+	// System accounts may go negative; they are where points come from
+	if _, err := l.PostCovered(Transfer(ReasonAdjustment, AccountAdjustments, alice, 5)); err != nil {
+		t.Errorf("PostCovered() from a system account error = %v", err)
+	}
+}
+
+func TestPostIfLatest(t *testing.T) {
+	l := New()
+	alice := UserAccount("alice")
+	if _, err := l.PostIfLatest(Transfer(ReasonAdjustment, AccountAdjustments, alice, 20), alice, 0); err != nil {
+		t.Fatalf("PostIfLatest() on an empty account error = %v", err)
+	}
+	history := l.History(alice)
+	if len(history) != 1 || history[0].Seq != 1 {
+		t.Fatalf("History() = %+v, want the one entry", history)
+	}
+
+	// Another entry lands after the history was read
+	l.Post(Transfer(ReasonAdjustment, AccountAdjustments, alice, 5))
+	if _, err := l.PostIfLatest(Transfer(ReasonExpiry, alice, AccountExpired, 20), alice, history[0].Seq); !errors.Is(err, ErrStale) {
+		t.Errorf("PostIfLatest() after a new entry error = %v, want ErrStale", err)
+	}
+	if _, err := l.PostIfLatest(Transfer(ReasonExpiry, alice, AccountExpired, 30), alice, 2); !errors.Is(err, ErrInsufficientPoints) {
+		t.Errorf("PostIfLatest() overdraft error = %v, want ErrInsufficientPoints", err)
+	}
+	if _, err := l.PostIfLatest(Transfer(ReasonExpiry, alice, AccountExpired, 25), alice, 2); err != nil {
+		t.Errorf("PostIfLatest() error = %v", err)
+	}
+	if b := l.Balance(alice); b != 0 {
+		t.Errorf("Balance() = %d, want 0", b)
+	}
+}
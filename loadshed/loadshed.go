@@ -0,0 +1,134 @@
+// Package loadshed bounds the number of requests in flight with an
+// adaptive (AIMD) concurrency limit: the limit grows by one while requests
+// complete within the latency target and the limit is being used, and is
+// cut multiplicatively when a request is slow or fails.
+package loadshed
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/suryamp/receipt-processor/metrics"
+)
+
+// Priority decides how much of the limit a request may use.
+type Priority int
+
+const (
+	// Critical requests (health checks, metrics scrapes) are always admitted.
+	Critical Priority = iota
+	// Read requests may use the whole limit.
+	Read
+	// Write requests may only use WriteFraction of it, leaving room for reads.
+	Write
+)
+
+func (p Priority) String() string {
+	switch p {
+	case Critical:
+		return "critical"
+	case Read:
+		return "read"
+	}
+	return "write"
+}
+
+// Config sets the bounds and tuning of a Limiter.
+type Config struct {
+	InitialLimit  int
+	MinLimit      int
+	MaxLimit      int
+	LatencyTarget time.Duration
+	Backoff       float64
+	WriteFraction float64
+	RetryAfter    time.Duration
+}
+
+var DefaultConfig = Config{
+	InitialLimit:  20,
+	MinLimit:      4,
+	MaxLimit:      200,
+	LatencyTarget: 250 * time.Millisecond,
+	Backoff:       0.9,
+	WriteFraction: 0.8,
+	RetryAfter:    time.Second,
+}
+
+type Limiter struct {
+	cfg Config
+	now func() time.Time
+
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+}
+
+func NewLimiter(cfg Config) *Limiter {
+	l := &Limiter{cfg: cfg, now: time.Now, limit: float64(cfg.InitialLimit)}
+	l.publish()
+	return l
+}
+
+// Acquire admits a request of priority p if there is room for it. The
+// returned release must be called once the request finishes, reporting
+// whether it failed.
+func (l *Limiter) Acquire(p Priority) (release func(failed bool), ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if p != Critical && float64(l.inflight) >= l.capacity(p) {
+		metrics.LoadShedTotal.WithLabelValues(p.String()).Inc()
+		return nil, false
+	}
+	l.inflight++
+	l.publish()
+
+	start := l.now()
+	var once sync.Once
+	return func(failed bool) {
+		once.Do(func() { l.release(l.now().Sub(start), failed) })
+	}, true
+}
+
+// Limit returns the current concurrency limit.
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// RetryAfter is the delay suggested to rejected callers.
+func (l *Limiter) RetryAfter() time.Duration {
+	return l.cfg.RetryAfter
+}
+
+func (l *Limiter) capacity(p Priority) float64 {
+	if p == Write {
+		return math.Max(1, math.Floor(l.limit*l.cfg.WriteFraction))
+	}
+	return math.Floor(l.limit)
+}
+
+func (l *Limiter) release(latency time.Duration, failed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Judge the limit against the load it was under before this request left.
+	inflight := l.inflight
+	l.inflight--
+
+	switch {
+	case failed || latency > l.cfg.LatencyTarget:
+		l.limit = math.Max(float64(l.cfg.MinLimit), l.limit*l.cfg.Backoff)
+	case float64(inflight)*2 >= l.limit:
+		l.limit = math.Min(float64(l.cfg.MaxLimit), l.limit+1)
+	}
+	l.publish()
+}
+
+// publish exports the limiter state; the caller must hold l.mu or own l.
+func (l *Limiter) publish() {
+	metrics.ConcurrencyLimit.Set(math.Floor(l.limit))
+	metrics.ConcurrencyInflight.Set(float64(l.inflight))
+}
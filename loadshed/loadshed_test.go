@@ -0,0 +1,93 @@
+package loadshed
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestLimiter(cfg Config) (*Limiter, *time.Time) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := NewLimiter(cfg)
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestAcquirePriorities(t *testing.T) {
+	l, _ := newTestLimiter(Config{InitialLimit: 4, MinLimit: 1, MaxLimit: 4, LatencyTarget: time.Second, Backoff: 0.5, WriteFraction: 0.5})
+
+	steps := []struct {
+		name     string
+		priority Priority
+		want     bool
+	}{
+		{"first write", Write, true},
+		{"second write", Write, true},
+		{"write over its share", Write, false},
+		{"read uses remaining capacity", Read, true},
+		{"read fills the limit", Read, true},
+		{"read over the limit", Read, false},
+		{"critical is always admitted", Critical, true},
+	}
+
+	for _, s := range steps {
+		if _, ok := l.Acquire(s.priority); ok != s.want {
+			t.Errorf("%s: Acquire(%v) = %v, want %v", s.name, s.priority, ok, s.want)
+		}
+	}
+}
+
+func TestAdaptiveLimit(t *testing.T) {
+	l, now := newTestLimiter(Config{InitialLimit: 4, MinLimit: 2, MaxLimit: 5, LatencyTarget: 100 * time.Millisecond, Backoff: 0.5, WriteFraction: 1})
+
+	run := func(n int, latency time.Duration, failed bool) {
+		releases := make([]func(bool), 0, n)
+		for i := 0; i < n; i++ {
+			release, ok := l.Acquire(Read)
+			if !ok {
+				t.Fatalf("Acquire() rejected request %d of %d at limit %d", i+1, n, l.Limit())
+			}
+			releases = append(releases, release)
+		}
+		*now = now.Add(latency)
+		for _, release := range releases {
+			release(failed)
+		}
+	}
+
+	steps := []struct {
+		name      string
+		inflight  int
+		latency   time.Duration
+		failed    bool
+		wantLimit int
+	}{
+		{"idle limit does not grow", 1, 10 * time.Millisecond, false, 4},
+		{"busy fast requests grow the limit", 2, 10 * time.Millisecond, false, 5},
+		{"growth is capped at maxLimit", 4, 10 * time.Millisecond, false, 5},
+		{"slow request backs off", 1, time.Second, false, 2},
+		{"backoff is floored at minLimit", 1, time.Second, false, 2},
+		{"failure backs off", 2, 10 * time.Millisecond, true, 2},
+	}
+
+	for _, s := range steps {
+		run(s.inflight, s.latency, s.failed)
+		if got := l.Limit(); got != s.wantLimit {
+			t.Errorf("%s: Limit() = %d, want %d", s.name, got, s.wantLimit)
+		}
+	}
+}
+
+func TestReleaseIsIdempotent(t *testing.T) {
+	l, _ := newTestLimiter(Config{InitialLimit: 1, MinLimit: 1, MaxLimit: 1, LatencyTarget: time.Second, Backoff: 0.5, WriteFraction: 1})
+
+	release, _ := l.Acquire(Read)
+	release(false)
+	release(false)
+
+	if _, ok := l.Acquire(Read); !ok {
+		t.Fatal("Acquire() rejected after release")
+	}
+	if _, ok := l.Acquire(Read); ok {
+		t.Error("double release freed more than one slot")
+	}
+}
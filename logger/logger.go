@@ -1,6 +1,8 @@
 package logger
 
 import (
+	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
@@ -8,27 +10,42 @@ import (
 	"github.com/suryamp/receipt-processor/internal/testing"
 )
 
+const (
+	DefaultDir   = "logs"
+	DefaultFile  = "receipt-processor.log"
+	DefaultLevel = LevelInfo
+
+	LevelInfo  = "info"
+	LevelError = "error"
+)
+
 var (
 	InfoLogger  *log.Logger
 	ErrorLogger *log.Logger
 )
 
 func Init() error {
+	return Configure(DefaultDir, DefaultFile, DefaultLevel)
+}
+
+// Configure points the loggers at dir/file and applies the given level.
+// Under go test the loggers always write to stdout.
+func Configure(dir, file, level string) error {
 	// For tests, just log to stdout
 	if testing.Testing() {
 		InfoLogger = log.New(os.Stdout, "[INFO] ", log.Ldate|log.Ltime|log.Lshortfile)
 		ErrorLogger = log.New(os.Stdout, "[ERROR] ", log.Ldate|log.Ltime|log.Lshortfile)
-		return nil
+		return SetLevel(level)
 	}
 
 	// Create logs directory if it doesn't exist
-	if err := os.MkdirAll("logs", 0755); err != nil {
+	if err := os.MkdirAll(dir, 0755); err != nil {
 		return err
 	}
 
 	// Open log file with append mode
 	logFile, err := os.OpenFile(
-		filepath.Join("logs", "receipt-processor.log"),
+		filepath.Join(dir, file),
 		os.O_APPEND|os.O_CREATE|os.O_WRONLY,
 		0644,
 	)
@@ -40,5 +57,27 @@ func Init() error {
 	InfoLogger = log.New(logFile, "[INFO] ", log.Ldate|log.Ltime|log.Lshortfile)
 	ErrorLogger = log.New(logFile, "[ERROR] ", log.Ldate|log.Ltime|log.Lshortfile)
 
+	return SetLevel(level)
+}
+
+// SetLevel silences InfoLogger when level is "error". ErrorLogger is never silenced.
+func SetLevel(level string) error {
+	if err := ValidateLevel(level); err != nil {
+		return err
+	}
+	if level == LevelError {
+		InfoLogger.SetOutput(io.Discard)
+	} else {
+		InfoLogger.SetOutput(ErrorLogger.Writer())
+	}
 	return nil
 }
+
+// ValidateLevel reports whether level is a supported log level.
+func ValidateLevel(level string) error {
+	switch level {
+	case LevelInfo, LevelError:
+		return nil
+	}
+	return fmt.Errorf("unknown log level %q", level)
+}
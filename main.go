@@ -2,74 +2,265 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
+	_ "time/tzdata" // receipts name IANA timezones; the runtime image has no zoneinfo
 
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/suryamp/receipt-processor/auth"
+	"github.com/suryamp/receipt-processor/authz"
+	"github.com/suryamp/receipt-processor/certs"
+	"github.com/suryamp/receipt-processor/config"
+	"github.com/suryamp/receipt-processor/expiry"
 	"github.com/suryamp/receipt-processor/handlers"
+	"github.com/suryamp/receipt-processor/importer"
+	"github.com/suryamp/receipt-processor/items"
+	"github.com/suryamp/receipt-processor/leaderboard"
+	"github.com/suryamp/receipt-processor/ledger"
+	"github.com/suryamp/receipt-processor/loadshed"
 	"github.com/suryamp/receipt-processor/logger"
 	"github.com/suryamp/receipt-processor/middleware"
 	"github.com/suryamp/receipt-processor/processor"
+	"github.com/suryamp/receipt-processor/promotions"
+	"github.com/suryamp/receipt-processor/ratelimit"
+	"github.com/suryamp/receipt-processor/rewards"
+	"github.com/suryamp/receipt-processor/stats"
+	"github.com/suryamp/receipt-processor/tiers"
+	"github.com/suryamp/receipt-processor/users"
 )
 
 var handler *handlers.Handler
 var receiptProcessor processor.ReceiptProcessor
 
-func init() {
-	if err := logger.Init(); err != nil {
+func main() {
+	loaded, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if loaded.PrintConfig {
+		if err := loaded.Print(os.Stdout); err != nil {
+			log.Fatalf("Failed to print configuration: %v", err)
+		}
+		return
+	}
+	cfg := loaded.Config
+
+	if err := logger.Configure(cfg.Logging.Dir, cfg.Logging.File, cfg.Logging.Level); err != nil {
 		log.Fatalf("Failed to initialize logger: %v", err)
 	}
-}
+	if loaded.File != "" {
+		logger.InfoLogger.Printf("Loaded configuration from %s", loaded.File)
+	}
 
-func main() {
-	receiptProcessor = processor.NewInMemoryProcessor()
-	handler = handlers.NewHandler(receiptProcessor)
+	campaigns := promotions.NewStore()
+	points := ledger.New()
+	accounts := users.NewStore(points)
+	catalog := rewards.NewStore(points)
+	rollup := stats.NewRollup()
+	bought := items.NewCatalog()
+	var sweeper *expiry.Sweeper
+	if cfg.Expiry.Enabled {
+		sweeper = expiry.NewSweeper(cfg.Expiry.ExpiryPolicy(), accounts)
+	}
+	var ranks *tiers.Tracker
+	if cfg.Tiers.Enabled {
+		ranks = tiers.NewTracker(cfg.Tiers.Levels, accounts).OnChange(func(c tiers.Change) {
+			logger.InfoLogger.Printf("User %s moved from tier %q to %q with %d qualifying points (%s)", c.UserID, c.From, c.To, c.Points, c.Cause)
+		})
+	}
+	inMemoryProcessor := processor.NewInMemoryProcessor(cfg.Rules).WithPromotions(campaigns).WithUsers(accounts).WithStats(rollup).WithItems(bought)
+	if ranks != nil {
+		inMemoryProcessor.WithTiers(ranks)
+	}
+	var boards *leaderboard.Boards
+	if cfg.Leaderboards.Enabled {
+		boards = leaderboard.New(cfg.Leaderboards.Location()).Follow(points)
+		inMemoryProcessor.WithLeaderboards(boards)
+	}
+	receiptProcessor = inMemoryProcessor
+	if err := inMemoryProcessor.SetShadowRules(shadowRules(cfg.Shadow)); err != nil {
+		logger.ErrorLogger.Fatalf("Invalid shadow rules: %v", err)
+	}
+	handler = handlers.NewHandler(receiptProcessor).WithLimits(handlers.Limits{
+		MaxBodyBytes:    cfg.Limits.MaxBodyBytes,
+		MaxItems:        cfg.Limits.MaxItems,
+		MaxStringLength: cfg.Limits.MaxStringLength,
+	})
+
+	// Rules, shadow rules, tier levels and log level can change without a
+	// restart, which would wipe all receipts. Everything is checked before
+	// anything is swapped, so a bad reload leaves the service as it was.
+	reloader := config.NewReloader(os.Args[1:], loaded, func(next *config.Loaded) error {
+		if err := next.Config.Rules.Validate(); err != nil {
+			return err
+		}
+		shadow := shadowRules(next.Config.Shadow)
+		if shadow != nil {
+			if err := shadow.Validate(); err != nil {
+				return fmt.Errorf("invalid shadow rules: %w", err)
+			}
+		}
+		if err := logger.ValidateLevel(next.Config.Logging.Level); err != nil {
+			return err
+		}
+		setLadder := ranks != nil && next.Config.Tiers.Enabled
+		if setLadder {
+			if err := next.Config.Tiers.Levels.Validate(); err != nil {
+				return err
+			}
+		}
+
+		if err := inMemoryProcessor.SetShadowRules(shadow); err != nil {
+			return fmt.Errorf("invalid shadow rules: %w", err)
+		}
+		if setLadder {
+			if err := ranks.SetLadder(next.Config.Tiers.Levels); err != nil {
+				return err
+			}
+		}
+		inMemoryProcessor.SetRules(next.Config.Rules)
+		logger.SetLevel(next.Config.Logging.Level)
+		return nil
+	})
+	adminHandler := handlers.NewAdminHandler(reloader)
+	promotionsHandler := handlers.NewPromotionsHandler(campaigns)
+	rulesHandler := handlers.NewRulesHandler(inMemoryProcessor)
+	usersHandler := handlers.NewUsersHandler(accounts)
+	ledgerHandler := handlers.NewLedgerHandler(points)
+	rewardsHandler := handlers.NewRewardsHandler(catalog, accounts)
+	expiryHandler := handlers.NewExpiryHandler(sweeper, cfg.Expiry.WarningDays)
+	tiersHandler := handlers.NewTiersHandler(ranks)
+	leaderboardsHandler := handlers.NewLeaderboardsHandler(boards)
+	statsHandler := handlers.NewStatsHandler(rollup)
+	itemsHandler := handlers.NewItemsHandler(bought)
+	exportHandler := handlers.NewExportHandler(inMemoryProcessor)
+	importsHandler := handlers.NewImportsHandler(
+		importer.New(inMemoryProcessor).WithLimits(cfg.Limits.MaxItems, cfg.Limits.MaxStringLength),
+		cfg.Imports.Mapping,
+	)
 
 	// Set up router
 	r := mux.NewRouter()
 
-	r.Handle("/metrics", promhttp.Handler())
-
 	// Add metrics middleware
 	r.Use(middleware.MetricsMiddleware)
+	if ls := cfg.LoadShed; ls.Enabled {
+		limiter := loadshed.NewLimiter(loadshed.Config{
+			InitialLimit:  ls.InitialLimit,
+			MinLimit:      ls.MinLimit,
+			MaxLimit:      ls.MaxLimit,
+			LatencyTarget: ls.LatencyTarget,
+			Backoff:       ls.Backoff,
+			WriteFraction: ls.WriteFraction,
+			RetryAfter:    ls.RetryAfter,
+		})
+		r.Use(middleware.LoadSheddingMiddleware(limiter, []string{"/exports/receipts", "/imports"}, "/health", "/metrics"))
+	}
+	r.Use(middleware.BodyLimitMiddleware(cfg.Limits.MaxBodyBytes, map[string]int64{"/imports": cfg.Imports.MaxBodyBytes}))
+	r.Use(middleware.ClientCertMiddleware(cfg.Server.TLS.ClientIdentities))
+	if cfg.Auth.Enabled {
+		var store auth.Store = &auth.MemoryStore{}
+		if cfg.Auth.KeysFile != "" {
+			if store, err = auth.LoadKeyFile(cfg.Auth.KeysFile); err != nil {
+				logger.ErrorLogger.Fatalf("Failed to load API keys: %v", err)
+			}
+		}
+		r.Use(middleware.AuthMiddleware(auth.NewAuthenticator(store, cfg.Auth.MaxClockSkew), "/health", "/metrics"))
+	}
+	if cfg.Authz.Enabled {
+		policy, err := authz.NewPolicy(authz.Routes, cfg.Authz.ClientRoles)
+		if err != nil {
+			logger.ErrorLogger.Fatalf("Invalid authorization policy: %v", err)
+		}
+		r.Use(middleware.AuthorizationMiddleware(policy, cfg.Authz.DryRun))
+	}
+	if rl := cfg.RateLimit; rl.Enabled {
+		limiter := ratelimit.NewLimiter(ratelimit.Config{
+			Default:      rl.Default,
+			Routes:       rl.Routes,
+			DailyQuota:   rl.DailyQuota,
+			ClientQuotas: rl.ClientQuotas,
+			QuotaRoutes:  rl.QuotaRoutes,
+		}, ratelimit.NewMemoryStore())
+		r.Use(middleware.RateLimitMiddleware(limiter, "/health", "/metrics"))
+	}
 
-	var okResponse = []byte("OK")
-	r.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) {
-		w.Write(okResponse)
-	})
-
-	r.HandleFunc("/receipts/process", handler.ProcessReceiptHandler).Methods("POST")
-	r.HandleFunc("/receipts/{id}/points", handler.GetPointsHandler).Methods("GET")
+	registerRoutes(r, handler, adminHandler, promotionsHandler, rulesHandler, usersHandler, ledgerHandler, rewardsHandler, expiryHandler, tiersHandler, leaderboardsHandler, statsHandler, itemsHandler, exportHandler, importsHandler)
 
 	// Configure server
 	srv := &http.Server{
-		Addr:         ":8080",
-		Handler:      r,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
+		Addr:           cfg.Server.Addr,
+		Handler:        r,
+		ReadTimeout:    cfg.Server.ReadTimeout,
+		WriteTimeout:   cfg.Server.WriteTimeout,
+		MaxHeaderBytes: cfg.Limits.MaxHeaderBytes,
+	}
+
+	if tlsCfg := cfg.Server.TLS; tlsCfg.Enabled() {
+		keyPair, err := certs.NewKeyPair(tlsCfg.CertFile, tlsCfg.KeyFile, certs.DefaultCheckInterval)
+		if err != nil {
+			logger.ErrorLogger.Fatalf("TLS setup failed: %v", err)
+		}
+		if srv.TLSConfig, err = certs.ServerConfig(keyPair, tlsCfg.ClientCAFile, tlsCfg.RequireClientCert); err != nil {
+			logger.ErrorLogger.Fatalf("TLS setup failed: %v", err)
+		}
 	}
 
 	// Start server
 	go func() {
-		logger.InfoLogger.Printf("Server starting on port 8080")
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if srv.TLSConfig != nil {
+			logger.InfoLogger.Printf("Server starting with TLS on %s", cfg.Server.Addr)
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			logger.InfoLogger.Printf("Server starting on %s", cfg.Server.Addr)
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			logger.ErrorLogger.Fatalf("Server error: %v", err)
 		}
 	}()
 
+	// Reload configuration on SIGHUP or config file change
+	reloadCtx, stopReloading := context.WithCancel(context.Background())
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go reloader.Run(reloadCtx, hup)
+
+	// Scheduled jobs: expiry sweeps, tier recalculation and leaderboard resets
+	sweepCtx, stopSweeping := context.WithCancel(context.Background())
+	if sweeper != nil {
+		logger.InfoLogger.Printf("Points expire under the %s policy after %d months; sweeping every %s", cfg.Expiry.Policy, cfg.Expiry.Months, cfg.Expiry.SweepInterval)
+		go sweeper.Run(sweepCtx, cfg.Expiry.SweepInterval)
+	}
+	if ranks != nil {
+		at, _ := tiers.ParseTimeOfDay(cfg.Tiers.RecalculateAt)
+		go ranks.Run(sweepCtx, at)
+	}
+	if boards != nil {
+		go boards.Run(sweepCtx)
+	}
+
 	// Graceful shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
 	logger.InfoLogger.Printf("Shutting down server...")
+	stopReloading()
+	stopSweeping()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
@@ -78,3 +269,71 @@ func main() {
 
 	logger.InfoLogger.Printf("Server exited gracefully")
 }
+
+// shadowRules returns the candidate rules to shadow, or nil if shadowing is off.
+func shadowRules(c config.ShadowConfig) *processor.Rules {
+	if !c.Enabled {
+		return nil
+	}
+	return &c.Rules
+}
+
+// registerRoutes adds every endpoint to r. Each route must also be listed in
+// authz.Routes, or authorization will deny it.
+func registerRoutes(r *mux.Router, handler *handlers.Handler, adminHandler *handlers.AdminHandler, promotionsHandler *handlers.PromotionsHandler, rulesHandler *handlers.RulesHandler, usersHandler *handlers.UsersHandler, ledgerHandler *handlers.LedgerHandler, rewardsHandler *handlers.RewardsHandler, expiryHandler *handlers.ExpiryHandler, tiersHandler *handlers.TiersHandler, leaderboardsHandler *handlers.LeaderboardsHandler, statsHandler *handlers.StatsHandler, itemsHandler *handlers.ItemsHandler, exportHandler *handlers.ExportHandler, importsHandler *handlers.ImportsHandler) {
+	r.Handle("/metrics", promhttp.Handler()).Methods("GET")
+
+	var okResponse = []byte("OK")
+	r.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write(okResponse)
+	}).Methods("GET")
+
+	r.HandleFunc("/receipts/process", handler.ProcessReceiptHandler).Methods("POST")
+	r.HandleFunc("/receipts/{id}/points", handler.GetPointsHandler).Methods("GET")
+	r.HandleFunc("/points/simulate", handler.SimulateHandler).Methods("POST")
+	r.HandleFunc("/users/{id}/balance", usersHandler.BalanceHandler).Methods("GET")
+	r.HandleFunc("/users/{id}/receipts", usersHandler.ReceiptsHandler).Methods("GET")
+	r.HandleFunc("/users/{id}/ledger", usersHandler.LedgerHandler).Methods("GET")
+	r.HandleFunc("/users/{id}/expiring", expiryHandler.ExpiringHandler).Methods("GET")
+	r.HandleFunc("/users/{id}/tier", tiersHandler.ProgressHandler).Methods("GET")
+	r.HandleFunc("/tiers", tiersHandler.LadderHandler).Methods("GET")
+	r.HandleFunc("/leaderboards", leaderboardsHandler.ListHandler).Methods("GET")
+	r.HandleFunc("/leaderboards/{name}", leaderboardsHandler.BoardHandler).Methods("GET")
+	r.HandleFunc("/stats", statsHandler.SummaryHandler).Methods("GET")
+	r.HandleFunc("/stats/{groupBy}", statsHandler.SummaryHandler).Methods("GET")
+	r.HandleFunc("/items", itemsHandler.ListHandler).Methods("GET")
+	// Item names may contain "/", as in "1/2 GALLON MILK", so prices come first
+	r.HandleFunc("/items/{name:.+}/prices", itemsHandler.PricesHandler).Methods("GET")
+	r.HandleFunc("/items/{name:.+}", itemsHandler.GetHandler).Methods("GET")
+	r.HandleFunc("/exports/receipts", exportHandler.ReceiptsHandler).Methods("GET")
+	r.HandleFunc("/imports", importsHandler.ImportHandler).Methods("POST")
+	r.HandleFunc("/rewards", rewardsHandler.CatalogHandler).Methods("GET")
+	r.HandleFunc("/users/{id}/redemptions", rewardsHandler.RedeemHandler).Methods("POST")
+	r.HandleFunc("/users/{id}/redemptions", rewardsHandler.RedemptionsHandler).Methods("GET")
+	r.HandleFunc("/users/{id}/redemptions/{redemptionId}/cancellation", rewardsHandler.CancelHandler).Methods("POST")
+	r.HandleFunc("/admin/config", adminHandler.ConfigStatusHandler).Methods("GET")
+	r.HandleFunc("/admin/promotions", promotionsHandler.ListHandler).Methods("GET")
+	r.HandleFunc("/admin/promotions", promotionsHandler.CreateHandler).Methods("POST")
+	r.HandleFunc("/admin/promotions/{id}", promotionsHandler.GetHandler).Methods("GET")
+	r.HandleFunc("/admin/promotions/{id}", promotionsHandler.UpdateHandler).Methods("PUT")
+	r.HandleFunc("/admin/promotions/{id}", promotionsHandler.DeleteHandler).Methods("DELETE")
+	r.HandleFunc("/admin/rules/versions", rulesHandler.VersionsHandler).Methods("GET")
+	r.HandleFunc("/admin/rules/versions/{version}", rulesHandler.VersionHandler).Methods("GET")
+	r.HandleFunc("/admin/rescore", rulesHandler.StartRescoreHandler).Methods("POST")
+	r.HandleFunc("/admin/rescore/{id}", rulesHandler.RescoreHandler).Methods("GET")
+	r.HandleFunc("/admin/shadow", rulesHandler.ShadowReportHandler).Methods("GET")
+	r.HandleFunc("/admin/shadow/receipts/{id}", rulesHandler.ShadowDeltaHandler).Methods("GET")
+	r.HandleFunc("/admin/users/{id}/adjustments", usersHandler.AdjustHandler).Methods("POST")
+	r.HandleFunc("/admin/ledger/entries", ledgerHandler.EntriesHandler).Methods("GET")
+	r.HandleFunc("/admin/ledger/entries/{id}", ledgerHandler.EntryHandler).Methods("GET")
+	r.HandleFunc("/admin/ledger/entries/{id}/reversal", ledgerHandler.ReversalHandler).Methods("POST")
+	r.HandleFunc("/admin/ledger/reconcile", ledgerHandler.ReconcileHandler).Methods("GET")
+	r.HandleFunc("/admin/rewards", rewardsHandler.ListHandler).Methods("GET")
+	r.HandleFunc("/admin/rewards", rewardsHandler.CreateHandler).Methods("POST")
+	r.HandleFunc("/admin/rewards/{id}", rewardsHandler.GetHandler).Methods("GET")
+	r.HandleFunc("/admin/rewards/{id}", rewardsHandler.UpdateHandler).Methods("PUT")
+	r.HandleFunc("/admin/rewards/{id}", rewardsHandler.DeleteHandler).Methods("DELETE")
+	r.HandleFunc("/admin/expiry/sweep", expiryHandler.SweepHandler).Methods("POST")
+	r.HandleFunc("/admin/tiers/changes", tiersHandler.ChangesHandler).Methods("GET")
+	r.HandleFunc("/admin/tiers/recalculate", tiersHandler.RecalculateHandler).Methods("POST")
+}
@@ -9,11 +9,20 @@ import (
 	"testing"
 
 	"github.com/gorilla/mux"
+	"github.com/suryamp/receipt-processor/authz"
 	"github.com/suryamp/receipt-processor/handlers"
+	"github.com/suryamp/receipt-processor/importer"
+	"github.com/suryamp/receipt-processor/logger"
 	"github.com/suryamp/receipt-processor/models"
 	"github.com/suryamp/receipt-processor/processor"
 )
 
+func init() {
+	if err := logger.Init(); err != nil {
+		panic(err)
+	}
+}
+
 func TestIntegration(t *testing.T) {
 	// Setup
 	receiptProcessor := &processor.InMemoryProcessor{}
@@ -40,6 +49,7 @@ func TestIntegration(t *testing.T) {
 		}
 
 		req := httptest.NewRequest("POST", "/receipts/process", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
 		w := httptest.NewRecorder()
 		handler.ProcessReceiptHandler(w, req)
 
@@ -96,3 +106,27 @@ func TestIntegration(t *testing.T) {
 		}
 	})
 }
+
+func TestRoutesCoveredByPolicy(t *testing.T) {
+	r := mux.NewRouter()
+	registerRoutes(r, handlers.NewHandler(&processor.InMemoryProcessor{}), handlers.NewAdminHandler(nil), handlers.NewPromotionsHandler(nil), handlers.NewRulesHandler(nil), handlers.NewUsersHandler(nil), handlers.NewLedgerHandler(nil), handlers.NewRewardsHandler(nil, nil), handlers.NewExpiryHandler(nil, 0), handlers.NewTiersHandler(nil), handlers.NewLeaderboardsHandler(nil), handlers.NewStatsHandler(nil), handlers.NewItemsHandler(nil), handlers.NewExportHandler(nil), handlers.NewImportsHandler(nil, importer.Mapping{}))
+
+	policy, err := authz.NewPolicy(authz.Routes, nil)
+	if err != nil {
+		t.Fatalf("NewPolicy() error = %v", err)
+	}
+	r.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, _ := route.GetPathTemplate()
+		methods, err := route.GetMethods()
+		if err != nil {
+			t.Errorf("route %s has no method restriction", path)
+			return nil
+		}
+		for _, method := range methods {
+			if _, ok := policy.Required(method, path); !ok {
+				t.Errorf("route %s %s is missing from authz.Routes", method, path)
+			}
+		}
+		return nil
+	})
+}
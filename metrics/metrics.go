@@ -22,4 +22,105 @@ var (
 		},
 		[]string{"handler"},
 	)
+
+	ConfigReloadsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "config_reloads_total",
+			Help: "Total number of configuration reload attempts",
+		},
+		[]string{"result"},
+	)
+
+	ConfigLastReloadSuccess = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "config_last_reload_success",
+			Help: "Whether the last configuration reload succeeded (1) or failed (0)",
+		},
+	)
+
+	ConfigInfo = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "config_info",
+			Help: "Versions of the active configuration and scoring rules",
+		},
+		[]string{"config_version", "rules_version"},
+	)
+
+	AuthzDenialsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "authz_denials_total",
+			Help: "Requests denied by the authorization policy, including would-be denials in dry-run mode",
+		},
+		[]string{"permission", "dry_run"},
+	)
+
+	RateLimitedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rate_limited_requests_total",
+			Help: "Requests rejected by the rate limiter, by route and reason (rate or quota)",
+		},
+		[]string{"route", "reason"},
+	)
+
+	ConcurrencyLimit = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "concurrency_limit",
+			Help: "Current adaptive limit on requests in flight",
+		},
+	)
+
+	ConcurrencyInflight = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "concurrency_inflight",
+			Help: "Requests currently admitted by the concurrency limiter",
+		},
+	)
+
+	LoadShedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "load_shed_requests_total",
+			Help: "Requests rejected by the concurrency limiter, by priority",
+		},
+		[]string{"priority"},
+	)
+
+	ShadowEvaluationsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shadow_evaluations_total",
+			Help: "Receipts scored under the candidate rules, or skipped because the evaluator was behind",
+		},
+		[]string{"result"},
+	)
+
+	ShadowPointsDelta = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "shadow_points_delta",
+			Help:    "Candidate minus active points per receipt, by candidate rules version",
+			Buckets: []float64{-100, -50, -10, -1, 0, 1, 10, 50, 100},
+		},
+		[]string{"candidate_version"},
+	)
+
+	PointsExpiredTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "points_expired_total",
+			Help: "Total number of points retired by expiry sweeps",
+		},
+	)
+
+	TierChangesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tier_changes_total",
+			Help: "Total number of users moving tier, by the tier moved to",
+		},
+		[]string{"tier"},
+	)
+
+	ImportReceiptsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "import_receipts_total",
+			Help: "Receipts read by bulk imports, by result (imported, skipped or invalid) and whether the import was a dry run",
+		},
+		[]string{"result", "dry_run"},
+	)
 )
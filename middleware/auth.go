@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/suryamp/receipt-processor/auth"
+	"github.com/suryamp/receipt-processor/logger"
+	"github.com/suryamp/receipt-processor/problem"
+)
+
+// AuthMiddleware rejects requests without a caller identity. Callers already
+// identified by a client certificate pass through; otherwise the
+// authenticator checks the request's API key or signature and attaches the
+// resulting identity to its context. Paths in public skip authentication.
+func AuthMiddleware(a *auth.Authenticator, public ...string) func(http.Handler) http.Handler {
+	skip := map[string]bool{}
+	for _, p := range public {
+		skip[p] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := auth.FromContext(r.Context()); ok || skip[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			id, err := a.Authenticate(r)
+			if err != nil {
+				status := http.StatusUnauthorized
+				var tooLarge *http.MaxBytesError
+				switch {
+				case errors.Is(err, auth.ErrClientDisabled):
+					status = http.StatusForbidden
+				case errors.As(err, &tooLarge):
+					status = http.StatusRequestEntityTooLarge
+				}
+				logger.ErrorLogger.Printf("Rejected request to %s: %v", r.URL.Path, err)
+				if status == http.StatusUnauthorized {
+					w.Header().Set("WWW-Authenticate", `Bearer realm="receipt-processor"`)
+				}
+				problem.Write(w, problem.Details{Status: status, Detail: err.Error()})
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithIdentity(r.Context(), id)))
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/suryamp/receipt-processor/auth"
+	"github.com/suryamp/receipt-processor/logger"
+	"github.com/suryamp/receipt-processor/problem"
+)
+
+func init() {
+	if err := logger.Init(); err != nil {
+		panic(err)
+	}
+}
+
+// echoIdentity writes the caller identity, or "anonymous".
+var echoIdentity = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	id, ok := auth.FromContext(r.Context())
+	if !ok {
+		w.Write([]byte("anonymous"))
+		return
+	}
+	w.Write([]byte(id.ID))
+})
+
+func TestAuthMiddleware(t *testing.T) {
+	store, _ := auth.NewMemoryStore([]auth.Client{
+		{ID: "partner-a", KeyHashes: []string{auth.HashKey("key-a")}},
+		{ID: "partner-c", KeyHashes: []string{auth.HashKey("key-c")}, Disabled: true},
+	})
+	handler := AuthMiddleware(auth.NewAuthenticator(store, time.Minute), "/health")(echoIdentity)
+
+	tests := []struct {
+		name       string
+		path       string
+		apiKey     string
+		preset     *auth.Identity
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "valid key", path: "/receipts/process", apiKey: "key-a", wantStatus: http.StatusOK, wantBody: "partner-a"},
+		{name: "missing key", path: "/receipts/process", wantStatus: http.StatusUnauthorized},
+		{name: "disabled client", path: "/receipts/process", apiKey: "key-c", wantStatus: http.StatusForbidden},
+		{name: "public path", path: "/health", wantStatus: http.StatusOK, wantBody: "anonymous"},
+		{
+			name:       "client certificate identity",
+			path:       "/receipts/process",
+			preset:     &auth.Identity{ID: "partner-cert", Method: auth.MethodClientCert},
+			wantStatus: http.StatusOK,
+			wantBody:   "partner-cert",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.path, nil)
+			if tt.apiKey != "" {
+				req.Header.Set(auth.HeaderAPIKey, tt.apiKey)
+			}
+			if tt.preset != nil {
+				req = req.WithContext(auth.WithIdentity(req.Context(), *tt.preset))
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %v, want %v", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				var details problem.Details
+				if ct := w.Header().Get("Content-Type"); ct != problem.ContentType {
+					t.Errorf("Content-Type = %v, want %v", ct, problem.ContentType)
+				}
+				if err := json.NewDecoder(w.Body).Decode(&details); err != nil || details.Status != tt.wantStatus {
+					t.Errorf("problem details = %+v, %v, want status %v", details, err, tt.wantStatus)
+				}
+				return
+			}
+			if got := w.Body.String(); got != tt.wantBody {
+				t.Errorf("body = %v, want %v", got, tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/suryamp/receipt-processor/auth"
+	"github.com/suryamp/receipt-processor/authz"
+	"github.com/suryamp/receipt-processor/logger"
+	"github.com/suryamp/receipt-processor/metrics"
+	"github.com/suryamp/receipt-processor/problem"
+)
+
+// AuthorizationMiddleware enforces policy on the matched mux route, and lets
+// handlers check further permissions with authz.Can. In dry-run mode
+// denials are only logged and counted, and the request proceeds.
+func AuthorizationMiddleware(policy *authz.Policy, dryRun bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path := routeTemplate(r)
+			perm, listed := policy.Required(r.Method, path)
+			id, authenticated := auth.FromContext(r.Context())
+
+			// Handlers check further permissions the same way
+			check := func(perm authz.Permission) bool {
+				if authenticated && policy.Allowed(id, perm) {
+					return true
+				}
+				metrics.AuthzDenialsTotal.WithLabelValues(string(perm), strconv.FormatBool(dryRun)).Inc()
+				if dryRun {
+					logger.InfoLogger.Printf("Authorization dry run: would deny %s %s for %q: caller lacks permission %s", r.Method, path, id.ID, perm)
+				}
+				return dryRun
+			}
+			serve := func() { next.ServeHTTP(w, r.WithContext(authz.WithCheck(r.Context(), check))) }
+
+			if perm == authz.Public {
+				serve()
+				return
+			}
+			if listed && authenticated && policy.Allowed(id, perm) {
+				serve()
+				return
+			}
+
+			status, detail := http.StatusForbidden, "caller lacks permission "+string(perm)
+			switch {
+			case !listed:
+				detail = "route is not covered by the authorization policy"
+			case !authenticated:
+				status, detail = http.StatusUnauthorized, "request has no caller identity"
+			}
+			metrics.AuthzDenialsTotal.WithLabelValues(string(perm), strconv.FormatBool(dryRun)).Inc()
+
+			if dryRun {
+				logger.InfoLogger.Printf("Authorization dry run: would deny %s %s for %q: %s", r.Method, path, id.ID, detail)
+				serve()
+				return
+			}
+			logger.ErrorLogger.Printf("Authorization denied %s %s for %q: %s", r.Method, path, id.ID, detail)
+			problem.Write(w, problem.Details{Status: status, Detail: detail})
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/suryamp/receipt-processor/auth"
+	"github.com/suryamp/receipt-processor/authz"
+)
+
+func TestAuthorizationMiddleware(t *testing.T) {
+	policy, err := authz.NewPolicy(authz.Routes, map[string][]string{"partner-cert": {"support"}})
+	if err != nil {
+		t.Fatalf("NewPolicy() error = %v", err)
+	}
+
+	newRouter := func(dryRun bool) *mux.Router {
+		r := mux.NewRouter()
+		r.Use(AuthorizationMiddleware(policy, dryRun))
+		r.Handle("/health", echoIdentity).Methods("GET")
+		r.Handle("/receipts/process", echoIdentity).Methods("POST")
+		r.Handle("/receipts/{id}/points", echoIdentity).Methods("GET")
+		r.Handle("/admin/config", echoIdentity).Methods("GET")
+		r.Handle("/users/{id}/redemptions", echoIdentity).Methods("POST")
+		r.Handle("/unlisted", echoIdentity).Methods("GET")
+		return r
+	}
+
+	submitter := &auth.Identity{ID: "partner-a", Roles: []string{"submitter"}}
+	reader := &auth.Identity{ID: "partner-b", Roles: []string{"reader"}}
+	cert := &auth.Identity{ID: "partner-cert", Method: auth.MethodClientCert}
+	admin := &auth.Identity{ID: "ops", Roles: []string{"admin"}}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		caller     *auth.Identity
+		dryRun     bool
+		wantStatus int
+	}{
+		{name: "public route without identity", method: "GET", path: "/health", wantStatus: http.StatusOK},
+		{name: "submitter submits", method: "POST", path: "/receipts/process", caller: submitter, wantStatus: http.StatusOK},
+		{name: "submitter cannot read", method: "GET", path: "/receipts/abc/points", caller: submitter, wantStatus: http.StatusForbidden},
+		{name: "reader reads by path template", method: "GET", path: "/receipts/abc/points", caller: reader, wantStatus: http.StatusOK},
+		{name: "reader cannot see admin", method: "GET", path: "/admin/config", caller: reader, wantStatus: http.StatusForbidden},
+		{name: "mapped client roles", method: "GET", path: "/admin/config", caller: cert, wantStatus: http.StatusOK},
+		{name: "submitter cannot redeem", method: "POST", path: "/users/alice/redemptions", caller: submitter, wantStatus: http.StatusForbidden},
+		{name: "admin redeems", method: "POST", path: "/users/alice/redemptions", caller: admin, wantStatus: http.StatusOK},
+		{name: "no identity", method: "GET", path: "/receipts/abc/points", wantStatus: http.StatusUnauthorized},
+		{name: "unlisted route denied even for admin", method: "GET", path: "/unlisted", caller: admin, wantStatus: http.StatusForbidden},
+		{name: "dry run lets denial through", method: "GET", path: "/admin/config", caller: reader, dryRun: true, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.caller != nil {
+				req = req.WithContext(auth.WithIdentity(req.Context(), *tt.caller))
+			}
+			w := httptest.NewRecorder()
+			newRouter(tt.dryRun).ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %v, want %v (body %s)", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAuthorizationMiddlewareCan(t *testing.T) {
+	policy, _ := authz.NewPolicy(authz.Routes, nil)
+	canAdmin := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authz.Can(r.Context(), authz.AdminWrite) {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	})
+
+	tests := []struct {
+		name   string
+		caller auth.Identity
+		dryRun bool
+		want   int
+	}{
+		{"admin", auth.Identity{ID: "ops", Roles: []string{"admin"}}, false, http.StatusOK},
+		{"submitter", auth.Identity{ID: "partner-a", Roles: []string{"submitter"}}, false, http.StatusForbidden},
+		{"submitter in dry run", auth.Identity{ID: "partner-a", Roles: []string{"submitter"}}, true, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mux.NewRouter()
+			r.Use(AuthorizationMiddleware(policy, tt.dryRun))
+			r.Handle("/points/simulate", canAdmin).Methods("POST")
+
+			req := httptest.NewRequest("POST", "/points/simulate", nil)
+			req = req.WithContext(auth.WithIdentity(req.Context(), tt.caller))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %v, want %v", w.Code, tt.want)
+			}
+		})
+	}
+
+	// Without the middleware nothing is checked
+	if !authz.Can(context.Background(), authz.AdminWrite) {
+		t.Error("Can() without a check = false, want true")
+	}
+}
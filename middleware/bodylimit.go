@@ -0,0 +1,22 @@
+package middleware
+
+import "net/http"
+
+// BodyLimitMiddleware caps every request body at maxBytes so that nothing
+// downstream, including signature checks, reads an unbounded body. Reads
+// past the limit fail with *http.MaxBytesError. Paths in larger have a
+// limit of their own instead, where zero means no limit.
+func BodyLimitMiddleware(maxBytes int64, larger map[string]int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit := maxBytes
+			if l, ok := larger[r.URL.Path]; ok {
+				limit = l
+			}
+			if limit > 0 && r.Body != nil {
+				r.Body = http.MaxBytesReader(w, r.Body, limit)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
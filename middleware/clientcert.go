@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/suryamp/receipt-processor/auth"
+)
+
+// ClientCertMiddleware attaches the caller identity of a verified client
+// certificate to the request context. identities maps a subject such as
+// "CN=partner-a,O=Acme" to an identity; unlisted subjects use their common name.
+func ClientCertMiddleware(identities map[string]string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			subject := r.TLS.VerifiedChains[0][0].Subject
+			id, ok := identities[subject.String()]
+			if !ok {
+				id = subject.CommonName
+			}
+			if id != "" {
+				r = r.WithContext(auth.WithIdentity(r.Context(), auth.Identity{ID: id, Method: auth.MethodClientCert}))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
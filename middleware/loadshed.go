@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/suryamp/receipt-processor/loadshed"
+	"github.com/suryamp/receipt-processor/logger"
+	"github.com/suryamp/receipt-processor/problem"
+)
+
+// LoadSheddingMiddleware admits requests through the adaptive concurrency
+// limiter l and rejects the excess with 503. Paths in critical are always
+// admitted; GET and HEAD requests are treated as reads and everything else as
+// writes, which give up capacity first. Paths in streaming bypass the limiter
+// altogether: an export or import runs for minutes, and counting it would
+// hold a slot and read as latency that cuts the limit for everything else.
+func LoadSheddingMiddleware(l *loadshed.Limiter, streaming []string, critical ...string) func(http.Handler) http.Handler {
+	always := map[string]bool{}
+	for _, p := range critical {
+		always[p] = true
+	}
+	bypass := map[string]bool{}
+	for _, p := range streaming {
+		bypass[p] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if bypass[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+			priority := loadshed.Write
+			switch {
+			case always[r.URL.Path]:
+				priority = loadshed.Critical
+			case r.Method == http.MethodGet || r.Method == http.MethodHead:
+				priority = loadshed.Read
+			}
+
+			release, ok := l.Acquire(priority)
+			if !ok {
+				retryAfter := int(math.Ceil(l.RetryAfter().Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+				logger.ErrorLogger.Printf("Shed %s %s (%s priority, limit %d)", r.Method, r.URL.Path, priority, l.Limit())
+				problem.Write(w, problem.Details{Status: http.StatusServiceUnavailable, Detail: "server is overloaded, retry later"})
+				return
+			}
+
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			defer func() { release(rec.status >= http.StatusInternalServerError) }()
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
+
+// statusRecorder remembers the status code written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
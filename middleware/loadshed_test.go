@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/suryamp/receipt-processor/loadshed"
+)
+
+func TestLoadSheddingMiddleware(t *testing.T) {
+	limiter := loadshed.NewLimiter(loadshed.Config{
+		InitialLimit: 2, MinLimit: 2, MaxLimit: 2,
+		LatencyTarget: time.Minute, Backoff: 0.5, WriteFraction: 0.5, RetryAfter: 3 * time.Second,
+	})
+
+	entered, unblock := make(chan struct{}), make(chan struct{})
+	blocking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-unblock
+	})
+	handler := LoadSheddingMiddleware(limiter, []string{"/imports"}, "/health")(blocking)
+
+	send := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	// Occupy the single write slot.
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- send("POST", "/receipts/process") }()
+	<-entered
+
+	w := send("POST", "/receipts/process")
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "3" {
+		t.Errorf("write over capacity = %v %v, want 503 with Retry-After 3", w.Code, w.Header())
+	}
+
+	for _, path := range []string{"/receipts/abc/points", "/health"} {
+		go func() { done <- send("GET", path) }()
+		<-entered
+	}
+	if w := send("GET", "/receipts/abc/points"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("read over capacity = %v, want 503", w.Code)
+	}
+
+	// Streaming requests bypass the limiter, so one is admitted while it is full
+	go func() { done <- send("POST", "/imports") }()
+	<-entered
+
+	close(unblock)
+	for i := 0; i < 4; i++ {
+		if w := <-done; w.Code != http.StatusOK {
+			t.Errorf("admitted request = %v, want 200", w.Code)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/suryamp/receipt-processor/auth"
+	"github.com/suryamp/receipt-processor/logger"
+	"github.com/suryamp/receipt-processor/metrics"
+	"github.com/suryamp/receipt-processor/problem"
+	"github.com/suryamp/receipt-processor/ratelimit"
+)
+
+// RateLimitMiddleware charges each request to its caller identity, or to the
+// client IP when there is none, and rejects it with 429 when the route's
+// token bucket or the caller's daily quota is exhausted. Paths in exempt are
+// never limited.
+func RateLimitMiddleware(l *ratelimit.Limiter, exempt ...string) func(http.Handler) http.Handler {
+	skip := map[string]bool{}
+	for _, p := range exempt {
+		skip[p] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path := routeTemplate(r)
+			if skip[path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			caller := ratelimit.IPCaller(clientIP(r))
+			if id, ok := auth.FromContext(r.Context()); ok {
+				caller = ratelimit.ClientCaller(id.ID)
+			}
+
+			d := l.Allow(caller, r.Method, path)
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(int(math.Ceil(d.Reset.Seconds()))))
+			if d.Allowed {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			retryAfter := int(math.Ceil(d.RetryAfter.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			metrics.RateLimitedTotal.WithLabelValues(path, d.Reason).Inc()
+			logger.ErrorLogger.Printf("Throttled %s %s for %s (%s)", r.Method, path, caller, d.Reason)
+
+			detail := fmt.Sprintf("rate limit exceeded, retry in %d seconds", retryAfter)
+			if d.Reason == ratelimit.ReasonQuota {
+				detail = "daily submission quota exhausted"
+			}
+			problem.Write(w, problem.Details{Status: http.StatusTooManyRequests, Detail: detail})
+		})
+	}
+}
+
+// routeTemplate returns the mux path template of the matched route, or the
+// raw path outside a router.
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil {
+			return tmpl
+		}
+	}
+	return r.URL.Path
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/suryamp/receipt-processor/auth"
+	"github.com/suryamp/receipt-processor/ratelimit"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.Config{
+		Default: ratelimit.Limit{Rate: 0.001, Burst: 1},
+	}, ratelimit.NewMemoryStore())
+	handler := RateLimitMiddleware(limiter, "/health")(echoIdentity)
+
+	send := func(path, remoteAddr string, caller *auth.Identity) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = remoteAddr
+		if caller != nil {
+			req = req.WithContext(auth.WithIdentity(req.Context(), *caller))
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	first := send("/receipts/abc/points", "10.0.0.1:1234", nil)
+	if first.Code != http.StatusOK || first.Header().Get("RateLimit-Limit") != "1" || first.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("first request = %v %v, want 200 with RateLimit headers", first.Code, first.Header())
+	}
+
+	second := send("/receipts/abc/points", "10.0.0.1:5678", nil)
+	if second.Code != http.StatusTooManyRequests || second.Header().Get("Retry-After") == "" {
+		t.Errorf("second request from same IP = %v %v, want 429 with Retry-After", second.Code, second.Header())
+	}
+
+	if w := send("/receipts/abc/points", "10.0.0.1:5678", &auth.Identity{ID: "partner-a"}); w.Code != http.StatusOK {
+		t.Errorf("identified caller on same IP = %v, want 200", w.Code)
+	}
+	if w := send("/health", "10.0.0.1:5678", nil); w.Code != http.StatusOK {
+		t.Errorf("exempt path = %v, want 200", w.Code)
+	}
+}
+
+func TestRateLimitMiddlewareClientQuota(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.Config{
+		Default:      ratelimit.Limit{Rate: 100, Burst: 100},
+		DailyQuota:   1,
+		ClientQuotas: map[string]int64{"partner-a": 3},
+		QuotaRoutes:  []string{"POST /receipts/process"},
+	}, ratelimit.NewMemoryStore())
+	handler := RateLimitMiddleware(limiter)(echoIdentity)
+
+	submitted := func(caller *auth.Identity, n int) int {
+		count := 0
+		for i := 0; i < n; i++ {
+			req := httptest.NewRequest("POST", "/receipts/process", nil)
+			if caller != nil {
+				req = req.WithContext(auth.WithIdentity(req.Context(), *caller))
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code == http.StatusOK {
+				count++
+			}
+		}
+		return count
+	}
+
+	// clientQuotas is keyed by the identity ID, as configured
+	if got := submitted(&auth.Identity{ID: "partner-a"}, 5); got != 3 {
+		t.Errorf("partner-a submitted %d, want its client quota of 3", got)
+	}
+	if got := submitted(&auth.Identity{ID: "partner-b"}, 5); got != 1 {
+		t.Errorf("partner-b submitted %d, want the daily quota of 1", got)
+	}
+	if got := submitted(nil, 5); got != 1 {
+		t.Errorf("anonymous caller submitted %d, want the daily quota of 1", got)
+	}
+}
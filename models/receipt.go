@@ -6,10 +6,17 @@ type Item struct {
 	Price            string `json:"price"`
 }
 
+// Receipt dates and times are the store's wall clock. Timezone (an IANA
+// name) and UTCOffset ("-05:00") say where that clock was; either may be
+// omitted, and UTCOffset also picks between repeated times when DST ends.
+// UserID, if set, credits the receipt's points to that user.
 type Receipt struct {
+	UserID       string `json:"userId,omitempty"`
 	Retailer     string `json:"retailer"`
 	PurchaseDate string `json:"purchaseDate"`
 	PurchaseTime string `json:"purchaseTime"`
+	Timezone     string `json:"timezone,omitempty"`
+	UTCOffset    string `json:"utcOffset,omitempty"`
 	Items        []Item `json:"items"`
 	Total        string `json:"total"`
 }
@@ -19,5 +26,33 @@ type ProcessResponse struct {
 }
 
 type PointsResponse struct {
-	Points int64 `json:"points"`
+	Points       int64              `json:"points"`
+	RulesVersion string             `json:"rulesVersion,omitempty"`
+	Tier         string             `json:"tier,omitempty"`
+	TierBonus    int64              `json:"tierBonus,omitempty"`
+	Promotions   []AppliedPromotion `json:"promotions,omitempty"`
+}
+
+// SimulateResponse is the score a receipt would get, rule by rule.
+type SimulateResponse struct {
+	Points       int64              `json:"points"`
+	BasePoints   int64              `json:"basePoints"`
+	RulesVersion string             `json:"rulesVersion"`
+	Breakdown    []RulePoints       `json:"breakdown"`
+	Tier         string             `json:"tier,omitempty"`
+	TierBonus    int64              `json:"tierBonus,omitempty"`
+	Promotions   []AppliedPromotion `json:"promotions,omitempty"`
+}
+
+// RulePoints is what one scoring rule awarded a receipt.
+type RulePoints struct {
+	Rule   string `json:"rule"`
+	Points int64  `json:"points"`
+}
+
+// AppliedPromotion is a campaign that added Bonus points to a receipt.
+type AppliedPromotion struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Bonus int64  `json:"bonus"`
 }
@@ -0,0 +1,31 @@
+// Package problem writes RFC 7807 problem details responses.
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+const ContentType = "application/problem+json"
+
+// Details is an RFC 7807 problem details body.
+type Details struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Write sends d with its status code. Type defaults to "about:blank" and
+// Title to the standard text for the status.
+func Write(w http.ResponseWriter, d Details) {
+	if d.Type == "" {
+		d.Type = "about:blank"
+	}
+	if d.Title == "" {
+		d.Title = http.StatusText(d.Status)
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(d.Status)
+	json.NewEncoder(w).Encode(d)
+}
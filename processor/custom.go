@@ -0,0 +1,140 @@
+package processor
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/suryamp/receipt-processor/expr"
+	"github.com/suryamp/receipt-processor/logger"
+	"github.com/suryamp/receipt-processor/models"
+)
+
+// CustomRule is a named scoring rule written in the expr language. It sees
+// the receipt as the variable receipt and must evaluate to an int, the
+// points it awards; negative results award nothing.
+type CustomRule struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description" json:"description,omitempty"`
+	Expression  string `yaml:"expression" json:"expression"`
+}
+
+var ruleNamePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]*$`)
+
+var itemType = expr.Object("item", map[string]*expr.Type{
+	"description": expr.String,
+	"price":       expr.Double,
+})
+
+// receiptVars declares what custom rules can see. Dates and times are on
+// the store's local clock and weekday counts from Sunday = 0.
+var receiptVars = map[string]*expr.Type{
+	"receipt": expr.Object("receipt", map[string]*expr.Type{
+		"retailer": expr.String,
+		"total":    expr.Double,
+		"items":    expr.ListOf(itemType),
+		"date":     expr.String,
+		"year":     expr.Int,
+		"month":    expr.Int,
+		"day":      expr.Int,
+		"weekday":  expr.Int,
+		"hour":     expr.Int,
+		"minute":   expr.Int,
+	}),
+}
+
+func compileCustomRule(r CustomRule) (*expr.Program, error) {
+	return expr.Compile(r.Expression, receiptVars, expr.Int)
+}
+
+// validateCustomRules checks that rule names are unique and that every
+// expression compiles.
+func (r Rules) validateCustomRules() error {
+	if l := r.ExpressionLimits; l.MaxSteps < 0 || l.MaxMemoryBytes < 0 || l.Timeout < 0 {
+		return fmt.Errorf("expressionLimits must not be negative")
+	}
+	seen := map[string]bool{}
+	for _, rule := range ruleNames {
+		seen[rule] = true
+	}
+	for _, c := range r.CustomRules {
+		if !ruleNamePattern.MatchString(c.Name) {
+			return fmt.Errorf("customRules: invalid name %q", c.Name)
+		}
+		if seen[c.Name] {
+			return fmt.Errorf("customRules: name %q is already taken", c.Name)
+		}
+		seen[c.Name] = true
+		if _, err := compileCustomRule(c); err != nil {
+			return fmt.Errorf("customRules[%s]: %w", c.Name, err)
+		}
+	}
+	return nil
+}
+
+// customRuleNames lists the custom rules by name.
+func (r Rules) customRuleNames() []string {
+	names := make([]string, len(r.CustomRules))
+	for i, c := range r.CustomRules {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// customBreakdown evaluates the rules' custom rules against receipt, whose
+// retailer is given by its canonical name, and returns what each awarded. A
+// rule that fails to evaluate, by timing out for one, awards nothing, and
+// the first such failure is returned.
+func (rs *ruleSet) customBreakdown(rules Rules, receipt models.Receipt, canonical string, purchased time.Time) ([]models.RulePoints, error) {
+	if len(rules.CustomRules) == 0 {
+		return nil, nil
+	}
+	vars := map[string]any{"receipt": receiptValue(receipt, canonical, purchased)}
+
+	breakdown := make([]models.RulePoints, 0, len(rules.CustomRules))
+	var failed error
+	for _, c := range rules.CustomRules {
+		program, ok := rs.programs[c.Name]
+		if !ok {
+			continue
+		}
+		var points int64
+		if v, err := program.Eval(vars, rules.ExpressionLimits); err != nil {
+			logger.ErrorLogger.Printf("Custom rule %s failed: %v", c.Name, err)
+			if failed == nil {
+				failed = fmt.Errorf("custom rule %s: %w", c.Name, err)
+			}
+		} else if p := v.(int64); p > 0 {
+			logger.InfoLogger.Printf("Custom rule %s awarded %d points", c.Name, p)
+			points = p
+		}
+		breakdown = append(breakdown, models.RulePoints{Rule: c.Name, Points: points})
+	}
+	return breakdown, failed
+}
+
+func receiptValue(receipt models.Receipt, canonical string, purchased time.Time) map[string]any {
+	items := make([]any, len(receipt.Items))
+	for i, item := range receipt.Items {
+		price, _ := strconv.ParseFloat(item.Price, 64)
+		items[i] = map[string]any{
+			"description": strings.TrimSpace(item.ShortDescription),
+			"price":       price,
+		}
+	}
+	total, _ := strconv.ParseFloat(receipt.Total, 64)
+	return map[string]any{
+		"retailer": canonical,
+		"total":    total,
+		"items":    items,
+		"date":     purchased.Format("2006-01-02"),
+		"year":     int64(purchased.Year()),
+		"month":    int64(purchased.Month()),
+		"day":      int64(purchased.Day()),
+		"weekday":  int64(purchased.Weekday()),
+		"hour":     int64(purchased.Hour()),
+		"minute":   int64(purchased.Minute()),
+	}
+}
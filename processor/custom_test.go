@@ -0,0 +1,79 @@
+package processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/suryamp/receipt-processor/models"
+)
+
+func TestCustomRules(t *testing.T) {
+	rules := DefaultRules()
+	rules.CustomRules = []CustomRule{
+		{Name: "weekendBigItems", Expression: "receipt.weekday in [0, 6] ? 5 * size(receipt.items.filter(i, i.price > 10.0)) : 0"},
+		{Name: "walgreensBonus", Expression: `receipt.retailer == "Walgreens" ? 7 : 0`},
+		{Name: "penalty", Expression: "-100"},
+		{Name: "runaway", Expression: "size(receipt.items.map(a, receipt.items.map(b, receipt.items.map(c, 1))))"},
+	}
+	rules.ExpressionLimits.MaxSteps = 50
+	rules.Retailers = []Retailer{{Name: "Walgreens", Overrides: RuleOverrides{Exclude: []string{"weekendBigItems"}}}}
+	if err := rules.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	p := NewInMemoryProcessor(rules)
+
+	items := []models.Item{
+		{ShortDescription: "ab", Price: "12.00"},
+		{ShortDescription: "cd", Price: "11.00"},
+		{ShortDescription: "ef", Price: "2.00"},
+	}
+	tests := []struct {
+		name     string
+		retailer string
+		date     string
+		want     int64
+	}{
+		// 1 (name) + 50 + 25 (total) + 5 (item pair) + 10 (two items over $10 on a Saturday)
+		{"weekend rule", "K", "2024-01-06", 91},
+		// same receipt on a Tuesday
+		{"weekday", "K", "2024-01-02", 81},
+		// 10 (name) + 50 + 25 + 5 + 7; the weekend rule is excluded for Walgreens
+		{"retailer rule and exclusion", "WALGREENS #1", "2024-01-06", 97},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, _ := p.ProcessReceipt(context.Background(), models.Receipt{
+				Retailer:     tt.retailer,
+				PurchaseDate: tt.date,
+				PurchaseTime: "10:00",
+				Items:        items,
+				Total:        "25.00",
+			})
+			if got, _ := p.GetPoints(id); got != tt.want {
+				t.Errorf("GetPoints() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCustomRulesValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []CustomRule
+	}{
+		{"type error", []CustomRule{{Name: "x", Expression: "receipt.total > 10"}}},
+		{"unknown field", []CustomRule{{Name: "x", Expression: "receipt.store"}}},
+		{"duplicate name", []CustomRule{{Name: "x", Expression: "1"}, {Name: "x", Expression: "2"}}},
+		{"built-in name", []CustomRule{{Name: RuleOddDay, Expression: "1"}}},
+		{"invalid name", []CustomRule{{Name: "two words", Expression: "1"}}},
+	}
+
+	for _, tt := range tests {
+		rules := DefaultRules()
+		rules.CustomRules = tt.rules
+		if err := rules.Validate(); err == nil {
+			t.Errorf("%s: Validate() = nil, want error", tt.name)
+		}
+	}
+}
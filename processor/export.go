@@ -0,0 +1,83 @@
+package processor
+
+import (
+	"time"
+
+	"github.com/suryamp/receipt-processor/models"
+)
+
+// ExportFilter selects receipts to export by purchase date, From to To
+// inclusive, each "2006-01-02" or empty for no bound, by canonical retailer
+// name and by user. Empty fields select everything.
+type ExportFilter struct {
+	From     string
+	To       string
+	Retailer string
+	UserID   string
+}
+
+func (f ExportFilter) matches(stored storedReceipt) bool {
+	date := stored.receipt.PurchaseDate
+	return (f.From == "" || date >= f.From) &&
+		(f.To == "" || date <= f.To) &&
+		(f.Retailer == "" || stored.retailer == f.Retailer) &&
+		(f.UserID == "" || stored.receipt.UserID == f.UserID)
+}
+
+// ExportedReceipt is a stored receipt with its points under the rules it is
+// pinned to. Retailer is as printed and CanonicalRetailer as the stats and
+// leaderboards know it. Promotions are the campaigns that added to Points.
+type ExportedReceipt struct {
+	ID                string                    `json:"id"`
+	UserID            string                    `json:"userId,omitempty"`
+	Retailer          string                    `json:"retailer"`
+	CanonicalRetailer string                    `json:"canonicalRetailer"`
+	PurchaseDate      string                    `json:"purchaseDate"`
+	PurchaseTime      string                    `json:"purchaseTime"`
+	Timezone          string                    `json:"timezone,omitempty"`
+	UTCOffset         string                    `json:"utcOffset,omitempty"`
+	Total             string                    `json:"total"`
+	Items             []models.Item             `json:"items"`
+	SubmittedAt       time.Time                 `json:"submittedAt"`
+	RulesVersion      string                    `json:"rulesVersion"`
+	Points            int64                     `json:"points"`
+	BasePoints        int64                     `json:"basePoints"`
+	Tier              string                    `json:"tier,omitempty"`
+	TierBonus         int64                     `json:"tierBonus,omitempty"`
+	Promotions        []models.AppliedPromotion `json:"promotions,omitempty"`
+}
+
+// Export calls fn with every stored receipt f selects, with the score it was
+// credited, in no particular order. It stops at the first error fn returns
+// and returns it.
+func (p *InMemoryProcessor) Export(f ExportFilter, fn func(ExportedReceipt) error) error {
+	var err error
+	p.receipts.Range(func(key, value any) bool {
+		stored := value.(storedReceipt)
+		if !f.matches(stored) {
+			return true
+		}
+		score := stored.credited()
+		err = fn(ExportedReceipt{
+			ID:                key.(string),
+			UserID:            stored.receipt.UserID,
+			Retailer:          stored.receipt.Retailer,
+			CanonicalRetailer: stored.retailer,
+			PurchaseDate:      stored.receipt.PurchaseDate,
+			PurchaseTime:      stored.receipt.PurchaseTime,
+			Timezone:          stored.receipt.Timezone,
+			UTCOffset:         stored.receipt.UTCOffset,
+			Total:             stored.receipt.Total,
+			Items:             stored.receipt.Items,
+			SubmittedAt:       stored.submittedAt,
+			RulesVersion:      score.RulesVersion,
+			Points:            score.Points,
+			BasePoints:        score.BasePoints,
+			Tier:              score.Tier,
+			TierBonus:         score.TierBonus,
+			Promotions:        score.Promotions,
+		})
+		return err == nil
+	})
+	return err
+}
@@ -0,0 +1,105 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/suryamp/receipt-processor/models"
+	"github.com/suryamp/receipt-processor/promotions"
+)
+
+func TestExport(t *testing.T) {
+	p := NewInMemoryProcessor(DefaultRules())
+	for _, r := range []struct{ user, retailer, date string }{
+		{"alice", "Target", "2024-01-02"},
+		{"bob", "Target", "2024-02-10"},
+		{"alice", "Walgreens", "2024-03-01"},
+	} {
+		p.ProcessReceipt(context.Background(), models.Receipt{
+			UserID:       r.user,
+			Retailer:     r.retailer,
+			PurchaseDate: r.date,
+			PurchaseTime: "13:01",
+			Items:        []models.Item{{ShortDescription: "Pepsi", Price: "1.25"}},
+			Total:        "1.25",
+		})
+	}
+
+	tests := []struct {
+		name   string
+		filter ExportFilter
+		want   []string // purchase dates
+	}{
+		{"everything", ExportFilter{}, []string{"2024-01-02", "2024-02-10", "2024-03-01"}},
+		{"from", ExportFilter{From: "2024-02-10"}, []string{"2024-02-10", "2024-03-01"}},
+		{"to", ExportFilter{To: "2024-02-10"}, []string{"2024-01-02", "2024-02-10"}},
+		{"retailer", ExportFilter{Retailer: "Target"}, []string{"2024-01-02", "2024-02-10"}},
+		{"user", ExportFilter{UserID: "alice", From: "2024-02-01"}, []string{"2024-03-01"}},
+		{"nothing", ExportFilter{UserID: "carol"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := p.Export(tt.filter, func(r ExportedReceipt) error {
+				got = append(got, r.PurchaseDate)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(got)
+			if len(got) != len(tt.want) {
+				t.Fatalf("exported %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("exported %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+
+	t.Run("scored", func(t *testing.T) {
+		p.Export(ExportFilter{From: "2024-01-02", To: "2024-01-02"}, func(r ExportedReceipt) error {
+			if r.Points != 31 || r.BasePoints != 31 || r.RulesVersion != DefaultRules().Version() || r.CanonicalRetailer != "Target" || len(r.Items) != 1 {
+				t.Errorf("exported %+v, want 31 points under the default rules", r)
+			}
+			return nil
+		})
+	})
+
+	t.Run("timezone and promotions", func(t *testing.T) {
+		campaigns := promotions.NewStore()
+		campaigns.Create(promotions.Campaign{Name: "Gatorade March", StartDate: "2024-03-01", EndDate: "2024-03-31", Keywords: []string{"gatorade"}, Bonus: 100})
+		p := NewInMemoryProcessor(DefaultRules()).WithPromotions(campaigns)
+		p.ProcessReceipt(context.Background(), models.Receipt{
+			Retailer:     "Target",
+			PurchaseDate: "2024-03-02",
+			PurchaseTime: "13:01",
+			Timezone:     "America/New_York",
+			UTCOffset:    "-05:00",
+			Items:        []models.Item{{ShortDescription: "Gatorade", Price: "2.25"}},
+			Total:        "2.25",
+		})
+		p.Export(ExportFilter{}, func(r ExportedReceipt) error {
+			if r.Timezone != "America/New_York" || r.UTCOffset != "-05:00" || r.Points != 131 || len(r.Promotions) != 1 || r.Promotions[0].Bonus != 100 {
+				t.Errorf("exported %+v, want the receipt's timezone and its 100 point promotion", r)
+			}
+			return nil
+		})
+	})
+
+	t.Run("stops at an error", func(t *testing.T) {
+		stop := errors.New("stop")
+		var n int
+		err := p.Export(ExportFilter{}, func(ExportedReceipt) error {
+			n++
+			return stop
+		})
+		if !errors.Is(err, stop) || n != 1 {
+			t.Errorf("Export() = %v after %d receipts, want stop after 1", err, n)
+		}
+	})
+}
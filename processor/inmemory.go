@@ -1,19 +1,32 @@
 package processor
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math"
 	"regexp"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/suryamp/receipt-processor/auth"
+	"github.com/suryamp/receipt-processor/expr"
+	"github.com/suryamp/receipt-processor/items"
+	"github.com/suryamp/receipt-processor/leaderboard"
 	"github.com/suryamp/receipt-processor/logger"
 	"github.com/suryamp/receipt-processor/models"
+	"github.com/suryamp/receipt-processor/promotions"
+	"github.com/suryamp/receipt-processor/stats"
+	"github.com/suryamp/receipt-processor/tiers"
+	"github.com/suryamp/receipt-processor/tz"
+	"github.com/suryamp/receipt-processor/users"
 )
 
+// Default values for Rules
 const (
 	RetailerNameMultiplier          = 1
 	RoundDollarPoints               = 50
@@ -31,99 +44,420 @@ var (
 	alphanumericRegex = regexp.MustCompile(`[a-zA-Z0-9]`)
 )
 
+// Rules holds the tunable parameters of the points calculation.
+// The zero value is not useful; start from DefaultRules.
+type Rules struct {
+	RetailerNameMultiplier          int64   `yaml:"retailerNameMultiplier" json:"retailerNameMultiplier"`
+	RoundDollarPoints               int64   `yaml:"roundDollarPoints" json:"roundDollarPoints"`
+	QuarterDollarPoints             int64   `yaml:"quarterDollarPoints" json:"quarterDollarPoints"`
+	ItemDescriptionPointsModulus    int     `yaml:"itemDescriptionPointsModulus" json:"itemDescriptionPointsModulus"`
+	ItemDescriptionPointsMultiplier float64 `yaml:"itemDescriptionPointsMultiplier" json:"itemDescriptionPointsMultiplier"`
+	OddDayPoints                    int64   `yaml:"oddDayPoints" json:"oddDayPoints"`
+	HappyHourPoints                 int64   `yaml:"happyHourPoints" json:"happyHourPoints"`
+	ItemPairPoints                  int64   `yaml:"itemPairPoints" json:"itemPairPoints"`
+	HappyHourStart                  int     `yaml:"happyHourStart" json:"happyHourStart"`
+	HappyHourEnd                    int     `yaml:"happyHourEnd" json:"happyHourEnd"`
+
+	// DefaultTimezone is the store timezone for receipts that carry neither
+	// a timezone nor a UTC offset, unless RetailerTimezones names one for
+	// the retailer. Date and time rules are evaluated in that timezone.
+	DefaultTimezone   string            `yaml:"defaultTimezone" json:"defaultTimezone"`
+	RetailerTimezones map[string]string `yaml:"retailerTimezones" json:"retailerTimezones,omitempty"`
+
+	// MaxPromotionBonus caps the points promotions add to one receipt; zero is uncapped.
+	MaxPromotionBonus int64 `yaml:"maxPromotionBonus" json:"maxPromotionBonus"`
+
+	// Retailers registers partner retailers under their canonical names,
+	// with aliases and rule overrides applied on top of these rules.
+	Retailers []Retailer `yaml:"retailers" json:"retailers,omitempty"`
+
+	// CustomRules are scored after the built-in rules above, each evaluated
+	// under ExpressionLimits.
+	CustomRules      []CustomRule `yaml:"customRules" json:"customRules,omitempty"`
+	ExpressionLimits expr.Limits  `yaml:"expressionLimits" json:"expressionLimits"`
+}
+
+// DefaultRules returns the rules described in the readme.
+func DefaultRules() Rules {
+	return Rules{
+		RetailerNameMultiplier:          RetailerNameMultiplier,
+		RoundDollarPoints:               RoundDollarPoints,
+		QuarterDollarPoints:             QuarterDollarPoints,
+		ItemDescriptionPointsModulus:    ItemDescriptionPointsModulus,
+		ItemDescriptionPointsMultiplier: ItemDescriptionPointsMultiplier,
+		OddDayPoints:                    OddDayPoints,
+		HappyHourPoints:                 HappyHourPoints,
+		ItemPairPoints:                  ItemPairPoints,
+		HappyHourStart:                  HappyHourStart,
+		HappyHourEnd:                    HappyHourEnd,
+		DefaultTimezone:                 "UTC",
+		ExpressionLimits:                expr.DefaultLimits,
+	}
+}
+
+// Validate reports whether the rules can be used for scoring.
+func (r Rules) Validate() error {
+	if r.ItemDescriptionPointsModulus <= 0 {
+		return fmt.Errorf("itemDescriptionPointsModulus must be positive")
+	}
+	if r.HappyHourStart < 0 || r.HappyHourStart > 23 {
+		return fmt.Errorf("happyHourStart must be between 0 and 23")
+	}
+	if r.HappyHourEnd <= r.HappyHourStart || r.HappyHourEnd > 24 {
+		return fmt.Errorf("happyHourEnd must be after happyHourStart and at most 24")
+	}
+	if r.MaxPromotionBonus < 0 {
+		return fmt.Errorf("maxPromotionBonus must not be negative")
+	}
+	if _, err := tz.Load(r.DefaultTimezone); err != nil {
+		return fmt.Errorf("defaultTimezone: %w", err)
+	}
+	for retailer, zone := range r.RetailerTimezones {
+		if _, err := tz.Load(zone); err != nil {
+			return fmt.Errorf("retailerTimezones[%s]: %w", retailer, err)
+		}
+	}
+	if err := r.validateCustomRules(); err != nil {
+		return err
+	}
+	return r.validateRetailers()
+}
+
+// Score is a receipt's points, the rules version they were calculated
+// under, what each rule awarded and the promotions that added to them.
+type Score struct {
+	Points       int64
+	BasePoints   int64
+	RulesVersion string
+	Breakdown    []models.RulePoints
+	Tier         string // the submitter's tier, if any
+	TierBonus    int64  // what the tier's multiplier added to the base points
+	Promotions   []models.AppliedPromotion
+
+	matched []promotions.Campaign // the campaigns the receipt matched
+	failed  error                 // a custom rule that failed and awarded nothing
+}
+
+// ErrNotFound is returned for a receipt ID that was never stored.
+var ErrNotFound = errors.New("No receipt found for that ID.")
+
 // Interface for business logic
 type ReceiptProcessor interface {
-	ProcessReceipt(receipt models.Receipt) (string, error)
+	ProcessReceipt(ctx context.Context, receipt models.Receipt) (string, error)
 	GetPoints(id string) (int64, error)
+	GetScore(id string) (Score, error)
+	GetScoreAt(id, rulesVersion string) (Score, error)
+	CurrentRules() Rules
+	Simulate(receipt models.Receipt, candidate *Rules) (Score, error)
+}
+
+// storedReceipt is a receipt together with who submitted it, when, the
+// version of the rules it is scored under, its user's tier at the time, the
+// campaigns it matched then and the score it was credited
+type storedReceipt struct {
+	receipt      models.Receipt
+	clientID     string
+	submittedAt  time.Time
+	rulesVersion string
+	tier         tiers.Tier // zero when the user had none
+	retailer     string     // canonical name when submitted, which the stats use
+	score        Score      // as credited when submitted or last rescored
+
+	// Only these campaigns ever apply to the receipt, as they were when it
+	// was submitted, so later edits to campaigns leave its points alone
+	campaigns []promotions.Campaign
 }
 
 // InMemoryProcessor implements ReceiptProcessor with in-memory storage
 type InMemoryProcessor struct {
-	receipts sync.Map // thread-safe map for storing receipts
+	receipts   sync.Map                // thread-safe map for storing receipts
+	rules      atomic.Pointer[ruleSet] // the current rule set; nil means DefaultRules
+	promotions *promotions.Store       // nil means no campaigns
+	users      *users.Store            // nil means receipts credit no one
+	tiers      *tiers.Tracker          // nil means no tier multipliers
+	boards     *leaderboard.Boards     // nil means no leaderboards
+	stats      *stats.Rollup           // nil means no stats
+	items      *items.Catalog          // nil means no item catalog
+
+	versionsMu sync.RWMutex
+	versions   map[string]*ruleSet // every rule set ever activated, by version
+
+	rescoreMu sync.Mutex
+	rescores  map[string]*RescoreJob // by ID
+
+	shadow atomic.Pointer[shadow] // nil when no candidate rules are set
 }
 
-func NewInMemoryProcessor() ReceiptProcessor {
+func NewInMemoryProcessor(rules Rules) *InMemoryProcessor {
 	logger.InfoLogger.Printf("Initializing receipt processor...")
-	return &InMemoryProcessor{
-		receipts: sync.Map{},
+	p := &InMemoryProcessor{}
+	p.activate(rules)
+	return p
+}
+
+// WithPromotions applies the campaigns in s on top of the base points.
+func (p *InMemoryProcessor) WithPromotions(s *promotions.Store) *InMemoryProcessor {
+	p.promotions = s
+	return p
+}
+
+// WithUsers credits the points of receipts with a user ID to that user.
+func (p *InMemoryProcessor) WithUsers(s *users.Store) *InMemoryProcessor {
+	p.users = s
+	return p
+}
+
+// WithTiers multiplies the base points of receipts with a user ID by the
+// user's tier. It needs WithUsers.
+func (p *InMemoryProcessor) WithTiers(t *tiers.Tracker) *InMemoryProcessor {
+	p.tiers = t
+	return p
+}
+
+// WithLeaderboards records every processed receipt on the retailer boards
+// in b. The user boards follow the ledger instead; see leaderboard.Boards.Follow.
+func (p *InMemoryProcessor) WithLeaderboards(b *leaderboard.Boards) *InMemoryProcessor {
+	p.boards = b
+	return p
+}
+
+// WithStats rolls up every processed receipt into r.
+func (p *InMemoryProcessor) WithStats(r *stats.Rollup) *InMemoryProcessor {
+	p.stats = r
+	return p
+}
+
+// WithItems adds the items of every processed receipt to c.
+func (p *InMemoryProcessor) WithItems(c *items.Catalog) *InMemoryProcessor {
+	p.items = c
+	return p
+}
+
+// SetRules atomically replaces the rules used for receipts submitted from
+// now on. Receipts already stored keep the version they were pinned to.
+func (p *InMemoryProcessor) SetRules(rules Rules) error {
+	if err := rules.Validate(); err != nil {
+		return err
+	}
+	rs := p.activate(rules)
+	logger.InfoLogger.Printf("Scoring rules updated to version %s", rs.version)
+	return nil
+}
+
+func (p *InMemoryProcessor) currentRules() *ruleSet {
+	if rules := p.rules.Load(); rules != nil {
+		return rules
 	}
+	return p.activate(DefaultRules())
 }
 
-func (p *InMemoryProcessor) ProcessReceipt(receipt models.Receipt) (string, error) {
+// ProcessReceipt stores the receipt, recording the authenticated caller in ctx as its submitter.
+func (p *InMemoryProcessor) ProcessReceipt(ctx context.Context, receipt models.Receipt) (string, error) {
 	id := uuid.New().String()
-	p.receipts.Store(id, receipt)
-	logger.InfoLogger.Printf("Processed new receipt with ID: %s", id)
+	rs := p.currentRules()
+	stored := storedReceipt{receipt: receipt, submittedAt: time.Now(), rulesVersion: rs.version}
+	stored.retailer, _ = rs.registry.Canonical(receipt.Retailer)
+	tracked := receipt.UserID != "" && p.users != nil && p.tiers != nil
+	if tracked {
+		stored.tier, _ = p.tiers.Evaluate(receipt.UserID, "receipt")
+	}
+	if caller, ok := auth.FromContext(ctx); ok {
+		stored.clientID = caller.ID
+	}
+	score := p.score(rs, receipt, stored.tier, p.campaigns())
+	stored.campaigns = score.matched
+	stored.score = score
+	points := score.Points
+	if receipt.UserID != "" && p.users != nil {
+		_, err := p.users.AddReceipt(receipt.UserID, users.Receipt{
+			ID:           id,
+			Retailer:     receipt.Retailer,
+			PurchaseDate: receipt.PurchaseDate,
+			Total:        receipt.Total,
+			Points:       points,
+			SubmittedAt:  stored.submittedAt,
+		})
+		if err != nil {
+			return "", fmt.Errorf("crediting user %s: %w", receipt.UserID, err)
+		}
+	}
+	if tracked {
+		// The receipt's own points may move the user up for their next one
+		p.tiers.Evaluate(receipt.UserID, "receipt")
+	}
+	p.receipts.Store(id, stored)
+	if p.boards != nil {
+		p.boards.Record(stored.retailer, points)
+	}
+	if p.stats != nil {
+		p.stats.Add(receipt.PurchaseDate, stored.retailer, receipt.Total, len(receipt.Items), points)
+	}
+	if p.items != nil {
+		p.items.Add(receipt.PurchaseDate, stored.retailer, receipt.Items)
+	}
+	p.enqueueShadow(id, stored, rs)
+	logger.InfoLogger.Printf("Processed new receipt with ID: %s (client %q)", id, stored.clientID)
 	return id, nil
 }
 
 func (p *InMemoryProcessor) GetPoints(id string) (int64, error) {
+	score, err := p.GetScore(id)
+	return score.Points, err
+}
+
+// GetScore returns the score the receipt was credited under the rules it is
+// pinned to.
+func (p *InMemoryProcessor) GetScore(id string) (Score, error) {
+	return p.GetScoreAt(id, "")
+}
+
+// GetScoreAt returns the receipt's points under the given rules version and
+// the campaigns it matched when submitted. If rulesVersion is empty it
+// returns the score the receipt was credited, without scoring it again.
+func (p *InMemoryProcessor) GetScoreAt(id, rulesVersion string) (Score, error) {
 	value, ok := p.receipts.Load(id)
 	if !ok {
-		return 0, fmt.Errorf("No receipt found for that ID.")
+		return Score{}, ErrNotFound
 	}
 
-	receipt, ok := value.(models.Receipt)
+	stored := value.(storedReceipt)
+	if rulesVersion == "" {
+		return stored.credited(), nil
+	}
+	rs, err := p.ruleSetFor(rulesVersion)
+	if err != nil {
+		return Score{}, err
+	}
+	return p.score(rs, stored.receipt, stored.tier, stored.campaigns), nil
+}
+
+// credited returns the score the receipt was credited, safe to hand out.
+func (s storedReceipt) credited() Score {
+	score := s.score
+	score.Breakdown = append([]models.RulePoints{}, score.Breakdown...)
+	score.Promotions = append([]models.AppliedPromotion(nil), score.Promotions...)
+	score.matched, score.failed = nil, nil
+	return score
+}
+
+// campaigns returns the campaigns new receipts are scored with.
+func (p *InMemoryProcessor) campaigns() []promotions.Campaign {
+	if p.promotions == nil {
+		return nil
+	}
+	return p.promotions.List()
+}
 
-	points := calculatePoints(receipt)
-	return points, nil
+// score applies the retailer's rules to receipt, then the tier multiplier,
+// then campaigns, which see the retailer under its canonical name and the
+// base points before the multiplier. New and simulated receipts are scored
+// with the current campaigns and stored ones with those they matched when
+// submitted; all of them are scored here.
+func (p *InMemoryProcessor) score(rs *ruleSet, receipt models.Receipt, tier tiers.Tier, campaigns []promotions.Campaign) Score {
+	canonical, rules := rs.forRetailer(receipt.Retailer)
+	score := Score{RulesVersion: rs.version, Breakdown: calculateBreakdown(rules, receipt)}
+	purchased, err := purchaseTime(rules, receipt)
+	if err == nil {
+		var custom []models.RulePoints
+		custom, score.failed = rs.customBreakdown(rules, receipt, canonical, purchased)
+		score.Breakdown = append(score.Breakdown, custom...)
+	}
+	for _, b := range score.Breakdown {
+		score.BasePoints += b.Points
+	}
+	score.Points = score.BasePoints
+	if tier.Name != "" {
+		score.Tier = tier.Name
+		score.TierBonus = int64(math.Round(float64(score.BasePoints) * (tier.Multiplier - 1)))
+		score.Points += score.TierBonus
+	}
+	if err != nil || len(campaigns) == 0 {
+		return score
+	}
+	receipt.Retailer = canonical
+	score.matched = promotions.Matching(campaigns, receipt, purchased)
+	bonus, applied := promotions.Evaluate(score.matched, receipt, purchased, score.BasePoints, rules.MaxPromotionBonus)
+	if bonus > 0 {
+		logger.InfoLogger.Printf("Promotions added %d points: %v", bonus, applied)
+	}
+	score.Points += bonus
+	score.Promotions = applied
+	return score
 }
 
 // Points calculation rules are based on various aspects of the receipt
-func calculatePoints(receipt models.Receipt) int64 {
+func calculatePoints(rules Rules, receipt models.Receipt) int64 {
 	var points int64
+	for _, b := range calculateBreakdown(rules, receipt) {
+		points += b.Points
+	}
+	logger.InfoLogger.Printf("Total points calculated for receipt: %d", points)
+	return points
+}
 
-	// Points from retailer name: points for every alphanumeric character
-	points += calculateRetailerNamePoints(receipt.Retailer)
-
-	// Points from total amount: points for round dollar amounts (no cents)
-	points += calculateRoundDollarPoints(receipt.Total)
+// calculateBreakdown returns what each built-in rule awards the receipt.
+func calculateBreakdown(rules Rules, receipt models.Receipt) []models.RulePoints {
+	breakdown := []models.RulePoints{
+		// Points from retailer name: points for every alphanumeric character
+		{Rule: RuleRetailerName, Points: calculateRetailerNamePoints(rules, receipt.Retailer)},
 
-	// Points from total amount: points if total is a multiple of 0.25
-	points += calculateQuarterPoints(receipt.Total)
+		// Points from total amount: points for round dollar amounts (no cents)
+		{Rule: RuleRoundDollar, Points: calculateRoundDollarPoints(rules, receipt.Total)},
 
-	// Points from item count: points for every two items
-	points += calculateItemCountPoints(receipt.Items)
+		// Points from total amount: points if total is a multiple of 0.25
+		{Rule: RuleQuarterDollar, Points: calculateQuarterPoints(rules, receipt.Total)},
 
-	// Points from item descriptions: points for length of trimmed description
-	points += calculateItemDescriptionPoints(receipt.Items)
+		// Points from item count: points for every two items
+		{Rule: RuleItemPairs, Points: calculateItemCountPoints(rules, receipt.Items)},
 
-	// Points from purchase date: points if the day is odd
-	points += calculateOddDayPoints(receipt.PurchaseDate)
+		// Points from item descriptions: points for length of trimmed description
+		{Rule: RuleItemDescription, Points: calculateItemDescriptionPoints(rules, receipt.Items)},
+	}
 
-	// Points from purchase time: points if time is in the happy hour timeframe
-	points += calculateHappyHourPoints(receipt.PurchaseTime)
+	// Date and time rules use the store's local clock
+	var oddDay, happyHour int64
+	if purchased, err := purchaseTime(rules, receipt); err == nil {
+		// Points from purchase date: points if the day is odd
+		oddDay = calculateOddDayPoints(rules, purchased)
 
-	logger.InfoLogger.Printf("Total points calculated for receipt: %d", points)
-	return points
+		// Points from purchase time: points if time is in the happy hour timeframe
+		happyHour = calculateHappyHourPoints(rules, purchased)
+	} else {
+		logger.ErrorLogger.Printf("No date or time points for receipt: %v", err)
+	}
+	return append(breakdown,
+		models.RulePoints{Rule: RuleOddDay, Points: oddDay},
+		models.RulePoints{Rule: RuleHappyHour, Points: happyHour},
+	)
 }
 
 // calculateRetailerNamePoints awards one point for every alphanumeric character in the retailer name.
-// Example: "Target" = (6 * RetailerNameMultiplier) points, "M&M Corner Market" = (14 * RetailerNameMultiplier) points
-func calculateRetailerNamePoints(retailer string) int64 {
+// Example: "Target" = (6 * rules.RetailerNameMultiplier) points, "M&M Corner Market" = (14 * rules.RetailerNameMultiplier) points
+func calculateRetailerNamePoints(rules Rules, retailer string) int64 {
 	matches := alphanumericRegex.FindAllString(retailer, -1)
-	points := int64(len(matches)) * RetailerNameMultiplier
+	points := int64(len(matches)) * rules.RetailerNameMultiplier
 	logger.InfoLogger.Printf("Retailer name '%s' earned %d points for %d alphanumeric characters",
 		retailer, points, len(matches))
 	return points
 }
 
-// calculateRoundDollarPoints awards RoundDollarPoints points if the total amount has no cents.
-// Example: "35.00" = RoundDollarPoints points, "35.99" = 0 points
-func calculateRoundDollarPoints(total string) int64 {
+// calculateRoundDollarPoints awards rules.RoundDollarPoints points if the total amount has no cents.
+// Example: "35.00" = rules.RoundDollarPoints points, "35.99" = 0 points
+func calculateRoundDollarPoints(rules Rules, total string) int64 {
 	if strings.HasSuffix(total, ".00") {
 		logger.InfoLogger.Printf("Round dollar amount found: %s", total)
-		return RoundDollarPoints
+		return rules.RoundDollarPoints
 	}
 	return 0
 }
 
-// calculateQuarterPoints awards QuarterDollarPoints points if the total is a multiple of 0.25.
-// Example: "35.25" = QuarterDollarPoints points, "35.99" = 0 points
-func calculateQuarterPoints(total string) int64 {
+// calculateQuarterPoints awards rules.QuarterDollarPoints points if the total is a multiple of 0.25.
+// Example: "35.25" = rules.QuarterDollarPoints points, "35.99" = 0 points
+func calculateQuarterPoints(rules Rules, total string) int64 {
 	if amount, err := strconv.ParseFloat(total, 64); err == nil {
 		if math.Mod(amount*100, 25) == 0 {
 			logger.InfoLogger.Printf("Quarter dollar amount found: %s", total)
-			return QuarterDollarPoints
+			return rules.QuarterDollarPoints
 		}
 	}
 	return 0
@@ -131,28 +465,28 @@ func calculateQuarterPoints(total string) int64 {
 
 // calculateItemCountPoints 5 points for every two items on the receipt.
 // Example: 3 items = 5 points, 1 item = 0 points, 4 items = 10 points
-func calculateItemCountPoints(items []models.Item) int64 {
-	points := int64((len(items) / 2) * ItemPairPoints)
+func calculateItemCountPoints(rules Rules, items []models.Item) int64 {
+	points := int64(len(items)/2) * rules.ItemPairPoints
 	logger.InfoLogger.Printf("Item count points: %d for %d items", points, len(items))
 	return points
 }
 
 // calculateItemDescriptionPoints awards points based on item descriptions.
 // For each item:
-// 1. If the trimmed length of the item description is a multiple of ItemDescriptionPointsModulus
-// 2. Multiply the price by ItemDescriptionPointsMultiplier and round up to nearest integer
-// Example: if "Mountain Dew" was divible by ItemDescriptionPointsModulus and it had price "2.25" = ceil(2.25 * ItemDescriptionPointsMultiplier) points
-func calculateItemDescriptionPoints(items []models.Item) int64 {
+// 1. If the trimmed length of the item description is a multiple of rules.ItemDescriptionPointsModulus
+// 2. Multiply the price by rules.ItemDescriptionPointsMultiplier and round up to nearest integer
+// Example: if "Mountain Dew" was divible by rules.ItemDescriptionPointsModulus and it had price "2.25" = ceil(2.25 * rules.ItemDescriptionPointsMultiplier) points
+func calculateItemDescriptionPoints(rules Rules, items []models.Item) int64 {
 	var points int64
 	for _, item := range items {
 
 		trimLen := len(strings.TrimSpace(item.ShortDescription))
 
-		if trimLen%ItemDescriptionPointsModulus == 0 {
+		if trimLen%rules.ItemDescriptionPointsModulus == 0 {
 
 			if price, err := strconv.ParseFloat(item.Price, 64); err == nil {
-				itemDescriptionPoints := int64(math.Ceil(price * ItemDescriptionPointsMultiplier))
-				logger.InfoLogger.Printf("Item '%s' earned %d points (description length %d is divisible by %d)", item.ShortDescription, itemDescriptionPoints, trimLen, ItemDescriptionPointsModulus)
+				itemDescriptionPoints := int64(math.Ceil(price * rules.ItemDescriptionPointsMultiplier))
+				logger.InfoLogger.Printf("Item '%s' earned %d points (description length %d is divisible by %d)", item.ShortDescription, itemDescriptionPoints, trimLen, rules.ItemDescriptionPointsModulus)
 				points += itemDescriptionPoints
 			}
 		}
@@ -161,30 +495,56 @@ func calculateItemDescriptionPoints(items []models.Item) int64 {
 	return points
 }
 
-// calculateOddDayPoints awards OddDayPoints points if the day in the purchase date is odd.
-// Example: 12/31/2025 = OddDayPoints points, 01/12/2024 = 0 points
-func calculateOddDayPoints(purchaseDate string) int64 {
-	if day, err := strconv.Atoi(purchaseDate[8:]); err == nil {
-		if day%2 == 1 {
-			logger.InfoLogger.Printf("Odd day points awarded for day: %d", day)
-			return OddDayPoints
+// purchaseTime returns the moment of purchase in the store's timezone: the
+// receipt's own timezone or UTC offset, else the retailer's configured
+// timezone, else rules.DefaultTimezone.
+func purchaseTime(rules Rules, receipt models.Receipt) (time.Time, error) {
+	var loc *time.Location
+	switch {
+	case receipt.Timezone != "":
+		var err error
+		if loc, err = tz.Load(receipt.Timezone); err != nil {
+			return time.Time{}, err
+		}
+	case receipt.UTCOffset != "":
+		offset, err := tz.ParseOffset(receipt.UTCOffset)
+		if err != nil {
+			return time.Time{}, err
+		}
+		loc = time.FixedZone("", offset)
+	default:
+		name := rules.DefaultTimezone
+		if zone, ok := rules.RetailerTimezones[receipt.Retailer]; ok {
+			name = zone
+		}
+		var err error
+		if loc, err = tz.Load(name); err != nil {
+			return time.Time{}, err
 		}
 	}
+	return tz.Local(receipt.PurchaseDate, receipt.PurchaseTime, loc, receipt.UTCOffset)
+}
+
+// calculateOddDayPoints awards rules.OddDayPoints points if the day of the purchase is odd.
+// Example: 12/31/2025 = rules.OddDayPoints points, 01/12/2024 = 0 points
+func calculateOddDayPoints(rules Rules, purchased time.Time) int64 {
+	if day := purchased.Day(); day%2 == 1 {
+		logger.InfoLogger.Printf("Odd day points awarded for day: %d", day)
+		return rules.OddDayPoints
+	}
 	return 0
 }
 
-// calculateHappyHourPoints awards HappyHourPoints points if time is in the happy hour timeframe
+// calculateHappyHourPoints awards rules.HappyHourPoints points if time is in the happy hour timeframe
 // Example: 3:33PM = 6 points, 7:45AM = 0 points (if happy hour started at 3PM and ended at 5PM)
-func calculateHappyHourPoints(purchaseTimeString string) int64 {
-	if purchaseTime, err := time.Parse("15:04", purchaseTimeString); err == nil {
-		startTime := time.Date(0, 1, 1, HappyHourStart, 0, 0, 0, time.UTC)
-		endTime := time.Date(0, 1, 1, HappyHourEnd, 0, 0, 0, time.UTC)
-		checkTime := time.Date(0, 1, 1, purchaseTime.Hour(), purchaseTime.Minute(), 0, 0, time.UTC)
-
-		if checkTime.After(startTime) && checkTime.Before(endTime) {
-			logger.InfoLogger.Printf("Happy hour points awarded for time: %s", purchaseTimeString)
-			return HappyHourPoints
-		}
+func calculateHappyHourPoints(rules Rules, purchased time.Time) int64 {
+	startTime := time.Date(0, 1, 1, rules.HappyHourStart, 0, 0, 0, time.UTC)
+	endTime := time.Date(0, 1, 1, rules.HappyHourEnd, 0, 0, 0, time.UTC)
+	checkTime := time.Date(0, 1, 1, purchased.Hour(), purchased.Minute(), 0, 0, time.UTC)
+
+	if checkTime.After(startTime) && checkTime.Before(endTime) {
+		logger.InfoLogger.Printf("Happy hour points awarded for time: %s", purchased.Format("15:04 MST"))
+		return rules.HappyHourPoints
 	}
 	return 0
 }
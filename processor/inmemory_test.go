@@ -1,10 +1,20 @@
 package processor
 
 import (
+	"context"
 	"testing"
+	"time"
 
+	"github.com/suryamp/receipt-processor/auth"
+	"github.com/suryamp/receipt-processor/items"
+	"github.com/suryamp/receipt-processor/leaderboard"
+	"github.com/suryamp/receipt-processor/ledger"
 	"github.com/suryamp/receipt-processor/logger"
 	"github.com/suryamp/receipt-processor/models"
+	"github.com/suryamp/receipt-processor/promotions"
+	"github.com/suryamp/receipt-processor/stats"
+	"github.com/suryamp/receipt-processor/tiers"
+	"github.com/suryamp/receipt-processor/users"
 )
 
 func init() {
@@ -43,7 +53,7 @@ func TestCalculateRetailerNamePoints(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := calculateRetailerNamePoints(tt.retailer); got != tt.want {
+			if got := calculateRetailerNamePoints(DefaultRules(), tt.retailer); got != tt.want {
 				t.Errorf("calculateRetailerNamePoints() = %v, want %v", got, tt.want)
 			}
 		})
@@ -75,7 +85,7 @@ func TestCalculateRoundDollarPoints(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := calculateRoundDollarPoints(tt.total); got != tt.want {
+			if got := calculateRoundDollarPoints(DefaultRules(), tt.total); got != tt.want {
 				t.Errorf("calculateRoundDollarPoints() = %v, want %v", got, tt.want)
 			}
 		})
@@ -107,7 +117,7 @@ func TestCalculateQuarterPoints(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := calculateQuarterPoints(tt.total); got != tt.want {
+			if got := calculateQuarterPoints(DefaultRules(), tt.total); got != tt.want {
 				t.Errorf("calculateQuarterPoints() = %v, want %v", got, tt.want)
 			}
 		})
@@ -146,7 +156,7 @@ func TestCalculateItemCountPoints(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := calculateItemCountPoints(tt.items); got != tt.want {
+			if got := calculateItemCountPoints(DefaultRules(), tt.items); got != tt.want {
 				t.Errorf("calculateItemCountPoints() = %v, want %v", got, tt.want)
 			}
 		})
@@ -186,7 +196,7 @@ func TestCalculateItemDescriptionPoints(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := calculateItemDescriptionPoints(tt.items); got != tt.want {
+			if got := calculateItemDescriptionPoints(DefaultRules(), tt.items); got != tt.want {
 				t.Errorf("calculateItemDescriptionPoints() = %v, want %v", got, tt.want)
 			}
 		})
@@ -195,25 +205,25 @@ func TestCalculateItemDescriptionPoints(t *testing.T) {
 
 func TestCalculateOddDayPoints(t *testing.T) {
 	tests := []struct {
-		name         string
-		purchaseDate string
-		want         int64
+		name      string
+		purchased time.Time
+		want      int64
 	}{
 		{
-			name:         "odd day",
-			purchaseDate: "2024-01-01",
-			want:         6,
+			name:      "odd day",
+			purchased: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			want:      6,
 		},
 		{
-			name:         "even day",
-			purchaseDate: "2024-01-02",
-			want:         0,
+			name:      "even day",
+			purchased: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+			want:      0,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := calculateOddDayPoints(tt.purchaseDate); got != tt.want {
+			if got := calculateOddDayPoints(DefaultRules(), tt.purchased); got != tt.want {
 				t.Errorf("calculateOddDayPoints() = %v, want %v", got, tt.want)
 			}
 		})
@@ -222,36 +232,66 @@ func TestCalculateOddDayPoints(t *testing.T) {
 
 func TestCalculateHappyHourPoints(t *testing.T) {
 	tests := []struct {
-		name         string
-		purchaseTime string
-		want         int64
+		name      string
+		purchased time.Time
+		want      int64
 	}{
 		{
-			name:         "during happy hour",
-			purchaseTime: "14:30",
-			want:         10,
+			name:      "during happy hour",
+			purchased: time.Date(2024, 1, 1, 14, 30, 0, 0, time.UTC),
+			want:      10,
 		},
 		{
-			name:         "before happy hour",
-			purchaseTime: "13:59",
-			want:         0,
+			name:      "before happy hour",
+			purchased: time.Date(2024, 1, 1, 13, 59, 0, 0, time.UTC),
+			want:      0,
 		},
 		{
-			name:         "after happy hour",
-			purchaseTime: "16:01",
-			want:         0,
+			name:      "after happy hour",
+			purchased: time.Date(2024, 1, 1, 16, 1, 0, 0, time.UTC),
+			want:      0,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := calculateHappyHourPoints(tt.purchaseTime); got != tt.want {
+			if got := calculateHappyHourPoints(DefaultRules(), tt.purchased); got != tt.want {
 				t.Errorf("calculateHappyHourPoints() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
 
+func TestPurchaseTime(t *testing.T) {
+	rules := DefaultRules()
+	rules.RetailerTimezones = map[string]string{"Target": "America/Chicago"}
+
+	tests := []struct {
+		name    string
+		receipt models.Receipt
+		want    string // RFC 3339
+	}{
+		{"default timezone", models.Receipt{Retailer: "Walgreens", PurchaseDate: "2024-07-01", PurchaseTime: "14:30"}, "2024-07-01T14:30:00Z"},
+		{"retailer timezone", models.Receipt{Retailer: "Target", PurchaseDate: "2024-07-01", PurchaseTime: "14:30"}, "2024-07-01T14:30:00-05:00"},
+		{"receipt timezone wins", models.Receipt{Retailer: "Target", PurchaseDate: "2024-07-01", PurchaseTime: "14:30", Timezone: "Europe/Paris"}, "2024-07-01T14:30:00+02:00"},
+		{"receipt offset wins", models.Receipt{Retailer: "Target", PurchaseDate: "2024-07-01", PurchaseTime: "14:30", UTCOffset: "+09:00"}, "2024-07-01T14:30:00+09:00"},
+		{"spring forward gap", models.Receipt{Retailer: "Target", PurchaseDate: "2024-03-10", PurchaseTime: "02:15"}, "2024-03-10T03:15:00-05:00"},
+		{"fall back disambiguated by offset", models.Receipt{Retailer: "Target", PurchaseDate: "2024-11-03", PurchaseTime: "01:15", UTCOffset: "-06:00", Timezone: "America/Chicago"}, "2024-11-03T01:15:00-06:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := purchaseTime(rules, tt.receipt)
+			if err != nil {
+				t.Fatalf("purchaseTime() error = %v", err)
+			}
+			if got.Format(time.RFC3339) != tt.want {
+				t.Errorf("purchaseTime() = %s, want %s", got.Format(time.RFC3339), tt.want)
+			}
+		})
+	}
+}
+
 func TestCalculatePoints(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -276,9 +316,290 @@ func TestCalculatePoints(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := calculatePoints(tt.receipt); got != tt.want {
+			if got := calculatePoints(DefaultRules(), tt.receipt); got != tt.want {
 				t.Errorf("calculatePoints() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestCalculatePointsAcrossDST(t *testing.T) {
+	rules := DefaultRules()
+	rules.HappyHourStart, rules.HappyHourEnd = 3, 4
+
+	receipt := models.Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2024-03-10",
+		PurchaseTime: "02:30",
+		Items:        []models.Item{{ShortDescription: "abcd", Price: "1.01"}},
+		Total:        "1.01",
+	}
+	// 6 (retailer) in UTC; in New York 02:30 does not exist that night and
+	// reads as 03:30, adding 10 (happy hour)
+	if got := calculatePoints(rules, receipt); got != 6 {
+		t.Errorf("calculatePoints() in UTC = %v, want 6", got)
+	}
+	receipt.Timezone = "America/New_York"
+	if got := calculatePoints(rules, receipt); got != 16 {
+		t.Errorf("calculatePoints() in New York = %v, want 16", got)
+	}
+}
+
+func TestProcessReceiptRecordsClient(t *testing.T) {
+	p := NewInMemoryProcessor(DefaultRules())
+	ctx := auth.WithIdentity(context.Background(), auth.Identity{ID: "partner-a", Method: auth.MethodAPIKey})
+
+	id, err := p.ProcessReceipt(ctx, models.Receipt{Retailer: "Target", PurchaseDate: "2024-01-01", PurchaseTime: "13:01", Total: "1.00"})
+	if err != nil {
+		t.Fatalf("ProcessReceipt() error = %v", err)
+	}
+	value, _ := p.receipts.Load(id)
+	if got := value.(storedReceipt).clientID; got != "partner-a" {
+		t.Errorf("stored client = %v, want partner-a", got)
+	}
+}
+
+func TestGetScoreAppliesPromotions(t *testing.T) {
+	campaigns := promotions.NewStore()
+	if _, err := campaigns.Create(promotions.Campaign{Name: "Gatorade March", StartDate: "2024-03-01", EndDate: "2024-03-31", Keywords: []string{"gatorade"}, Bonus: 100}); err != nil {
+		t.Fatal(err)
+	}
+	p := NewInMemoryProcessor(DefaultRules()).WithPromotions(campaigns)
+
+	id, _ := p.ProcessReceipt(context.Background(), models.Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2024-03-02",
+		PurchaseTime: "13:01",
+		Items:        []models.Item{{ShortDescription: "Gatorade", Price: "2.25"}},
+		Total:        "2.25",
+	})
+	score, err := p.GetScore(id)
+	if err != nil {
+		t.Fatalf("GetScore() error = %v", err)
+	}
+	// 6 (retailer) + 25 (multiple of 0.25) base, plus 100 from the campaign
+	if score.BasePoints != 31 || score.Points != 131 || len(score.Promotions) != 1 {
+		t.Errorf("GetScore() = %+v, want 31 base and 131 points from one promotion", score)
+	}
+	if points, _ := p.GetPoints(id); points != score.Points {
+		t.Errorf("GetPoints() = %d, want %d", points, score.Points)
+	}
+}
+
+func TestCampaignChangesLeaveStoredReceipts(t *testing.T) {
+	campaigns := promotions.NewStore()
+	march, _ := campaigns.Create(promotions.Campaign{Name: "Gatorade March", StartDate: "2024-03-01", EndDate: "2024-03-31", Keywords: []string{"gatorade"}, Bonus: 100})
+	p := NewInMemoryProcessor(DefaultRules()).WithPromotions(campaigns)
+
+	receipt := models.Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2024-03-02",
+		PurchaseTime: "13:01",
+		Items:        []models.Item{{ShortDescription: "Gatorade", Price: "2.25"}},
+		Total:        "2.25",
+	}
+	id, _ := p.ProcessReceipt(context.Background(), receipt)
+
+	// Editing, adding and deleting campaigns changes only new receipts
+	march.Bonus = 500
+	campaigns.Update(march.ID, march)
+	campaigns.Create(promotions.Campaign{Name: "Target", StartDate: "2024-03-01", EndDate: "2024-03-31", Retailers: []string{"Target"}, Bonus: 7})
+	later, _ := p.ProcessReceipt(context.Background(), receipt)
+	campaigns.Delete(march.ID)
+
+	if score, _ := p.GetScore(id); score.Points != 131 || len(score.Promotions) != 1 || score.Promotions[0].Bonus != 100 {
+		t.Errorf("score after campaign changes = %+v, want the 100 point bonus it was submitted with", score)
+	}
+	if score, _ := p.GetScore(later); score.Points != 538 || len(score.Promotions) != 2 {
+		t.Errorf("later score = %+v, want both campaigns as they were then", score)
+	}
+	if score, _ := p.Simulate(receipt, nil); score.Points != 38 {
+		t.Errorf("Simulate() = %+v, want only the campaign left", score)
+	}
+}
+
+func TestProcessReceiptCreditsUser(t *testing.T) {
+	points := ledger.New()
+	accounts := users.NewStore(points)
+	p := NewInMemoryProcessor(DefaultRules()).WithUsers(accounts)
+
+	receipt := models.Receipt{
+		UserID:       "alice",
+		Retailer:     "Target",
+		PurchaseDate: "2024-01-02",
+		PurchaseTime: "13:01",
+		Items:        []models.Item{{ShortDescription: "Pepsi", Price: "1.25"}},
+		Total:        "1.25",
+	}
+	first, _ := p.ProcessReceipt(context.Background(), receipt)
+	p.ProcessReceipt(context.Background(), receipt)
+	anonymous := receipt
+	anonymous.UserID = ""
+	p.ProcessReceipt(context.Background(), anonymous)
+
+	// 6 (retailer) + 25 (multiple of 0.25) per receipt
+	if u, _ := accounts.Get("alice"); u.Balance != 62 || u.Receipts != 2 {
+		t.Errorf("user = %+v, want balance 62 from 2 receipts", u)
+	}
+
+	// Rescoring moves the balance by the change
+	rules := DefaultRules()
+	rules.QuarterDollarPoints = 100
+	p.SetRules(rules)
+	job, _ := p.StartRescore(RescoreRequest{})
+	waitForRescore(t, p, job.ID)
+	if u, _ := accounts.Get("alice"); u.Balance != 212 {
+		t.Errorf("balance after rescore = %d, want 212", u.Balance)
+	}
+	if page, _, _ := accounts.Receipts("alice", 1, 1); page[0].ID != first || page[0].Points != 106 {
+		t.Errorf("first receipt after rescore = %+v, want 106 points", page[0])
+	}
+
+	// Two receipt credits and two rescore entries, all referring to a receipt
+	entries, total := points.Entries(ledger.UserAccount("alice"), 0, 10)
+	if total != 4 {
+		t.Fatalf("ledger entries = %d, want 4", total)
+	}
+	for _, e := range entries {
+		if e.ReceiptID == "" {
+			t.Errorf("entry %+v has no receipt ID", e)
+		}
+	}
+	if entries[3].Reason != ledger.ReasonReceipt || entries[0].Reason != ledger.ReasonRescore {
+		t.Errorf("entry reasons = %s first and %s last, want receipt then rescore", entries[3].Reason, entries[0].Reason)
+	}
+	if r := points.Reconcile(); !r.OK() {
+		t.Errorf("Reconcile() = %+v, want no mismatches", r)
+	}
+}
+
+func TestProcessReceiptTierBonus(t *testing.T) {
+	accounts := users.NewStore(ledger.New())
+	tracker := tiers.NewTracker(tiers.Ladder{{Name: "Silver", MinPoints: 30, Multiplier: 2}}, accounts)
+	p := NewInMemoryProcessor(DefaultRules()).WithUsers(accounts).WithTiers(tracker)
+
+	receipt := models.Receipt{
+		UserID:       "alice",
+		Retailer:     "Target",
+		PurchaseDate: "2024-01-02",
+		PurchaseTime: "13:01",
+		Items:        []models.Item{{ShortDescription: "Pepsi", Price: "1.25"}},
+		Total:        "1.25",
+	}
+	// The first receipt's 31 points reach Silver, which doubles the second's
+	first, _ := p.ProcessReceipt(context.Background(), receipt)
+	second, _ := p.ProcessReceipt(context.Background(), receipt)
+
+	if score, _ := p.GetScore(first); score.Tier != "" || score.Points != 31 {
+		t.Errorf("first score = %+v, want 31 points without a tier", score)
+	}
+	score, _ := p.GetScore(second)
+	if score.Tier != "Silver" || score.TierBonus != 31 || score.Points != 62 || score.BasePoints != 31 {
+		t.Errorf("second score = %+v, want 31 base points doubled by Silver", score)
+	}
+	if u, _ := accounts.Get("alice"); u.Balance != 93 {
+		t.Errorf("balance = %d, want 93", u.Balance)
+	}
+
+	// A receipt keeps the tier it was submitted under through rescores
+	rules := DefaultRules()
+	rules.QuarterDollarPoints = 100
+	p.SetRules(rules)
+	job, _ := p.StartRescore(RescoreRequest{})
+	waitForRescore(t, p, job.ID)
+	if score, _ := p.GetScore(second); score.Tier != "Silver" || score.Points != 212 {
+		t.Errorf("second score after rescore = %+v, want 106 base points doubled", score)
+	}
+	if u, _ := accounts.Get("alice"); u.Balance != 318 {
+		t.Errorf("balance after rescore = %d, want 318", u.Balance)
+	}
+}
+
+func TestProcessReceiptLeaderboards(t *testing.T) {
+	points := ledger.New()
+	boards := leaderboard.New(time.UTC).Follow(points)
+	p := NewInMemoryProcessor(DefaultRules()).WithUsers(users.NewStore(points)).WithLeaderboards(boards)
+
+	receipt := models.Receipt{
+		UserID:       "alice",
+		Retailer:     "  Target ",
+		PurchaseDate: "2024-01-02",
+		PurchaseTime: "13:01",
+		Items:        []models.Item{{ShortDescription: "Pepsi", Price: "1.25"}},
+		Total:        "1.25",
+	}
+	p.ProcessReceipt(context.Background(), receipt)
+	receipt.UserID = ""
+	p.ProcessReceipt(context.Background(), receipt)
+
+	// Users score the points credited to them, and anonymous receipts count
+	// only for their retailer
+	if page, _ := boards.Page(leaderboard.UsersAllTime, 0, 10, "alice"); page.Entries != 1 || page.Self == nil || page.Self.Score != 31 {
+		t.Errorf("users board = %+v, want alice with 31 points", page)
+	}
+	page, _ := boards.Page(leaderboard.RetailersReceipts, 0, 10, "Target")
+	if page.Self == nil || page.Self.Score != 2 {
+		t.Errorf("retailers board = %+v, want Target with 2 receipts", page)
+	}
+
+	// A rescore moves both boards by the change
+	rules := DefaultRules()
+	rules.QuarterDollarPoints = 100
+	p.SetRules(rules)
+	job, _ := p.StartRescore(RescoreRequest{})
+	waitForRescore(t, p, job.ID)
+	if page, _ := boards.Page(leaderboard.UsersAllTime, 0, 10, "alice"); page.Self == nil || page.Self.Score != 106 {
+		t.Errorf("users board after rescore = %+v, want alice with 106 points", page)
+	}
+	if page, _ := boards.Page(leaderboard.RetailersPoints, 0, 10, "Target"); page.Self == nil || page.Self.Score != 212 {
+		t.Errorf("retailers board after rescore = %+v, want Target with 212 points", page)
+	}
+}
+
+func TestProcessReceiptStats(t *testing.T) {
+	rollup := stats.NewRollup()
+	p := NewInMemoryProcessor(DefaultRules()).WithStats(rollup)
+
+	receipt := models.Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2024-01-02",
+		PurchaseTime: "13:01",
+		Items:        []models.Item{{ShortDescription: "Pepsi", Price: "1.25"}},
+		Total:        "1.25",
+	}
+	p.ProcessReceipt(context.Background(), receipt)
+	p.ProcessReceipt(context.Background(), receipt)
+	if report, _ := rollup.Query(stats.Query{}); report.Total.Receipts != 2 || report.Total.Points != 62 || report.Total.Spend != "2.50" {
+		t.Errorf("stats = %+v, want 2 receipts with 62 points and 2.50 spent", report.Total)
+	}
+
+	// A rescore moves the rolled up points with the receipts
+	rules := DefaultRules()
+	rules.QuarterDollarPoints = 100
+	p.SetRules(rules)
+	job, _ := p.StartRescore(RescoreRequest{})
+	waitForRescore(t, p, job.ID)
+	if report, _ := rollup.Query(stats.Query{}); report.Total.Points != 212 {
+		t.Errorf("stats points after rescore = %d, want 212", report.Total.Points)
+	}
+}
+
+func TestProcessReceiptItems(t *testing.T) {
+	catalog := items.NewCatalog()
+	p := NewInMemoryProcessor(DefaultRules()).WithItems(catalog)
+
+	p.ProcessReceipt(context.Background(), models.Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2024-01-02",
+		PurchaseTime: "13:01",
+		Items:        []models.Item{{ShortDescription: "Pepsi 12PK", Price: "6.00"}, {ShortDescription: "PEPSI 12 pk", Price: "7.00"}},
+		Total:        "13.00",
+	})
+	item, err := catalog.Get("pepsi 12 pack")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got := item.ByRetailer["Target"]; got.Purchases != 2 || got.Median != "6.50" {
+		t.Errorf("Target prices = %+v, want 2 purchases with a median of 6.50", got)
+	}
+}
@@ -0,0 +1,211 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/suryamp/receipt-processor/logger"
+)
+
+var (
+	// ErrRescoreRunning is returned when a rescore is started while another runs.
+	ErrRescoreRunning = errors.New("a rescore job is already running")
+	// ErrJobNotFound is returned for an unknown rescore job ID.
+	ErrJobNotFound = errors.New("rescore job not found")
+)
+
+// maxReportedChanges bounds the changes a rescore report lists; the
+// largest changes are kept and the totals always cover every receipt.
+const maxReportedChanges = 1000
+
+// Rescore job states.
+const (
+	RescoreRunning = "running"
+	RescoreDone    = "done"
+)
+
+// RescoreRequest asks for every stored receipt to be scored under
+// RulesVersion, the current rules if empty. Unless DryRun is set, the
+// receipts are pinned to that version afterwards.
+type RescoreRequest struct {
+	RulesVersion string `json:"rulesVersion"`
+	DryRun       bool   `json:"dryRun"`
+}
+
+// ScoreChange is a receipt whose total differs under the new rules.
+type ScoreChange struct {
+	ReceiptID   string `json:"receiptId"`
+	FromVersion string `json:"fromVersion"`
+	OldPoints   int64  `json:"oldPoints"`
+	NewPoints   int64  `json:"newPoints"`
+	Delta       int64  `json:"delta"`
+}
+
+// RescoreJob is the progress and diff report of a bulk rescore. Failed
+// counts receipts a custom rule could not be evaluated for; they keep their
+// points and version and are not reported as changed.
+type RescoreJob struct {
+	ID           string        `json:"id"`
+	Status       string        `json:"status"`
+	RulesVersion string        `json:"rulesVersion"`
+	DryRun       bool          `json:"dryRun"`
+	StartedAt    time.Time     `json:"startedAt"`
+	FinishedAt   *time.Time    `json:"finishedAt,omitempty"`
+	Scanned      int           `json:"scanned"`
+	Changed      int           `json:"changed"`
+	Failed       int           `json:"failed"`
+	PointsDelta  int64         `json:"pointsDelta"`
+	Changes      []ScoreChange `json:"changes"`
+	Truncated    bool          `json:"truncated"`
+}
+
+// StartRescore starts a rescore job in the background and returns it as
+// started. Only one job runs at a time.
+func (p *InMemoryProcessor) StartRescore(req RescoreRequest) (RescoreJob, error) {
+	target := p.currentRules()
+	if req.RulesVersion != "" {
+		var err error
+		if target, err = p.ruleSetFor(req.RulesVersion); err != nil {
+			return RescoreJob{}, err
+		}
+	}
+
+	p.rescoreMu.Lock()
+	defer p.rescoreMu.Unlock()
+	for _, job := range p.rescores {
+		if job.Status == RescoreRunning {
+			return RescoreJob{}, fmt.Errorf("%w: %s", ErrRescoreRunning, job.ID)
+		}
+	}
+	job := &RescoreJob{
+		ID:           uuid.New().String(),
+		Status:       RescoreRunning,
+		RulesVersion: target.version,
+		DryRun:       req.DryRun,
+		StartedAt:    time.Now(),
+		Changes:      []ScoreChange{},
+	}
+	if p.rescores == nil {
+		p.rescores = map[string]*RescoreJob{}
+	}
+	p.rescores[job.ID] = job
+	logger.InfoLogger.Printf("Rescore %s started: rules version %s, dry run %t", job.ID, job.RulesVersion, job.DryRun)
+
+	go p.rescore(job, target)
+	return *job, nil
+}
+
+// Rescore returns the rescore job with the given ID.
+func (p *InMemoryProcessor) Rescore(id string) (RescoreJob, error) {
+	p.rescoreMu.Lock()
+	defer p.rescoreMu.Unlock()
+	job, ok := p.rescores[id]
+	if !ok {
+		return RescoreJob{}, ErrJobNotFound
+	}
+	report := *job
+	report.Changes = append([]ScoreChange{}, job.Changes...)
+	return report, nil
+}
+
+// largestChanges sorts changes by size, largest first, and keeps the first
+// maxReportedChanges of them.
+func largestChanges(changes []ScoreChange) []ScoreChange {
+	sort.Slice(changes, func(i, j int) bool {
+		a, b := abs(changes[i].Delta), abs(changes[j].Delta)
+		if a != b {
+			return a > b
+		}
+		return changes[i].ReceiptID < changes[j].ReceiptID
+	})
+	if len(changes) > maxReportedChanges {
+		changes = changes[:maxReportedChanges]
+	}
+	return changes
+}
+
+func (p *InMemoryProcessor) rescore(job *RescoreJob, target *ruleSet) {
+	var changes []ScoreChange
+	truncated := false
+	p.receipts.Range(func(key, value any) bool {
+		id, stored := key.(string), value.(storedReceipt)
+		// Changes are from the points credited, which the ledger, stats and
+		// leaderboards recorded
+		oldPoints := stored.score.Points
+		score := p.score(target, stored.receipt, stored.tier, stored.campaigns)
+		if score.failed != nil {
+			// A rule that timed out scored 0, which is no reason to move points
+			logger.ErrorLogger.Printf("Rescore %s: receipt %s left as it was: %v", job.ID, id, score.failed)
+			p.rescoreMu.Lock()
+			job.Scanned++
+			job.Failed++
+			p.rescoreMu.Unlock()
+			return true
+		}
+		newPoints := score.Points
+		if newPoints != oldPoints {
+			changes = append(changes, ScoreChange{
+				ReceiptID:   id,
+				FromVersion: stored.rulesVersion,
+				OldPoints:   oldPoints,
+				NewPoints:   newPoints,
+				Delta:       newPoints - oldPoints,
+			})
+			// Trim now and then, so the list stays bounded however many change
+			if len(changes) >= 2*maxReportedChanges {
+				changes = largestChanges(changes)
+				truncated = true
+			}
+		}
+		if !job.DryRun && (stored.rulesVersion != target.version || newPoints != oldPoints) {
+			stored.rulesVersion = target.version
+			stored.score = score
+			if userID := stored.receipt.UserID; userID != "" && p.users != nil {
+				// Post the change in points to the user's ledger account
+				if err := p.users.RescoreReceipt(userID, id, oldPoints, newPoints); err != nil {
+					logger.ErrorLogger.Printf("Rescore %s: crediting receipt %s to user %s: %v", job.ID, id, userID, err)
+				}
+			}
+			if p.stats != nil {
+				p.stats.AddPoints(stored.receipt.PurchaseDate, stored.retailer, newPoints-oldPoints)
+			}
+			if p.boards != nil {
+				p.boards.AddPoints(stored.retailer, newPoints-oldPoints)
+			}
+			p.receipts.Store(id, stored)
+		}
+
+		p.rescoreMu.Lock()
+		job.Scanned++
+		if newPoints != oldPoints {
+			job.Changed++
+			job.PointsDelta += newPoints - oldPoints
+		}
+		p.rescoreMu.Unlock()
+		return true
+	})
+
+	// Report the largest changes first
+	truncated = truncated || len(changes) > maxReportedChanges
+	changes = largestChanges(changes)
+
+	p.rescoreMu.Lock()
+	defer p.rescoreMu.Unlock()
+	finished := time.Now()
+	job.Status = RescoreDone
+	job.FinishedAt = &finished
+	job.Changes = append(job.Changes, changes...)
+	job.Truncated = truncated
+	logger.InfoLogger.Printf("Rescore %s done: %d receipts scanned, %d changed by %d points in total, %d failed",
+		job.ID, job.Scanned, job.Changed, job.PointsDelta, job.Failed)
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
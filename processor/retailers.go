@@ -0,0 +1,182 @@
+package processor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/suryamp/receipt-processor/expr"
+	"github.com/suryamp/receipt-processor/retailers"
+)
+
+// Names of the scoring rules, used to exclude them for a retailer.
+const (
+	RuleRetailerName    = "retailerName"
+	RuleRoundDollar     = "roundDollar"
+	RuleQuarterDollar   = "quarterDollar"
+	RuleItemPairs       = "itemPairs"
+	RuleItemDescription = "itemDescription"
+	RuleOddDay          = "oddDay"
+	RuleHappyHour       = "happyHour"
+)
+
+var ruleNames = []string{RuleRetailerName, RuleRoundDollar, RuleQuarterDollar, RuleItemPairs, RuleItemDescription, RuleOddDay, RuleHappyHour}
+
+// Retailer is a partner retailer: its canonical name, the other names its
+// receipts are printed under, and the terms it negotiated.
+type Retailer struct {
+	Name      string        `yaml:"name" json:"name"`
+	Aliases   []string      `yaml:"aliases" json:"aliases,omitempty"`
+	Overrides RuleOverrides `yaml:"overrides" json:"overrides"`
+}
+
+// RuleOverrides replaces the set fields of the default rules for one
+// retailer. Exclude lists rules, built-in (RuleRetailerName and so on) or
+// custom, that award the retailer's receipts nothing.
+type RuleOverrides struct {
+	RetailerNameMultiplier          *int64   `yaml:"retailerNameMultiplier" json:"retailerNameMultiplier,omitempty"`
+	RoundDollarPoints               *int64   `yaml:"roundDollarPoints" json:"roundDollarPoints,omitempty"`
+	QuarterDollarPoints             *int64   `yaml:"quarterDollarPoints" json:"quarterDollarPoints,omitempty"`
+	ItemDescriptionPointsModulus    *int     `yaml:"itemDescriptionPointsModulus" json:"itemDescriptionPointsModulus,omitempty"`
+	ItemDescriptionPointsMultiplier *float64 `yaml:"itemDescriptionPointsMultiplier" json:"itemDescriptionPointsMultiplier,omitempty"`
+	OddDayPoints                    *int64   `yaml:"oddDayPoints" json:"oddDayPoints,omitempty"`
+	HappyHourPoints                 *int64   `yaml:"happyHourPoints" json:"happyHourPoints,omitempty"`
+	ItemPairPoints                  *int64   `yaml:"itemPairPoints" json:"itemPairPoints,omitempty"`
+	HappyHourStart                  *int     `yaml:"happyHourStart" json:"happyHourStart,omitempty"`
+	HappyHourEnd                    *int     `yaml:"happyHourEnd" json:"happyHourEnd,omitempty"`
+	Exclude                         []string `yaml:"exclude" json:"exclude,omitempty"`
+}
+
+// apply returns r with the overrides laid over it.
+func (o RuleOverrides) apply(r Rules) Rules {
+	setInt64 := func(dst *int64, src *int64) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+	setInt := func(dst *int, src *int) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+	setInt64(&r.RetailerNameMultiplier, o.RetailerNameMultiplier)
+	setInt64(&r.RoundDollarPoints, o.RoundDollarPoints)
+	setInt64(&r.QuarterDollarPoints, o.QuarterDollarPoints)
+	setInt(&r.ItemDescriptionPointsModulus, o.ItemDescriptionPointsModulus)
+	if o.ItemDescriptionPointsMultiplier != nil {
+		r.ItemDescriptionPointsMultiplier = *o.ItemDescriptionPointsMultiplier
+	}
+	setInt64(&r.OddDayPoints, o.OddDayPoints)
+	setInt64(&r.HappyHourPoints, o.HappyHourPoints)
+	setInt64(&r.ItemPairPoints, o.ItemPairPoints)
+	setInt(&r.HappyHourStart, o.HappyHourStart)
+	setInt(&r.HappyHourEnd, o.HappyHourEnd)
+
+	if len(o.Exclude) > 0 {
+		var kept []CustomRule
+		for _, c := range r.CustomRules {
+			if !contains(o.Exclude, c.Name) {
+				kept = append(kept, c)
+			}
+		}
+		r.CustomRules = kept
+	}
+	for _, rule := range o.Exclude {
+		switch rule {
+		case RuleRetailerName:
+			r.RetailerNameMultiplier = 0
+		case RuleRoundDollar:
+			r.RoundDollarPoints = 0
+		case RuleQuarterDollar:
+			r.QuarterDollarPoints = 0
+		case RuleItemPairs:
+			r.ItemPairPoints = 0
+		case RuleItemDescription:
+			r.ItemDescriptionPointsMultiplier = 0
+		case RuleOddDay:
+			r.OddDayPoints = 0
+		case RuleHappyHour:
+			r.HappyHourPoints = 0
+		}
+	}
+	return r
+}
+
+// validateRetailers checks that the registry can be built and that every
+// retailer's effective rules are valid.
+func (r Rules) validateRetailers() error {
+	if _, err := retailers.NewRegistry(registryEntries(r.Retailers)); err != nil {
+		return fmt.Errorf("retailers: %w", err)
+	}
+	known := append(append([]string{}, ruleNames...), r.customRuleNames()...)
+	for _, retailer := range r.Retailers {
+		for _, rule := range retailer.Overrides.Exclude {
+			if !contains(known, rule) {
+				return fmt.Errorf("retailers[%s]: unknown rule %q in exclude, want one of %v", retailer.Name, rule, known)
+			}
+		}
+		effective := retailer.Overrides.apply(r)
+		effective.Retailers = nil
+		if err := effective.Validate(); err != nil {
+			return fmt.Errorf("retailers[%s]: %w", retailer.Name, err)
+		}
+	}
+	return nil
+}
+
+// ruleSet is a Rules with its retailer registry and custom rules built for
+// scoring.
+type ruleSet struct {
+	rules       Rules
+	version     string
+	activatedAt time.Time
+
+	registry  *retailers.Registry
+	overrides map[string]RuleOverrides // by canonical name
+	programs  map[string]*expr.Program // custom rules by name
+}
+
+// newRuleSet prepares rules for scoring. The rules must be valid.
+func newRuleSet(rules Rules) *ruleSet {
+	rs := &ruleSet{rules: rules, overrides: map[string]RuleOverrides{}, programs: map[string]*expr.Program{}}
+	rs.registry, _ = retailers.NewRegistry(registryEntries(rules.Retailers))
+	for _, r := range rules.Retailers {
+		rs.overrides[r.Name] = r.Overrides
+	}
+	for _, c := range rules.CustomRules {
+		if program, err := compileCustomRule(c); err == nil {
+			rs.programs[c.Name] = program
+		}
+	}
+	return rs
+}
+
+// forRetailer returns the canonical name of a printed retailer name and the
+// rules its receipts are scored with.
+func (rs *ruleSet) forRetailer(name string) (string, Rules) {
+	canonical, _ := rs.registry.Canonical(name)
+	rules := rs.rules
+	if o, ok := rs.overrides[canonical]; ok {
+		rules = o.apply(rules)
+	}
+	if zone, ok := rules.RetailerTimezones[canonical]; ok {
+		rules.DefaultTimezone = zone
+	}
+	return canonical, rules
+}
+
+func registryEntries(list []Retailer) []retailers.Entry {
+	entries := make([]retailers.Entry, 0, len(list))
+	for _, r := range list {
+		entries = append(entries, retailers.Entry{Name: r.Name, Aliases: r.Aliases})
+	}
+	return entries
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/suryamp/receipt-processor/models"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+func TestRetailerOverrides(t *testing.T) {
+	rules := DefaultRules()
+	rules.RetailerTimezones = map[string]string{"Walgreens": "America/Chicago"}
+	rules.Retailers = []Retailer{
+		{
+			Name:    "Walgreens",
+			Aliases: []string{"Walgreen Co"},
+			Overrides: RuleOverrides{
+				HappyHourStart: ptr(9),
+				HappyHourEnd:   ptr(11),
+				Exclude:        []string{RuleRoundDollar},
+			},
+		},
+		{Name: "Target", Overrides: RuleOverrides{RetailerNameMultiplier: ptr(int64(3))}},
+	}
+	if err := rules.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	p := NewInMemoryProcessor(rules)
+
+	tests := []struct {
+		name     string
+		retailer string
+		time     string
+		want     int64
+	}{
+		// 12 (printed name) + 25 (quarter) + 10 (happy hour 9-11); round dollar excluded
+		{"branch number resolves to retailer", "WALGREENS #123", "10:15", 47},
+		// 10 (printed name) + 25 (quarter) + 10 (happy hour)
+		{"alias resolves to retailer", "Walgreen Co", "10:15", 45},
+		// 9 (name) + 25 (quarter); default happy hour no longer applies
+		{"default happy hour replaced", "Walgreens", "14:30", 34},
+		// 18 (name, 3 per character) + 50 (round dollar) + 25 (quarter) + 10 (happy hour)
+		{"multiplier override", "Target", "14:30", 103},
+		// 6 (name) + 50 + 25 + 10 under the default rules
+		{"unregistered retailer", "Kroger", "14:30", 91},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, _ := p.ProcessReceipt(context.Background(), models.Receipt{
+				Retailer:     tt.retailer,
+				PurchaseDate: "2024-01-02",
+				PurchaseTime: tt.time,
+				Items:        []models.Item{{ShortDescription: "ab", Price: "5.00"}},
+				Total:        "5.00",
+			})
+			if got, _ := p.GetPoints(id); got != tt.want {
+				t.Errorf("GetPoints() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetailerOverridesValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		retailers []Retailer
+	}{
+		{"unknown excluded rule", []Retailer{{Name: "Target", Overrides: RuleOverrides{Exclude: []string{"weekend"}}}}},
+		{"invalid effective rules", []Retailer{{Name: "Target", Overrides: RuleOverrides{HappyHourEnd: ptr(10)}}}},
+		{"alias claimed twice", []Retailer{{Name: "Target", Aliases: []string{"TGT"}}, {Name: "Tegut", Aliases: []string{"TGT"}}}},
+	}
+
+	for _, tt := range tests {
+		rules := DefaultRules()
+		rules.Retailers = tt.retailers
+		if err := rules.Validate(); err == nil {
+			t.Errorf("%s: Validate() = nil, want error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/suryamp/receipt-processor/logger"
+	"github.com/suryamp/receipt-processor/metrics"
+)
+
+// ErrNoShadowDelta is returned for a receipt the candidate rules have not scored.
+var ErrNoShadowDelta = errors.New("receipt has no shadow evaluation")
+
+const (
+	// shadowQueueSize bounds receipts waiting for shadow evaluation; beyond
+	// it receipts are skipped rather than slowing submissions down.
+	shadowQueueSize = 1024
+	// shadowTopRetailers is how many retailers the report ranks.
+	shadowTopRetailers = 10
+	// shadowKeptDeltas bounds the receipts whose deltas can be looked up;
+	// the report's counts cover every receipt evaluated.
+	shadowKeptDeltas = 10000
+)
+
+// shadowBuckets are the upper bounds of the delta distribution; the last
+// bucket is everything above the final bound.
+var shadowBuckets = []int64{-100, -10, -1, 0, 9, 99}
+
+// ShadowDelta compares one receipt's points under the active and the
+// candidate rules.
+type ShadowDelta struct {
+	ReceiptID        string `json:"receiptId"`
+	Retailer         string `json:"retailer"`
+	ActiveVersion    string `json:"activeVersion"`
+	CandidateVersion string `json:"candidateVersion"`
+	ActivePoints     int64  `json:"activePoints"`
+	CandidatePoints  int64  `json:"candidatePoints"`
+	Delta            int64  `json:"delta"`
+}
+
+// DeltaBucket counts receipts whose delta falls in a range.
+type DeltaBucket struct {
+	Range string `json:"range"`
+	Count int    `json:"count"`
+}
+
+// RetailerDelta is the change the candidate rules make to one retailer's receipts.
+type RetailerDelta struct {
+	Retailer   string  `json:"retailer"`
+	Receipts   int     `json:"receipts"`
+	TotalDelta int64   `json:"totalDelta"`
+	MeanDelta  float64 `json:"meanDelta"`
+}
+
+// ShadowReport aggregates the deltas since the candidate rules were set.
+type ShadowReport struct {
+	Enabled          bool            `json:"enabled"`
+	CandidateVersion string          `json:"candidateVersion,omitempty"`
+	Since            *time.Time      `json:"since,omitempty"`
+	Evaluated        int             `json:"evaluated"`
+	Skipped          int             `json:"skipped"`
+	Increased        int             `json:"increased"`
+	Decreased        int             `json:"decreased"`
+	Unchanged        int             `json:"unchanged"`
+	TotalDelta       int64           `json:"totalDelta"`
+	MeanDelta        float64         `json:"meanDelta"`
+	MinDelta         int64           `json:"minDelta"`
+	MaxDelta         int64           `json:"maxDelta"`
+	Distribution     []DeltaBucket   `json:"distribution"`
+	TopRetailers     []RetailerDelta `json:"topRetailers"`
+}
+
+type shadowJob struct {
+	id     string
+	stored storedReceipt
+	active *ruleSet
+}
+
+// shadow scores processed receipts under candidate rules in the background.
+type shadow struct {
+	rs    *ruleSet
+	queue chan shadowJob
+	stop  chan struct{}
+	since time.Time
+
+	mu        sync.Mutex
+	deltas    map[string]ShadowDelta // the latest shadowKeptDeltas
+	kept      []string               // IDs in deltas, a ring starting at next
+	next      int
+	summary   ShadowReport // counts and deltas over every receipt
+	skipped   int
+	buckets   []int
+	retailers map[string]*RetailerDelta
+}
+
+// SetShadowRules evaluates every receipt processed from now on under
+// candidate as well, without changing its points. Setting the rules already
+// in use keeps their results; nil stops shadow evaluation.
+func (p *InMemoryProcessor) SetShadowRules(candidate *Rules) error {
+	if candidate == nil {
+		if old := p.shadow.Swap(nil); old != nil {
+			close(old.stop)
+			metrics.ShadowPointsDelta.Reset()
+			logger.InfoLogger.Printf("Shadow evaluation of rules version %s stopped", old.rs.version)
+		}
+		return nil
+	}
+	if err := candidate.Validate(); err != nil {
+		return err
+	}
+	rules := candidate.clone()
+	version := rules.Version()
+	if current := p.shadow.Load(); current != nil && current.rs.version == version {
+		return nil
+	}
+
+	rs := newRuleSet(rules)
+	rs.version = version
+	s := &shadow{
+		rs:        rs,
+		queue:     make(chan shadowJob, shadowQueueSize),
+		stop:      make(chan struct{}),
+		since:     time.Now(),
+		deltas:    map[string]ShadowDelta{},
+		buckets:   make([]int, len(shadowBuckets)+1),
+		retailers: map[string]*RetailerDelta{},
+	}
+	go p.runShadow(s)
+	if old := p.shadow.Swap(s); old != nil {
+		close(old.stop)
+	}
+	metrics.ShadowPointsDelta.Reset()
+	logger.InfoLogger.Printf("Shadow evaluation of rules version %s started", version)
+	return nil
+}
+
+// enqueueShadow hands a processed receipt to the shadow evaluator, if any.
+func (p *InMemoryProcessor) enqueueShadow(id string, stored storedReceipt, active *ruleSet) {
+	s := p.shadow.Load()
+	if s == nil {
+		return
+	}
+	select {
+	case s.queue <- shadowJob{id: id, stored: stored, active: active}:
+	default:
+		s.mu.Lock()
+		s.skipped++
+		s.mu.Unlock()
+		metrics.ShadowEvaluationsTotal.WithLabelValues("skipped").Inc()
+	}
+}
+
+func (p *InMemoryProcessor) runShadow(s *shadow) {
+	for {
+		select {
+		case <-s.stop:
+			return
+		case job := <-s.queue:
+			r := job.stored
+			retailer, _ := job.active.forRetailer(r.receipt.Retailer)
+			active := r.score.Points
+			candidate := p.score(s.rs, r.receipt, r.tier, r.campaigns).Points
+			s.record(ShadowDelta{
+				ReceiptID:        job.id,
+				Retailer:         retailer,
+				ActiveVersion:    job.active.version,
+				CandidateVersion: s.rs.version,
+				ActivePoints:     active,
+				CandidatePoints:  candidate,
+				Delta:            candidate - active,
+			})
+		}
+	}
+}
+
+func (s *shadow) record(d ShadowDelta) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.kept) < shadowKeptDeltas {
+		s.kept = append(s.kept, d.ReceiptID)
+	} else {
+		delete(s.deltas, s.kept[s.next])
+		s.kept[s.next] = d.ReceiptID
+		s.next = (s.next + 1) % shadowKeptDeltas
+	}
+	s.deltas[d.ReceiptID] = d
+
+	sum := &s.summary
+	switch {
+	case d.Delta > 0:
+		sum.Increased++
+	case d.Delta < 0:
+		sum.Decreased++
+	default:
+		sum.Unchanged++
+	}
+	if sum.Evaluated == 0 || d.Delta < sum.MinDelta {
+		sum.MinDelta = d.Delta
+	}
+	if sum.Evaluated == 0 || d.Delta > sum.MaxDelta {
+		sum.MaxDelta = d.Delta
+	}
+	sum.Evaluated++
+	sum.TotalDelta += d.Delta
+	s.buckets[sort.Search(len(shadowBuckets), func(i int) bool { return d.Delta <= shadowBuckets[i] })]++
+	r, ok := s.retailers[d.Retailer]
+	if !ok {
+		r = &RetailerDelta{Retailer: d.Retailer}
+		s.retailers[d.Retailer] = r
+	}
+	r.Receipts++
+	r.TotalDelta += d.Delta
+
+	metrics.ShadowEvaluationsTotal.WithLabelValues("evaluated").Inc()
+	metrics.ShadowPointsDelta.WithLabelValues(d.CandidateVersion).Observe(float64(d.Delta))
+}
+
+// ShadowDelta returns the shadow evaluation of a receipt.
+func (p *InMemoryProcessor) ShadowDelta(id string) (ShadowDelta, error) {
+	s := p.shadow.Load()
+	if s == nil {
+		return ShadowDelta{}, ErrNoShadowDelta
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.deltas[id]
+	if !ok {
+		return ShadowDelta{}, ErrNoShadowDelta
+	}
+	return d, nil
+}
+
+// ShadowReport summarizes how the candidate rules would change payouts.
+func (p *InMemoryProcessor) ShadowReport() ShadowReport {
+	s := p.shadow.Load()
+	if s == nil {
+		return ShadowReport{Distribution: []DeltaBucket{}, TopRetailers: []RetailerDelta{}}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	since := s.since
+	report := s.summary
+	report.Enabled = true
+	report.CandidateVersion = s.rs.version
+	report.Since = &since
+	report.Skipped = s.skipped
+	if report.Evaluated > 0 {
+		report.MeanDelta = float64(report.TotalDelta) / float64(report.Evaluated)
+	}
+
+	for i, count := range s.buckets {
+		report.Distribution = append(report.Distribution, DeltaBucket{Range: bucketRange(i), Count: count})
+	}
+
+	report.TopRetailers = make([]RetailerDelta, 0, len(s.retailers))
+	for _, r := range s.retailers {
+		d := *r
+		d.MeanDelta = float64(d.TotalDelta) / float64(d.Receipts)
+		report.TopRetailers = append(report.TopRetailers, d)
+	}
+	sort.Slice(report.TopRetailers, func(i, j int) bool {
+		a, b := report.TopRetailers[i], report.TopRetailers[j]
+		if abs(a.TotalDelta) != abs(b.TotalDelta) {
+			return abs(a.TotalDelta) > abs(b.TotalDelta)
+		}
+		return a.Retailer < b.Retailer
+	})
+	if len(report.TopRetailers) > shadowTopRetailers {
+		report.TopRetailers = report.TopRetailers[:shadowTopRetailers]
+	}
+	return report
+}
+
+// bucketRange names the i'th delta bucket, such as "-9 to -1".
+func bucketRange(i int) string {
+	switch {
+	case i == 0:
+		return fmt.Sprintf("%d or less", shadowBuckets[0])
+	case i == len(shadowBuckets):
+		return fmt.Sprintf("%d or more", shadowBuckets[i-1]+1)
+	}
+	lo, hi := shadowBuckets[i-1]+1, shadowBuckets[i]
+	if lo == hi {
+		return strconv.FormatInt(lo, 10)
+	}
+	return fmt.Sprintf("%d to %d", lo, hi)
+}
@@ -0,0 +1,118 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestShadowEvaluation(t *testing.T) {
+	p := NewInMemoryProcessor(DefaultRules())
+	candidate := DefaultRules()
+	candidate.QuarterDollarPoints = 100
+	if err := p.SetShadowRules(&candidate); err != nil {
+		t.Fatalf("SetShadowRules() error = %v", err)
+	}
+
+	quarter, _ := p.ProcessReceipt(context.Background(), versionedReceipt)
+	other := versionedReceipt
+	other.Retailer = "Walmart"
+	other.Total = "1.10"
+	p.ProcessReceipt(context.Background(), other)
+	report := waitForShadow(t, p, 2)
+
+	// The candidate adds 75 to the quarter-dollar receipt only
+	if points, _ := p.GetPoints(quarter); points != 31 {
+		t.Errorf("GetPoints() = %d, want the active rules' 31", points)
+	}
+	delta, err := p.ShadowDelta(quarter)
+	if err != nil {
+		t.Fatalf("ShadowDelta() error = %v", err)
+	}
+	if delta.ActivePoints != 31 || delta.CandidatePoints != 106 || delta.Delta != 75 || delta.CandidateVersion != candidate.Version() {
+		t.Errorf("ShadowDelta() = %+v, want 31 -> 106", delta)
+	}
+
+	if report.Increased != 1 || report.Unchanged != 1 || report.TotalDelta != 75 || report.MeanDelta != 37.5 || report.MinDelta != 0 || report.MaxDelta != 75 {
+		t.Errorf("ShadowReport() = %+v, want one receipt up by 75 and one unchanged", report)
+	}
+	counts := map[string]int{}
+	for _, b := range report.Distribution {
+		counts[b.Range] = b.Count
+	}
+	if counts["0"] != 1 || counts["10 to 99"] != 1 || len(report.Distribution) != 7 {
+		t.Errorf("ShadowReport() distribution = %v", report.Distribution)
+	}
+	if len(report.TopRetailers) != 2 || report.TopRetailers[0].Retailer != "Target" || report.TopRetailers[0].MeanDelta != 75 {
+		t.Errorf("ShadowReport() top retailers = %+v, want Target first", report.TopRetailers)
+	}
+
+	// Setting the same candidate again keeps the results
+	same := DefaultRules()
+	same.QuarterDollarPoints = 100
+	p.SetShadowRules(&same)
+	if got := p.ShadowReport(); got.Evaluated != 2 {
+		t.Errorf("ShadowReport() after setting the same rules evaluated %d, want 2", got.Evaluated)
+	}
+
+	p.SetShadowRules(nil)
+	if got := p.ShadowReport(); got.Enabled {
+		t.Error("ShadowReport() enabled after stopping")
+	}
+	if _, err := p.ShadowDelta(quarter); !errors.Is(err, ErrNoShadowDelta) {
+		t.Errorf("ShadowDelta() after stopping error = %v, want ErrNoShadowDelta", err)
+	}
+
+	candidate.HappyHourEnd = 0
+	if err := p.SetShadowRules(&candidate); err == nil {
+		t.Error("SetShadowRules() with invalid rules succeeded")
+	}
+}
+
+func TestShadowKeepsRecentDeltas(t *testing.T) {
+	s := &shadow{rs: &ruleSet{}, deltas: map[string]ShadowDelta{}, buckets: make([]int, len(shadowBuckets)+1), retailers: map[string]*RetailerDelta{}}
+	n := shadowKeptDeltas + 5
+	for i := 0; i < n; i++ {
+		s.record(ShadowDelta{ReceiptID: fmt.Sprint(i), Retailer: "Target", Delta: int64(i % 3)})
+	}
+	p := NewInMemoryProcessor(DefaultRules())
+	p.shadow.Store(s)
+
+	if len(s.deltas) != shadowKeptDeltas {
+		t.Errorf("kept %d deltas, want %d", len(s.deltas), shadowKeptDeltas)
+	}
+	if _, err := p.ShadowDelta("0"); !errors.Is(err, ErrNoShadowDelta) {
+		t.Errorf("ShadowDelta() of the oldest receipt error = %v, want ErrNoShadowDelta", err)
+	}
+	if d, err := p.ShadowDelta(fmt.Sprint(n - 1)); err != nil || d.Delta != int64((n-1)%3) {
+		t.Errorf("ShadowDelta() of the latest receipt = %+v, %v", d, err)
+	}
+	report := p.ShadowReport()
+	if report.Evaluated != n || report.Unchanged+report.Increased != n || report.MinDelta != 0 || report.MaxDelta != 2 {
+		t.Errorf("ShadowReport() = %+v, want all %d receipts counted", report, n)
+	}
+}
+
+func TestBucketRange(t *testing.T) {
+	want := []string{"-100 or less", "-99 to -10", "-9 to -1", "0", "1 to 9", "10 to 99", "100 or more"}
+	for i, w := range want {
+		if got := bucketRange(i); got != w {
+			t.Errorf("bucketRange(%d) = %q, want %q", i, got, w)
+		}
+	}
+}
+
+func waitForShadow(t *testing.T, p *InMemoryProcessor, evaluated int) ShadowReport {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if report := p.ShadowReport(); report.Evaluated >= evaluated {
+			return report
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("shadow evaluation did not reach %d receipts", evaluated)
+	return ShadowReport{}
+}
@@ -0,0 +1,38 @@
+package processor
+
+import (
+	"fmt"
+
+	"github.com/suryamp/receipt-processor/models"
+	"github.com/suryamp/receipt-processor/tiers"
+)
+
+// CurrentRules returns a copy of the rules new receipts are scored under.
+func (p *InMemoryProcessor) CurrentRules() Rules {
+	return p.currentRules().rules.clone()
+}
+
+// Simulate scores receipt without storing it, under the candidate rules or,
+// if candidate is nil, the current rules, and with its user's current tier
+// and the current campaigns. Candidate custom rules are evaluated under the
+// current rules' expression limits.
+// It takes the same path as scoring a stored receipt. The receipt must
+// already be valid.
+func (p *InMemoryProcessor) Simulate(receipt models.Receipt, candidate *Rules) (Score, error) {
+	rs := p.currentRules()
+	if candidate != nil {
+		rules := candidate.clone()
+		// Custom rules run under the server's limits, whatever the candidate says
+		rules.ExpressionLimits = rs.rules.ExpressionLimits
+		if err := rules.Validate(); err != nil {
+			return Score{}, fmt.Errorf("invalid rules: %w", err)
+		}
+		rs = newRuleSet(rules)
+		rs.version = rules.Version()
+	}
+	var tier tiers.Tier
+	if receipt.UserID != "" && p.tiers != nil {
+		tier, _ = p.tiers.Current(receipt.UserID)
+	}
+	return p.score(rs, receipt, tier, p.campaigns()), nil
+}
@@ -0,0 +1,85 @@
+package processor
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/suryamp/receipt-processor/models"
+)
+
+func TestSimulate(t *testing.T) {
+	rules := DefaultRules()
+	rules.CustomRules = []CustomRule{{Name: "pepsi", Expression: `receipt.items.exists(i, i.description == "Pepsi") ? 3 : 0`}}
+	p := NewInMemoryProcessor(rules)
+
+	id, _ := p.ProcessReceipt(context.Background(), versionedReceipt)
+	stored, _ := p.GetScore(id)
+	simulated, err := p.Simulate(versionedReceipt, nil)
+	if err != nil {
+		t.Fatalf("Simulate() error = %v", err)
+	}
+	if !reflect.DeepEqual(simulated, stored) {
+		t.Errorf("Simulate() = %+v, want the stored score %+v", simulated, stored)
+	}
+
+	// 6 (retailer) + 25 (multiple of 0.25) + 3 (custom)
+	want := []models.RulePoints{
+		{Rule: RuleRetailerName, Points: 6},
+		{Rule: RuleRoundDollar},
+		{Rule: RuleQuarterDollar, Points: 25},
+		{Rule: RuleItemPairs},
+		{Rule: RuleItemDescription},
+		{Rule: RuleOddDay},
+		{Rule: RuleHappyHour},
+		{Rule: "pepsi", Points: 3},
+	}
+	if simulated.Points != 34 || !reflect.DeepEqual(simulated.Breakdown, want) {
+		t.Errorf("Simulate() = %d points %v, want 34 points %v", simulated.Points, simulated.Breakdown, want)
+	}
+
+	candidate := p.CurrentRules()
+	candidate.QuarterDollarPoints = 100
+	score, err := p.Simulate(versionedReceipt, &candidate)
+	if err != nil {
+		t.Fatalf("Simulate() with candidate error = %v", err)
+	}
+	if score.Points != 109 || score.RulesVersion != candidate.Version() {
+		t.Errorf("Simulate() with candidate = %d under %s, want 109 under %s", score.Points, score.RulesVersion, candidate.Version())
+	}
+	if len(p.RulesVersions()) != 1 {
+		t.Errorf("Simulate() with candidate registered a rules version")
+	}
+
+	candidate.HappyHourEnd = 0
+	if _, err := p.Simulate(versionedReceipt, &candidate); err == nil {
+		t.Error("Simulate() with invalid candidate succeeded")
+	}
+	count := 0
+	p.receipts.Range(func(_, _ any) bool { count++; return true })
+	if count != 1 {
+		t.Errorf("%d receipts stored, want only the processed one", count)
+	}
+}
+
+func TestSimulateKeepsExpressionLimits(t *testing.T) {
+	rules := DefaultRules()
+	rules.ExpressionLimits.MaxSteps = 50
+	p := NewInMemoryProcessor(rules)
+
+	candidate := p.CurrentRules()
+	candidate.CustomRules = []CustomRule{{Name: "runaway", Expression: "size(receipt.items.map(a, receipt.items.map(b, receipt.items.map(c, 1))))"}}
+	candidate.ExpressionLimits.MaxSteps = 1 << 30
+	receipt := versionedReceipt
+	receipt.Items = append(receipt.Items, receipt.Items[0], receipt.Items[0], receipt.Items[0])
+
+	score, err := p.Simulate(receipt, &candidate)
+	if err != nil {
+		t.Fatalf("Simulate() error = %v", err)
+	}
+	for _, rule := range score.Breakdown {
+		if rule.Rule == "runaway" && rule.Points != 0 {
+			t.Errorf("runaway rule scored %d points, want it stopped by the server's step limit", rule.Points)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ErrUnknownVersion is returned for a rules version the processor has never used.
+var ErrUnknownVersion = errors.New("unknown rules version")
+
+// Version returns a short content hash identifying the rules. Equal rules
+// have equal versions.
+func (r Rules) Version() string {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return "unknown"
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:6])
+}
+
+// clone returns a deep copy of r, so that a rule set cannot change under
+// the receipts pinned to it.
+func (r Rules) clone() Rules {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return r
+	}
+	var c Rules
+	if err := json.Unmarshal(b, &c); err != nil {
+		return r
+	}
+	return c
+}
+
+// RulesVersion describes a rule set the processor has scored with.
+type RulesVersion struct {
+	Version     string    `json:"version"`
+	ActivatedAt time.Time `json:"activatedAt"`
+	Current     bool      `json:"current"`
+	Receipts    int       `json:"receipts"`
+}
+
+// activate makes rules the current rule set, reusing the existing set if
+// these rules have been active before.
+func (p *InMemoryProcessor) activate(rules Rules) *ruleSet {
+	rules = rules.clone()
+	version := rules.Version()
+
+	p.versionsMu.Lock()
+	defer p.versionsMu.Unlock()
+	if p.versions == nil {
+		p.versions = map[string]*ruleSet{}
+	}
+	rs, ok := p.versions[version]
+	if !ok {
+		rs = newRuleSet(rules)
+		rs.version = version
+		rs.activatedAt = time.Now()
+		p.versions[version] = rs
+	}
+	p.rules.Store(rs)
+	return rs
+}
+
+// ruleSetFor returns the rule set with the given version.
+func (p *InMemoryProcessor) ruleSetFor(version string) (*ruleSet, error) {
+	p.versionsMu.RLock()
+	defer p.versionsMu.RUnlock()
+	rs, ok := p.versions[version]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownVersion, version)
+	}
+	return rs, nil
+}
+
+// Rules returns the rules with the given version.
+func (p *InMemoryProcessor) Rules(version string) (Rules, error) {
+	rs, err := p.ruleSetFor(version)
+	if err != nil {
+		return Rules{}, err
+	}
+	return rs.rules.clone(), nil
+}
+
+// RulesVersions lists every rule set the processor has used, oldest first,
+// with the number of receipts pinned to each.
+func (p *InMemoryProcessor) RulesVersions() []RulesVersion {
+	pinned := map[string]int{}
+	p.receipts.Range(func(_, value any) bool {
+		pinned[value.(storedReceipt).rulesVersion]++
+		return true
+	})
+	current := p.currentRules().version
+
+	p.versionsMu.RLock()
+	defer p.versionsMu.RUnlock()
+	list := make([]RulesVersion, 0, len(p.versions))
+	for version, rs := range p.versions {
+		list = append(list, RulesVersion{
+			Version:     version,
+			ActivatedAt: rs.activatedAt,
+			Current:     version == current,
+			Receipts:    pinned[version],
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ActivatedAt.Before(list[j].ActivatedAt)
+	})
+	return list
+}
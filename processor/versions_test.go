@@ -0,0 +1,268 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/suryamp/receipt-processor/ledger"
+	"github.com/suryamp/receipt-processor/models"
+	"github.com/suryamp/receipt-processor/users"
+)
+
+var versionedReceipt = models.Receipt{
+	Retailer:     "Target",
+	PurchaseDate: "2024-01-02",
+	PurchaseTime: "13:01",
+	Items:        []models.Item{{ShortDescription: "Pepsi", Price: "1.25"}},
+	Total:        "1.25",
+}
+
+func TestReceiptsArePinnedToRulesVersion(t *testing.T) {
+	p := NewInMemoryProcessor(DefaultRules())
+	original := p.currentRules().version
+	id, _ := p.ProcessReceipt(context.Background(), versionedReceipt)
+
+	rules := DefaultRules()
+	rules.QuarterDollarPoints = 100
+	if err := p.SetRules(rules); err != nil {
+		t.Fatalf("SetRules() error = %v", err)
+	}
+	later, _ := p.ProcessReceipt(context.Background(), versionedReceipt)
+
+	tests := []struct {
+		name    string
+		id      string
+		version string
+		want    int64
+		wantVer string
+	}{
+		// 6 (retailer) + 25 (multiple of 0.25)
+		{"pinned to original", id, "", 31, original},
+		{"evaluated under new rules", id, rules.Version(), 106, rules.Version()},
+		{"pinned to new", later, "", 106, rules.Version()},
+		{"evaluated under original rules", later, original, 31, original},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score, err := p.GetScoreAt(tt.id, tt.version)
+			if err != nil {
+				t.Fatalf("GetScoreAt() error = %v", err)
+			}
+			if score.Points != tt.want || score.RulesVersion != tt.wantVer {
+				t.Errorf("GetScoreAt() = %d under %s, want %d under %s", score.Points, score.RulesVersion, tt.want, tt.wantVer)
+			}
+		})
+	}
+
+	if _, err := p.GetScoreAt(id, "nope"); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("GetScoreAt() unknown version error = %v, want ErrUnknownVersion", err)
+	}
+	if _, err := p.GetScoreAt("nope", ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetScoreAt() unknown receipt error = %v, want ErrNotFound", err)
+	}
+
+	// Reactivating earlier rules reuses their version
+	if err := p.SetRules(DefaultRules()); err != nil {
+		t.Fatal(err)
+	}
+	versions := p.RulesVersions()
+	if len(versions) != 2 || !versions[0].Current || versions[0].Version != original || versions[0].Receipts != 1 {
+		t.Errorf("RulesVersions() = %+v, want the original current of two", versions)
+	}
+}
+
+func TestRulesVersionIsImmutable(t *testing.T) {
+	rules := DefaultRules()
+	rules.RetailerTimezones = map[string]string{"Target": "America/Chicago"}
+	p := NewInMemoryProcessor(rules)
+	version := rules.Version()
+
+	rules.RetailerTimezones["Target"] = "Asia/Tokyo"
+	got, err := p.Rules(version)
+	if err != nil {
+		t.Fatalf("Rules() error = %v", err)
+	}
+	if got.RetailerTimezones["Target"] != "America/Chicago" || got.Version() != version {
+		t.Errorf("Rules() = %v, changed after activation", got.RetailerTimezones)
+	}
+}
+
+func TestRescore(t *testing.T) {
+	p := NewInMemoryProcessor(DefaultRules())
+	original := p.currentRules().version
+	changed, _ := p.ProcessReceipt(context.Background(), versionedReceipt)
+	round := versionedReceipt
+	round.Total = "1.10"
+	unchanged, _ := p.ProcessReceipt(context.Background(), round)
+
+	rules := DefaultRules()
+	rules.QuarterDollarPoints = 100
+	p.SetRules(rules)
+
+	if _, err := p.StartRescore(RescoreRequest{RulesVersion: "nope"}); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("StartRescore() error = %v, want ErrUnknownVersion", err)
+	}
+
+	for _, dryRun := range []bool{true, false} {
+		started, err := p.StartRescore(RescoreRequest{DryRun: dryRun})
+		if err != nil {
+			t.Fatalf("StartRescore() error = %v", err)
+		}
+		job := waitForRescore(t, p, started.ID)
+
+		if job.Scanned != 2 || job.Changed != 1 || job.PointsDelta != 75 || len(job.Changes) != 1 {
+			t.Fatalf("rescore report = %+v, want 1 of 2 receipts changed by 75", job)
+		}
+		want := ScoreChange{ReceiptID: changed, FromVersion: original, OldPoints: 31, NewPoints: 106, Delta: 75}
+		if dryRun && job.Changes[0] != want {
+			t.Errorf("rescore change = %+v, want %+v", job.Changes[0], want)
+		}
+	}
+
+	// The real run pinned both receipts to the new rules
+	for _, id := range []string{changed, unchanged} {
+		if score, _ := p.GetScore(id); score.RulesVersion != rules.Version() {
+			t.Errorf("receipt %s pinned to %s, want %s", id, score.RulesVersion, rules.Version())
+		}
+	}
+	if _, err := p.Rescore("nope"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Rescore() error = %v, want ErrJobNotFound", err)
+	}
+}
+
+func TestRescoreAfterReversal(t *testing.T) {
+	l := ledger.New()
+	p := NewInMemoryProcessor(DefaultRules()).WithUsers(users.NewStore(l))
+	receipt := versionedReceipt
+	receipt.UserID = "alice"
+	reversed, _ := p.ProcessReceipt(context.Background(), receipt)
+	kept, _ := p.ProcessReceipt(context.Background(), receipt)
+
+	alice := ledger.UserAccount("alice")
+	entries, _ := l.Entries(alice, 0, 10)
+	for _, e := range entries {
+		if e.ReceiptID == reversed {
+			if _, err := l.Reverse(e.ID, "fraudulent receipt"); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	rules := DefaultRules()
+	rules.QuarterDollarPoints = 100
+	p.SetRules(rules)
+	started, err := p.StartRescore(RescoreRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForRescore(t, p, started.ID)
+
+	// Only the receipt still credited gains the 75 points; the reversed one stays at 0
+	if got := l.ReceiptPoints(alice, reversed); got != 0 {
+		t.Errorf("reversed receipt credited %d points after the rescore, want 0", got)
+	}
+	if got := l.ReceiptPoints(alice, kept); got != 106 {
+		t.Errorf("kept receipt credited %d points after the rescore, want 106", got)
+	}
+	if b := l.Balance(alice); b != 106 {
+		t.Errorf("balance = %d, want 106", b)
+	}
+}
+
+func TestReadsReturnCreditedScore(t *testing.T) {
+	p := NewInMemoryProcessor(DefaultRules())
+	id, _ := p.ProcessReceipt(context.Background(), versionedReceipt)
+
+	// Stand in for a custom rule that timed out when the receipt was scored:
+	// reads keep to what was credited rather than scoring it again
+	value, _ := p.receipts.Load(id)
+	stored := value.(storedReceipt)
+	stored.score.Points = 12
+	p.receipts.Store(id, stored)
+
+	if points, _ := p.GetPoints(id); points != 12 {
+		t.Errorf("GetPoints() = %d, want the 12 credited", points)
+	}
+	p.Export(ExportFilter{}, func(r ExportedReceipt) error {
+		if r.Points != 12 {
+			t.Errorf("exported %d points, want the 12 credited", r.Points)
+		}
+		return nil
+	})
+	if score, _ := p.GetScoreAt(id, stored.rulesVersion); score.Points != 31 {
+		t.Errorf("GetScoreAt() an explicit version = %d, want it scored again to 31", score.Points)
+	}
+}
+
+func TestRescoreSkipsFailedRules(t *testing.T) {
+	l := ledger.New()
+	p := NewInMemoryProcessor(DefaultRules()).WithUsers(users.NewStore(l))
+	original := p.currentRules().version
+	receipt := versionedReceipt
+	receipt.UserID = "alice"
+	receipt.Items = append(receipt.Items, receipt.Items[0], receipt.Items[0], receipt.Items[0])
+	receipt.Total = "5.00"
+	id, _ := p.ProcessReceipt(context.Background(), receipt)
+	before := l.Balance(ledger.UserAccount("alice"))
+
+	// The runaway rule exceeds the step limit, as a rule that times out would
+	rules := DefaultRules()
+	rules.QuarterDollarPoints = 100
+	rules.CustomRules = []CustomRule{{Name: "runaway", Expression: "size(receipt.items.map(a, receipt.items.map(b, receipt.items.map(c, 1))))"}}
+	rules.ExpressionLimits.MaxSteps = 50
+	if err := p.SetRules(rules); err != nil {
+		t.Fatal(err)
+	}
+	started, err := p.StartRescore(RescoreRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := waitForRescore(t, p, started.ID)
+
+	if job.Scanned != 1 || job.Failed != 1 || job.Changed != 0 || job.PointsDelta != 0 {
+		t.Errorf("rescore report = %+v, want the receipt failed and nothing changed", job)
+	}
+	if b := l.Balance(ledger.UserAccount("alice")); b != before {
+		t.Errorf("balance = %d after the rescore, want %d", b, before)
+	}
+	if score, _ := p.GetScore(id); score.RulesVersion != original {
+		t.Errorf("receipt pinned to %s, want it left on %s", score.RulesVersion, original)
+	}
+}
+
+func TestRescoreBoundsChanges(t *testing.T) {
+	p := NewInMemoryProcessor(DefaultRules())
+	n := 2*maxReportedChanges + 5
+	for i := 0; i < n; i++ {
+		p.ProcessReceipt(context.Background(), versionedReceipt)
+	}
+	rules := DefaultRules()
+	rules.QuarterDollarPoints = 100
+	p.SetRules(rules)
+
+	started, _ := p.StartRescore(RescoreRequest{DryRun: true})
+	job := waitForRescore(t, p, started.ID)
+	if job.Changed != n || job.PointsDelta != int64(75*n) || len(job.Changes) != maxReportedChanges || !job.Truncated {
+		t.Errorf("rescore changed %d by %d listing %d (truncated %t), want %d by %d listing %d, truncated",
+			job.Changed, job.PointsDelta, len(job.Changes), job.Truncated, n, 75*n, maxReportedChanges)
+	}
+}
+
+func waitForRescore(t *testing.T, p *InMemoryProcessor, id string) RescoreJob {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := p.Rescore(id)
+		if err != nil {
+			t.Fatalf("Rescore() error = %v", err)
+		}
+		if job.Status == RescoreDone {
+			return job
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("rescore %s did not finish", id)
+	return RescoreJob{}
+}
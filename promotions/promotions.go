@@ -0,0 +1,256 @@
+// Package promotions adds time-bounded bonus campaigns on top of the base
+// points of a receipt.
+//
+// A campaign matches a receipt purchased (on the store's local calendar)
+// between its start and end dates, from one of its retailers, containing an
+// item that mentions one of its keywords; empty lists match everything. A
+// matching campaign adds a multiple of the base points, a flat bonus, or
+// both, up to its own cap. Stackable campaigns add up; an exclusive campaign
+// never combines with another, and the receipt gets whichever of the two
+// options is worth more.
+package promotions
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/suryamp/receipt-processor/models"
+)
+
+const dateLayout = "2006-01-02"
+
+// ErrNotFound is returned for an unknown campaign ID.
+var ErrNotFound = errors.New("campaign not found")
+
+type Campaign struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	StartDate string   `json:"startDate"`
+	EndDate   string   `json:"endDate"` // inclusive
+	Retailers []string `json:"retailers,omitempty"`
+	Keywords  []string `json:"keywords,omitempty"`
+
+	// Multiplier scales the base points; 2 doubles them. Zero means none.
+	Multiplier float64 `json:"multiplier,omitempty"`
+	Bonus      int64   `json:"bonus,omitempty"`
+	MaxBonus   int64   `json:"maxBonus,omitempty"` // zero is uncapped
+	Exclusive  bool    `json:"exclusive,omitempty"`
+}
+
+// Validate reports whether c is a usable campaign.
+func (c Campaign) Validate() error {
+	if strings.TrimSpace(c.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	start, err := time.Parse(dateLayout, c.StartDate)
+	if err != nil {
+		return fmt.Errorf("startDate must be YYYY-MM-DD")
+	}
+	end, err := time.Parse(dateLayout, c.EndDate)
+	if err != nil {
+		return fmt.Errorf("endDate must be YYYY-MM-DD")
+	}
+	if end.Before(start) {
+		return fmt.Errorf("endDate must not be before startDate")
+	}
+	if c.Multiplier != 0 && c.Multiplier <= 1 {
+		return fmt.Errorf("multiplier must be greater than 1")
+	}
+	if c.Bonus < 0 || c.MaxBonus < 0 {
+		return fmt.Errorf("bonus and maxBonus must not be negative")
+	}
+	if c.Multiplier == 0 && c.Bonus == 0 {
+		return fmt.Errorf("campaign needs a multiplier or a bonus")
+	}
+	return nil
+}
+
+// matches reports whether the campaign applies to receipt, bought on the
+// local date purchased.
+func (c Campaign) matches(receipt models.Receipt, purchased time.Time) bool {
+	date := purchased.Format(dateLayout)
+	if date < c.StartDate || date > c.EndDate {
+		return false
+	}
+	if len(c.Retailers) > 0 && !containsFold(c.Retailers, receipt.Retailer) {
+		return false
+	}
+	if len(c.Keywords) == 0 {
+		return true
+	}
+	for _, item := range receipt.Items {
+		desc := strings.ToLower(item.ShortDescription)
+		for _, k := range c.Keywords {
+			if strings.Contains(desc, strings.ToLower(k)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// bonus returns the points the campaign adds to base.
+func (c Campaign) bonus(base int64) int64 {
+	points := c.Bonus
+	if c.Multiplier > 0 {
+		points += int64(math.Round(float64(base) * (c.Multiplier - 1)))
+	}
+	if c.MaxBonus > 0 && points > c.MaxBonus {
+		points = c.MaxBonus
+	}
+	return points
+}
+
+// Evaluate returns the bonus the campaigns add to a receipt worth base
+// points and which campaigns contributed. maxBonus caps the total bonus per
+// receipt; zero leaves it uncapped.
+func Evaluate(campaigns []Campaign, receipt models.Receipt, purchased time.Time, base, maxBonus int64) (int64, []models.AppliedPromotion) {
+	var stacked []models.AppliedPromotion
+	var stackedTotal int64
+	var best *models.AppliedPromotion
+
+	for _, c := range campaigns {
+		if !c.matches(receipt, purchased) {
+			continue
+		}
+		applied := models.AppliedPromotion{ID: c.ID, Name: c.Name, Bonus: c.bonus(base)}
+		if applied.Bonus == 0 {
+			continue
+		}
+		if c.Exclusive {
+			if best == nil || applied.Bonus > best.Bonus {
+				best = &applied
+			}
+			continue
+		}
+		stacked = append(stacked, applied)
+		stackedTotal += applied.Bonus
+	}
+
+	chosen := stacked
+	if best != nil && best.Bonus > stackedTotal {
+		chosen = []models.AppliedPromotion{*best}
+	}
+
+	var total int64
+	var out []models.AppliedPromotion
+	for _, a := range chosen {
+		if maxBonus > 0 && total+a.Bonus > maxBonus {
+			a.Bonus = maxBonus - total
+		}
+		if a.Bonus <= 0 {
+			break
+		}
+		total += a.Bonus
+		out = append(out, a)
+	}
+	return total, out
+}
+
+// Matching returns the campaigns that apply to receipt, bought on the local
+// date purchased, in the order given. Evaluating only these later gives the
+// same result as evaluating them all, however the campaigns change since.
+func Matching(campaigns []Campaign, receipt models.Receipt, purchased time.Time) []Campaign {
+	var out []Campaign
+	for _, c := range campaigns {
+		if c.matches(receipt, purchased) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Store holds campaigns in memory. It is safe for concurrent use.
+type Store struct {
+	mu        sync.RWMutex
+	campaigns map[string]Campaign
+}
+
+func NewStore() *Store {
+	return &Store{campaigns: map[string]Campaign{}}
+}
+
+// List returns all campaigns ordered by start date, then ID.
+func (s *Store) List() []Campaign {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]Campaign, 0, len(s.campaigns))
+	for _, c := range s.campaigns {
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].StartDate != out[j].StartDate {
+			return out[i].StartDate < out[j].StartDate
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+func (s *Store) Get(id string) (Campaign, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, ok := s.campaigns[id]
+	if !ok {
+		return Campaign{}, ErrNotFound
+	}
+	return c, nil
+}
+
+// Create validates c, assigns it a new ID and stores it.
+func (s *Store) Create(c Campaign) (Campaign, error) {
+	if err := c.Validate(); err != nil {
+		return Campaign{}, err
+	}
+	c.ID = uuid.New().String()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.campaigns[c.ID] = c
+	return c, nil
+}
+
+// Update replaces the campaign with the given ID.
+func (s *Store) Update(id string, c Campaign) (Campaign, error) {
+	if err := c.Validate(); err != nil {
+		return Campaign{}, err
+	}
+	c.ID = id
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.campaigns[id]; !ok {
+		return Campaign{}, ErrNotFound
+	}
+	s.campaigns[id] = c
+	return c, nil
+}
+
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.campaigns[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.campaigns, id)
+	return nil
+}
+
+func containsFold(list []string, s string) bool {
+	s = strings.TrimSpace(s)
+	for _, v := range list {
+		if strings.EqualFold(strings.TrimSpace(v), s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package promotions
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/suryamp/receipt-processor/models"
+)
+
+func TestEvaluate(t *testing.T) {
+	blackFriday := Campaign{ID: "bf", Name: "Black Friday", StartDate: "2024-11-25", EndDate: "2024-12-01", Retailers: []string{"Target"}, Multiplier: 2}
+	gatorade := Campaign{ID: "gat", Name: "Gatorade March", StartDate: "2024-03-01", EndDate: "2024-03-31", Keywords: []string{"gatorade"}, Bonus: 100}
+	springStack := Campaign{ID: "spring", Name: "Spring", StartDate: "2024-03-01", EndDate: "2024-03-31", Bonus: 20}
+	bigExclusive := Campaign{ID: "vip", Name: "VIP", StartDate: "2024-03-01", EndDate: "2024-03-31", Bonus: 500, Exclusive: true}
+	smallExclusive := Campaign{ID: "small", Name: "Small", StartDate: "2024-03-01", EndDate: "2024-03-31", Bonus: 50, Exclusive: true}
+	capped := Campaign{ID: "cap", Name: "Capped", StartDate: "2024-03-01", EndDate: "2024-03-31", Multiplier: 10, MaxBonus: 30}
+
+	target := models.Receipt{Retailer: "target", Items: []models.Item{{ShortDescription: "Pepsi"}}}
+	gatoradeReceipt := models.Receipt{Retailer: "Walgreens", Items: []models.Item{{ShortDescription: "Gatorade Lemon"}}}
+	day := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+
+	tests := []struct {
+		name      string
+		campaigns []Campaign
+		receipt   models.Receipt
+		purchased time.Time
+		maxBonus  int64
+		wantBonus int64
+		wantIDs   []string
+	}{
+		{"multiplier doubles base", []Campaign{blackFriday}, target, day("2024-11-29"), 0, 40, []string{"bf"}},
+		{"last day is inclusive", []Campaign{blackFriday}, target, day("2024-12-01"), 0, 40, []string{"bf"}},
+		{"outside window", []Campaign{blackFriday}, target, day("2024-12-02"), 0, 0, nil},
+		{"other retailer", []Campaign{blackFriday}, gatoradeReceipt, day("2024-11-29"), 0, 0, nil},
+		{"keyword matches item", []Campaign{gatorade}, gatoradeReceipt, day("2024-03-15"), 0, 100, []string{"gat"}},
+		{"keyword missing", []Campaign{gatorade}, target, day("2024-03-15"), 0, 0, nil},
+		{"stackable campaigns add up", []Campaign{gatorade, springStack}, gatoradeReceipt, day("2024-03-15"), 0, 120, []string{"gat", "spring"}},
+		{"better exclusive wins", []Campaign{gatorade, springStack, bigExclusive}, gatoradeReceipt, day("2024-03-15"), 0, 500, []string{"vip"}},
+		{"better stack beats exclusive", []Campaign{gatorade, springStack, smallExclusive}, gatoradeReceipt, day("2024-03-15"), 0, 120, []string{"gat", "spring"}},
+		{"campaign cap", []Campaign{capped}, target, day("2024-03-15"), 0, 30, []string{"cap"}},
+		{"receipt cap trims later campaigns", []Campaign{gatorade, springStack}, gatoradeReceipt, day("2024-03-15"), 110, 110, []string{"gat", "spring"}},
+		{"receipt cap drops exhausted campaigns", []Campaign{gatorade, springStack}, gatoradeReceipt, day("2024-03-15"), 100, 100, []string{"gat"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bonus, applied := Evaluate(tt.campaigns, tt.receipt, tt.purchased, 40, tt.maxBonus)
+			var ids []string
+			for _, a := range applied {
+				ids = append(ids, a.ID)
+			}
+			if bonus != tt.wantBonus || !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("Evaluate() = %d %v, want %d %v", bonus, ids, tt.wantBonus, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestCampaignValidate(t *testing.T) {
+	valid := Campaign{Name: "x", StartDate: "2024-01-01", EndDate: "2024-01-31", Bonus: 10}
+
+	tests := []struct {
+		name   string
+		modify func(c *Campaign)
+	}{
+		{"missing name", func(c *Campaign) { c.Name = " " }},
+		{"bad start date", func(c *Campaign) { c.StartDate = "01/01/2024" }},
+		{"end before start", func(c *Campaign) { c.EndDate = "2023-12-31" }},
+		{"multiplier not above one", func(c *Campaign) { c.Multiplier = 0.5 }},
+		{"negative cap", func(c *Campaign) { c.MaxBonus = -1 }},
+		{"no reward", func(c *Campaign) { c.Bonus = 0 }},
+	}
+
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("Validate() of valid campaign = %v", err)
+	}
+	for _, tt := range tests {
+		c := valid
+		tt.modify(&c)
+		if err := c.Validate(); err == nil {
+			t.Errorf("%s: Validate() = nil, want error", tt.name)
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	s := NewStore()
+	c, err := s.Create(Campaign{Name: "x", StartDate: "2024-01-01", EndDate: "2024-01-31", Bonus: 10})
+	if err != nil || c.ID == "" {
+		t.Fatalf("Create() = %+v, %v", c, err)
+	}
+
+	c.Bonus = 20
+	if _, err := s.Update(c.ID, c); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if got, _ := s.Get(c.ID); got.Bonus != 20 {
+		t.Errorf("Get() after update = %+v", got)
+	}
+	if _, err := s.Update("missing", c); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Update(missing) error = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(c.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := s.Get(c.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after delete error = %v, want ErrNotFound", err)
+	}
+	if len(s.List()) != 0 {
+		t.Errorf("List() after delete = %v", s.List())
+	}
+}
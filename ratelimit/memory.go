@@ -0,0 +1,99 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepEvery is how many operations pass between sweeps of stale counters.
+const sweepEvery = 1024
+
+type bucketState struct {
+	tokens float64
+	last   time.Time
+	limit  Limit
+}
+
+type quotaState struct {
+	day   time.Time
+	count int64
+}
+
+// MemoryStore is a Store for a single instance. Full buckets and quota
+// counters from previous days are swept periodically.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucketState
+	quotas  map[string]*quotaState
+	ops     int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*bucketState{}, quotas: map[string]*quotaState{}}
+}
+
+func (s *MemoryStore) Take(key string, limit Limit, now time.Time) Bucket {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maybeSweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucketState{tokens: float64(limit.Burst), last: now}
+		s.buckets[key] = b
+	}
+	b.limit = limit
+	b.tokens = refill(b, now)
+	b.last = now
+
+	if b.tokens < 1 {
+		return Bucket{Allowed: false, Tokens: b.tokens}
+	}
+	b.tokens--
+	return Bucket{Allowed: true, Tokens: b.tokens}
+}
+
+func (s *MemoryStore) Increment(key string, day time.Time, max int64) (int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q, ok := s.quotas[key]
+	if !ok || !q.day.Equal(day) {
+		q = &quotaState{day: day}
+		s.quotas[key] = q
+	}
+	if q.count >= max {
+		return q.count, false
+	}
+	q.count++
+	return q.count, true
+}
+
+func refill(b *bucketState, now time.Time) float64 {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed <= 0 {
+		return b.tokens
+	}
+	return math.Min(float64(b.limit.Burst), b.tokens+elapsed*b.limit.Rate)
+}
+
+// maybeSweep drops buckets that have refilled completely and quotas from
+// earlier days; the caller must hold s.mu.
+func (s *MemoryStore) maybeSweep(now time.Time) {
+	s.ops++
+	if s.ops%sweepEvery != 0 {
+		return
+	}
+	for key, b := range s.buckets {
+		if refill(b, now) >= float64(b.limit.Burst) {
+			delete(s.buckets, key)
+		}
+	}
+	today := now.UTC().Truncate(24 * time.Hour)
+	for key, q := range s.quotas {
+		if q.day.Before(today) {
+			delete(s.quotas, key)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+// Package ratelimit throttles callers with per-route token buckets and caps
+// daily submissions with quotas. Counters live in a Store so several
+// instances can share them; MemoryStore keeps them in process.
+package ratelimit
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// Limit is a token bucket refilled at Rate tokens per second up to Burst.
+type Limit struct {
+	Rate  float64 `yaml:"rate" json:"rate"`
+	Burst int     `yaml:"burst" json:"burst"`
+}
+
+// RouteLimit overrides the default Limit for Method requests to the mux path
+// template Path.
+type RouteLimit struct {
+	Method string `yaml:"method" json:"method"`
+	Path   string `yaml:"path" json:"path"`
+	Limit  `yaml:",inline"`
+}
+
+// Bucket is the state of one token bucket after a Take.
+type Bucket struct {
+	Allowed bool
+	Tokens  float64 // tokens left after this request
+}
+
+// Store holds bucket and quota counters.
+type Store interface {
+	// Take removes one token from the bucket key, refilling it according to limit first.
+	Take(key string, limit Limit, now time.Time) Bucket
+	// Increment adds one to the quota counter key for the day starting at day
+	// unless it already reached max, and returns the resulting count.
+	Increment(key string, day time.Time, max int64) (count int64, allowed bool)
+}
+
+// Config describes the limits enforced by a Limiter.
+type Config struct {
+	Default Limit
+	Routes  []RouteLimit
+	// DailyQuota caps requests per caller per UTC day on QuotaRoutes
+	// ("METHOD /path/template"). ClientQuotas overrides it per client, by
+	// the client's identity ID.
+	DailyQuota   int64
+	ClientQuotas map[string]int64
+	QuotaRoutes  []string
+}
+
+// Decision is the outcome of Allow, carrying what the RateLimit-* headers report.
+type Decision struct {
+	Allowed    bool
+	Reason     string // "rate" or "quota" when not allowed
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+const (
+	ReasonRate  = "rate"
+	ReasonQuota = "quota"
+)
+
+type Limiter struct {
+	cfg    Config
+	routes map[string]Limit
+	quota  map[string]bool
+	store  Store
+	now    func() time.Time
+}
+
+func NewLimiter(cfg Config, store Store) *Limiter {
+	l := &Limiter{cfg: cfg, routes: map[string]Limit{}, quota: map[string]bool{}, store: store, now: time.Now}
+	for _, r := range cfg.Routes {
+		l.routes[r.Method+" "+r.Path] = r.Limit
+	}
+	for _, r := range cfg.QuotaRoutes {
+		l.quota[r] = true
+	}
+	return l
+}
+
+const clientPrefix = "client:"
+
+// ClientCaller is the caller of requests by an authenticated client.
+func ClientCaller(id string) string {
+	return clientPrefix + id
+}
+
+// IPCaller is the caller of unauthenticated requests from an IP address.
+func IPCaller(ip string) string {
+	return "ip:" + ip
+}
+
+// Allow charges one request by caller, a ClientCaller or IPCaller, to
+// method and path (a mux path template).
+func (l *Limiter) Allow(caller, method, path string) Decision {
+	now := l.now()
+	route := method + " " + path
+	limit, ok := l.routes[route]
+	if !ok {
+		limit = l.cfg.Default
+	}
+
+	bucket := l.store.Take(caller+"|"+route, limit, now)
+	d := Decision{
+		Allowed:   bucket.Allowed,
+		Limit:     limit.Burst,
+		Remaining: int(math.Max(0, math.Floor(bucket.Tokens))),
+		Reset:     secondsToFill(float64(limit.Burst)-bucket.Tokens, limit.Rate),
+	}
+	if !bucket.Allowed {
+		d.Reason = ReasonRate
+		d.RetryAfter = secondsToFill(1-bucket.Tokens, limit.Rate)
+		return d
+	}
+
+	if !l.quota[route] {
+		return d
+	}
+	max := l.cfg.DailyQuota
+	if id, ok := strings.CutPrefix(caller, clientPrefix); ok {
+		if quota, ok := l.cfg.ClientQuotas[id]; ok {
+			max = quota
+		}
+	}
+	if max <= 0 {
+		return d
+	}
+	day := now.UTC().Truncate(24 * time.Hour)
+	if _, allowed := l.store.Increment(caller+"|quota", day, max); !allowed {
+		d.Allowed = false
+		d.Reason = ReasonQuota
+		d.Remaining = 0
+		d.RetryAfter = day.Add(24 * time.Hour).Sub(now)
+		d.Reset = d.RetryAfter
+	}
+	return d
+}
+
+func secondsToFill(tokens, rate float64) time.Duration {
+	if tokens <= 0 || rate <= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(tokens/rate)) * time.Second
+}
@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestLimiter(cfg Config) (*Limiter, *time.Time) {
+	now := time.Date(2024, 1, 1, 23, 59, 0, 0, time.UTC)
+	l := NewLimiter(cfg, NewMemoryStore())
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestTokenBucket(t *testing.T) {
+	l, now := newTestLimiter(Config{
+		Default: Limit{Rate: 1, Burst: 2},
+		Routes:  []RouteLimit{{Method: "POST", Path: "/receipts/process", Limit: Limit{Rate: 0.5, Burst: 1}}},
+	})
+
+	steps := []struct {
+		name          string
+		caller        string
+		method, path  string
+		advance       time.Duration
+		wantAllowed   bool
+		wantRemaining int
+	}{
+		{"first request uses burst", "a", "GET", "/receipts/{id}/points", 0, true, 1},
+		{"second request empties bucket", "a", "GET", "/receipts/{id}/points", 0, true, 0},
+		{"third request throttled", "a", "GET", "/receipts/{id}/points", 0, false, 0},
+		{"other caller has own bucket", "b", "GET", "/receipts/{id}/points", 0, true, 1},
+		{"route override has own bucket", "a", "POST", "/receipts/process", 0, true, 0},
+		{"route override throttles sooner", "a", "POST", "/receipts/process", time.Second, false, 0},
+		{"bucket refills over time", "a", "GET", "/receipts/{id}/points", time.Second, true, 1},
+	}
+
+	for _, s := range steps {
+		*now = now.Add(s.advance)
+		d := l.Allow(s.caller, s.method, s.path)
+		if d.Allowed != s.wantAllowed || d.Remaining != s.wantRemaining {
+			t.Errorf("%s: Allow() = allowed %v remaining %d, want %v %d", s.name, d.Allowed, d.Remaining, s.wantAllowed, s.wantRemaining)
+		}
+		if !d.Allowed && (d.Reason != ReasonRate || d.RetryAfter <= 0) {
+			t.Errorf("%s: Allow() = %+v, want rate reason with Retry-After", s.name, d)
+		}
+	}
+}
+
+func TestDailyQuota(t *testing.T) {
+	l, now := newTestLimiter(Config{
+		Default:      Limit{Rate: 100, Burst: 100},
+		DailyQuota:   2,
+		ClientQuotas: map[string]int64{"big": 3},
+		QuotaRoutes:  []string{"POST /receipts/process"},
+	})
+
+	allowed := func(caller string, n int) int {
+		count := 0
+		for i := 0; i < n; i++ {
+			if l.Allow(caller, "POST", "/receipts/process").Allowed {
+				count++
+			}
+		}
+		return count
+	}
+
+	small, big := ClientCaller("small"), ClientCaller("big")
+	if got := allowed(small, 5); got != 2 {
+		t.Errorf("default quota allowed %d, want 2", got)
+	}
+	if got := allowed(big, 5); got != 3 {
+		t.Errorf("client quota allowed %d, want 3", got)
+	}
+	if got := allowed(IPCaller("big"), 5); got != 2 {
+		t.Errorf("client quota applied to an IP, allowed %d, want 2", got)
+	}
+	if !l.Allow(small, "GET", "/receipts/{id}/points").Allowed {
+		t.Errorf("quota applied to a route outside quotaRoutes")
+	}
+
+	d := l.Allow(small, "POST", "/receipts/process")
+	if d.Reason != ReasonQuota || d.RetryAfter != time.Minute {
+		t.Errorf("Allow() over quota = %+v, want quota reason retrying at midnight", d)
+	}
+
+	*now = now.Add(time.Minute)
+	if got := allowed(small, 1); got != 1 {
+		t.Errorf("quota did not reset at midnight UTC")
+	}
+}
@@ -0,0 +1,103 @@
+// Package retailers resolves the retailer names printed on receipts to
+// canonical retailers, so "Walgreens", "WALGREENS #123" and configured
+// aliases such as "Walgreen Co" all refer to the same one.
+package retailers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// storeNumber matches tokens like "#123" or "0042" that identify a branch.
+	storeNumber = regexp.MustCompile(`^#?\d+$`)
+	// numberSign matches a spelled out "No. 17", rewritten to "#17".
+	numberSign = regexp.MustCompile(`\bNO\.?\s*(\d+)\b`)
+)
+
+// Normalize reduces a printed retailer name to the form names are compared
+// in: upper case, single spaces, and branch numbers dropped.
+func Normalize(name string) string {
+	var kept []string
+	name = numberSign.ReplaceAllString(strings.ToUpper(name), "#$1")
+	for _, token := range strings.Fields(name) {
+		if !storeNumber.MatchString(token) {
+			kept = append(kept, token)
+		}
+	}
+	return strings.Join(kept, " ")
+}
+
+// Entry is a canonical retailer name and the other names it appears under.
+// An alias ending in "*" matches any name starting with the rest of it.
+type Entry struct {
+	Name    string
+	Aliases []string
+}
+
+// Registry maps printed names to canonical names. The zero value knows no
+// retailers and resolves every name to itself.
+type Registry struct {
+	exact    map[string]string
+	prefixes []prefix
+}
+
+type prefix struct {
+	match, name string
+}
+
+// NewRegistry builds a registry from entries. A name or alias may belong to
+// only one retailer.
+func NewRegistry(entries []Entry) (*Registry, error) {
+	r := &Registry{exact: map[string]string{}}
+	owner := map[string]string{}
+	for _, e := range entries {
+		if strings.TrimSpace(e.Name) == "" {
+			return nil, fmt.Errorf("retailer name is required")
+		}
+		for _, alias := range append([]string{e.Name}, e.Aliases...) {
+			key := Normalize(strings.TrimSuffix(alias, "*"))
+			if key == "" {
+				return nil, fmt.Errorf("retailer %q has an empty alias", e.Name)
+			}
+			if strings.HasSuffix(alias, "*") {
+				key += "*"
+			}
+			if prev, ok := owner[key]; ok && prev != e.Name {
+				return nil, fmt.Errorf("%q is claimed by both %q and %q", alias, prev, e.Name)
+			}
+			owner[key] = e.Name
+
+			if match, ok := strings.CutSuffix(key, "*"); ok {
+				r.prefixes = append(r.prefixes, prefix{match: match, name: e.Name})
+			} else {
+				r.exact[key] = e.Name
+			}
+		}
+	}
+	return r, nil
+}
+
+// Canonical returns the canonical name for a printed retailer name, and
+// whether the registry knows it. Unknown names are returned trimmed.
+func (r *Registry) Canonical(name string) (string, bool) {
+	if r == nil {
+		return strings.TrimSpace(name), false
+	}
+	key := Normalize(name)
+	if canonical, ok := r.exact[key]; ok {
+		return canonical, true
+	}
+	// The longest matching prefix is the most specific one.
+	best, bestLen := "", -1
+	for _, p := range r.prefixes {
+		if strings.HasPrefix(key, p.match) && len(p.match) > bestLen {
+			best, bestLen = p.name, len(p.match)
+		}
+	}
+	if bestLen >= 0 {
+		return best, true
+	}
+	return strings.TrimSpace(name), false
+}
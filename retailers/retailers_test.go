@@ -0,0 +1,75 @@
+package retailers
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Walgreens", "WALGREENS"},
+		{"  WALGREENS   #123 ", "WALGREENS"},
+		{"Target Store 0042", "TARGET STORE"},
+		{"CVS No. 17", "CVS"},
+		{"7-Eleven", "7-ELEVEN"},
+		{"M&M Corner Market", "M&M CORNER MARKET"},
+	}
+
+	for _, tt := range tests {
+		if got := Normalize(tt.in); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r, err := NewRegistry([]Entry{
+		{Name: "Walgreens", Aliases: []string{"Walgreen Co", "WAG*"}},
+		{Name: "Target", Aliases: []string{"Target Store", "TGT*", "TGT EXPRESS*"}},
+	})
+	if err != nil {
+		t.Fatalf("NewRegistry() error = %v", err)
+	}
+
+	tests := []struct {
+		in        string
+		want      string
+		wantKnown bool
+	}{
+		{"Walgreens", "Walgreens", true},
+		{"WALGREENS #123", "Walgreens", true},
+		{"walgreen co", "Walgreens", true},
+		{"WAG 991 ELM ST", "Walgreens", true},
+		{"Target Store 0042", "Target", true},
+		{"TGT EXPRESS DOWNTOWN", "Target", true},
+		{" Kroger ", "Kroger", false},
+	}
+
+	for _, tt := range tests {
+		got, known := r.Canonical(tt.in)
+		if got != tt.want || known != tt.wantKnown {
+			t.Errorf("Canonical(%q) = %q %v, want %q %v", tt.in, got, known, tt.want, tt.wantKnown)
+		}
+	}
+
+	var empty *Registry
+	if got, known := empty.Canonical("Target #1"); got != "Target #1" || known {
+		t.Errorf("nil registry Canonical() = %q %v", got, known)
+	}
+}
+
+func TestNewRegistryErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []Entry
+	}{
+		{"missing name", []Entry{{Name: " "}}},
+		{"store number only alias", []Entry{{Name: "Target", Aliases: []string{"#12"}}}},
+		{"alias claimed twice", []Entry{{Name: "Target", Aliases: []string{"TGT"}}, {Name: "Tegut", Aliases: []string{"tgt"}}}},
+	}
+
+	for _, tt := range tests {
+		if _, err := NewRegistry(tt.entries); err == nil {
+			t.Errorf("%s: NewRegistry() error = nil", tt.name)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+// Package rewards is the catalog users spend their points on.
+//
+// A redemption debits the reward's cost from the user's ledger account and
+// takes one unit of inventory in a single step: both happen or neither does,
+// and a redemption the user cannot afford is refused even when many arrive
+// at once. Cancelling a redemption reverses its ledger entry and returns the
+// unit to inventory.
+package rewards
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/suryamp/receipt-processor/ledger"
+)
+
+var (
+	// ErrNotFound is returned for an unknown reward ID.
+	ErrNotFound = errors.New("reward not found")
+	// ErrRedemptionNotFound is returned for an unknown redemption ID, or one
+	// belonging to another user.
+	ErrRedemptionNotFound = errors.New("redemption not found")
+	// ErrUnavailable is returned when redeeming a reward outside its
+	// availability window.
+	ErrUnavailable = errors.New("reward is not available")
+	// ErrOutOfStock is returned when redeeming a reward with no inventory left.
+	ErrOutOfStock = errors.New("reward is out of stock")
+	// ErrCancelled is returned when cancelling a redemption a second time.
+	ErrCancelled = errors.New("redemption is already cancelled")
+)
+
+// Reward is an item in the catalog.
+type Reward struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Cost        int64  `json:"cost"`      // points
+	Inventory   int    `json:"inventory"` // units left to redeem
+
+	// The reward can be redeemed from AvailableFrom until, but not at,
+	// AvailableUntil. Nil leaves that end of the window open.
+	AvailableFrom  *time.Time `json:"availableFrom,omitempty"`
+	AvailableUntil *time.Time `json:"availableUntil,omitempty"`
+}
+
+// Validate reports whether r is a usable reward.
+func (r Reward) Validate() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if r.Cost <= 0 {
+		return fmt.Errorf("cost must be positive")
+	}
+	if r.Inventory < 0 {
+		return fmt.Errorf("inventory must not be negative")
+	}
+	if r.AvailableFrom != nil && r.AvailableUntil != nil && !r.AvailableUntil.After(*r.AvailableFrom) {
+		return fmt.Errorf("availableUntil must be after availableFrom")
+	}
+	return nil
+}
+
+// Available reports whether the reward can be redeemed at t, stock permitting.
+func (r Reward) Available(t time.Time) bool {
+	if r.AvailableFrom != nil && t.Before(*r.AvailableFrom) {
+		return false
+	}
+	return r.AvailableUntil == nil || t.Before(*r.AvailableUntil)
+}
+
+// Status is where a redemption stands.
+type Status string
+
+const (
+	StatusRedeemed  Status = "redeemed"
+	StatusCancelled Status = "cancelled"
+)
+
+// Redemption is a reward a user spent points on. EntryID is the ledger
+// entry that debited them and RefundEntryID the one that refunded them.
+type Redemption struct {
+	ID            string     `json:"id"`
+	UserID        string     `json:"userId"`
+	RewardID      string     `json:"rewardId"`
+	RewardName    string     `json:"rewardName"`
+	Cost          int64      `json:"cost"`
+	Status        Status     `json:"status"`
+	EntryID       string     `json:"entryId"`
+	RefundEntryID string     `json:"refundEntryId,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	CancelledAt   *time.Time `json:"cancelledAt,omitempty"`
+}
+
+// Store holds the catalog and redemptions in memory and spends points from
+// a ledger. It is safe for concurrent use.
+type Store struct {
+	mu          sync.Mutex
+	rewards     map[string]Reward
+	redemptions map[string]*Redemption
+	byUser      map[string][]string // redemption IDs in the order they were made
+	ledger      *ledger.Ledger
+	now         func() time.Time
+}
+
+// NewStore returns a store that spends points from l.
+func NewStore(l *ledger.Ledger) *Store {
+	return &Store{
+		rewards:     map[string]Reward{},
+		redemptions: map[string]*Redemption{},
+		byUser:      map[string][]string{},
+		ledger:      l,
+		now:         time.Now,
+	}
+}
+
+// List returns all rewards ordered by cost, then name.
+func (s *Store) List() []Reward {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Reward, 0, len(s.rewards))
+	for _, r := range s.rewards {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Cost != out[j].Cost {
+			return out[i].Cost < out[j].Cost
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// Available returns the rewards that can be redeemed now and are in stock.
+func (s *Store) Available() []Reward {
+	now := s.now()
+	var out []Reward
+	for _, r := range s.List() {
+		if r.Available(now) && r.Inventory > 0 {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func (s *Store) Get(id string) (Reward, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.rewards[id]
+	if !ok {
+		return Reward{}, ErrNotFound
+	}
+	return r, nil
+}
+
+// Create validates r, assigns it a new ID and stores it.
+func (s *Store) Create(r Reward) (Reward, error) {
+	if err := r.Validate(); err != nil {
+		return Reward{}, err
+	}
+	r.ID = uuid.New().String()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rewards[r.ID] = r
+	return r, nil
+}
+
+// Update replaces the reward with the given ID, including its inventory.
+func (s *Store) Update(id string, r Reward) (Reward, error) {
+	if err := r.Validate(); err != nil {
+		return Reward{}, err
+	}
+	r.ID = id
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.rewards[id]; !ok {
+		return Reward{}, ErrNotFound
+	}
+	s.rewards[id] = r
+	return r, nil
+}
+
+// Delete removes a reward from the catalog. Its redemptions are kept.
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.rewards[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.rewards, id)
+	return nil
+}
+
+// Redeem spends a user's points on one unit of a reward. It fails with
+// ledger.ErrInsufficientPoints if the user cannot afford it.
+func (s *Store) Redeem(userID, rewardID string) (Redemption, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reward, ok := s.rewards[rewardID]
+	if !ok {
+		return Redemption{}, ErrNotFound
+	}
+	now := s.now()
+	if !reward.Available(now) {
+		return Redemption{}, ErrUnavailable
+	}
+	if reward.Inventory == 0 {
+		return Redemption{}, ErrOutOfStock
+	}
+
+	redemption := &Redemption{
+		ID:         uuid.New().String(),
+		UserID:     userID,
+		RewardID:   reward.ID,
+		RewardName: reward.Name,
+		Cost:       reward.Cost,
+		Status:     StatusRedeemed,
+		CreatedAt:  now,
+	}
+	debit := ledger.Transfer(ledger.ReasonRedemption, ledger.UserAccount(userID), ledger.AccountRedeemed, reward.Cost)
+	debit.Reference = redemption.ID
+	debit.Memo = reward.Name
+	entry, err := s.ledger.PostCovered(debit)
+	if err != nil {
+		return Redemption{}, err
+	}
+
+	redemption.EntryID = entry.ID
+	reward.Inventory--
+	s.rewards[reward.ID] = reward
+	s.redemptions[redemption.ID] = redemption
+	s.byUser[userID] = append(s.byUser[userID], redemption.ID)
+	return *redemption, nil
+}
+
+// Cancel refunds one of a user's redemptions and returns its unit to
+// inventory if the reward is still in the catalog.
+func (s *Store) Cancel(userID, redemptionID string) (Redemption, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	redemption, ok := s.redemptions[redemptionID]
+	if !ok || redemption.UserID != userID {
+		return Redemption{}, ErrRedemptionNotFound
+	}
+	if redemption.Status == StatusCancelled {
+		return Redemption{}, ErrCancelled
+	}
+
+	refund, err := s.ledger.Refund(redemption.EntryID, "redemption cancelled")
+	if err != nil {
+		return Redemption{}, fmt.Errorf("refunding redemption %s: %w", redemptionID, err)
+	}
+	now := s.now()
+	redemption.Status = StatusCancelled
+	redemption.RefundEntryID = refund.ID
+	redemption.CancelledAt = &now
+	if reward, ok := s.rewards[redemption.RewardID]; ok {
+		reward.Inventory++
+		s.rewards[reward.ID] = reward
+	}
+	return *redemption, nil
+}
+
+// Redemptions returns up to limit of a user's redemptions, newest first,
+// after skipping offset of them, and how many the user has made in all.
+func (s *Store) Redemptions(userID string, offset, limit int) ([]Redemption, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := s.byUser[userID]
+	total := len(ids)
+	page := []Redemption{}
+	for i := total - 1 - offset; i >= 0 && len(page) < limit; i-- {
+		page = append(page, *s.redemptions[ids[i]])
+	}
+	return page, total
+}
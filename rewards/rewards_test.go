@@ -0,0 +1,245 @@
+package rewards
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/suryamp/receipt-processor/ledger"
+)
+
+// fund credits a user points as if they had submitted a receipt.
+func fund(t *testing.T, l *ledger.Ledger, userID string, points int64) {
+	t.Helper()
+	credit := ledger.Transfer(ledger.ReasonReceipt, ledger.AccountIssued, ledger.UserAccount(userID), points)
+	credit.ReceiptID = "receipt-" + userID
+	if _, err := l.Post(credit); err != nil {
+		t.Fatalf("funding %s: %v", userID, err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	from := time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC)
+	until := from.Add(24 * time.Hour)
+
+	tests := []struct {
+		name    string
+		reward  Reward
+		wantErr bool
+	}{
+		{"valid", Reward{Name: "Mug", Cost: 500, Inventory: 10}, false},
+		{"with window", Reward{Name: "Mug", Cost: 500, AvailableFrom: &from, AvailableUntil: &until}, false},
+		{"no name", Reward{Name: " ", Cost: 500}, true},
+		{"free", Reward{Name: "Mug"}, true},
+		{"negative inventory", Reward{Name: "Mug", Cost: 500, Inventory: -1}, true},
+		{"window ends first", Reward{Name: "Mug", Cost: 500, AvailableFrom: &until, AvailableUntil: &from}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.reward.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRedeem(t *testing.T) {
+	l := ledger.New()
+	s := NewStore(l)
+	now := time.Date(2024, 11, 15, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	fund(t, l, "alice", 1000)
+
+	later := now.Add(time.Hour)
+	mug, _ := s.Create(Reward{Name: "Mug", Cost: 400, Inventory: 1})
+	hat, _ := s.Create(Reward{Name: "Hat", Cost: 100, Inventory: 5, AvailableFrom: &later})
+	if available := s.Available(); len(available) != 1 || available[0].ID != mug.ID {
+		t.Errorf("Available() = %+v, want only the mug", available)
+	}
+
+	redemption, err := s.Redeem("alice", mug.ID)
+	if err != nil {
+		t.Fatalf("Redeem() error = %v", err)
+	}
+	if redemption.Status != StatusRedeemed || redemption.Cost != 400 || redemption.EntryID == "" {
+		t.Errorf("Redeem() = %+v, want a redeemed 400 point mug", redemption)
+	}
+	if b := l.Balance(ledger.UserAccount("alice")); b != 600 {
+		t.Errorf("balance after redeeming = %d, want 600", b)
+	}
+	if entry, _ := l.Get(redemption.EntryID); entry.Reason != ledger.ReasonRedemption || entry.Reference != redemption.ID {
+		t.Errorf("redemption entry = %+v, want a redemption referring to %s", entry, redemption.ID)
+	}
+
+	if _, err := s.Redeem("alice", mug.ID); !errors.Is(err, ErrOutOfStock) {
+		t.Errorf("Redeem() with no stock error = %v, want ErrOutOfStock", err)
+	}
+	if _, err := s.Redeem("alice", hat.ID); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Redeem() before the window error = %v, want ErrUnavailable", err)
+	}
+	if _, err := s.Redeem("alice", "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Redeem() unknown reward error = %v, want ErrNotFound", err)
+	}
+	now = later
+	if _, err := s.Redeem("bob", hat.ID); !errors.Is(err, ledger.ErrInsufficientPoints) {
+		t.Errorf("Redeem() by a user without points error = %v, want ErrInsufficientPoints", err)
+	}
+
+	if _, err := s.Cancel("bob", redemption.ID); !errors.Is(err, ErrRedemptionNotFound) {
+		t.Errorf("Cancel() of another user's redemption error = %v, want ErrRedemptionNotFound", err)
+	}
+	cancelled, err := s.Cancel("alice", redemption.ID)
+	if err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+	if cancelled.Status != StatusCancelled || cancelled.RefundEntryID == "" || cancelled.CancelledAt == nil {
+		t.Errorf("Cancel() = %+v, want a refunded cancellation", cancelled)
+	}
+	if b := l.Balance(ledger.UserAccount("alice")); b != 1000 {
+		t.Errorf("balance after cancelling = %d, want 1000", b)
+	}
+	if r, _ := s.Get(mug.ID); r.Inventory != 1 {
+		t.Errorf("inventory after cancelling = %d, want 1", r.Inventory)
+	}
+	if _, err := s.Cancel("alice", redemption.ID); !errors.Is(err, ErrCancelled) {
+		t.Errorf("Cancel() twice error = %v, want ErrCancelled", err)
+	}
+
+	if page, total := s.Redemptions("alice", 0, 10); total != 1 || page[0].Status != StatusCancelled {
+		t.Errorf("Redemptions() = %+v of %d, want the cancelled mug", page, total)
+	}
+}
+
+// TestRedeemConcurrent races many redemptions against a balance that covers
+// only some of them: exactly those succeed and the user never goes negative.
+func TestRedeemConcurrent(t *testing.T) {
+	l := ledger.New()
+	s := NewStore(l)
+	fund(t, l, "alice", 1000)
+	reward, _ := s.Create(Reward{Name: "Sticker", Cost: 30, Inventory: 1000})
+
+	var redeemed, refused atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.Redeem("alice", reward.ID)
+			switch {
+			case err == nil:
+				redeemed.Add(1)
+			case errors.Is(err, ledger.ErrInsufficientPoints):
+				refused.Add(1)
+			default:
+				t.Errorf("Redeem() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 1000 / 30 = 33 stickers, with 10 points left over
+	if redeemed.Load() != 33 || refused.Load() != 167 {
+		t.Errorf("%d redeemed and %d refused, want 33 and 167", redeemed.Load(), refused.Load())
+	}
+	if b := l.Balance(ledger.UserAccount("alice")); b != 10 {
+		t.Errorf("balance = %d, want 10", b)
+	}
+	if r, _ := s.Get(reward.ID); r.Inventory != 1000-33 {
+		t.Errorf("inventory = %d, want %d", r.Inventory, 1000-33)
+	}
+	if r := l.Reconcile(); !r.OK() {
+		t.Errorf("Reconcile() = %+v, want no mismatches", r)
+	}
+}
+
+// TestRedeemConcurrentStock races many users who can all afford a reward
+// for fewer units than there are users.
+func TestRedeemConcurrentStock(t *testing.T) {
+	l := ledger.New()
+	s := NewStore(l)
+	reward, _ := s.Create(Reward{Name: "Headphones", Cost: 100, Inventory: 7})
+	const users = 50
+	for i := 0; i < users; i++ {
+		fund(t, l, "user"+strconv.Itoa(i), 100)
+	}
+
+	var redeemed, outOfStock atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < users; i++ {
+		wg.Add(1)
+		go func(userID string) {
+			defer wg.Done()
+			_, err := s.Redeem(userID, reward.ID)
+			switch {
+			case err == nil:
+				redeemed.Add(1)
+			case errors.Is(err, ErrOutOfStock):
+				outOfStock.Add(1)
+			default:
+				t.Errorf("Redeem() error = %v", err)
+			}
+		}("user" + strconv.Itoa(i))
+	}
+	wg.Wait()
+
+	if redeemed.Load() != 7 || outOfStock.Load() != users-7 {
+		t.Errorf("%d redeemed and %d out of stock, want 7 and %d", redeemed.Load(), outOfStock.Load(), users-7)
+	}
+	if b := l.Balance(ledger.AccountRedeemed); b != 700 {
+		t.Errorf("redeemed account balance = %d, want 700", b)
+	}
+	if r, _ := s.Get(reward.ID); r.Inventory != 0 {
+		t.Errorf("inventory = %d, want 0", r.Inventory)
+	}
+}
+
+// TestRedeemCancelConcurrent redeems and cancels in parallel; every unit
+// and point must be accounted for once the dust settles.
+func TestRedeemCancelConcurrent(t *testing.T) {
+	l := ledger.New()
+	s := NewStore(l)
+	fund(t, l, "alice", 500)
+	reward, _ := s.Create(Reward{Name: "Coffee", Cost: 50, Inventory: 5})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				redemption, err := s.Redeem("alice", reward.ID)
+				if err != nil {
+					continue
+				}
+				if j%2 == 0 {
+					if _, err := s.Cancel("alice", redemption.ID); err != nil {
+						t.Errorf("Cancel() error = %v", err)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	page, _ := s.Redemptions("alice", 0, math.MaxInt)
+	var open int
+	for _, r := range page {
+		if r.Status == StatusRedeemed {
+			open++
+		}
+	}
+	if r, _ := s.Get(reward.ID); r.Inventory != 5-open {
+		t.Errorf("inventory = %d with %d open redemptions, want %d", r.Inventory, open, 5-open)
+	}
+	if b := l.Balance(ledger.UserAccount("alice")); b != 500-int64(open)*50 || b < 0 {
+		t.Errorf("balance = %d with %d open redemptions, want %d", b, open, 500-open*50)
+	}
+	if r := l.Reconcile(); !r.OK() {
+		t.Errorf("Reconcile() = %+v, want no mismatches", r)
+	}
+}
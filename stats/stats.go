@@ -0,0 +1,237 @@
+// Package stats summarizes stored receipts by retailer and purchase date.
+//
+// Receipts are rolled up as they are processed into one cell per retailer
+// and purchase date, so a query reads only the cells in its date range
+// rather than every receipt.
+package stats
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrInvalidQuery is returned for a query with a bad range or grouping.
+var ErrInvalidQuery = errors.New("invalid stats query")
+
+// Grouping is what a report's groups are.
+type Grouping string
+
+const (
+	ByNone     Grouping = ""
+	ByRetailer Grouping = "retailer"
+	ByDay      Grouping = "day"
+	ByWeek     Grouping = "week" // starting Monday
+	ByMonth    Grouping = "month"
+)
+
+// cell is the totals of the receipts from one retailer on one date.
+type cell struct {
+	receipts int64
+	points   int64
+	items    int64
+	spend    int64 // cents
+}
+
+func (c *cell) add(o *cell) {
+	c.receipts += o.receipts
+	c.points += o.points
+	c.items += o.items
+	c.spend += o.spend
+}
+
+// Summary is the totals and averages of a set of receipts. Spend is the
+// sum of their totals; AverageItems and AverageTotal are the basket size.
+type Summary struct {
+	Receipts      int64   `json:"receipts"`
+	Points        int64   `json:"points"`
+	AveragePoints float64 `json:"averagePoints"`
+	Items         int64   `json:"items"`
+	AverageItems  float64 `json:"averageItems"`
+	Spend         string  `json:"spend"`
+	AverageTotal  string  `json:"averageTotal"`
+}
+
+func (c cell) summary() Summary {
+	s := Summary{Receipts: c.receipts, Points: c.points, Items: c.items, Spend: dollars(float64(c.spend)), AverageTotal: "0.00"}
+	if c.receipts > 0 {
+		n := float64(c.receipts)
+		s.AveragePoints = round2(float64(c.points) / n)
+		s.AverageItems = round2(float64(c.items) / n)
+		s.AverageTotal = dollars(float64(c.spend) / n)
+	}
+	return s
+}
+
+func dollars(cents float64) string {
+	return fmt.Sprintf("%.2f", math.Round(cents)/100)
+}
+
+func round2(f float64) float64 {
+	return math.Round(f*100) / 100
+}
+
+// Group is the summary of the receipts sharing a key: a retailer's
+// canonical name, a date, the Monday starting a week, or a month
+// ("2024-01").
+type Group struct {
+	Key string `json:"key"`
+	Summary
+}
+
+// Report is the answer to a query.
+type Report struct {
+	From     string   `json:"from,omitempty"`
+	To       string   `json:"to,omitempty"`
+	Retailer string   `json:"retailer,omitempty"`
+	GroupBy  Grouping `json:"groupBy,omitempty"`
+	Total    Summary  `json:"total"`
+	Groups   []Group  `json:"groups,omitempty"`
+}
+
+// Query selects receipts by purchase date, From to To inclusive, each
+// "2006-01-02" or empty for no bound, and optionally by retailer.
+type Query struct {
+	From     string
+	To       string
+	Retailer string
+	GroupBy  Grouping
+}
+
+func (q Query) validate() error {
+	for _, d := range []string{q.From, q.To} {
+		if _, err := time.Parse(time.DateOnly, d); d != "" && err != nil {
+			return fmt.Errorf("%w: %q is not a YYYY-MM-DD date", ErrInvalidQuery, d)
+		}
+	}
+	if q.From != "" && q.To != "" && q.From > q.To {
+		return fmt.Errorf("%w: from is after to", ErrInvalidQuery)
+	}
+	switch q.GroupBy {
+	case ByNone, ByRetailer, ByDay, ByWeek, ByMonth:
+		return nil
+	}
+	return fmt.Errorf("%w: cannot group by %q", ErrInvalidQuery, q.GroupBy)
+}
+
+// key returns the group a cell for retailer on date belongs to.
+func (q Query) key(date, retailer string) string {
+	if q.GroupBy == ByRetailer {
+		return retailer
+	}
+	return q.GroupBy.Period(date)
+}
+
+// Period returns the day, week or month a "2006-01-02" date falls in: the
+// date itself, the Monday starting its week, or "2006-01".
+func (g Grouping) Period(date string) string {
+	switch g {
+	case ByWeek:
+		d, err := time.Parse(time.DateOnly, date)
+		if err != nil {
+			return date
+		}
+		return d.AddDate(0, 0, -((int(d.Weekday()) + 6) % 7)).Format(time.DateOnly)
+	case ByMonth:
+		if len(date) >= 7 {
+			return date[:7]
+		}
+	}
+	return date
+}
+
+// Rollup keeps the receipt totals by purchase date and retailer. It is safe
+// for concurrent use.
+type Rollup struct {
+	mu    sync.RWMutex
+	cells map[string]map[string]*cell // by date, then retailer
+	dates []string                    // the keys of cells, in order
+}
+
+func NewRollup() *Rollup {
+	return &Rollup{cells: map[string]map[string]*cell{}}
+}
+
+// cell returns the cell for retailer on date, creating it if needed. The
+// caller holds mu.
+func (r *Rollup) cell(date, retailer string) *cell {
+	byRetailer, ok := r.cells[date]
+	if !ok {
+		byRetailer = map[string]*cell{}
+		r.cells[date] = byRetailer
+		i := sort.SearchStrings(r.dates, date)
+		r.dates = append(r.dates, "")
+		copy(r.dates[i+1:], r.dates[i:])
+		r.dates[i] = date
+	}
+	c, ok := byRetailer[retailer]
+	if !ok {
+		c = &cell{}
+		byRetailer[retailer] = c
+	}
+	return c
+}
+
+// Add rolls up a receipt. date is its purchase date and total its total in
+// dollars, as on the receipt.
+func (r *Rollup) Add(date, retailer, total string, items int, points int64) {
+	amount, _ := strconv.ParseFloat(total, 64)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cell(date, retailer).add(&cell{receipts: 1, points: points, items: int64(items), spend: int64(math.Round(amount * 100))})
+}
+
+// AddPoints moves the points of a receipt already rolled up by delta, as
+// when it is rescored.
+func (r *Rollup) AddPoints(date, retailer string, delta int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cell(date, retailer).points += delta
+}
+
+// Query summarizes the receipts q selects, in all and by q.GroupBy. Groups
+// are in key order.
+func (r *Rollup) Query(q Query) (Report, error) {
+	if err := q.validate(); err != nil {
+		return Report{}, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	first := sort.SearchStrings(r.dates, q.From)
+	var total cell
+	groups := map[string]*cell{}
+	for _, date := range r.dates[first:] {
+		if q.To != "" && date > q.To {
+			break
+		}
+		for retailer, c := range r.cells[date] {
+			if q.Retailer != "" && retailer != q.Retailer {
+				continue
+			}
+			total.add(c)
+			if q.GroupBy == ByNone {
+				continue
+			}
+			key := q.key(date, retailer)
+			if groups[key] == nil {
+				groups[key] = &cell{}
+			}
+			groups[key].add(c)
+		}
+	}
+
+	report := Report{From: q.From, To: q.To, Retailer: q.Retailer, GroupBy: q.GroupBy, Total: total.summary()}
+	if q.GroupBy != ByNone {
+		report.Groups = make([]Group, 0, len(groups))
+		for key, c := range groups {
+			report.Groups = append(report.Groups, Group{Key: key, Summary: c.summary()})
+		}
+		sort.Slice(report.Groups, func(i, j int) bool { return report.Groups[i].Key < report.Groups[j].Key })
+	}
+	return report, nil
+}
@@ -0,0 +1,119 @@
+package stats
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestQuery(t *testing.T) {
+	r := NewRollup()
+	r.Add("2024-01-01", "Target", "10.00", 2, 100) // a Monday
+	r.Add("2024-01-01", "Walgreens", "5.50", 1, 20)
+	r.Add("2024-01-07", "Target", "4.25", 3, 40) // a Sunday
+	r.Add("2024-01-08", "Target", "0.25", 1, 31)
+	r.Add("2024-02-01", "Walgreens", "1.00", 1, 9)
+	r.AddPoints("2024-01-08", "Target", 10)
+
+	tests := []struct {
+		name  string
+		query Query
+		want  string
+	}{
+		{"total", Query{}, "5 receipts, 210 points, 8 items, 21.00 spent"},
+		{"range", Query{From: "2024-01-02", To: "2024-01-31"}, "2 receipts, 81 points, 4 items, 4.50 spent"},
+		{"retailer", Query{Retailer: "Walgreens"}, "2 receipts, 29 points, 2 items, 6.50 spent"},
+		{"by retailer", Query{GroupBy: ByRetailer}, "[Target: 3 receipts, 181 points Walgreens: 2 receipts, 29 points]"},
+		{"by week", Query{GroupBy: ByWeek, To: "2024-01-31"}, "[2024-01-01: 3 receipts, 160 points 2024-01-08: 1 receipts, 41 points]"},
+		{"by month", Query{GroupBy: ByMonth}, "[2024-01: 4 receipts, 201 points 2024-02: 1 receipts, 9 points]"},
+		{"by day", Query{GroupBy: ByDay, From: "2024-01-07"}, "[2024-01-07: 1 receipts, 40 points 2024-01-08: 1 receipts, 41 points 2024-02-01: 1 receipts, 9 points]"},
+		{"empty range", Query{From: "2023-01-01", To: "2023-12-31"}, "0 receipts, 0 points, 0 items, 0.00 spent"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := r.Query(tt.query)
+			if err != nil {
+				t.Fatalf("Query() error = %v", err)
+			}
+			got := fmt.Sprintf("%d receipts, %d points, %d items, %s spent", report.Total.Receipts, report.Total.Points, report.Total.Items, report.Total.Spend)
+			if tt.query.GroupBy != ByNone {
+				var groups []string
+				for _, g := range report.Groups {
+					groups = append(groups, fmt.Sprintf("%s: %d receipts, %d points", g.Key, g.Receipts, g.Points))
+				}
+				got = fmt.Sprint(groups)
+			}
+			if got != tt.want {
+				t.Errorf("Query() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	report, _ := r.Query(Query{Retailer: "Target"})
+	if s := report.Total; s.AveragePoints != 60.33 || s.AverageItems != 2 || s.AverageTotal != "4.83" {
+		t.Errorf("Target averages = %+v, want 60.33 points, 2 items and 4.83 per receipt", s)
+	}
+}
+
+func TestQueryInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		query Query
+	}{
+		{"bad from", Query{From: "01/02/2024"}},
+		{"bad to", Query{To: "2024-13-01"}},
+		{"reversed", Query{From: "2024-02-01", To: "2024-01-01"}},
+		{"bad grouping", Query{GroupBy: "year"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRollup().Query(tt.query); !errors.Is(err, ErrInvalidQuery) {
+				t.Errorf("Query() error = %v, want ErrInvalidQuery", err)
+			}
+		})
+	}
+}
+
+// TestRollupMatchesReceipts checks the rollup against summing the receipts
+// one by one.
+func TestRollupMatchesReceipts(t *testing.T) {
+	type receipt struct {
+		date, retailer string
+		cents          int64
+		items          int
+		points         int64
+	}
+	rng := rand.New(rand.NewSource(1))
+	start, _ := time.Parse(time.DateOnly, "2024-01-01")
+	retailers := []string{"Target", "Walgreens", "M&M Corner Market"}
+	var receipts []receipt
+	r := NewRollup()
+	for i := 0; i < 1000; i++ {
+		rc := receipt{
+			date:     start.AddDate(0, 0, rng.Intn(120)).Format(time.DateOnly),
+			retailer: retailers[rng.Intn(len(retailers))],
+			cents:    int64(rng.Intn(10000)),
+			items:    1 + rng.Intn(5),
+			points:   int64(rng.Intn(200)),
+		}
+		receipts = append(receipts, rc)
+		r.Add(rc.date, rc.retailer, fmt.Sprintf("%d.%02d", rc.cents/100, rc.cents%100), rc.items, rc.points)
+	}
+
+	q := Query{From: "2024-02-10", To: "2024-03-20", Retailer: "Target"}
+	var want cell
+	for _, rc := range receipts {
+		if rc.date >= q.From && rc.date <= q.To && rc.retailer == q.Retailer {
+			want.add(&cell{receipts: 1, points: rc.points, items: int64(rc.items), spend: rc.cents})
+		}
+	}
+	report, err := r.Query(q)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if report.Total != want.summary() {
+		t.Errorf("Query() = %+v, want %+v", report.Total, want.summary())
+	}
+}
@@ -0,0 +1,318 @@
+// Package tiers ranks users by the points they earned from receipts over
+// the trailing twelve months. Each tier multiplies the base points of the
+// receipts its members submit.
+//
+// A user's tier is evaluated as each of their receipts is processed and
+// again in a nightly recalculation, which is what moves users down once old
+// receipts leave the window. Every change is recorded as an event.
+package tiers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/suryamp/receipt-processor/ledger"
+	"github.com/suryamp/receipt-processor/logger"
+	"github.com/suryamp/receipt-processor/metrics"
+	"github.com/suryamp/receipt-processor/users"
+)
+
+// Window is how far back qualifying points are counted.
+const Window = 12 // months
+
+// maxChanges bounds the change events kept in memory.
+const maxChanges = 10000
+
+// Tier is a level users reach by earning MinPoints in the window.
+type Tier struct {
+	Name       string  `yaml:"name" json:"name"`
+	MinPoints  int64   `yaml:"minPoints" json:"minPoints"`
+	Multiplier float64 `yaml:"multiplier" json:"multiplier"` // applied to base points; 1.25 adds a quarter
+}
+
+// Ladder is the tiers from lowest to highest. Users below the first tier
+// have none.
+type Ladder []Tier
+
+// DefaultLadder is the Silver, Gold and Platinum ladder.
+func DefaultLadder() Ladder {
+	return Ladder{
+		{Name: "Silver", MinPoints: 1000, Multiplier: 1.1},
+		{Name: "Gold", MinPoints: 5000, Multiplier: 1.25},
+		{Name: "Platinum", MinPoints: 15000, Multiplier: 1.5},
+	}
+}
+
+// Validate reports whether l is a usable ladder.
+func (l Ladder) Validate() error {
+	seen := map[string]bool{}
+	for i, t := range l {
+		if strings.TrimSpace(t.Name) == "" {
+			return fmt.Errorf("tier %d needs a name", i)
+		}
+		if seen[strings.ToLower(t.Name)] {
+			return fmt.Errorf("tier %q is defined twice", t.Name)
+		}
+		seen[strings.ToLower(t.Name)] = true
+		if t.MinPoints < 1 {
+			return fmt.Errorf("tier %q: minPoints must be positive", t.Name)
+		}
+		if i > 0 && t.MinPoints <= l[i-1].MinPoints {
+			return fmt.Errorf("tier %q: minPoints must be above %q's", t.Name, l[i-1].Name)
+		}
+		if t.Multiplier < 1 {
+			return fmt.Errorf("tier %q: multiplier must be at least 1", t.Name)
+		}
+	}
+	return nil
+}
+
+// For returns the highest tier points qualify for.
+func (l Ladder) For(points int64) (Tier, bool) {
+	for i := len(l) - 1; i >= 0; i-- {
+		if points >= l[i].MinPoints {
+			return l[i], true
+		}
+	}
+	return Tier{}, false
+}
+
+// next returns the lowest tier points do not yet qualify for.
+func (l Ladder) next(points int64) (Tier, bool) {
+	for _, t := range l {
+		if points < t.MinPoints {
+			return t, true
+		}
+	}
+	return Tier{}, false
+}
+
+// QualifyingPoints sums the points an account earned from receipts, net of
+// rescores and reversals, in the window before now.
+func QualifyingPoints(account string, history []ledger.Entry, now time.Time) int64 {
+	since := now.AddDate(0, -Window, 0)
+	var points int64
+	for _, e := range history {
+		if e.ReceiptID == "" || !e.Time.After(since) || e.Time.After(now) {
+			continue
+		}
+		for _, p := range e.Postings {
+			if p.Account == account {
+				points += p.Amount
+			}
+		}
+	}
+	return points
+}
+
+// Change is a user moving between tiers. An empty tier is none.
+type Change struct {
+	UserID string    `json:"userId"`
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Points int64     `json:"points"` // qualifying points at the time
+	Cause  string    `json:"cause"`  // "receipt" or "recalculation"
+	At     time.Time `json:"at"`
+}
+
+// Progress is where a user stands on the ladder.
+type Progress struct {
+	UserID           string    `json:"userId"`
+	Tier             string    `json:"tier"`
+	Multiplier       float64   `json:"multiplier"`
+	QualifyingPoints int64     `json:"qualifyingPoints"`
+	NextTier         string    `json:"nextTier,omitempty"`
+	PointsToNext     int64     `json:"pointsToNext,omitempty"`
+	EvaluatedAt      time.Time `json:"evaluatedAt"`
+}
+
+// Tracker keeps each user's tier. It is safe for concurrent use.
+type Tracker struct {
+	mu       sync.Mutex
+	ladder   Ladder
+	current  map[string]string // user ID to tier name
+	changes  []Change          // oldest first
+	onChange []func(Change)
+	users    *users.Store
+	ledger   *ledger.Ledger
+	now      func() time.Time
+}
+
+// NewTracker returns a tracker ranking the users in accounts on l.
+func NewTracker(l Ladder, accounts *users.Store) *Tracker {
+	return &Tracker{ladder: l, current: map[string]string{}, users: accounts, ledger: accounts.Ledger(), now: time.Now}
+}
+
+// WithClock sets the clock the window is measured from.
+func (t *Tracker) WithClock(now func() time.Time) *Tracker {
+	t.now = now
+	return t
+}
+
+// OnChange registers f to be called, in order, with every tier change.
+func (t *Tracker) OnChange(f func(Change)) *Tracker {
+	t.onChange = append(t.onChange, f)
+	return t
+}
+
+// Ladder returns the tiers in use.
+func (t *Tracker) Ladder() Ladder {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append(Ladder{}, t.ladder...)
+}
+
+// SetLadder replaces the tiers. Users move to their new tiers as they are
+// next evaluated.
+func (t *Tracker) SetLadder(l Ladder) error {
+	if err := l.Validate(); err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ladder = append(Ladder{}, l...)
+	return nil
+}
+
+// Evaluate recomputes a user's tier, recording a change if it moved, and
+// returns it. ok is false if the user has no tier.
+func (t *Tracker) Evaluate(userID, cause string) (tier Tier, ok bool) {
+	account := ledger.UserAccount(userID)
+	points := QualifyingPoints(account, t.ledger.History(account), t.now())
+
+	t.mu.Lock()
+	tier, ok = t.ladder.For(points)
+	from := t.current[userID]
+	var change *Change
+	if tier.Name != from {
+		change = &Change{UserID: userID, From: from, To: tier.Name, Points: points, Cause: cause, At: t.now()}
+		if tier.Name == "" {
+			delete(t.current, userID)
+		} else {
+			t.current[userID] = tier.Name
+		}
+		t.changes = append(t.changes, *change)
+		if len(t.changes) > maxChanges {
+			t.changes = t.changes[len(t.changes)-maxChanges:]
+		}
+	}
+	listeners := t.onChange
+	t.mu.Unlock()
+
+	if change != nil {
+		metrics.TierChangesTotal.WithLabelValues(tierLabel(change.To)).Inc()
+		for _, f := range listeners {
+			f(*change)
+		}
+	}
+	return tier, ok
+}
+
+func tierLabel(name string) string {
+	if name == "" {
+		return "none"
+	}
+	return name
+}
+
+// Current returns the tier a user qualifies for now without recording it.
+func (t *Tracker) Current(userID string) (Tier, bool) {
+	account := ledger.UserAccount(userID)
+	points := QualifyingPoints(account, t.ledger.History(account), t.now())
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.ladder.For(points)
+}
+
+// Recalculate evaluates every user and returns how many changed tier.
+func (t *Tracker) Recalculate() int {
+	var changed int
+	for _, id := range t.users.IDs() {
+		t.mu.Lock()
+		before := t.current[id]
+		t.mu.Unlock()
+		if tier, _ := t.Evaluate(id, "recalculation"); tier.Name != before {
+			changed++
+		}
+	}
+	logger.InfoLogger.Printf("Tier recalculation: %d users changed tier", changed)
+	return changed
+}
+
+// Progress returns a user's tier and how far they are from the next one.
+func (t *Tracker) Progress(userID string) (Progress, error) {
+	if _, err := t.users.Get(userID); err != nil {
+		return Progress{}, err
+	}
+	account := ledger.UserAccount(userID)
+	now := t.now()
+	points := QualifyingPoints(account, t.ledger.History(account), now)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p := Progress{UserID: userID, Multiplier: 1, QualifyingPoints: points, EvaluatedAt: now}
+	if tier, ok := t.ladder.For(points); ok {
+		p.Tier = tier.Name
+		p.Multiplier = tier.Multiplier
+	}
+	if next, ok := t.ladder.next(points); ok {
+		p.NextTier = next.Name
+		p.PointsToNext = next.MinPoints - points
+	}
+	return p, nil
+}
+
+// Changes returns up to limit tier changes, newest first, after skipping
+// offset of them, and how many are kept in all.
+func (t *Tracker) Changes(offset, limit int) ([]Change, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	total := len(t.changes)
+	page := []Change{}
+	for i := total - 1 - offset; i >= 0 && len(page) < limit; i-- {
+		page = append(page, t.changes[i])
+	}
+	return page, total
+}
+
+// ErrInvalidTime is returned for a time of day that is not "15:04".
+var ErrInvalidTime = errors.New(`time of day must look like "03:00"`)
+
+// ParseTimeOfDay parses a "15:04" time of day into an offset from midnight.
+func ParseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, ErrInvalidTime
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// nextRun returns the first time after now that is at the given offset from
+// a local midnight.
+func nextRun(now time.Time, at time.Duration) time.Time {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	next := midnight.Add(at)
+	if !next.After(now) {
+		next = time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location()).Add(at)
+	}
+	return next
+}
+
+// Run recalculates every tier daily at the given offset from local
+// midnight until ctx is cancelled.
+func (t *Tracker) Run(ctx context.Context, at time.Duration) {
+	for {
+		timer := time.NewTimer(time.Until(nextRun(t.now(), at)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			t.Recalculate()
+		}
+	}
+}
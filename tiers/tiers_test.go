@@ -0,0 +1,192 @@
+package tiers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/suryamp/receipt-processor/ledger"
+	"github.com/suryamp/receipt-processor/logger"
+	"github.com/suryamp/receipt-processor/users"
+)
+
+func init() {
+	if err := logger.Init(); err != nil {
+		panic(err)
+	}
+}
+
+// clock is a settable time source shared by the ledger and the tracker.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func (c *clock) set(date string) {
+	c.t, _ = time.Parse("2006-01-02", date)
+}
+
+func newTestTracker(c *clock) (*Tracker, *users.Store) {
+	accounts := users.NewStore(ledger.New().WithClock(c.now))
+	return NewTracker(DefaultLadder(), accounts).WithClock(c.now), accounts
+}
+
+func TestLadderValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		ladder  Ladder
+		wantErr bool
+	}{
+		{"default", DefaultLadder(), false},
+		{"empty", Ladder{}, false},
+		{"no name", Ladder{{MinPoints: 10, Multiplier: 1}}, true},
+		{"duplicate", Ladder{{"Gold", 10, 1.1}, {"gold", 20, 1.2}}, true},
+		{"no minimum", Ladder{{"Gold", 0, 1.1}}, true},
+		{"out of order", Ladder{{"Gold", 20, 1.2}, {"Silver", 10, 1.1}}, true},
+		{"multiplier below 1", Ladder{{"Gold", 10, 0.9}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.ladder.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLadderFor(t *testing.T) {
+	l := DefaultLadder()
+	tests := []struct {
+		points   int64
+		wantTier string
+		wantNext string
+	}{
+		{0, "", "Silver"},
+		{999, "", "Silver"},
+		{1000, "Silver", "Gold"},
+		{14999, "Gold", "Platinum"},
+		{15000, "Platinum", ""},
+	}
+
+	for _, tt := range tests {
+		tier, _ := l.For(tt.points)
+		next, _ := l.next(tt.points)
+		if tier.Name != tt.wantTier || next.Name != tt.wantNext {
+			t.Errorf("For(%d) = %q, next %q; want %q, next %q", tt.points, tier.Name, next.Name, tt.wantTier, tt.wantNext)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	c := &clock{}
+	c.set("2024-01-10")
+	tr, accounts := newTestTracker(c)
+	var events []Change
+	tr.OnChange(func(ch Change) { events = append(events, ch) })
+
+	accounts.AddReceipt("alice", users.Receipt{ID: "r1", Points: 800})
+	if tier, ok := tr.Evaluate("alice", "receipt"); ok {
+		t.Errorf("Evaluate() with 800 points = %q, want no tier", tier.Name)
+	}
+	// Adjustments do not count toward a tier
+	accounts.Adjust("alice", 5000, "goodwill")
+	if _, ok := tr.Evaluate("alice", "receipt"); ok {
+		t.Error("Evaluate() counted an adjustment")
+	}
+
+	c.set("2024-06-01")
+	accounts.AddReceipt("alice", users.Receipt{ID: "r2", Points: 4500})
+	if tier, _ := tr.Evaluate("alice", "receipt"); tier.Name != "Gold" {
+		t.Errorf("Evaluate() with 5300 points = %q, want Gold", tier.Name)
+	}
+	p, err := tr.Progress("alice")
+	if err != nil {
+		t.Fatalf("Progress() error = %v", err)
+	}
+	if p.Tier != "Gold" || p.Multiplier != 1.25 || p.QualifyingPoints != 5300 || p.NextTier != "Platinum" || p.PointsToNext != 9700 {
+		t.Errorf("Progress() = %+v, want Gold with 9700 points to Platinum", p)
+	}
+
+	// r1 leaves the window, and only the nightly recalculation notices
+	c.set("2025-01-10")
+	if got := tr.Recalculate(); got != 1 {
+		t.Errorf("Recalculate() changed %d users, want 1", got)
+	}
+	if got := tr.Recalculate(); got != 0 {
+		t.Errorf("second Recalculate() changed %d users, want 0", got)
+	}
+	c.set("2025-06-01")
+	tr.Recalculate()
+
+	want := []Change{
+		{UserID: "alice", From: "", To: "Gold", Points: 5300, Cause: "receipt"},
+		{UserID: "alice", From: "Gold", To: "Silver", Points: 4500, Cause: "recalculation"},
+		{UserID: "alice", From: "Silver", To: "", Points: 0, Cause: "recalculation"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("change events = %+v, want %d", events, len(want))
+	}
+	for i, ch := range events {
+		ch.At = time.Time{}
+		if ch != want[i] {
+			t.Errorf("change %d = %+v, want %+v", i, ch, want[i])
+		}
+	}
+	if page, total := tr.Changes(0, 1); total != 3 || page[0].To != "" {
+		t.Errorf("Changes(0, 1) = %+v of %d, want the downgrade to no tier of 3", page, total)
+	}
+}
+
+func TestSetLadder(t *testing.T) {
+	c := &clock{}
+	c.set("2024-01-10")
+	tr, accounts := newTestTracker(c)
+	accounts.AddReceipt("alice", users.Receipt{ID: "r1", Points: 1200})
+
+	if err := tr.SetLadder(Ladder{{"Gold", 10, 0.5}}); err == nil {
+		t.Error("SetLadder() accepted an invalid ladder")
+	}
+	if err := tr.SetLadder(Ladder{{"Bronze", 100, 1.05}, {"Gold", 1000, 1.3}}); err != nil {
+		t.Fatalf("SetLadder() error = %v", err)
+	}
+	if tier, _ := tr.Current("alice"); tier.Name != "Gold" || tier.Multiplier != 1.3 {
+		t.Errorf("Current() = %+v, want Gold at 1.3", tier)
+	}
+	if _, total := tr.Changes(0, 10); total != 0 {
+		t.Errorf("Current() recorded %d changes, want none", total)
+	}
+}
+
+func TestProgressUnknownUser(t *testing.T) {
+	tr, _ := newTestTracker(&clock{})
+	if _, err := tr.Progress("bob"); !errors.Is(err, users.ErrNotFound) {
+		t.Errorf("Progress() unknown user error = %v, want users.ErrNotFound", err)
+	}
+}
+
+func TestNextRun(t *testing.T) {
+	at, err := ParseTimeOfDay("03:00")
+	if err != nil {
+		t.Fatalf("ParseTimeOfDay() error = %v", err)
+	}
+	tests := []struct {
+		now  string
+		want string
+	}{
+		{"2024-01-10T01:00:00Z", "2024-01-10T03:00:00Z"},
+		{"2024-01-10T03:00:00Z", "2024-01-11T03:00:00Z"},
+		{"2024-12-31T23:59:00Z", "2025-01-01T03:00:00Z"},
+	}
+	for _, tt := range tests {
+		now, _ := time.Parse(time.RFC3339, tt.now)
+		if got := nextRun(now, at).Format(time.RFC3339); got != tt.want {
+			t.Errorf("nextRun(%s) = %s, want %s", tt.now, got, tt.want)
+		}
+	}
+
+	for _, s := range []string{"3am", "25:00", ""} {
+		if _, err := ParseTimeOfDay(s); !errors.Is(err, ErrInvalidTime) {
+			t.Errorf("ParseTimeOfDay(%q) error = %v, want ErrInvalidTime", s, err)
+		}
+	}
+}
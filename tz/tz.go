@@ -0,0 +1,104 @@
+// Package tz turns the wall-clock date and time printed on a receipt into
+// an instant in the store's timezone.
+package tz
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var offsetPattern = regexp.MustCompile(`^([+-])(\d{2}):(\d{2})$`)
+
+var locations sync.Map // name -> *time.Location
+
+// Load returns the IANA location name, caching it since reading the
+// timezone database is comparatively slow.
+func Load(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", name)
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// ParseOffset parses a UTC offset of the form "+hh:mm" or "-hh:mm" into
+// seconds east of UTC.
+func ParseOffset(s string) (int, error) {
+	m := offsetPattern.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("utc offset %q must look like +hh:mm", s)
+	}
+	hours, _ := strconv.Atoi(m[2])
+	minutes, _ := strconv.Atoi(m[3])
+	if hours > 14 || minutes > 59 || hours == 14 && minutes > 0 {
+		return 0, fmt.Errorf("utc offset %q is out of range", s)
+	}
+	seconds := hours*3600 + minutes*60
+	if m[1] == "-" {
+		seconds = -seconds
+	}
+	return seconds, nil
+}
+
+// Local interprets date ("2006-01-02") and clock ("15:04") as wall-clock
+// time in loc. A time skipped by a DST transition is moved forward by the
+// length of the gap, as the store's clock would have shown it. A time that
+// occurs twice resolves to the occurrence whose offset matches offset, or
+// the earlier one when offset is empty; an offset the zone never uses at
+// that time is an error.
+func Local(date, clock string, loc *time.Location, offset string) (time.Time, error) {
+	wall, err := time.Parse("2006-01-02 15:04", date+" "+clock)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid purchase date or time")
+	}
+
+	want, hasOffset := 0, offset != ""
+	if hasOffset {
+		if want, err = ParseOffset(offset); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	// Transitions are at least a day apart, so the offsets in force half a
+	// day either side are the only ones that can apply to this wall time.
+	_, before := wall.Add(-12 * time.Hour).In(loc).Zone()
+	_, after := wall.Add(12 * time.Hour).In(loc).Zone()
+
+	var matches []time.Time
+	for _, off := range []int{before, after} {
+		t := wall.Add(-time.Duration(off) * time.Second).In(loc)
+		if sameWall(t, wall) && (len(matches) == 0 || !t.Equal(matches[0])) {
+			matches = append(matches, t)
+		}
+	}
+
+	if hasOffset {
+		for _, t := range matches {
+			if _, off := t.Zone(); off == want {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("utc offset %s is not used by %s at %s %s", offset, loc, date, clock)
+	}
+	if len(matches) == 0 {
+		// In a gap: the offset from before the transition lands after it.
+		return wall.Add(-time.Duration(before) * time.Second).In(loc), nil
+	}
+	if len(matches) == 2 && matches[1].Before(matches[0]) {
+		return matches[1], nil
+	}
+	return matches[0], nil
+}
+
+func sameWall(t, wall time.Time) bool {
+	y, mo, d := t.Date()
+	wy, wmo, wd := wall.Date()
+	return y == wy && mo == wmo && d == wd && t.Hour() == wall.Hour() && t.Minute() == wall.Minute()
+}
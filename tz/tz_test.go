@@ -0,0 +1,74 @@
+package tz
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLocal(t *testing.T) {
+	newYork, err := Load("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		date    string
+		clock   string
+		loc     *time.Location
+		offset  string
+		want    string // RFC 3339
+		wantErr bool
+	}{
+		{"plain time in utc", "2024-06-01", "14:30", time.UTC, "", "2024-06-01T14:30:00Z", false},
+		{"summer time", "2024-06-01", "14:30", newYork, "", "2024-06-01T14:30:00-04:00", false},
+		{"winter time", "2024-01-15", "14:30", newYork, "", "2024-01-15T14:30:00-05:00", false},
+		{"spring forward gap moves ahead", "2024-03-10", "02:30", newYork, "", "2024-03-10T03:30:00-04:00", false},
+		{"fall back picks earlier", "2024-11-03", "01:30", newYork, "", "2024-11-03T01:30:00-04:00", false},
+		{"fall back with later offset", "2024-11-03", "01:30", newYork, "-05:00", "2024-11-03T01:30:00-05:00", false},
+		{"offset matching the zone", "2024-06-01", "14:30", newYork, "-04:00", "2024-06-01T14:30:00-04:00", false},
+		{"fixed offset zone", "2024-06-01", "23:30", time.FixedZone("", 5*3600+1800), "+05:30", "2024-06-01T23:30:00+05:30", false},
+		{"offset the zone does not use", "2024-06-01", "14:30", newYork, "-05:00", "", true},
+		{"offset inside the gap", "2024-03-10", "02:30", newYork, "-05:00", "", true},
+		{"malformed offset", "2024-06-01", "14:30", time.UTC, "0500", "", true},
+		{"malformed date", "2024-13-01", "14:30", time.UTC, "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Local(tt.date, tt.clock, tt.loc, tt.offset)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Local() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.Format(time.RFC3339) != tt.want {
+				t.Errorf("Local() = %s, want %s", got.Format(time.RFC3339), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOffset(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{"+00:00", 0, false},
+		{"-05:00", -5 * 3600, false},
+		{"+05:45", 5*3600 + 45*60, false},
+		{"+14:00", 14 * 3600, false},
+		{"+14:30", 0, true},
+		{"+03:60", 0, true},
+		{"Z", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseOffset(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseOffset(%q) = %d, %v; want %d, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+	if _, err := Load("Mars/Olympus_Mons"); err == nil {
+		t.Error("Load() of an unknown zone succeeded")
+	}
+}
@@ -0,0 +1,201 @@
+// Package users keeps the people receipts are submitted for and their
+// receipt history. Their points live in a ledger: every credit and
+// correction is a ledger entry, and balances are read from the ledger
+// rather than kept alongside the history.
+package users
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/suryamp/receipt-processor/ledger"
+)
+
+// ErrNotFound is returned for a user with no receipts.
+var ErrNotFound = errors.New("user not found")
+
+// User is a user's running totals.
+type User struct {
+	ID            string    `json:"id"`
+	Balance       int64     `json:"balance"`
+	Receipts      int       `json:"receipts"`
+	CreatedAt     time.Time `json:"createdAt"`
+	LastReceiptAt time.Time `json:"lastReceiptAt"`
+}
+
+// Receipt is an entry in a user's receipt history. Points is the net the
+// ledger has credited the user for it.
+type Receipt struct {
+	ID           string    `json:"id"`
+	Retailer     string    `json:"retailer"`
+	PurchaseDate string    `json:"purchaseDate"`
+	Total        string    `json:"total"`
+	Points       int64     `json:"points"`
+	SubmittedAt  time.Time `json:"submittedAt"`
+}
+
+type account struct {
+	user     User
+	receipts []Receipt // in submission order
+}
+
+// Store keeps users in memory. A user is created by their first receipt.
+type Store struct {
+	mu     sync.RWMutex
+	users  map[string]*account
+	ledger *ledger.Ledger
+	now    func() time.Time
+}
+
+// NewStore returns a store that records points in l.
+func NewStore(l *ledger.Ledger) *Store {
+	return &Store{users: map[string]*account{}, ledger: l, now: time.Now}
+}
+
+// IDs returns every user's ID in order.
+func (s *Store) IDs() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := make([]string, 0, len(s.users))
+	for id := range s.users {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// PurchaseDates returns the purchase date of each of a user's receipts by
+// receipt ID.
+func (s *Store) PurchaseDates(id string) (map[string]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	a, ok := s.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	dates := make(map[string]string, len(a.receipts))
+	for _, r := range a.receipts {
+		dates[r.ID] = r.PurchaseDate
+	}
+	return dates, nil
+}
+
+// Ledger returns the ledger the store records points in.
+func (s *Store) Ledger() *ledger.Ledger {
+	return s.ledger
+}
+
+// AddReceipt records a receipt for userID and credits them its points.
+func (s *Store) AddReceipt(userID string, r Receipt) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.Points != 0 {
+		credit := ledger.Transfer(ledger.ReasonReceipt, ledger.AccountIssued, ledger.UserAccount(userID), r.Points)
+		credit.ReceiptID = r.ID
+		if _, err := s.ledger.Post(credit); err != nil {
+			return User{}, fmt.Errorf("crediting receipt %s: %w", r.ID, err)
+		}
+	}
+
+	now := s.now()
+	a, ok := s.users[userID]
+	if !ok {
+		a = &account{user: User{ID: userID, CreatedAt: now}}
+		s.users[userID] = a
+	}
+	r.Points = 0 // read back from the ledger
+	a.receipts = append(a.receipts, r)
+	a.user.Receipts++
+	a.user.LastReceiptAt = now
+	return s.withBalance(a.user), nil
+}
+
+// RescoreReceipt credits or debits a user the change in a receipt's points
+// from oldPoints to newPoints. A receipt whose credit an admin reversed
+// stays reversed: nothing is posted for it.
+func (s *Store) RescoreReceipt(userID, receiptID string, oldPoints, newPoints int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[userID]; !ok {
+		return ErrNotFound
+	}
+	account := ledger.UserAccount(userID)
+	delta := newPoints - oldPoints
+	if delta == 0 || s.ledger.ReceiptReversed(account, receiptID) {
+		return nil
+	}
+	entry := ledger.Transfer(ledger.ReasonRescore, ledger.AccountIssued, account, delta)
+	entry.ReceiptID = receiptID
+	_, err := s.ledger.Post(entry)
+	return err
+}
+
+// Adjust posts a manual correction, which may be negative, to a user's
+// points. A negative correction is refused with ledger.ErrInsufficientPoints
+// if the user cannot cover it.
+func (s *Store) Adjust(userID string, points int64, memo string) (ledger.Entry, error) {
+	s.mu.RLock()
+	_, ok := s.users[userID]
+	s.mu.RUnlock()
+	if !ok {
+		return ledger.Entry{}, ErrNotFound
+	}
+	entry := ledger.Transfer(ledger.ReasonAdjustment, ledger.AccountAdjustments, ledger.UserAccount(userID), points)
+	entry.Memo = memo
+	if points < 0 {
+		return s.ledger.PostCovered(entry)
+	}
+	return s.ledger.Post(entry)
+}
+
+// Get returns a user's totals.
+func (s *Store) Get(id string) (User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	a, ok := s.users[id]
+	if !ok {
+		return User{}, ErrNotFound
+	}
+	return s.withBalance(a.user), nil
+}
+
+func (s *Store) withBalance(u User) User {
+	u.Balance = s.ledger.Balance(ledger.UserAccount(u.ID))
+	return u
+}
+
+// Receipts returns up to limit of a user's receipts, newest first, after
+// skipping offset of them, and how many receipts the user has in all.
+func (s *Store) Receipts(id string, offset, limit int) ([]Receipt, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	a, ok := s.users[id]
+	if !ok {
+		return nil, 0, ErrNotFound
+	}
+	account := ledger.UserAccount(id)
+	total := len(a.receipts)
+	page := []Receipt{}
+	for i := total - 1 - offset; i >= 0 && len(page) < limit; i-- {
+		r := a.receipts[i]
+		r.Points = s.ledger.ReceiptPoints(account, r.ID)
+		page = append(page, r)
+	}
+	return page, total, nil
+}
+
+// Entries returns up to limit of the ledger entries for a user, newest
+// first, after skipping offset of them, and how many there are in all.
+func (s *Store) Entries(id string, offset, limit int) ([]ledger.Entry, int, error) {
+	s.mu.RLock()
+	_, ok := s.users[id]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, 0, ErrNotFound
+	}
+	entries, total := s.ledger.Entries(ledger.UserAccount(id), offset, limit)
+	return entries, total, nil
+}
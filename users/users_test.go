@@ -0,0 +1,103 @@
+package users
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/suryamp/receipt-processor/ledger"
+)
+
+func TestStore(t *testing.T) {
+	l := ledger.New()
+	s := NewStore(l)
+	if _, err := s.Get("alice"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() unknown user error = %v, want ErrNotFound", err)
+	}
+
+	s.AddReceipt("alice", Receipt{ID: "r1", Points: 10})
+	s.AddReceipt("alice", Receipt{ID: "r2", Points: 25})
+	s.AddReceipt("alice", Receipt{ID: "r3", Points: 5})
+	s.AddReceipt("bob", Receipt{ID: "r4", Points: 7})
+
+	u, err := s.Get("alice")
+	if err != nil || u.Balance != 40 || u.Receipts != 3 {
+		t.Fatalf("Get() = %+v, %v, want balance 40 from 3 receipts", u, err)
+	}
+	if err := s.RescoreReceipt("alice", "r2", 25, 30); err != nil {
+		t.Fatalf("RescoreReceipt() error = %v", err)
+	}
+	if u, _ := s.Get("alice"); u.Balance != 45 {
+		t.Errorf("balance after RescoreReceipt() = %d, want 45", u.Balance)
+	}
+	if err := s.RescoreReceipt("carol", "r2", 0, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RescoreReceipt() unknown user error = %v, want ErrNotFound", err)
+	}
+
+	tests := []struct {
+		offset, limit int
+		want          []string
+	}{
+		{0, 50, []string{"r3", "r2", "r1"}},
+		{1, 1, []string{"r2"}},
+		{2, 5, []string{"r1"}},
+		{3, 5, nil},
+	}
+	for _, tt := range tests {
+		page, total, err := s.Receipts("alice", tt.offset, tt.limit)
+		if err != nil || total != 3 || len(page) != len(tt.want) {
+			t.Errorf("Receipts(%d, %d) = %v, %d, %v, want %v of 3", tt.offset, tt.limit, page, total, err, tt.want)
+			continue
+		}
+		for i, r := range page {
+			if r.ID != tt.want[i] {
+				t.Errorf("Receipts(%d, %d)[%d] = %s, want %s", tt.offset, tt.limit, i, r.ID, tt.want[i])
+			}
+		}
+	}
+	if page, _, _ := s.Receipts("alice", 1, 1); page[0].Points != 30 {
+		t.Errorf("Receipts() points = %d, want the rescored 30", page[0].Points)
+	}
+}
+
+func TestStoreLedger(t *testing.T) {
+	l := ledger.New()
+	s := NewStore(l)
+	if _, err := s.Adjust("alice", 5, "goodwill"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Adjust() unknown user error = %v, want ErrNotFound", err)
+	}
+
+	s.AddReceipt("alice", Receipt{ID: "r1", Points: 10})
+	s.AddReceipt("alice", Receipt{ID: "r2"}) // no points, so no entry
+	adjustment, err := s.Adjust("alice", -3, "duplicate receipt")
+	if err != nil {
+		t.Fatalf("Adjust() error = %v", err)
+	}
+	if u, _ := s.Get("alice"); u.Balance != 7 {
+		t.Errorf("balance after adjustment = %d, want 7", u.Balance)
+	}
+	if _, err := s.Adjust("alice", -8, "overdraft"); !errors.Is(err, ledger.ErrInsufficientPoints) {
+		t.Errorf("Adjust() past the balance error = %v, want ErrInsufficientPoints", err)
+	}
+
+	// Reversals in the ledger show up in the balance
+	if _, err := l.Reverse(adjustment.ID, "adjusted in error"); err != nil {
+		t.Fatalf("Reverse() error = %v", err)
+	}
+	if u, _ := s.Get("alice"); u.Balance != 10 {
+		t.Errorf("balance after reversal = %d, want 10", u.Balance)
+	}
+
+	entries, total, err := s.Entries("alice", 0, 10)
+	if err != nil || total != 3 {
+		t.Fatalf("Entries() = %d entries, %v, want 3", total, err)
+	}
+	want := []ledger.Reason{ledger.ReasonReversal, ledger.ReasonAdjustment, ledger.ReasonReceipt}
+	for i, e := range entries {
+		if e.Reason != want[i] {
+			t.Errorf("Entries()[%d].Reason = %s, want %s", i, e.Reason, want[i])
+		}
+	}
+	if _, _, err := s.Entries("bob", 0, 10); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Entries() unknown user error = %v, want ErrNotFound", err)
+	}
+}
@@ -1,21 +1,54 @@
 package validator
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
 	"time"
 
 	"github.com/suryamp/receipt-processor/models"
+	"github.com/suryamp/receipt-processor/tz"
 )
 
-// Modified regex from api.yml
+// Modified regex from api.yml. Retailer names and item descriptions may
+// carry the punctuation retailers.Normalize and items.Normalize handle, as
+// in "WALGREENS #123", "1/2 Gal Milk" or "Bagel w/ Cream Cheese".
 var (
-	retailerPattern = regexp.MustCompile(`^[\w\s\-&]+$`)
+	retailerPattern = regexp.MustCompile(`^[\w\s\-&#.']+$`)
 	pricePattern    = regexp.MustCompile(`^\d+\.\d{2}$`)
-	descPattern     = regexp.MustCompile(`^[\w\s\-]+$`)
+	descPattern     = regexp.MustCompile(`^[\w\s\-/.,;:!?"'()]+$`)
+	userIDPattern   = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._@-]{0,63}$`)
 )
 
+// ErrTooManyItems is returned by ValidateLimits when a receipt has more items than allowed.
+var ErrTooManyItems = errors.New("too many items")
+
+// ValidateLimits checks the receipt against size limits. A zero limit is not enforced.
+func ValidateLimits(r models.Receipt, maxItems, maxStringLength int) error {
+	if maxItems > 0 && len(r.Items) > maxItems {
+		return fmt.Errorf("%w: %d exceeds the limit of %d", ErrTooManyItems, len(r.Items), maxItems)
+	}
+
+	if maxStringLength <= 0 {
+		return nil
+	}
+	fields := []string{r.UserID, r.Retailer, r.PurchaseDate, r.PurchaseTime, r.Timezone, r.UTCOffset, r.Total}
+	for _, item := range r.Items {
+		fields = append(fields, item.ShortDescription, item.Price)
+	}
+	for _, f := range fields {
+		if len(f) > maxStringLength {
+			return fmt.Errorf("field longer than %d characters", maxStringLength)
+		}
+	}
+	return nil
+}
+
 func ValidateReceipt(r models.Receipt) error {
+	if r.UserID != "" && !userIDPattern.MatchString(r.UserID) {
+		return fmt.Errorf("invalid user id format")
+	}
+
 	if !retailerPattern.MatchString(r.Retailer) {
 		return fmt.Errorf("invalid retailer format")
 	}
@@ -28,6 +61,10 @@ func ValidateReceipt(r models.Receipt) error {
 		return fmt.Errorf("invalid time format")
 	}
 
+	if err := validateTimezone(r); err != nil {
+		return err
+	}
+
 	if !pricePattern.MatchString(r.Total) {
 		return fmt.Errorf("invalid total format")
 	}
@@ -47,3 +84,29 @@ func ValidateReceipt(r models.Receipt) error {
 
 	return nil
 }
+
+// validateTimezone checks the optional timezone and UTC offset, and that
+// they agree with each other at the time of purchase.
+func validateTimezone(r models.Receipt) error {
+	loc := time.UTC
+	if r.Timezone != "" {
+		var err error
+		if loc, err = tz.Load(r.Timezone); err != nil {
+			return fmt.Errorf("invalid timezone")
+		}
+	}
+	if r.UTCOffset == "" {
+		return nil
+	}
+	offset, err := tz.ParseOffset(r.UTCOffset)
+	if err != nil {
+		return fmt.Errorf("invalid utc offset format")
+	}
+	if r.Timezone == "" {
+		loc = time.FixedZone("", offset)
+	}
+	if _, err := tz.Local(r.PurchaseDate, r.PurchaseTime, loc, r.UTCOffset); err != nil {
+		return fmt.Errorf("utc offset does not match timezone")
+	}
+	return nil
+}
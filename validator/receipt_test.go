@@ -1,6 +1,8 @@
 package validator
 
 import (
+	"errors"
+	"strings"
 	"testing"
 
 	"github.com/suryamp/receipt-processor/models"
@@ -26,6 +28,19 @@ func TestValidateReceipt(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "retailer with branch number",
+			receipt: models.Receipt{
+				Retailer:     "WALGREENS #123",
+				PurchaseDate: "2024-01-01",
+				PurchaseTime: "13:01",
+				Total:        "35.35",
+				Items: []models.Item{
+					{ShortDescription: "Mountain Dew", Price: "1.25"},
+				},
+			},
+			wantErr: false,
+		},
 		{
 			name: "invalid retailer with special chars",
 			receipt: models.Receipt{
@@ -40,6 +55,35 @@ func TestValidateReceipt(t *testing.T) {
 			wantErr: true,
 			errMsg:  "invalid retailer format",
 		},
+		{
+			name: "valid user id",
+			receipt: models.Receipt{
+				UserID:       "user-42@example.com",
+				Retailer:     "Target",
+				PurchaseDate: "2024-01-01",
+				PurchaseTime: "13:01",
+				Total:        "35.35",
+				Items: []models.Item{
+					{ShortDescription: "Mountain Dew", Price: "1.25"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid user id",
+			receipt: models.Receipt{
+				UserID:       "user 42",
+				Retailer:     "Target",
+				PurchaseDate: "2024-01-01",
+				PurchaseTime: "13:01",
+				Total:        "35.35",
+				Items: []models.Item{
+					{ShortDescription: "Mountain Dew", Price: "1.25"},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid user id format",
+		},
 		{
 			name: "invalid date format",
 			receipt: models.Receipt{
@@ -94,6 +138,19 @@ func TestValidateReceipt(t *testing.T) {
 			wantErr: true,
 			errMsg:  "at least one item required",
 		},
+		{
+			name: "item description with abbreviations",
+			receipt: models.Receipt{
+				Retailer:     "Target",
+				PurchaseDate: "2024-01-01",
+				PurchaseTime: "13:01",
+				Total:        "35.35",
+				Items: []models.Item{
+					{ShortDescription: "1/2 Gal. Milk w/ Vit. D", Price: "1.25"},
+				},
+			},
+			wantErr: false,
+		},
 		{
 			name: "invalid item description",
 			receipt: models.Receipt{
@@ -122,6 +179,67 @@ func TestValidateReceipt(t *testing.T) {
 			wantErr: true,
 			errMsg:  "invalid item price format",
 		},
+		{
+			name: "valid timezone and offset",
+			receipt: models.Receipt{
+				Retailer:     "Target",
+				PurchaseDate: "2024-01-01",
+				PurchaseTime: "13:01",
+				Timezone:     "America/New_York",
+				UTCOffset:    "-05:00",
+				Total:        "35.35",
+				Items: []models.Item{
+					{ShortDescription: "Mountain Dew", Price: "1.25"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown timezone",
+			receipt: models.Receipt{
+				Retailer:     "Target",
+				PurchaseDate: "2024-01-01",
+				PurchaseTime: "13:01",
+				Timezone:     "America/Atlantis",
+				Total:        "35.35",
+				Items: []models.Item{
+					{ShortDescription: "Mountain Dew", Price: "1.25"},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid timezone",
+		},
+		{
+			name: "malformed utc offset",
+			receipt: models.Receipt{
+				Retailer:     "Target",
+				PurchaseDate: "2024-01-01",
+				PurchaseTime: "13:01",
+				UTCOffset:    "UTC-5",
+				Total:        "35.35",
+				Items: []models.Item{
+					{ShortDescription: "Mountain Dew", Price: "1.25"},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid utc offset format",
+		},
+		{
+			name: "utc offset not used by timezone",
+			receipt: models.Receipt{
+				Retailer:     "Target",
+				PurchaseDate: "2024-01-01",
+				PurchaseTime: "13:01",
+				Timezone:     "America/New_York",
+				UTCOffset:    "-04:00",
+				Total:        "35.35",
+				Items: []models.Item{
+					{ShortDescription: "Mountain Dew", Price: "1.25"},
+				},
+			},
+			wantErr: true,
+			errMsg:  "utc offset does not match timezone",
+		},
 	}
 
 	for _, tt := range tests {
@@ -137,3 +255,41 @@ func TestValidateReceipt(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateLimits(t *testing.T) {
+	receipt := models.Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2024-01-01",
+		PurchaseTime: "13:01",
+		Total:        "2.50",
+		Items: []models.Item{
+			{ShortDescription: "Mountain Dew", Price: "1.25"},
+			{ShortDescription: strings.Repeat("a", 30), Price: "1.25"},
+		},
+	}
+
+	tests := []struct {
+		name            string
+		maxItems        int
+		maxStringLength int
+		wantErr         bool
+		wantTooMany     bool
+	}{
+		{name: "within limits", maxItems: 2, maxStringLength: 30},
+		{name: "limits disabled", maxItems: 0, maxStringLength: 0},
+		{name: "too many items", maxItems: 1, maxStringLength: 30, wantErr: true, wantTooMany: true},
+		{name: "description too long", maxItems: 2, maxStringLength: 29, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateLimits(receipt, tt.maxItems, tt.maxStringLength)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateLimits() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := errors.Is(err, ErrTooManyItems); got != tt.wantTooMany {
+				t.Errorf("ValidateLimits() ErrTooManyItems = %v, want %v", got, tt.wantTooMany)
+			}
+		})
+	}
+}